@@ -0,0 +1,262 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package signtool
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+// RepairOptions specifies the input for authoring a repair assertion.
+type RepairOptions struct {
+	// KeyID specifies the key id of the key to use
+	KeyID string
+
+	// BrandID is the brand issuing the repair, it is also used
+	// as authority
+	BrandID string
+	// RepairID optionally specifies an already issued repair of
+	// which a new revision should be signed, otherwise the next
+	// free repair-id for the brand is assigned
+	RepairID string
+
+	// Summary is a short description of the repair
+	Summary string
+
+	// Series, Architectures and Models restrict the devices the
+	// repair applies to, empty means no restriction
+	Series        []string
+	Architectures []string
+	Models        []string
+
+	// Disabled marks the repair as disabled
+	Disabled bool
+
+	// Script is the repair script, used as body of the assertion
+	Script []byte
+}
+
+// RepairStore keeps track of the repair assertions issued so far.
+type RepairStore struct {
+	bs asserts.Backstore
+}
+
+// OpenRepairStore opens the store of issued repairs at path,
+// creating it if necessary.
+func OpenRepairStore(path string) (*RepairStore, error) {
+	bs, err := asserts.OpenFSBackstore(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RepairStore{bs: bs}, nil
+}
+
+// Latest returns the latest issued revision of the given repair.
+// It returns asserts.ErrNotFound if the repair was never issued.
+func (rs *RepairStore) Latest(brandID, repairID string) (*asserts.Repair, error) {
+	a, err := rs.bs.Get(asserts.RepairType, []string{brandID, repairID}, asserts.RepairType.MaxSupportedFormat())
+	if err != nil {
+		return nil, err
+	}
+	return a.(*asserts.Repair), nil
+}
+
+// NextRepairID returns the first repair-id not yet used by the brand.
+func (rs *RepairStore) NextRepairID(brandID string) (string, error) {
+	max := 0
+	err := rs.bs.Search(asserts.RepairType, map[string]string{
+		"brand-id": brandID,
+	}, func(a asserts.Assertion) {
+		id, err := strconv.Atoi(a.(*asserts.Repair).RepairID())
+		if err == nil && id > max {
+			max = id
+		}
+	}, asserts.RepairType.MaxSupportedFormat())
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(max + 1), nil
+}
+
+// Put records a newly issued repair.
+func (rs *RepairStore) Put(repair *asserts.Repair) error {
+	return rs.bs.Put(asserts.RepairType, repair)
+}
+
+func stringList(l []string) []interface{} {
+	res := make([]interface{}, len(l))
+	for i, s := range l {
+		res[i] = s
+	}
+	return res
+}
+
+// SignRepair produces a signed repair assertion as specified by opts,
+// assigning its repair-id and revision based on the repairs already
+// recorded in store. The new repair is recorded in store as well.
+func SignRepair(opts *RepairOptions, store *RepairStore, keypairMgr asserts.KeypairManager) (*asserts.Repair, error) {
+	if opts.BrandID == "" {
+		return nil, fmt.Errorf("cannot sign repair without a brand")
+	}
+	if len(opts.Script) == 0 {
+		return nil, fmt.Errorf("cannot sign repair without a script")
+	}
+
+	repairID := opts.RepairID
+	revision := 0
+	if repairID == "" {
+		var err error
+		repairID, err = store.NextRepairID(opts.BrandID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		latest, err := store.Latest(opts.BrandID, repairID)
+		if err == asserts.ErrNotFound {
+			return nil, fmt.Errorf("cannot find repair %s-%s to revise", opts.BrandID, repairID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		revision = latest.Revision() + 1
+	}
+
+	headers := map[string]interface{}{
+		"authority-id": opts.BrandID,
+		"brand-id":     opts.BrandID,
+		"repair-id":    repairID,
+		"timestamp":    time.Now().UTC().Format(time.RFC3339),
+	}
+	if revision > 0 {
+		headers["revision"] = strconv.Itoa(revision)
+	}
+	if opts.Summary != "" {
+		headers["summary"] = opts.Summary
+	}
+	if len(opts.Series) != 0 {
+		headers["series"] = stringList(opts.Series)
+	}
+	if len(opts.Architectures) != 0 {
+		headers["architectures"] = stringList(opts.Architectures)
+	}
+	if len(opts.Models) != 0 {
+		headers["models"] = stringList(opts.Models)
+	}
+	if opts.Disabled {
+		headers["disabled"] = "true"
+	}
+
+	adb, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		KeypairManager: keypairMgr,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := adb.Sign(asserts.RepairType, headers, opts.Script, opts.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	repair := a.(*asserts.Repair)
+
+	if err := store.Put(repair); err != nil {
+		return nil, fmt.Errorf("cannot record repair %s-%s: %v", opts.BrandID, repairID, err)
+	}
+
+	return repair, nil
+}
+
+// VerifyRepair decodes the given repair assertion and verifies its
+// signature against the public key in accKey, the account-key
+// assertion of the key it was signed with. No private key is needed.
+func VerifyRepair(encoded []byte, accKey *asserts.AccountKey) (*asserts.Repair, error) {
+	a, err := asserts.Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode repair: %v", err)
+	}
+	repair, ok := a.(*asserts.Repair)
+	if !ok {
+		return nil, fmt.Errorf("cannot verify repair: assertion is a %q", a.Type().Name)
+	}
+
+	if accKey.AccountID() != repair.AuthorityID() {
+		return nil, fmt.Errorf("cannot verify repair: account-key is for %q, not for the repair authority %q", accKey.AccountID(), repair.AuthorityID())
+	}
+	if accKey.PublicKeyID() != repair.SignKeyID() {
+		return nil, fmt.Errorf("cannot verify repair: signed with key %s, not with the account-key %s", repair.SignKeyID(), accKey.PublicKeyID())
+	}
+	if err := asserts.CheckSignature(repair, accKey, nil, time.Time{}); err != nil {
+		return nil, fmt.Errorf("cannot verify repair: %v", err)
+	}
+	if err := asserts.CheckTimestampVsSigningKeyValidity(repair, accKey, nil, time.Time{}); err != nil {
+		return nil, fmt.Errorf("cannot verify repair: %v", err)
+	}
+
+	return repair, nil
+}
+
+// FormatRepair returns a human readable representation of the repair,
+// listing its headers followed by its script.
+func FormatRepair(repair *asserts.Repair) []byte {
+	buf := bytes.NewBuffer(nil)
+
+	fmt.Fprintf(buf, "repair: %s-%s\n", repair.BrandID(), repair.RepairID())
+	fmt.Fprintf(buf, "revision: %d\n", repair.Revision())
+	if summary := repair.HeaderString("summary"); summary != "" {
+		fmt.Fprintf(buf, "summary: %s\n", summary)
+	}
+	fmt.Fprintf(buf, "timestamp: %s\n", repair.Timestamp().Format(time.RFC3339))
+	fmt.Fprintf(buf, "disabled: %t\n", repair.Disabled())
+	for _, l := range []struct {
+		name string
+		vals []string
+	}{
+		{"series", repair.Series()},
+		{"architectures", repair.Architectures()},
+		{"models", repair.Models()},
+	} {
+		if len(l.vals) == 0 {
+			fmt.Fprintf(buf, "%s: all\n", l.name)
+			continue
+		}
+		vals := append([]string(nil), l.vals...)
+		sort.Strings(vals)
+		fmt.Fprintf(buf, "%s: %s\n", l.name, strings.Join(vals, ", "))
+	}
+	fmt.Fprintf(buf, "sign-key-sha3-384: %s\n", repair.SignKeyID())
+	fmt.Fprintf(buf, "script: |\n")
+	for _, line := range strings.SplitAfter(string(repair.Body()), "\n") {
+		if line == "" {
+			continue
+		}
+		fmt.Fprintf(buf, "  %s", line)
+	}
+	if body := repair.Body(); len(body) > 0 && body[len(body)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
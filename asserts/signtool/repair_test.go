@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package signtool_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/signtool"
+)
+
+type repairSuite struct {
+	keypairMgr asserts.KeypairManager
+	testKeyID  string
+
+	storeSigning *assertstest.StoreStack
+	brandAcct    *asserts.Account
+	brandAcctKey *asserts.AccountKey
+
+	store *signtool.RepairStore
+}
+
+var _ = Suite(&repairSuite{})
+
+const repairScript = "#!/bin/sh\necho fixing\n"
+
+func (s *repairSuite) SetUpSuite(c *C) {
+	testKey, _ := assertstest.GenerateKey(752)
+
+	s.keypairMgr = asserts.NewMemoryKeypairManager()
+	s.keypairMgr.Put(testKey)
+	s.testKeyID = testKey.PublicKey().ID()
+
+	rootPrivKey, _ := assertstest.GenerateKey(1024)
+	storePrivKey, _ := assertstest.GenerateKey(752)
+	s.storeSigning = assertstest.NewStoreStack("can0nical", rootPrivKey, storePrivKey)
+	s.brandAcct = assertstest.NewAccount(s.storeSigning, "my-brand", map[string]interface{}{
+		"account-id": "my-brand",
+	}, "")
+	s.brandAcctKey = assertstest.NewAccountKey(s.storeSigning, s.brandAcct, map[string]interface{}{
+		"since": "2017-01-01T00:00:00Z",
+	}, testKey.PublicKey(), "")
+}
+
+func (s *repairSuite) SetUpTest(c *C) {
+	store, err := signtool.OpenRepairStore(filepath.Join(c.MkDir(), "repairs"))
+	c.Assert(err, IsNil)
+	s.store = store
+}
+
+func (s *repairSuite) TestSignRepairAssignsRepairIDs(c *C) {
+	opts := signtool.RepairOptions{
+		KeyID:         s.testKeyID,
+		BrandID:       "my-brand",
+		Summary:       "fix the thing",
+		Series:        []string{"16"},
+		Architectures: []string{"amd64", "armhf"},
+		Models:        []string{"my-brand/my-model"},
+		Script:        []byte(repairScript),
+	}
+
+	r, err := signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+	c.Check(r.BrandID(), Equals, "my-brand")
+	c.Check(r.AuthorityID(), Equals, "my-brand")
+	c.Check(r.RepairID(), Equals, "1")
+	c.Check(r.Revision(), Equals, 0)
+	c.Check(r.HeaderString("summary"), Equals, "fix the thing")
+	c.Check(r.Series(), DeepEquals, []string{"16"})
+	c.Check(r.Architectures(), DeepEquals, []string{"amd64", "armhf"})
+	c.Check(r.Models(), DeepEquals, []string{"my-brand/my-model"})
+	c.Check(r.Disabled(), Equals, false)
+	c.Check(string(r.Body()), Equals, repairScript)
+
+	r, err = signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+	c.Check(r.RepairID(), Equals, "2")
+
+	// other brands have their own sequence
+	opts.BrandID = "other-brand"
+	r, err = signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+	c.Check(r.RepairID(), Equals, "1")
+}
+
+func (s *repairSuite) TestSignRepairNewRevision(c *C) {
+	opts := signtool.RepairOptions{
+		KeyID:   s.testKeyID,
+		BrandID: "my-brand",
+		Script:  []byte(repairScript),
+	}
+	_, err := signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+
+	opts.RepairID = "1"
+	opts.Disabled = true
+	r, err := signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+	c.Check(r.RepairID(), Equals, "1")
+	c.Check(r.Revision(), Equals, 1)
+	c.Check(r.Disabled(), Equals, true)
+
+	latest, err := s.store.Latest("my-brand", "1")
+	c.Assert(err, IsNil)
+	c.Check(latest.Revision(), Equals, 1)
+
+	next, err := s.store.NextRepairID("my-brand")
+	c.Assert(err, IsNil)
+	c.Check(next, Equals, "2")
+}
+
+func (s *repairSuite) TestSignRepairErrors(c *C) {
+	for _, t := range []struct {
+		opts     signtool.RepairOptions
+		expected string
+	}{
+		{signtool.RepairOptions{Script: []byte(repairScript)}, "cannot sign repair without a brand"},
+		{signtool.RepairOptions{BrandID: "my-brand"}, "cannot sign repair without a script"},
+		{signtool.RepairOptions{BrandID: "my-brand", RepairID: "3", Script: []byte(repairScript)}, "cannot find repair my-brand-3 to revise"},
+	} {
+		opts := t.opts
+		opts.KeyID = s.testKeyID
+		_, err := signtool.SignRepair(&opts, s.store, s.keypairMgr)
+		c.Check(err, ErrorMatches, t.expected)
+	}
+}
+
+func (s *repairSuite) TestVerifyAndFormatRepair(c *C) {
+	opts := signtool.RepairOptions{
+		KeyID:   s.testKeyID,
+		BrandID: "my-brand",
+		Summary: "fix the thing",
+		Series:  []string{"16"},
+		Models:  []string{"my-brand/other-model", "my-brand/my-model"},
+		Script:  []byte(repairScript),
+	}
+	r, err := signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+
+	// only the public key in the account-key is needed
+	verified, err := signtool.VerifyRepair(asserts.Encode(r), s.brandAcctKey)
+	c.Assert(err, IsNil)
+	c.Check(verified.RepairID(), Equals, "1")
+
+	c.Check(string(signtool.FormatRepair(verified)), Equals, `repair: my-brand-1
+revision: 0
+summary: fix the thing
+timestamp: `+r.Timestamp().Format("2006-01-02T15:04:05Z07:00")+`
+disabled: false
+series: 16
+architectures: all
+models: my-brand/my-model, my-brand/other-model
+sign-key-sha3-384: `+s.testKeyID+`
+script: |
+  #!/bin/sh
+  echo fixing
+`)
+}
+
+func (s *repairSuite) TestVerifyRepairErrors(c *C) {
+	otherKey, _ := assertstest.GenerateKey(752)
+	otherAcctKey := assertstest.NewAccountKey(s.storeSigning, s.brandAcct, nil, otherKey.PublicKey(), "")
+	otherAcct := assertstest.NewAccount(s.storeSigning, "other-brand", map[string]interface{}{
+		"account-id": "other-brand",
+	}, "")
+	otherBrandAcctKey := assertstest.NewAccountKey(s.storeSigning, otherAcct, nil, otherKey.PublicKey(), "")
+
+	opts := signtool.RepairOptions{
+		KeyID:   s.testKeyID,
+		BrandID: "my-brand",
+		Script:  []byte(repairScript),
+	}
+	r, err := signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+
+	_, err = signtool.VerifyRepair(asserts.Encode(r), otherAcctKey)
+	c.Check(err, ErrorMatches, "cannot verify repair: signed with key .*, not with the account-key .*")
+
+	_, err = signtool.VerifyRepair(asserts.Encode(r), otherBrandAcctKey)
+	c.Check(err, ErrorMatches, `cannot verify repair: account-key is for "other-brand", not for the repair authority "my-brand"`)
+
+	_, err = signtool.VerifyRepair(asserts.Encode(s.brandAcct), s.brandAcctKey)
+	c.Check(err, ErrorMatches, `cannot verify repair: assertion is a "account"`)
+
+	_, err = signtool.VerifyRepair([]byte("garbage"), s.brandAcctKey)
+	c.Check(err, ErrorMatches, "cannot decode repair: .*")
+}
+
+func (s *repairSuite) TestVerifyRepairOutsideKeyValidity(c *C) {
+	testKey, err := s.keypairMgr.Get(s.testKeyID)
+	c.Assert(err, IsNil)
+	acctKey := assertstest.NewAccountKey(s.storeSigning, s.brandAcct, map[string]interface{}{
+		"since": "2017-01-01T00:00:00Z",
+		"until": "2017-02-01T00:00:00Z",
+	}, testKey.PublicKey(), "")
+
+	opts := signtool.RepairOptions{
+		KeyID:   s.testKeyID,
+		BrandID: "my-brand",
+		Script:  []byte(repairScript),
+	}
+	r, err := signtool.SignRepair(&opts, s.store, s.keypairMgr)
+	c.Assert(err, IsNil)
+
+	_, err = signtool.VerifyRepair(asserts.Encode(r), acctKey)
+	c.Check(err, ErrorMatches, "cannot verify repair: repair assertion timestamp outside of signing key validity .*")
+}
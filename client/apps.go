@@ -0,0 +1,196 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AppOptions represent the options of the Apps call.
+type AppOptions struct {
+	// If Service is true, only return apps that are services
+	// (app.IsService() is true); otherwise, return all apps.
+	Service bool
+}
+
+// Apps returns information about the apps of the given snaps, or about
+// the given <snap>.<app> apps. If names is empty, the apps of all
+// installed snaps are returned.
+func (client *Client) Apps(names []string, opts AppOptions) ([]*AppInfo, error) {
+	q := make(url.Values)
+	if len(names) > 0 {
+		q.Add("names", strings.Join(names, ","))
+	}
+	if opts.Service {
+		q.Add("select", "service")
+	}
+
+	var appInfos []*AppInfo
+	_, err := client.doSync("GET", "/v2/apps", q, nil, nil, &appInfos)
+
+	return appInfos, err
+}
+
+// StartOptions represent the different options of the Start call.
+type StartOptions struct {
+	// Enable, as well as starting, the listed services. A
+	// disabled service does not start on boot.
+	Enable bool `json:"enable,omitempty"`
+}
+
+// StopOptions represent the different options of the Stop call.
+type StopOptions struct {
+	// Disable, as well as stopping, the listed services. A
+	// service that is not disabled starts on boot.
+	Disable bool `json:"disable,omitempty"`
+}
+
+// RestartOptions represent the different options of the Restart call.
+type RestartOptions struct{}
+
+type appInstruction struct {
+	Action string   `json:"action"`
+	Names  []string `json:"names"`
+	*StartOptions
+	*StopOptions
+	*RestartOptions
+}
+
+// Start services.
+//
+// It takes a list of names that can be snaps, of which all their
+// services are started, or snap.service which are individual
+// services to start; it shouldn't be empty.
+func (client *Client) Start(names []string, opts StartOptions) (changeID string, err error) {
+	return client.appsAction(&appInstruction{
+		Action:       "start",
+		Names:        names,
+		StartOptions: &opts,
+	})
+}
+
+// Stop services.
+//
+// It takes a list of names that can be snaps, of which all their
+// services are stopped, or snap.service which are individual
+// services to stop; it shouldn't be empty.
+func (client *Client) Stop(names []string, opts StopOptions) (changeID string, err error) {
+	return client.appsAction(&appInstruction{
+		Action:      "stop",
+		Names:       names,
+		StopOptions: &opts,
+	})
+}
+
+// Restart services.
+//
+// It takes a list of names that can be snaps, of which all their
+// services are restarted, or snap.service which are individual
+// services to restart; it shouldn't be empty.
+func (client *Client) Restart(names []string, opts RestartOptions) (changeID string, err error) {
+	return client.appsAction(&appInstruction{
+		Action:         "restart",
+		Names:          names,
+		RestartOptions: &opts,
+	})
+}
+
+func (client *Client) appsAction(inst *appInstruction) (changeID string, err error) {
+	b, err := json.Marshal(inst)
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/apps", nil, nil, bytes.NewReader(b))
+}
+
+// Log holds the information of a single syslog entry
+type Log struct {
+	Timestamp time.Time `json:"timestamp"` // Timestamp of the event, in RFC3339 format to µs precision.
+	Message   string    `json:"message"`   // The log message itself
+	SID       string    `json:"sid"`       // The syslog identifier
+	PID       string    `json:"pid"`       // The process identifier
+}
+
+func (l Log) String() string {
+	return fmt.Sprintf("%s %s[%s]: %s", l.Timestamp.Format(time.RFC3339), l.SID, l.PID, l.Message)
+}
+
+// LogOptions represent the options of the Logs call.
+type LogOptions struct {
+	N      int  // The maximum number of log lines to retrieve initially. If <0, no limit.
+	Follow bool // Whether to continue returning new lines as they appear
+}
+
+// Logs asks for the logs of a series of services, by name.
+//
+// The logs are streamed through the returned channel, which is closed
+// once no more logs are to be expected.
+func (client *Client) Logs(names []string, opts LogOptions) (<-chan Log, error) {
+	query := url.Values{}
+	if len(names) > 0 {
+		query.Set("names", strings.Join(names, ","))
+	}
+	query.Set("n", strconv.Itoa(opts.N))
+	if opts.Follow {
+		query.Set("follow", strconv.FormatBool(opts.Follow))
+	}
+
+	rsp, err := client.raw("GET", "/v2/logs", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != 200 {
+		defer rsp.Body.Close()
+		return nil, parseError(rsp)
+	}
+
+	ch := make(chan Log, 20)
+	go func() {
+		defer rsp.Body.Close()
+		defer close(ch)
+		// the logs come as a JSON text sequence (RFC 7464); the
+		// stream ends at the first error, be it EOF or a bogus entry
+		reader := bufio.NewReader(rsp.Body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			line = bytes.TrimLeft(line, "\x1e")
+			if len(bytes.TrimSpace(line)) > 0 {
+				var log Log
+				if err := json.Unmarshal(line, &log); err != nil {
+					return
+				}
+				ch <- log
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientAppsService(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+  {"snap": "foo", "name": "svc", "daemon": "simple", "enabled": true, "active": true},
+  {"snap": "foo", "name": "other", "daemon": "forking"}
+]}`
+	apps, err := cs.cli.Apps([]string{"foo"}, client.AppOptions{Service: true})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/apps")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"names": []string{"foo"}, "select": []string{"service"}})
+	c.Check(apps, check.DeepEquals, []*client.AppInfo{
+		{Snap: "foo", Name: "svc", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "foo", Name: "other", Daemon: "forking"},
+	})
+	c.Check(apps[0].IsService(), check.Equals, true)
+}
+
+func (cs *clientSuite) TestClientAppsAll(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"snap": "foo", "name": "app"}]}`
+	apps, err := cs.cli.Apps(nil, client.AppOptions{})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+	c.Check(apps, check.DeepEquals, []*client.AppInfo{{Snap: "foo", Name: "app"}})
+	c.Check(apps[0].IsService(), check.Equals, false)
+}
+
+func (cs *clientSuite) TestClientAppsError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "snap \"foo\" has no service \"bar\"", "kind": "app-not-found"}}`
+	cs.status = 404
+	_, err := cs.cli.Apps([]string{"foo.bar"}, client.AppOptions{Service: true})
+	c.Assert(err, check.ErrorMatches, `snap "foo" has no service "bar"`)
+	c.Check(err.(*client.Error).Kind, check.Equals, client.ErrorKindAppNotFound)
+}
+
+func (cs *clientSuite) TestClientServiceActions(c *check.C) {
+	for _, t := range []struct {
+		do       func([]string) (string, error)
+		expected map[string]interface{}
+	}{
+		{
+			func(names []string) (string, error) { return cs.cli.Start(names, client.StartOptions{}) },
+			map[string]interface{}{"action": "start", "names": []interface{}{"foo", "bar.baz"}},
+		}, {
+			func(names []string) (string, error) { return cs.cli.Start(names, client.StartOptions{Enable: true}) },
+			map[string]interface{}{"action": "start", "names": []interface{}{"foo", "bar.baz"}, "enable": true},
+		}, {
+			func(names []string) (string, error) { return cs.cli.Stop(names, client.StopOptions{Disable: true}) },
+			map[string]interface{}{"action": "stop", "names": []interface{}{"foo", "bar.baz"}, "disable": true},
+		}, {
+			func(names []string) (string, error) { return cs.cli.Restart(names, client.RestartOptions{}) },
+			map[string]interface{}{"action": "restart", "names": []interface{}{"foo", "bar.baz"}},
+		},
+	} {
+		cs.rsp = `{"type": "async", "status-code": 202, "result": {}, "change": "24"}`
+		id, err := t.do([]string{"foo", "bar.baz"})
+		c.Assert(err, check.IsNil)
+		c.Check(id, check.Equals, "24")
+		c.Check(cs.req.Method, check.Equals, "POST")
+		c.Check(cs.req.URL.Path, check.Equals, "/v2/apps")
+
+		var body map[string]interface{}
+		c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+		c.Check(body, check.DeepEquals, t.expected)
+	}
+}
+
+func (cs *clientSuite) TestClientLogs(c *check.C) {
+	cs.rsp = "\x1e{\"timestamp\":\"2017-08-01T12:00:00Z\",\"message\":\"hello\",\"sid\":\"foo.svc\",\"pid\":\"42\"}\n" +
+		"\x1e{\"timestamp\":\"2017-08-01T12:00:01Z\",\"message\":\"bye\",\"sid\":\"foo.svc\",\"pid\":\"42\"}\n"
+	ch, err := cs.cli.Logs([]string{"foo"}, client.LogOptions{N: 2, Follow: true})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/logs")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"names": []string{"foo"}, "n": []string{"2"}, "follow": []string{"true"}})
+
+	var logs []client.Log
+	for l := range ch {
+		logs = append(logs, l)
+	}
+	c.Check(logs, check.DeepEquals, []client.Log{
+		{Timestamp: time.Date(2017, 8, 1, 12, 0, 0, 0, time.UTC), Message: "hello", SID: "foo.svc", PID: "42"},
+		{Timestamp: time.Date(2017, 8, 1, 12, 0, 1, 0, time.UTC), Message: "bye", SID: "foo.svc", PID: "42"},
+	})
+	c.Check(logs[0].String(), check.Equals, "2017-08-01T12:00:00Z foo.svc[42]: hello")
+}
+
+func (cs *clientSuite) TestClientLogsError(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "snap \"foo\" not found", "kind": "snap-not-found"}}`
+	cs.status = 404
+	cs.header = http.Header{"Content-Type": {"application/json"}}
+	_, err := cs.cli.Logs([]string{"foo"}, client.LogOptions{N: -1})
+	c.Check(err, check.ErrorMatches, `snap "foo" not found`)
+}
@@ -265,25 +265,32 @@ func (client *Client) doSync(method, path string, query url.Values, headers map[
 }
 
 func (client *Client) doAsync(method, path string, query url.Values, headers map[string]string, body io.Reader) (changeID string, err error) {
+	_, changeID, err = client.doAsyncFull(method, path, query, headers, body)
+	return changeID, err
+}
+
+// doAsyncFull is like doAsync but also returns the result of the
+// response, for the operations that have one.
+func (client *Client) doAsyncFull(method, path string, query url.Values, headers map[string]string, body io.Reader) (result json.RawMessage, changeID string, err error) {
 	var rsp response
 
 	if err := client.do(method, path, query, headers, body, &rsp); err != nil {
-		return "", err
+		return nil, "", err
 	}
 	if err := rsp.err(); err != nil {
-		return "", err
+		return nil, "", err
 	}
 	if rsp.Type != "async" {
-		return "", fmt.Errorf("expected async response for %q on %q, got %q", method, path, rsp.Type)
+		return nil, "", fmt.Errorf("expected async response for %q on %q, got %q", method, path, rsp.Type)
 	}
 	if rsp.StatusCode != 202 {
-		return "", fmt.Errorf("operation not accepted")
+		return nil, "", fmt.Errorf("operation not accepted")
 	}
 	if rsp.Change == "" {
-		return "", fmt.Errorf("async response without change reference")
+		return nil, "", fmt.Errorf("async response without change reference")
 	}
 
-	return rsp.Change, nil
+	return rsp.Result, rsp.Change, nil
 }
 
 type ServerVersion struct {
@@ -357,6 +364,10 @@ const (
 	ErrorKindNoUpdateAvailable      = "snap-no-update-available"
 
 	ErrorKindNotSnap = "snap-not-a-snap"
+
+	ErrorKindAppNotFound = "app-not-found"
+
+	ErrorKindUnsuccessful = "unsuccessful"
 )
 
 // IsTwoFactorError returns whether the given error is due to problems
@@ -380,6 +391,18 @@ type RefreshInfo struct {
 	Schedule string `json:"schedule"`
 	Last     string `json:"last"`
 	Next     string `json:"next"`
+	Hold     string `json:"hold,omitempty"`
+	// Held maps the names of snaps whose refreshes are held to the
+	// time the hold expires
+	Held map[string]string `json:"held,omitempty"`
+}
+
+// ProxyInfo holds the proxies snapd is configured to use, without
+// their credentials.
+type ProxyInfo struct {
+	HTTP    string `json:"http,omitempty"`
+	HTTPS   string `json:"https,omitempty"`
+	NoProxy string `json:"no-proxy,omitempty"`
 }
 
 // SysInfo holds system information
@@ -393,6 +416,7 @@ type SysInfo struct {
 	KernelVersion string `json:"kernel-version,omitempty"`
 
 	Refresh RefreshInfo `json:"refresh,omitempty"`
+	Proxy   *ProxyInfo  `json:"proxy,omitempty"`
 }
 
 func (rsp *response) err() error {
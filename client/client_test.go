@@ -214,6 +214,18 @@ func (cs *clientSuite) TestClientSysInfo(c *C) {
 	})
 }
 
+func (cs *clientSuite) TestClientSysInfoProxy(c *C) {
+	cs.rsp = `{"type": "sync", "result":
+                     {"series": "16",
+                      "proxy": {"https": "http://proxy.example.com:3128", "no-proxy": "example.com"}}}`
+	sysInfo, err := cs.cli.SysInfo()
+	c.Check(err, IsNil)
+	c.Check(sysInfo.Proxy, DeepEquals, &client.ProxyInfo{
+		HTTPS:   "http://proxy.example.com:3128",
+		NoProxy: "example.com",
+	})
+}
+
 func (cs *clientSuite) TestServerVersion(c *C) {
 	cs.rsp = `{"type": "sync", "result":
                      {"series": "16",
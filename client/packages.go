@@ -66,8 +66,25 @@ type Snap struct {
 }
 
 type AppInfo struct {
-	Name   string `json:"name"`
-	Daemon string `json:"daemon"`
+	Snap           string     `json:"snap,omitempty"`
+	Name           string     `json:"name"`
+	Daemon         string     `json:"daemon"`
+	Enabled        bool       `json:"enabled,omitempty"`
+	Active         bool       `json:"active,omitempty"`
+	Timer          string     `json:"timer,omitempty"`
+	NextActivation *time.Time `json:"next-activation,omitempty"`
+}
+
+// IsService returns true if the application is a background daemon.
+func (a *AppInfo) IsService() bool {
+	if a == nil {
+		return false
+	}
+	if a.Daemon == "" {
+		return false
+	}
+
+	return true
 }
 
 type Screenshot struct {
@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// QuotaValues are the resource limits of a quota group.
+type QuotaValues struct {
+	// Memory is the maximum memory in bytes, or 0 if not limited.
+	Memory uint64 `json:"memory,omitempty"`
+	// CPU is the maximum CPU time as a percentage of a single CPU, or 0
+	// if not limited.
+	CPU int `json:"cpu,omitempty"`
+}
+
+// QuotaUsage is the current resource usage of a quota group.
+type QuotaUsage struct {
+	Memory  uint64        `json:"memory"`
+	CPUTime time.Duration `json:"cpu-time"`
+}
+
+// QuotaGroupResult is a quota group, as returned by the API.
+type QuotaGroupResult struct {
+	GroupName   string       `json:"group-name"`
+	Snaps       []string     `json:"snaps,omitempty"`
+	Constraints *QuotaValues `json:"constraints,omitempty"`
+	Current     *QuotaUsage  `json:"current,omitempty"`
+}
+
+type quotaAction struct {
+	Action      string       `json:"action"`
+	GroupName   string       `json:"group-name"`
+	Snaps       []string     `json:"snaps,omitempty"`
+	Constraints *QuotaValues `json:"constraints,omitempty"`
+}
+
+func (client *Client) quotaAction(action *quotaAction) (changeID string, err error) {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal quota action: %v", err)
+	}
+
+	return client.doAsync("POST", "/v2/quotas", nil, map[string]string{
+		"Content-Type": "application/json",
+	}, bytes.NewBuffer(data))
+}
+
+// EnsureQuota creates the quota group with the given name, or updates it
+// if it exists, with the given limits and snaps. Zero limits keep the
+// current ones of an existing group, and the snaps are added to the ones
+// already in it.
+func (client *Client) EnsureQuota(groupName string, snaps []string, limits *QuotaValues) (changeID string, err error) {
+	if groupName == "" {
+		return "", fmt.Errorf("cannot create or update quota group without a name")
+	}
+	return client.quotaAction(&quotaAction{
+		Action:      "ensure",
+		GroupName:   groupName,
+		Snaps:       snaps,
+		Constraints: limits,
+	})
+}
+
+// RemoveQuota removes the quota group with the given name.
+func (client *Client) RemoveQuota(groupName string) (changeID string, err error) {
+	if groupName == "" {
+		return "", fmt.Errorf("cannot remove quota group without a name")
+	}
+	return client.quotaAction(&quotaAction{
+		Action:    "remove",
+		GroupName: groupName,
+	})
+}
+
+// Quotas lists the quota groups in the system.
+func (client *Client) Quotas() ([]*QuotaGroupResult, error) {
+	var groups []*QuotaGroupResult
+	_, err := client.doSync("GET", "/v2/quotas", nil, nil, nil, &groups)
+	return groups, err
+}
+
+// GetQuotaGroup returns the quota group with the given name.
+func (client *Client) GetQuotaGroup(groupName string) (*QuotaGroupResult, error) {
+	if groupName == "" {
+		return nil, fmt.Errorf("cannot get quota group without a name")
+	}
+
+	var group *QuotaGroupResult
+	_, err := client.doSync("GET", "/v2/quotas/"+groupName, nil, nil, nil, &group)
+	return group, err
+}
@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientQuotas(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+  {"group-name": "grp", "snaps": ["foo", "bar"], "constraints": {"memory": 1048576, "cpu": 50}, "current": {"memory": 4096, "cpu-time": 2000000000}}
+]}`
+	groups, err := cs.cli.Quotas()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
+	c.Check(groups, check.DeepEquals, []*client.QuotaGroupResult{{
+		GroupName:   "grp",
+		Snaps:       []string{"foo", "bar"},
+		Constraints: &client.QuotaValues{Memory: 1048576, CPU: 50},
+		Current:     &client.QuotaUsage{Memory: 4096, CPUTime: 2 * time.Second},
+	}})
+}
+
+func (cs *clientSuite) TestClientGetQuotaGroup(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"group-name": "grp", "constraints": {"cpu": 50}}}`
+	group, err := cs.cli.GetQuotaGroup("grp")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas/grp")
+	c.Check(group, check.DeepEquals, &client.QuotaGroupResult{
+		GroupName:   "grp",
+		Constraints: &client.QuotaValues{CPU: 50},
+	})
+
+	_, err = cs.cli.GetQuotaGroup("")
+	c.Check(err, check.ErrorMatches, "cannot get quota group without a name")
+}
+
+func (cs *clientSuite) TestClientEnsureQuota(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "result": null, "change": "42"}`
+	changeID, err := cs.cli.EnsureQuota("grp", []string{"foo"}, &client.QuotaValues{Memory: 1048576})
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/quotas")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action":      "ensure",
+		"group-name":  "grp",
+		"snaps":       []interface{}{"foo"},
+		"constraints": map[string]interface{}{"memory": 1048576.0},
+	})
+
+	_, err = cs.cli.EnsureQuota("", nil, nil)
+	c.Check(err, check.ErrorMatches, "cannot create or update quota group without a name")
+}
+
+func (cs *clientSuite) TestClientRemoveQuota(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "result": null, "change": "42"}`
+	changeID, err := cs.cli.RemoveQuota("grp")
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "42")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action":     "remove",
+		"group-name": "grp",
+	})
+
+	_, err = cs.cli.RemoveQuota("")
+	c.Check(err, check.ErrorMatches, "cannot remove quota group without a name")
+}
@@ -27,6 +27,7 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type SnapOptions struct {
@@ -73,6 +74,9 @@ type actionData struct {
 type multiActionData struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
+	Users  []string `json:"users,omitempty"`
+	// Duration is only used with the "hold" action
+	Duration string `json:"duration,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -104,6 +108,27 @@ func (client *Client) RefreshMany(names []string, options *SnapOptions) (changeI
 	return client.doMultiSnapAction("refresh", names, options)
 }
 
+// HoldRefreshes holds the automatic refreshes of the given snaps, or
+// of all of them if none is given, for the given duration. A zero
+// duration releases the hold.
+func (client *Client) HoldRefreshes(names []string, duration time.Duration) (changeID string, err error) {
+	action := multiActionData{
+		Action:   "hold",
+		Snaps:    names,
+		Duration: duration.String(),
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	return client.doAsync("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data))
+}
+
 func (client *Client) Enable(name string, options *SnapOptions) (changeID string, err error) {
 	return client.doSnapAction("enable", name, options)
 }
@@ -117,6 +142,11 @@ func (client *Client) Revert(name string, options *SnapOptions) (changeID string
 	return client.doSnapAction("revert", name, options)
 }
 
+// Switch moves the snap to a different channel without a refresh
+func (client *Client) Switch(name string, options *SnapOptions) (changeID string, err error) {
+	return client.doSnapAction("switch", name, options)
+}
+
 var ErrDangerousNotApplicable = fmt.Errorf("dangerous option only meaningful when installing from a local file")
 
 func (client *Client) doSnapAction(actionName string, snapName string, options *SnapOptions) (changeID string, err error) {
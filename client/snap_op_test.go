@@ -28,6 +28,7 @@ import (
 	"mime"
 	"mime/multipart"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -46,6 +47,7 @@ var ops = []struct {
 	{(*client.Client).Revert, "revert"},
 	{(*client.Client).Enable, "enable"},
 	{(*client.Client).Disable, "disable"},
+	{(*client.Client).Switch, "switch"},
 }
 
 var multiOps = []struct {
@@ -171,6 +173,27 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientHoldRefreshes(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	id, err := cs.cli.HoldRefreshes([]string{pkgName}, 48*time.Hour)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Assert(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
+
+	var jsonBody map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":   "hold",
+		"snaps":    []interface{}{pkgName},
+		"duration": "48h0m0s",
+	})
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.rsp = `{
 		"change": "66b3",
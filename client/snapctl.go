@@ -40,6 +40,16 @@ type snapctlOutput struct {
 	Stderr string `json:"stderr"`
 }
 
+// UnsuccessfulError is returned by RunSnapctl when the command ran but
+// asked for snapctl to exit with a non-zero exit code.
+type UnsuccessfulError struct {
+	ExitCode int
+}
+
+func (e *UnsuccessfulError) Error() string {
+	return fmt.Sprintf("snapctl unsuccessful with exit code: %d", e.ExitCode)
+}
+
 // RunSnapctl requests a snapctl run for the given options.
 func (client *Client) RunSnapctl(options *SnapCtlOptions) (stdout, stderr []byte, err error) {
 	b, err := json.Marshal(options)
@@ -49,6 +59,18 @@ func (client *Client) RunSnapctl(options *SnapCtlOptions) (stdout, stderr []byte
 
 	var output snapctlOutput
 	_, err = client.doSync("POST", "/v2/snapctl", nil, nil, bytes.NewReader(b), &output)
+	if e, ok := err.(*Error); ok && e.Kind == ErrorKindUnsuccessful {
+		var unsuccessful struct {
+			snapctlOutput
+			ExitCode int `json:"exit-code"`
+		}
+		// the error value was decoded generically, so round-trip it
+		if v, err := json.Marshal(e.Value); err == nil {
+			if err := json.Unmarshal(v, &unsuccessful); err == nil {
+				return []byte(unsuccessful.Stdout), []byte(unsuccessful.Stderr), &UnsuccessfulError{ExitCode: unsuccessful.ExitCode}
+			}
+		}
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// A Snapshot is a collection of archives with the data of a snap, and
+// the metadata needed to restore them (including checksums).
+type Snapshot struct {
+	// SetID is the ID of the snapshot set the snapshot belongs to (a
+	// snapshot set is the result of a single "snap save")
+	SetID uint64 `json:"set"`
+	// Time is when the collection of the snapshot's data started
+	Time time.Time `json:"time"`
+
+	// the snap this data is for
+	Snap     string        `json:"snap"`
+	Revision snap.Revision `json:"revision"`
+	Version  string        `json:"version,omitempty"`
+
+	// Conf is the configuration of the snap when the snapshot was taken
+	Conf *json.RawMessage `json:"conf,omitempty"`
+
+	// SHA3_384 holds the hash of each of the archives in the snapshot,
+	// keyed by archive name ("archive.tgz" for the system data, and
+	// "user/<username>.tgz" for each user's)
+	SHA3_384 map[string]string `json:"sha3-384"`
+	// Size is the sum of the sizes of the archives
+	Size int64 `json:"size,omitempty"`
+}
+
+// A SnapshotSet is the group of snapshots taken together.
+type SnapshotSet struct {
+	ID        uint64      `json:"id"`
+	Snapshots []*Snapshot `json:"snapshots"`
+}
+
+// Time of the snapshot set, that of its earliest snapshot.
+func (ss SnapshotSet) Time() time.Time {
+	var t time.Time
+	for _, sh := range ss.Snapshots {
+		if t.IsZero() || sh.Time.Before(t) {
+			t = sh.Time
+		}
+	}
+	return t
+}
+
+// Size of the snapshot set, the sum of the sizes of its snapshots.
+func (ss SnapshotSet) Size() int64 {
+	var sum int64
+	for _, sh := range ss.Snapshots {
+		sum += sh.Size
+	}
+	return sum
+}
+
+// SnapshotSets lists the snapshot sets in the system, or just the one
+// with the given ID if it is not zero, restricted to the snapshots of
+// the given snaps if any.
+func (client *Client) SnapshotSets(setID uint64, snapNames []string) ([]SnapshotSet, error) {
+	q := make(url.Values)
+	if setID > 0 {
+		q.Add("set", strconv.FormatUint(setID, 10))
+	}
+	if len(snapNames) > 0 {
+		q.Add("snaps", strings.Join(snapNames, ","))
+	}
+
+	var snapshotSets []SnapshotSet
+	_, err := client.doSync("GET", "/v2/snapshots", q, nil, nil, &snapshotSets)
+	return snapshotSets, err
+}
+
+// SnapshotMany snapshots the data of the given snaps, or of all of
+// them if none is given, for the given users, or for all of them if
+// none is given. It returns the ID of the new snapshot set.
+func (client *Client) SnapshotMany(snapNames []string, users []string) (setID uint64, changeID string, err error) {
+	action := &multiActionData{
+		Action: "snapshot",
+		Snaps:  snapNames,
+		Users:  users,
+	}
+	data, err := json.Marshal(action)
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+
+	result, changeID, err := client.doAsyncFull("POST", "/v2/snaps", nil, map[string]string{
+		"Content-Type": "application/json",
+	}, bytes.NewBuffer(data))
+	if err != nil {
+		return 0, "", err
+	}
+
+	var x struct {
+		SetID uint64 `json:"set-id"`
+	}
+	if err := json.Unmarshal(result, &x); err != nil {
+		return 0, "", fmt.Errorf("cannot decode snapshot set ID: %v", err)
+	}
+
+	return x.SetID, changeID, nil
+}
+
+type snapshotAction struct {
+	SetID  uint64   `json:"set"`
+	Action string   `json:"action"`
+	Snaps  []string `json:"snaps,omitempty"`
+	Users  []string `json:"users,omitempty"`
+}
+
+func (client *Client) snapshotAction(action *snapshotAction) (changeID string, err error) {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal snapshot action: %v", err)
+	}
+
+	return client.doAsync("POST", "/v2/snapshots", nil, map[string]string{
+		"Content-Type": "application/json",
+	}, bytes.NewBuffer(data))
+}
+
+// RestoreSnapshots restores the data of the given snaps, or of all of
+// them if none is given, from the given snapshot set, for the given
+// users, or for all of them if none is given.
+func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "restore",
+		Snaps:  snaps,
+		Users:  users,
+	})
+}
+
+// ForgetSnapshots permanently removes the snapshots of the given
+// snaps, or all of them if none is given, from the given snapshot set.
+func (client *Client) ForgetSnapshots(setID uint64, snaps []string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "forget",
+		Snaps:  snaps,
+	})
+}
@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientSnapshotSets(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+  {"id": 1, "snapshots": [
+    {"set": 1, "time": "2017-08-01T12:00:00Z", "snap": "foo", "revision": "7", "version": "1.0", "sha3-384": {"archive.tgz": "abc"}, "size": 100},
+    {"set": 1, "time": "2017-08-01T11:00:00Z", "snap": "bar", "revision": "x1", "sha3-384": {}, "size": 20}
+  ]}
+]}`
+	sets, err := cs.cli.SnapshotSets(1, []string{"foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"set": []string{"1"}, "snaps": []string{"foo,bar"}})
+	c.Assert(sets, check.HasLen, 1)
+	c.Check(sets[0].ID, check.Equals, uint64(1))
+	c.Assert(sets[0].Snapshots, check.HasLen, 2)
+	c.Check(sets[0].Snapshots[0], check.DeepEquals, &client.Snapshot{
+		SetID:    1,
+		Time:     time.Date(2017, 8, 1, 12, 0, 0, 0, time.UTC),
+		Snap:     "foo",
+		Revision: snap.R(7),
+		Version:  "1.0",
+		SHA3_384: map[string]string{"archive.tgz": "abc"},
+		Size:     100,
+	})
+	c.Check(sets[0].Time(), check.Equals, time.Date(2017, 8, 1, 11, 0, 0, 0, time.UTC))
+	c.Check(sets[0].Size(), check.Equals, int64(120))
+}
+
+func (cs *clientSuite) TestClientSnapshotSetsAll(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+	sets, err := cs.cli.SnapshotSets(0, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.HasLen, 0)
+	c.Check(sets, check.HasLen, 0)
+}
+
+func (cs *clientSuite) TestClientSnapshotMany(c *check.C) {
+	cs.rsp = `{"type": "async", "status-code": 202, "result": {"set-id": 42}, "change": "24"}`
+	setID, changeID, err := cs.cli.SnapshotMany([]string{"foo", "bar"}, []string{"alice"})
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(42))
+	c.Check(changeID, check.Equals, "24")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "snapshot",
+		"snaps":  []interface{}{"foo", "bar"},
+		"users":  []interface{}{"alice"},
+	})
+}
+
+func (cs *clientSuite) TestClientSnapshotActions(c *check.C) {
+	for _, t := range []struct {
+		do       func() (string, error)
+		expected map[string]interface{}
+	}{
+		{
+			func() (string, error) { return cs.cli.RestoreSnapshots(42, []string{"foo"}, []string{"bob"}) },
+			map[string]interface{}{"action": "restore", "set": 42., "snaps": []interface{}{"foo"}, "users": []interface{}{"bob"}},
+		}, {
+			func() (string, error) { return cs.cli.RestoreSnapshots(42, nil, nil) },
+			map[string]interface{}{"action": "restore", "set": 42.},
+		}, {
+			func() (string, error) { return cs.cli.ForgetSnapshots(42, []string{"foo"}) },
+			map[string]interface{}{"action": "forget", "set": 42., "snaps": []interface{}{"foo"}},
+		},
+	} {
+		cs.rsp = `{"type": "async", "status-code": 202, "result": {}, "change": "24"}`
+		id, err := t.do()
+		c.Assert(err, check.IsNil)
+		c.Check(id, check.Equals, "24")
+		c.Check(cs.req.Method, check.Equals, "POST")
+		c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+
+		var body map[string]interface{}
+		c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+		c.Check(body, check.DeepEquals, t.expected)
+	}
+}
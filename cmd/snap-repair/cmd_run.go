@@ -21,6 +21,11 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
 )
 
 func init() {
@@ -38,6 +43,48 @@ func init() {
 type cmdRun struct{}
 
 func (c *cmdRun) Execute(args []string) error {
-	fmt.Fprintf(Stdout, "run is not implemented yet\n")
+	if err := os.MkdirAll(dirs.SnapRepairRunDir, 0755); err != nil {
+		return err
+	}
+
+	run, err := NewRunner()
+	if err != nil {
+		return err
+	}
+	if err := run.LoadState(); err != nil {
+		return err
+	}
+	// persist the initial state even if there are no repairs
+	if err := run.SaveState(); err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, brandID := range run.Brands() {
+		for {
+			repair, err := run.Next(brandID)
+			if err == ErrRepairNotFound {
+				// no more repairs for this brand
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			// a repair that cannot be run stays to be retried and
+			// does not prevent running the others
+			if err := repair.Run(); err != nil {
+				logger.Noticef("cannot run repair %s: %v", repair, err)
+				failed = append(failed, repair.String())
+			}
+		}
+	}
+
+	if err := run.SaveState(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cannot run repairs: %s", strings.Join(failed, ", "))
+	}
 	return nil
 }
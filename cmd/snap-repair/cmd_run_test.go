@@ -20,14 +20,66 @@
 package main_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	. "gopkg.in/check.v1"
 
 	repair "github.com/snapcore/snapd/cmd/snap-repair"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/testutil"
 )
 
-func (r *repairSuite) TestRun(c *C) {
+func (s *runnerSuite) TestRunCommand(c *C) {
+	os.Setenv("SNAPPY_FORCE_REPAIR_URL", s.mockServer.URL+"/")
+	defer os.Unsetenv("SNAPPY_FORCE_REPAIR_URL")
+
+	r := s.signRepair(c, "1", nil, "#!/bin/sh\necho hello from repair\n")
+	s.publish("1", r, s.aux...)
+
+	err := repair.ParseArgs([]string{"run"})
+	c.Assert(err, IsNil)
+
+	out := readFile(c, filepath.Join(dirs.SnapRepairRunDir, "my-brand", "1", "r0.done"))
+	c.Check(out, testutil.Contains, "hello from repair\n")
+	c.Check(readFile(c, dirs.SnapRepairStateFile), testutil.Contains, `"my-brand":[{"sequence":1,"revision":0,"status":"done"}]`)
+}
+
+func (s *runnerSuite) TestRunCommandRetryOnce(c *C) {
+	os.Setenv("SNAPPY_FORCE_REPAIR_URL", s.mockServer.URL+"/")
+	defer os.Unsetenv("SNAPPY_FORCE_REPAIR_URL")
+
+	r := s.signRepair(c, "1", nil, "#!/bin/sh\necho failing\nexit 1\n")
+	s.publish("1", r, s.aux...)
+
+	// the failing repair is run once and the command terminates
+	err := repair.ParseArgs([]string{"run"})
+	c.Assert(err, IsNil)
+
+	out := readFile(c, filepath.Join(dirs.SnapRepairRunDir, "my-brand", "1", "r0.retry"))
+	c.Check(out, testutil.Contains, "failing\n")
+	c.Check(readFile(c, dirs.SnapRepairStateFile), testutil.Contains, `"my-brand":[{"sequence":1,"revision":0,"status":"retry"}]`)
+}
+
+func (s *runnerSuite) TestRunCommandContinuesAfterFailure(c *C) {
+	os.Setenv("SNAPPY_FORCE_REPAIR_URL", s.mockServer.URL+"/")
+	defer os.Unsetenv("SNAPPY_FORCE_REPAIR_URL")
+
+	r1 := s.signRepair(c, "1", nil, "#!/bin/sh\necho repair 1\n")
+	s.publish("1", r1, s.aux...)
+	r2 := s.signRepair(c, "2", nil, "#!/bin/sh\necho repair 2\n")
+	s.publish("2", r2, s.aux...)
+
+	// the run directory of the first repair cannot be created
+	brandRunDir := filepath.Join(dirs.SnapRepairRunDir, "my-brand")
+	c.Assert(os.MkdirAll(brandRunDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(brandRunDir, "1"), nil, 0644), IsNil)
+
 	err := repair.ParseArgs([]string{"run"})
-	c.Check(err, IsNil)
-	c.Check(r.Stdout(), Equals, "run is not implemented yet\n")
+	c.Check(err, ErrorMatches, "cannot run repairs: my-brand-1")
 
+	out := readFile(c, filepath.Join(brandRunDir, "2", "r0.done"))
+	c.Check(out, testutil.Contains, "repair 2\n")
+	c.Check(readFile(c, dirs.SnapRepairStateFile), testutil.Contains, `"my-brand":[{"sequence":1,"revision":0,"status":"retry"},{"sequence":2,"revision":0,"status":"done"}]`)
 }
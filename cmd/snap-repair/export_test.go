@@ -19,8 +19,20 @@
 
 package main
 
+import (
+	"time"
+)
+
 var (
 	Parser    = parser
 	ParseArgs = parseArgs
 	Run       = run
 )
+
+func MockDefaultRepairTimeout(d time.Duration) (restore func()) {
+	old := defaultRepairTimeout
+	defaultRepairTimeout = d
+	return func() {
+		defaultRepairTimeout = old
+	}
+}
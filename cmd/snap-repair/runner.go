@@ -0,0 +1,596 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/retry.v1"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// RepairStatus represents the possible statuses of a repair.
+type RepairStatus int
+
+const (
+	RetryStatus RepairStatus = iota
+	SkipStatus
+	DoneStatus
+)
+
+func (rs RepairStatus) String() string {
+	switch rs {
+	case RetryStatus:
+		return "retry"
+	case SkipStatus:
+		return "skip"
+	case DoneStatus:
+		return "done"
+	}
+	return fmt.Sprintf("RepairStatus(%d)", rs)
+}
+
+// MarshalJSON makes RepairStatus a json.Marshaller, so that the state
+// keeps the status by name rather than by value.
+func (rs RepairStatus) MarshalJSON() ([]byte, error) {
+	switch rs {
+	case RetryStatus, SkipStatus, DoneStatus:
+		return json.Marshal(rs.String())
+	}
+	return nil, fmt.Errorf("cannot marshal invalid repair status %d", rs)
+}
+
+// UnmarshalJSON makes RepairStatus a json.Unmarshaller.
+func (rs *RepairStatus) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("cannot unmarshal repair status: %v", err)
+	}
+	switch s {
+	case "retry":
+		*rs = RetryStatus
+	case "skip":
+		*rs = SkipStatus
+	case "done":
+		*rs = DoneStatus
+	default:
+		return fmt.Errorf("cannot unmarshal repair status: unknown status %q", s)
+	}
+	return nil
+}
+
+// ErrRepairNotFound is returned by Fetch when the repair does not exist.
+var ErrRepairNotFound = errors.New("repair not found")
+
+var (
+	// defaultRepairTimeout is the maximum time a repair script is
+	// allowed to run before it gets killed
+	defaultRepairTimeout = 30 * time.Minute
+
+	fetchRetryStrategy = retry.LimitCount(7, retry.LimitTime(90*time.Second,
+		retry.Exponential{
+			Initial: 500 * time.Millisecond,
+			Factor:  2.5,
+		},
+	))
+)
+
+func useStaging() bool {
+	return osutil.GetenvBool("SNAPPY_USE_STAGING_STORE")
+}
+
+func baseURL() string {
+	if u := os.Getenv("SNAPPY_FORCE_REPAIR_URL"); u != "" {
+		return u
+	}
+	if useStaging() {
+		return "https://api.staging.snapcraft.io/v2/"
+	}
+	return "https://api.snapcraft.io/v2/"
+}
+
+type deviceInfo struct {
+	Brand string `json:"brand"`
+	Model string `json:"model"`
+}
+
+// repairState holds the persisted state of a single repair sequence
+// entry of a brand.
+type repairState struct {
+	Sequence int          `json:"sequence"`
+	Revision int          `json:"revision"`
+	Status   RepairStatus `json:"status"`
+}
+
+// state is the persisted state of the runner, kept in
+// dirs.SnapRepairStateFile.
+type state struct {
+	Device    deviceInfo                `json:"device"`
+	Sequences map[string][]*repairState `json:"sequences,omitempty"`
+}
+
+// Runner implements fetching, tracking and running repairs.
+type Runner struct {
+	BaseURL *url.URL
+	cli     *http.Client
+
+	state         state
+	stateModified bool
+
+	// nextSequence is the sequence number of the next repair of
+	// each brand to consider in this run
+	nextSequence map[string]int
+}
+
+// NewRunner returns a Runner.
+func NewRunner() (*Runner, error) {
+	u, err := url.Parse(baseURL())
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse repair base URL: %v", err)
+	}
+	return &Runner{
+		BaseURL:      u,
+		cli:          httputil.NewHTTPClient(&httputil.ClientOpts{Timeout: 15 * time.Second}),
+		nextSequence: make(map[string]int),
+	}, nil
+}
+
+// Fetch retrieves a stream with the repair with the given brandID
+// and sequence number, together with the auxiliary assertions needed
+// to verify it.
+func (run *Runner) Fetch(brandID string, seq int) (repair *asserts.Repair, aux []asserts.Assertion, err error) {
+	u, err := run.BaseURL.Parse(fmt.Sprintf("repairs/%s/%d", brandID, seq))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r []asserts.Assertion
+	resp, err := httputil.RetryRequest(u.String(), func() (*http.Response, error) {
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", httputil.UserAgent())
+		req.Header.Set("Accept", asserts.MediaType)
+		return run.cli.Do(req)
+	}, func(resp *http.Response) error {
+		if resp.StatusCode != 200 {
+			return nil
+		}
+		// decode assertions
+		dec := asserts.NewDecoder(resp.Body)
+		for {
+			a, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			r = append(r, a)
+		}
+		if len(r) == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}, fetchRetryStrategy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch resp.StatusCode {
+	case 200:
+		// ok
+	case 404:
+		return nil, nil, ErrRepairNotFound
+	default:
+		return nil, nil, fmt.Errorf("cannot fetch repair, unexpected status %d", resp.StatusCode)
+	}
+
+	repair, ok := r[0].(*asserts.Repair)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot fetch repair, unexpected first assertion %q", r[0].Type().Name)
+	}
+	if repair.BrandID() != brandID || repair.RepairID() != strconv.Itoa(seq) {
+		return nil, nil, fmt.Errorf("cannot fetch repair, id mismatch %s/%s != %s/%d", repair.BrandID(), repair.RepairID(), brandID, seq)
+	}
+
+	return repair, r[1:], nil
+}
+
+// Verify verifies the repair against the trusted assertions using
+// the given auxiliary assertions for its prerequisites.
+func (run *Runner) Verify(repair *asserts.Repair, aux []asserts.Assertion) error {
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   sysdb.Trusted(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// add the auxiliary assertions in an order that satisfies their
+	// prerequisites, which the server does not guarantee
+	pending := aux
+	for len(pending) > 0 {
+		var failed []asserts.Assertion
+		var lastErr error
+		for _, a := range pending {
+			switch a.Type() {
+			case asserts.AccountType, asserts.AccountKeyType:
+			default:
+				return fmt.Errorf("unexpected auxiliary assertion %q", a.Type().Name)
+			}
+			if err := db.Add(a); err != nil {
+				if _, ok := err.(*asserts.RevisionError); ok {
+					// already trusted
+					continue
+				}
+				failed = append(failed, a)
+				lastErr = fmt.Errorf("cannot add auxiliary assertion %v: %v", a.Ref(), err)
+			}
+		}
+		if len(failed) == len(pending) {
+			return lastErr
+		}
+		pending = failed
+	}
+
+	return db.Check(repair)
+}
+
+// LoadState loads the repairs state from disk, or initializes it
+// from the device information if there is none yet.
+func (run *Runner) LoadState() error {
+	f, err := os.Open(dirs.SnapRepairStateFile)
+	if os.IsNotExist(err) {
+		return run.initState()
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&run.state); err != nil {
+		return fmt.Errorf("cannot read repair state: %v", err)
+	}
+	return nil
+}
+
+// SaveState saves the repairs state to disk if it was modified.
+func (run *Runner) SaveState() error {
+	if !run.stateModified {
+		return nil
+	}
+	m, err := json.Marshal(&run.state)
+	if err != nil {
+		return fmt.Errorf("cannot marshal repair state: %v", err)
+	}
+	if err := os.MkdirAll(dirs.SnapRepairDir, 0775); err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(dirs.SnapRepairStateFile, m, 0600, 0); err != nil {
+		return fmt.Errorf("cannot save repair state: %v", err)
+	}
+	run.stateModified = false
+	return nil
+}
+
+// initState gets the device brand and model from the snapd state.
+func (run *Runner) initState() error {
+	f, err := os.Open(dirs.SnapStateFile)
+	if err != nil {
+		return fmt.Errorf("cannot read device information: %v", err)
+	}
+	defer f.Close()
+
+	var snapdState struct {
+		Data struct {
+			Auth struct {
+				Device deviceInfo `json:"device"`
+			} `json:"auth"`
+		} `json:"data"`
+	}
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&snapdState); err != nil {
+		return fmt.Errorf("cannot read device information: %v", err)
+	}
+	device := snapdState.Data.Auth.Device
+	if device.Brand == "" || device.Model == "" {
+		return fmt.Errorf("cannot read device information: device not yet identified")
+	}
+
+	run.state = state{Device: device}
+	run.stateModified = true
+	return nil
+}
+
+// BrandModel returns the brand and model of the device.
+func (run *Runner) BrandModel() (brand, model string) {
+	return run.state.Device.Brand, run.state.Device.Model
+}
+
+// Brands returns the brands whose repairs apply to the device, in
+// the order they should be run.
+func (run *Runner) Brands() []string {
+	brands := []string{"canonical"}
+	if brand := run.state.Device.Brand; brand != "canonical" {
+		brands = append(brands, brand)
+	}
+	return brands
+}
+
+func (run *Runner) sequenceState(brandID string, seq int) *repairState {
+	for _, rs := range run.state.Sequences[brandID] {
+		if rs.Sequence == seq {
+			return rs
+		}
+	}
+	return nil
+}
+
+func (run *Runner) setRepairState(brandID string, rs *repairState) {
+	if run.state.Sequences == nil {
+		run.state.Sequences = make(map[string][]*repairState)
+	}
+	if cur := run.sequenceState(brandID, rs.Sequence); cur != nil {
+		*cur = *rs
+	} else {
+		run.state.Sequences[brandID] = append(run.state.Sequences[brandID], rs)
+	}
+	run.stateModified = true
+}
+
+// Applicable returns whether the repair applies to the device
+// according to its series, architectures and models headers.
+func (run *Runner) Applicable(repair *asserts.Repair) bool {
+	if repair.Disabled() {
+		return false
+	}
+	if series := repair.Series(); len(series) != 0 && !strutil.ListContains(series, release.Series) {
+		return false
+	}
+	if archs := repair.Architectures(); len(archs) != 0 && !strutil.ListContains(archs, arch.UbuntuArchitecture()) {
+		return false
+	}
+	if models := repair.Models(); len(models) != 0 {
+		brandModel := fmt.Sprintf("%s/%s", run.state.Device.Brand, run.state.Device.Model)
+		if !strutil.ListContains(models, brandModel) {
+			return false
+		}
+	}
+	return true
+}
+
+// Next returns the next repair for the brand that needs to run,
+// fetching and verifying it. It returns ErrRepairNotFound when there
+// are no more repairs to consider. Repairs that are not applicable
+// or are done or skipped are passed over, the latter unless a newer
+// revision of them was published. Each repair is considered at most
+// once per run, so a repair to be retried is returned again only by
+// a later run.
+func (run *Runner) Next(brandID string) (*Repair, error) {
+	seq := run.nextSequence[brandID]
+	if seq == 0 {
+		seq = 1
+	}
+	for ; ; seq++ {
+		// whatever happens to this repair, the next call moves on
+		run.nextSequence[brandID] = seq + 1
+		rs := run.sequenceState(brandID, seq)
+
+		repair, aux, err := run.Fetch(brandID, seq)
+		if err != nil {
+			return nil, err
+		}
+
+		if rs != nil && repair.Revision() <= rs.Revision && rs.Status != RetryStatus {
+			continue
+		}
+
+		if err := run.Verify(repair, aux); err != nil {
+			return nil, fmt.Errorf("cannot verify repair %s-%d: %v", brandID, seq, err)
+		}
+
+		rs = &repairState{
+			Sequence: seq,
+			Revision: repair.Revision(),
+			Status:   RetryStatus,
+		}
+		if !run.Applicable(repair) {
+			rs.Status = SkipStatus
+			run.setRepairState(brandID, rs)
+			continue
+		}
+		run.setRepairState(brandID, rs)
+
+		return &Repair{
+			Repair:   repair,
+			run:      run,
+			sequence: seq,
+		}, nil
+	}
+}
+
+// Repair is a runnable repair.
+type Repair struct {
+	*asserts.Repair
+
+	run      *Runner
+	sequence int
+}
+
+// String returns the repair name, of the form <brand>-<sequence>.
+func (r *Repair) String() string {
+	return fmt.Sprintf("%s-%d", r.BrandID(), r.sequence)
+}
+
+// RunDir returns the directory where the repair script, its output
+// and its status are kept.
+func (r *Repair) RunDir() string {
+	return filepath.Join(dirs.SnapRepairRunDir, r.BrandID(), strconv.Itoa(r.sequence))
+}
+
+// Run executes the repair script and records the resulting status in
+// the runner state. The script can report its status by invoking
+// "snap-repair done|skip|retry", otherwise a successful exit means
+// the repair is done and a failure means it will be retried.
+func (r *Repair) Run() error {
+	rundir := r.RunDir()
+	if err := os.MkdirAll(rundir, 0700); err != nil {
+		return err
+	}
+
+	base := filepath.Join(rundir, fmt.Sprintf("r%d", r.Revision()))
+	script := base + ".script"
+	if err := osutil.AtomicWriteFile(script, r.Body(), 0700, 0); err != nil {
+		return err
+	}
+
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer statusR.Close()
+	defer statusW.Close()
+
+	logPath := base + ".running"
+	logf, err := os.OpenFile(logPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer logf.Close()
+	fmt.Fprintf(logf, "repair: %s\nrevision: %d\nsummary: %s\noutput:\n", r, r.Revision(), r.HeaderString("summary"))
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "PATH=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env,
+		// the repair script uses fd 3 to report its status
+		"SNAP_REPAIR_STATUS_FD=3",
+		"SNAP_REPAIR_RUN_DIR="+rundir,
+		// make "snap-repair done|skip|retry" available to the script
+		"PATH="+os.Getenv("PATH")+":"+dirs.CoreLibExecDir,
+	)
+	cmd := exec.Command(script)
+	cmd.Env = env
+	cmd.Dir = rundir
+	cmd.ExtraFiles = []*os.File{statusW}
+	cmd.Stdout = logf
+	cmd.Stderr = logf
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	statusW.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	// a process started by the script that left its process group
+	// can keep the status fd open after the script is gone, so the
+	// status is read on the side and only waited for until timeout
+	statusReported := make(chan []byte, 1)
+	go func() {
+		reported, err := ioutil.ReadAll(statusR)
+		if err != nil {
+			reported = nil
+		}
+		statusReported <- reported
+	}()
+
+	timeout := time.NewTimer(defaultRepairTimeout)
+	defer timeout.Stop()
+
+	var scriptErr error
+	var reported []byte
+	select {
+	case scriptErr = <-done:
+		select {
+		case reported = <-statusReported:
+		case <-timeout.C:
+			logger.Noticef("cannot read the status of repair %s revision %d: status fd still open after %s", r, r.Revision(), defaultRepairTimeout)
+		}
+	case <-timeout.C:
+		// kill the whole process group
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		scriptErr = fmt.Errorf("repair did not finish within %s", defaultRepairTimeout)
+		select {
+		case reported = <-statusReported:
+		default:
+		}
+	}
+
+	// the status reported by the script takes precedence
+	status := RetryStatus
+	if scriptErr == nil {
+		status = DoneStatus
+	}
+	if reported != nil {
+		switch strings.TrimSpace(string(reported)) {
+		case "done":
+			status = DoneStatus
+		case "skip":
+			status = SkipStatus
+		case "retry":
+			status = RetryStatus
+		}
+	}
+
+	if scriptErr != nil {
+		fmt.Fprintf(logf, "error: %v\n", scriptErr)
+		logger.Noticef("repair %s revision %d failed: %v", r, r.Revision(), scriptErr)
+	}
+	if err := os.Rename(logPath, fmt.Sprintf("%s.%s", base, status)); err != nil {
+		return err
+	}
+
+	r.run.setRepairState(r.BrandID(), &repairState{
+		Sequence: r.sequence,
+		Revision: r.Revision(),
+		Status:   status,
+	})
+	return r.run.SaveState()
+}
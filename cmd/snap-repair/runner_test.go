@@ -0,0 +1,335 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/arch"
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	repair "github.com/snapcore/snapd/cmd/snap-repair"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type runnerSuite struct {
+	testutil.BaseTest
+
+	tmpdir string
+
+	storeSigning *assertstest.StoreStack
+	brandSigning *assertstest.SigningDB
+
+	brandAcct    *asserts.Account
+	brandAcctKey *asserts.AccountKey
+	aux          []asserts.Assertion
+
+	mockServer *httptest.Server
+	repairs    map[string]string
+	requests   []string
+}
+
+var _ = Suite(&runnerSuite{})
+
+func (s *runnerSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.tmpdir = c.MkDir()
+	dirs.SetRootDir(s.tmpdir)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	rootPrivKey, _ := assertstest.GenerateKey(1024)
+	storePrivKey, _ := assertstest.GenerateKey(752)
+	s.storeSigning = assertstest.NewStoreStack("can0nical", rootPrivKey, storePrivKey)
+	s.AddCleanup(sysdb.InjectTrusted(s.storeSigning.Trusted))
+
+	brandPrivKey, _ := assertstest.GenerateKey(752)
+	s.brandAcct = assertstest.NewAccount(s.storeSigning, "my-brand", map[string]interface{}{
+		"account-id": "my-brand",
+	}, "")
+	s.brandAcctKey = assertstest.NewAccountKey(s.storeSigning, s.brandAcct, nil, brandPrivKey.PublicKey(), "")
+	s.brandSigning = assertstest.NewSigningDB("my-brand", brandPrivKey)
+	s.aux = []asserts.Assertion{s.storeSigning.StoreAccountKey(""), s.brandAcct, s.brandAcctKey}
+
+	s.repairs = make(map[string]string)
+	s.requests = nil
+	s.mockServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Accept"), Equals, asserts.MediaType)
+		s.requests = append(s.requests, r.URL.Path)
+		content, ok := s.repairs[r.URL.Path]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		w.Header().Set("Content-Type", asserts.MediaType)
+		w.Write([]byte(content))
+	}))
+	s.AddCleanup(s.mockServer.Close)
+
+	s.mockDevice(c, "my-brand", "my-model")
+}
+
+func (s *runnerSuite) mockDevice(c *C, brand, model string) {
+	st := map[string]interface{}{
+		"data": map[string]interface{}{
+			"auth": map[string]interface{}{
+				"device": map[string]interface{}{
+					"brand": brand,
+					"model": model,
+				},
+			},
+		},
+	}
+	b, err := json.Marshal(st)
+	c.Assert(err, IsNil)
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, b, 0600), IsNil)
+}
+
+func (s *runnerSuite) signRepair(c *C, repairID string, extra map[string]interface{}, script string) *asserts.Repair {
+	headers := map[string]interface{}{
+		"brand-id":  "my-brand",
+		"repair-id": repairID,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	a, err := s.brandSigning.Sign(asserts.RepairType, headers, []byte(script), "")
+	c.Assert(err, IsNil)
+	return a.(*asserts.Repair)
+}
+
+func (s *runnerSuite) publish(repairID string, repair *asserts.Repair, aux ...asserts.Assertion) {
+	buf := string(asserts.Encode(repair))
+	for _, a := range aux {
+		buf += "\n" + string(asserts.Encode(a))
+	}
+	s.repairs["/repairs/my-brand/"+repairID] = buf
+}
+
+func (s *runnerSuite) newRunner(c *C) *repair.Runner {
+	run, err := repair.NewRunner()
+	c.Assert(err, IsNil)
+	u, err := url.Parse(s.mockServer.URL)
+	c.Assert(err, IsNil)
+	run.BaseURL = u
+	c.Assert(run.LoadState(), IsNil)
+	return run
+}
+
+func (s *runnerSuite) TestLoadStateFromDevice(c *C) {
+	run := s.newRunner(c)
+
+	brand, model := run.BrandModel()
+	c.Check(brand, Equals, "my-brand")
+	c.Check(model, Equals, "my-model")
+	c.Check(run.Brands(), DeepEquals, []string{"canonical", "my-brand"})
+
+	c.Assert(run.SaveState(), IsNil)
+	c.Check(readFile(c, dirs.SnapRepairStateFile), Equals, `{"device":{"brand":"my-brand","model":"my-model"}}`)
+}
+
+func (s *runnerSuite) TestLoadStateNoDevice(c *C) {
+	s.mockDevice(c, "", "")
+
+	run, err := repair.NewRunner()
+	c.Assert(err, IsNil)
+	c.Check(run.LoadState(), ErrorMatches, "cannot read device information: device not yet identified")
+}
+
+func (s *runnerSuite) TestNewRunnerInvalidURL(c *C) {
+	os.Setenv("SNAPPY_FORCE_REPAIR_URL", "://bad")
+	defer os.Unsetenv("SNAPPY_FORCE_REPAIR_URL")
+
+	_, err := repair.NewRunner()
+	c.Check(err, ErrorMatches, "cannot parse repair base URL: .*")
+}
+
+func (s *runnerSuite) TestFetchAndVerify(c *C) {
+	r := s.signRepair(c, "1", nil, "#!/bin/sh\nexit 0\n")
+	s.publish("1", r, s.aux...)
+
+	run := s.newRunner(c)
+	got, aux, err := run.Fetch("my-brand", 1)
+	c.Assert(err, IsNil)
+	c.Check(got.RepairID(), Equals, "1")
+	c.Check(aux, HasLen, 3)
+
+	c.Check(run.Verify(got, aux), IsNil)
+}
+
+func (s *runnerSuite) TestFetchNotFound(c *C) {
+	run := s.newRunner(c)
+	_, _, err := run.Fetch("my-brand", 1)
+	c.Check(err, Equals, repair.ErrRepairNotFound)
+}
+
+func (s *runnerSuite) TestFetchIDMismatch(c *C) {
+	r := s.signRepair(c, "2", nil, "#!/bin/sh\nexit 0\n")
+	s.publish("1", r, s.aux...)
+
+	run := s.newRunner(c)
+	_, _, err := run.Fetch("my-brand", 1)
+	c.Check(err, ErrorMatches, `cannot fetch repair, id mismatch my-brand/2 != my-brand/1`)
+}
+
+func (s *runnerSuite) TestVerifyMissingKey(c *C) {
+	r := s.signRepair(c, "1", nil, "#!/bin/sh\nexit 0\n")
+
+	run := s.newRunner(c)
+	err := run.Verify(r, []asserts.Assertion{s.storeSigning.StoreAccountKey(""), s.brandAcct})
+	c.Check(err, ErrorMatches, `no matching public key .* for signature by "my-brand"`)
+}
+
+func (s *runnerSuite) TestApplicable(c *C) {
+	run := s.newRunner(c)
+
+	for _, t := range []struct {
+		headers    map[string]interface{}
+		applicable bool
+	}{
+		{nil, true},
+		{map[string]interface{}{"series": []interface{}{"16"}}, true},
+		{map[string]interface{}{"series": []interface{}{"18"}}, false},
+		{map[string]interface{}{"architectures": []interface{}{arch.UbuntuArchitecture()}}, true},
+		{map[string]interface{}{"architectures": []interface{}{"other-arch"}}, false},
+		{map[string]interface{}{"models": []interface{}{"my-brand/my-model"}}, true},
+		{map[string]interface{}{"models": []interface{}{"my-brand/other-model"}}, false},
+		{map[string]interface{}{"disabled": "true"}, false},
+	} {
+		r := s.signRepair(c, "1", t.headers, "")
+		c.Check(run.Applicable(r), Equals, t.applicable, Commentf("%v", t.headers))
+	}
+}
+
+func (s *runnerSuite) TestNextAndRun(c *C) {
+	r1 := s.signRepair(c, "1", nil, "#!/bin/sh\necho hello\n")
+	s.publish("1", r1, s.aux...)
+	r2 := s.signRepair(c, "2", map[string]interface{}{"models": []interface{}{"my-brand/other-model"}}, "#!/bin/sh\nexit 0\n")
+	s.publish("2", r2, s.aux...)
+	r3 := s.signRepair(c, "3", nil, "#!/bin/sh\necho failing\nexit 1\n")
+	s.publish("3", r3, s.aux...)
+
+	run := s.newRunner(c)
+
+	rpr, err := run.Next("my-brand")
+	c.Assert(err, IsNil)
+	c.Check(rpr.String(), Equals, "my-brand-1")
+	c.Assert(rpr.Run(), IsNil)
+	c.Check(readFile(c, filepath.Join(rpr.RunDir(), "r0.done")), testutil.Contains, "hello\n")
+
+	// 2 does not apply to this model and is skipped
+	rpr, err = run.Next("my-brand")
+	c.Assert(err, IsNil)
+	c.Check(rpr.String(), Equals, "my-brand-3")
+	c.Assert(rpr.Run(), IsNil)
+	c.Check(readFile(c, filepath.Join(rpr.RunDir(), "r0.retry")), testutil.Contains, "failing\n")
+
+	// 3 is not retried within the same run
+	_, err = run.Next("my-brand")
+	c.Check(err, Equals, repair.ErrRepairNotFound)
+
+	// and each repair was fetched only once
+	c.Check(s.requests, DeepEquals, []string{"/repairs/my-brand/1", "/repairs/my-brand/2", "/repairs/my-brand/3", "/repairs/my-brand/4"})
+
+	c.Assert(run.SaveState(), IsNil)
+	c.Check(readFile(c, dirs.SnapRepairStateFile), testutil.Contains, `"my-brand":[{"sequence":1,"revision":0,"status":"done"},{"sequence":2,"revision":0,"status":"skip"},{"sequence":3,"revision":0,"status":"retry"}]`)
+
+	// 3 is retried by the next run
+	run = s.newRunner(c)
+	rpr, err = run.Next("my-brand")
+	c.Assert(err, IsNil)
+	c.Check(rpr.String(), Equals, "my-brand-3")
+}
+
+func (s *runnerSuite) TestRunReportedStatus(c *C) {
+	// the script can override the status the same way
+	// "snap-repair skip" does, by writing to the status fd
+	script := "#!/bin/sh\necho skip >&$SNAP_REPAIR_STATUS_FD\nexit 1\n"
+	r := s.signRepair(c, "1", nil, script)
+	s.publish("1", r, s.aux...)
+
+	run := s.newRunner(c)
+	rpr, err := run.Next("my-brand")
+	c.Assert(err, IsNil)
+	c.Assert(rpr.Run(), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(rpr.RunDir(), "r0.skip")), Equals, true)
+
+	_, err = run.Next("my-brand")
+	c.Check(err, Equals, repair.ErrRepairNotFound)
+}
+
+func (s *runnerSuite) TestRunStatusFdKeptOpen(c *C) {
+	restore := repair.MockDefaultRepairTimeout(200 * time.Millisecond)
+	defer restore()
+
+	// the background process keeps the status fd open after the
+	// script is done
+	script := "#!/bin/sh\nsleep 10 &\nexit 0\n"
+	r := s.signRepair(c, "1", nil, script)
+	s.publish("1", r, s.aux...)
+
+	run := s.newRunner(c)
+	rpr, err := run.Next("my-brand")
+	c.Assert(err, IsNil)
+
+	start := time.Now()
+	c.Assert(rpr.Run(), IsNil)
+	c.Check(time.Since(start) < 5*time.Second, Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(rpr.RunDir(), "r0.done")), Equals, true)
+}
+
+func (s *runnerSuite) TestRepairStatusJSON(c *C) {
+	for _, status := range []repair.RepairStatus{repair.RetryStatus, repair.SkipStatus, repair.DoneStatus} {
+		b, err := json.Marshal(status)
+		c.Assert(err, IsNil)
+		c.Check(string(b), Equals, `"`+status.String()+`"`)
+
+		var got repair.RepairStatus
+		c.Assert(json.Unmarshal(b, &got), IsNil)
+		c.Check(got, Equals, status)
+	}
+
+	_, err := json.Marshal(repair.RepairStatus(42))
+	c.Check(err, ErrorMatches, ".*cannot marshal invalid repair status 42")
+
+	var got repair.RepairStatus
+	c.Check(json.Unmarshal([]byte(`"bogus"`), &got), ErrorMatches, `cannot unmarshal repair status: unknown status "bogus"`)
+	c.Check(json.Unmarshal([]byte(`2`), &got), ErrorMatches, `cannot unmarshal repair status: .*`)
+}
+
+func readFile(c *C, p string) string {
+	content, err := ioutil.ReadFile(p)
+	c.Assert(err, IsNil)
+	return string(content)
+}
@@ -105,7 +105,14 @@ func run() error {
 			changesMade = append(changesMade, change)
 			continue
 		}
-		if err := change.Perform(); err != nil {
+		synthesised, err := change.Perform()
+		// Record the synthetic changes, made to construct writable mimics,
+		// even if the change itself failed so that they are torn down once
+		// they are no longer needed.
+		for _, synthetic := range synthesised {
+			changesMade = append(changesMade, *synthetic)
+		}
+		if err != nil {
 			logger.Noticef("cannot change mount namespace of snap %q according to change %s: %s", snapName, change, err)
 			continue
 		}
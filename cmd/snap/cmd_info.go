@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
@@ -200,6 +201,27 @@ func maybePrintCommands(w io.Writer, snapName string, allApps []client.AppInfo,
 	}
 }
 
+func maybePrintTimers(w io.Writer, snapName string, allApps []client.AppInfo) {
+	var timers []client.AppInfo
+	for _, app := range allApps {
+		if app.Timer != "" {
+			timers = append(timers, app)
+		}
+	}
+	if len(timers) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "timers:\n")
+	for _, app := range timers {
+		fmt.Fprintf(w, "  %s:\t%s", snap.JoinSnapApp(snapName, app.Name), app.Timer)
+		if app.NextActivation != nil {
+			fmt.Fprintf(w, ", next: %s", app.NextActivation.UTC().Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
 // displayChannels displays channels and tracks in the right order
 func displayChannels(w io.Writer, remote *client.Snap) {
 	// \t\t\t so we get "installed" lined up with "channels"
@@ -279,6 +301,7 @@ func (x *infoCmd) Execute([]string) error {
 		maybePrintType(w, both.Type)
 		maybePrintID(w, both)
 		maybePrintCommands(w, snapName, both.Apps, termWidth)
+		maybePrintTimers(w, snapName, both.Apps)
 
 		if x.Verbose {
 			fmt.Fprintln(w, "notes:\t")
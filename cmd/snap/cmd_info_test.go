@@ -60,3 +60,57 @@ snap-id: mVyGrEwiqSi5PugCwyH7WgpoQLemtTd6
 `)
 	c.Check(s.Stderr(), check.Equals, "")
 }
+
+const localTimerJSON = `
+{
+  "type": "sync",
+  "status-code": 200,
+  "status": "OK",
+  "result": {
+    "confinement": "strict",
+    "description": "GNU hello prints a friendly greeting.",
+    "developer": "canonical",
+    "id": "mVyGrEwiqSi5PugCwyH7WgpoQLemtTd6",
+    "name": "hello",
+    "revision": "1",
+    "status": "active",
+    "summary": "The GNU Hello snap",
+    "type": "app",
+    "version": "2.10",
+    "apps": [
+      {"name": "hello", "daemon": ""},
+      {"name": "backup", "daemon": "oneshot", "timer": "mon-fri@9:00", "next-activation": "2017-02-06T09:00:00Z"}
+    ]
+  }
+}
+`
+
+func (s *SnapSuite) TestInfoTimers(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			fmt.Fprint(w, findPricedJSON)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/hello")
+			fmt.Fprint(w, localTimerJSON)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d (%v)", n+1, r)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"info", "hello"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Matches, `(?ms).*
+commands:
+  - hello
+timers:
+  hello.backup: mon-fri@9:00, next: 2017-02-06T09:00:00Z
+.*`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	shortSetQuotaHelp = i18n.G("Create or update a quota group")
+	longSetQuotaHelp  = i18n.G(`
+The set-quota command creates a quota group with the given resource
+limits and snaps, or updates an existing one.
+
+The services of the snaps in a quota group share its limits: --memory
+caps the memory they can use together, and --cpu the CPU time they
+can use, as a percentage of a single CPU.
+
+When updating an existing group, limits that are not given are kept
+and the given snaps are added to the ones already in the group.
+`)
+	shortQuotasHelp = i18n.G("Show quota groups")
+	longQuotasHelp  = i18n.G(`
+The quotas command shows the quota groups in the system, with their
+limits, their current resource usage and their snaps.
+`)
+	shortRemoveQuotaHelp = i18n.G("Remove a quota group")
+	longRemoveQuotaHelp  = i18n.G(`
+The remove-quota command removes a quota group. The services of its
+snaps are no longer limited by it.
+`)
+)
+
+type cmdSetQuota struct {
+	waitMixin
+	Memory     string `long:"memory"`
+	CPU        string `long:"cpu"`
+	Positional struct {
+		GroupName string              `positional-arg-name:"<group>" required:"yes"`
+		Snaps     []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+// parseCPUQuota parses a CPU limit given as a percentage, with or
+// without the percent sign, e.g. "50%".
+func parseCPUQuota(cpu string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(cpu, "%"))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf(i18n.G("cannot use CPU limit %q: not a positive percentage"), cpu)
+	}
+	return n, nil
+}
+
+func (x *cmdSetQuota) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	limits := &client.QuotaValues{}
+	if x.Memory != "" {
+		memory, err := strutil.ParseByteSize(x.Memory)
+		if err != nil {
+			return err
+		}
+		limits.Memory = uint64(memory)
+	}
+	if x.CPU != "" {
+		cpu, err := parseCPUQuota(x.CPU)
+		if err != nil {
+			return err
+		}
+		limits.CPU = cpu
+	}
+
+	cli := Client()
+	changeID, err := cli.EnsureQuota(x.Positional.GroupName, installedSnapNames(x.Positional.Snaps), limits)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Quota group %q updated.\n"), x.Positional.GroupName)
+	return nil
+}
+
+type cmdQuotas struct{}
+
+func (x *cmdQuotas) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	groups, err := Client().Quotas()
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No quota groups found."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Quota\tMemory\tCPU\tMemory usage\tCPU time\tSnaps"))
+	for _, grp := range groups {
+		memory, cpu := "-", "-"
+		if grp.Constraints != nil && grp.Constraints.Memory != 0 {
+			memory = strutil.SizeToStr(int64(grp.Constraints.Memory))
+		}
+		if grp.Constraints != nil && grp.Constraints.CPU != 0 {
+			cpu = fmt.Sprintf("%d%%", grp.Constraints.CPU)
+		}
+		usage, cpuTime := "-", "-"
+		if grp.Current != nil {
+			usage = strutil.SizeToStr(int64(grp.Current.Memory))
+			cpuTime = fmt.Sprintf("%ds", int64(grp.Current.CPUTime.Seconds()))
+		}
+		snaps := "-"
+		if len(grp.Snaps) > 0 {
+			snaps = strings.Join(grp.Snaps, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", grp.GroupName, memory, cpu, usage, cpuTime, snaps)
+	}
+
+	return nil
+}
+
+type cmdRemoveQuota struct {
+	waitMixin
+	Positional struct {
+		GroupName string `positional-arg-name:"<group>" required:"yes"`
+	} `positional-args:"yes"`
+}
+
+func (x *cmdRemoveQuota) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	changeID, err := cli.RemoveQuota(x.Positional.GroupName)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Quota group %q removed.\n"), x.Positional.GroupName)
+	return nil
+}
+
+func init() {
+	addCommand("set-quota",
+		shortSetQuotaHelp,
+		longSetQuotaHelp,
+		func() flags.Commander {
+			return &cmdSetQuota{}
+		}, waitDescs.also(map[string]string{
+			"memory": i18n.G("Maximum memory the group can use, e.g. 512MB"),
+			"cpu":    i18n.G("Maximum CPU time the group can use, as a percentage of one CPU"),
+		}), []argDesc{
+			{
+				name: "<group>",
+				// TRANSLATORS: This should probably not start with a lowercase letter.
+				desc: i18n.G("Name of the quota group"),
+			}, {
+				name: "<snap>",
+				// TRANSLATORS: This should probably not start with a lowercase letter.
+				desc: i18n.G("Snap whose services are placed in the group"),
+			},
+		})
+
+	addCommand("quotas",
+		shortQuotasHelp,
+		longQuotasHelp,
+		func() flags.Commander {
+			return &cmdQuotas{}
+		}, nil, nil)
+
+	addCommand("remove-quota",
+		shortRemoveQuotaHelp,
+		longRemoveQuotaHelp,
+		func() flags.Commander {
+			return &cmdRemoveQuota{}
+		}, waitDescs, []argDesc{
+			{
+				name: "<group>",
+				// TRANSLATORS: This should probably not start with a lowercase letter.
+				desc: i18n.G("Name of the quota group"),
+			},
+		})
+}
@@ -0,0 +1,116 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestSetQuota(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/quotas":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action":      "ensure",
+				"group-name":  "grp",
+				"snaps":       []interface{}{"foo", "bar"},
+				"constraints": map[string]interface{}{"memory": 512000000.0, "cpu": 50.0},
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		case "/v2/changes/42":
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+		n++
+	})
+	rest, err := Parser().ParseArgs([]string{"set-quota", "--memory=512MB", "--cpu=50%", "grp", "foo", "bar"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, "Quota group \"grp\" updated.\n")
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestSetQuotaInvalidLimits(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := Parser().ParseArgs([]string{"set-quota", "--memory=lots", "grp"})
+	c.Check(err, ErrorMatches, `cannot parse "lots": need a number with a unit as input`)
+	_, err = Parser().ParseArgs([]string{"set-quota", "--cpu=-5%", "grp"})
+	c.Check(err, ErrorMatches, `cannot use CPU limit "-5%": not a positive percentage`)
+}
+
+func (s *SnapSuite) TestQuotas(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/quotas")
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+  {"group-name": "grp", "snaps": ["foo", "bar"], "constraints": {"memory": 512000000}, "current": {"memory": 2048000, "cpu-time": 65000000000}},
+  {"group-name": "other", "constraints": {"cpu": 50}}
+]}`)
+	})
+	rest, err := Parser().ParseArgs([]string{"quotas"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Quota  Memory  CPU  Memory usage  CPU time  Snaps\n"+
+		"grp    512MB   -    2MB           65s       foo,bar\n"+
+		"other  -       50%  -             -         -\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestQuotasNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := Parser().ParseArgs([]string{"quotas"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No quota groups found.\n")
+}
+
+func (s *SnapSuite) TestRemoveQuota(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/quotas":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action":     "remove",
+				"group-name": "grp",
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+		case "/v2/changes/42":
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	_, err := Parser().ParseArgs([]string{"remove-quota", "grp"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Quota group \"grp\" removed.\n")
+}
@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestServices(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/apps")
+		c.Check(r.URL.Query(), DeepEquals, url.Values{"names": []string{"foo"}, "select": []string{"service"}})
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+  {"snap": "foo", "name": "bar", "daemon": "simple", "enabled": true, "active": true},
+  {"snap": "foo", "name": "baz", "daemon": "forking"}
+]}`)
+		n++
+	})
+	rest, err := Parser().ParseArgs([]string{"services", "foo"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Service  Startup   Current\n"+
+		"foo.bar  enabled   active\n"+
+		"foo.baz  disabled  inactive\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 1)
+}
+
+func (s *SnapSuite) TestServicesNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/apps")
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := Parser().ParseArgs([]string{"services"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "There are no services provided by installed snaps.\n")
+}
+
+func (s *SnapSuite) TestLogs(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/logs")
+		c.Check(r.URL.Query(), DeepEquals, url.Values{"names": []string{"foo"}, "n": []string{"-1"}, "follow": []string{"true"}})
+		w.Header().Set("Content-Type", "application/json-seq")
+		fmt.Fprint(w, "\x1e"+`{"timestamp":"2017-08-01T12:00:00Z","message":"hello","sid":"foo.bar","pid":"42"}`+"\n")
+	})
+	_, err := Parser().ParseArgs([]string{"logs", "-n=all", "-f", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "2017-08-01T12:00:00Z foo.bar[42]: hello\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestLogsDefaultN(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query(), DeepEquals, url.Values{"names": []string{"foo.bar"}, "n": []string{"10"}})
+	})
+	_, err := Parser().ParseArgs([]string{"logs", "foo.bar"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *SnapSuite) TestLogsBadN(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := Parser().ParseArgs([]string{"logs", "-n=-2", "foo"})
+	c.Assert(err, ErrorMatches, "invalid argument for flag ‘-n’: expected a non-negative integer argument, or “all”.")
+}
+
+func (s *SnapSuite) TestServiceActions(c *C) {
+	for _, t := range []struct {
+		args     []string
+		expected map[string]interface{}
+		stdout   string
+	}{
+		{[]string{"start", "foo"}, map[string]interface{}{"action": "start", "names": []interface{}{"foo"}}, "Started.\n"},
+		{[]string{"start", "--enable", "foo.bar"}, map[string]interface{}{"action": "start", "names": []interface{}{"foo.bar"}, "enable": true}, "Started.\n"},
+		{[]string{"stop", "--disable", "foo", "baz"}, map[string]interface{}{"action": "stop", "names": []interface{}{"foo", "baz"}, "disable": true}, "Stopped.\n"},
+		{[]string{"restart", "foo"}, map[string]interface{}{"action": "restart", "names": []interface{}{"foo"}}, "Restarted.\n"},
+	} {
+		s.SetUpTest(c)
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/apps":
+				c.Check(r.Method, Equals, "POST")
+				c.Check(DecodedRequestBody(c, r), DeepEquals, t.expected)
+				fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+			case "/v2/changes/42":
+				c.Check(r.Method, Equals, "GET")
+				fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+			default:
+				c.Fatalf("unexpected path %q", r.URL.Path)
+			}
+		})
+		_, err := Parser().ParseArgs(t.args)
+		c.Assert(err, IsNil)
+		c.Check(s.Stdout(), Equals, t.stdout, Commentf("%v", t.args))
+		c.Check(s.Stderr(), Equals, "")
+		s.TearDownTest(c)
+	}
+}
+
+func (s *SnapSuite) TestServiceActionNoWait(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/apps")
+		fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+	})
+	_, err := Parser().ParseArgs([]string{"restart", "--no-wait", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "42\n")
+}
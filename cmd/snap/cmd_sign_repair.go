@@ -0,0 +1,241 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// keypairOptions select the keypair manager and key to sign with:
+// by default the named GnuPG key, or, if a keypair directory is
+// given, the key with the given id stored there.
+type keypairOptions struct {
+	KeyName    keyName `short:"k" default:"default"`
+	KeypairDir string  `long:"keypair-dir"`
+	KeyID      string  `long:"key-id"`
+}
+
+var keypairOptionsHelp = map[string]string{
+	"k":           i18n.G("Name of the GnuPG key to use (defaults to 'default' as key name)"),
+	"keypair-dir": i18n.G("Use the filesystem keypair manager in the given directory instead of GnuPG"),
+	"key-id":      i18n.G("Identifier of the key to use from the keypair directory"),
+}
+
+func (x *keypairOptions) signingKey() (asserts.KeypairManager, asserts.PrivateKey, error) {
+	if x.KeypairDir == "" {
+		gkm := asserts.NewGPGKeypairManager()
+		privKey, err := gkm.GetByName(string(x.KeyName))
+		if err != nil {
+			// TRANSLATORS: %q is the key name, %v the error message
+			return nil, nil, fmt.Errorf(i18n.G("cannot use %q key: %v"), x.KeyName, err)
+		}
+		return gkm, privKey, nil
+	}
+
+	if x.KeyID == "" {
+		return nil, nil, fmt.Errorf(i18n.G("cannot use a keypair directory without --key-id"))
+	}
+	fskm, err := asserts.OpenFSKeypairManager(x.KeypairDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	privKey, err := fskm.Get(x.KeyID)
+	if err != nil {
+		// TRANSLATORS: %q is the key id, %v the error message
+		return nil, nil, fmt.Errorf(i18n.G("cannot use %q key: %v"), x.KeyID, err)
+	}
+	return fskm, privKey, nil
+}
+
+type cmdSignRepair struct {
+	Positional struct {
+		Script string
+	} `positional-args:"yes" required:"yes"`
+
+	BrandID       string   `long:"brand-id" required:"yes"`
+	RepairID      string   `long:"repair-id"`
+	Summary       string   `long:"summary"`
+	Series        []string `long:"series"`
+	Architectures []string `long:"arch"`
+	Models        []string `long:"model"`
+	Disabled      bool     `long:"disabled"`
+	StoreDir      string   `long:"store-dir"`
+
+	keypairOptions
+}
+
+var shortSignRepairHelp = i18n.G("Create a repair assertion")
+var longSignRepairHelp = i18n.G(`
+The sign-repair command creates and signs a repair assertion running the
+given script on the devices it targets.
+
+Unless a repair-id is given to issue a new revision of an existing repair,
+the next free repair-id of the brand is assigned. Issued repairs are
+recorded in the store directory (by default ~/.snap/repairs).
+`)
+
+type cmdVerifyRepair struct {
+	Positional struct {
+		Filename string
+	} `positional-args:"yes" required:"yes"`
+
+	AccountKey string `long:"account-key" required:"yes"`
+}
+
+var shortVerifyRepairHelp = i18n.G("Verify and show a repair assertion")
+var longVerifyRepairHelp = i18n.G(`
+The verify-repair command checks the signature of the given repair
+assertion against the public key in the given account-key assertion of
+the signing key, and shows the repair headers and script.
+`)
+
+func init() {
+	signOpts := map[string]string{
+		"brand-id":  i18n.G("Identifier of the brand issuing the repair"),
+		"repair-id": i18n.G("Issue a new revision of the repair with this identifier"),
+		"summary":   i18n.G("Short summary of what the repair does"),
+		"series":    i18n.G("Restrict the repair to this series (can be repeated)"),
+		"arch":      i18n.G("Restrict the repair to this architecture (can be repeated)"),
+		"model":     i18n.G("Restrict the repair to this brand-id/model (can be repeated)"),
+		"disabled":  i18n.G("Issue the repair as disabled"),
+		"store-dir": i18n.G("Directory recording the issued repairs"),
+	}
+	for k, v := range keypairOptionsHelp {
+		signOpts[k] = v
+	}
+	cmd := addCommand("sign-repair", shortSignRepairHelp, longSignRepairHelp, func() flags.Commander {
+		return &cmdSignRepair{}
+	}, signOpts, []argDesc{{
+		name: i18n.G("<script>"),
+		desc: i18n.G("Filename of the repair script"),
+	}})
+	cmd.hidden = true
+
+	cmd = addCommand("verify-repair", shortVerifyRepairHelp, longVerifyRepairHelp, func() flags.Commander {
+		return &cmdVerifyRepair{}
+	}, map[string]string{
+		"account-key": i18n.G("Filename of the account-key assertion of the signing key"),
+	}, []argDesc{{
+		name: i18n.G("<filename>"),
+		desc: i18n.G("Filename of the repair assertion"),
+	}})
+	cmd.hidden = true
+}
+
+func defaultRepairStoreDir() (string, error) {
+	real, err := osutil.RealUser()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(real.HomeDir, ".snap", "repairs"), nil
+}
+
+func (x *cmdSignRepair) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	script, err := ioutil.ReadFile(x.Positional.Script)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read repair script: %v"), err)
+	}
+
+	keypairMgr, privKey, err := x.signingKey()
+	if err != nil {
+		return err
+	}
+
+	storeDir := x.StoreDir
+	if storeDir == "" {
+		storeDir, err = defaultRepairStoreDir()
+		if err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(storeDir, 0700); err != nil {
+		return err
+	}
+	store, err := signtool.OpenRepairStore(storeDir)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot open the repairs store: %v"), err)
+	}
+
+	opts := signtool.RepairOptions{
+		KeyID:         privKey.PublicKey().ID(),
+		BrandID:       x.BrandID,
+		RepairID:      x.RepairID,
+		Summary:       x.Summary,
+		Series:        x.Series,
+		Architectures: x.Architectures,
+		Models:        x.Models,
+		Disabled:      x.Disabled,
+		Script:        script,
+	}
+	repair, err := signtool.SignRepair(&opts, store, keypairMgr)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot sign repair: %v"), err)
+	}
+
+	_, err = Stdout.Write(asserts.Encode(repair))
+	return err
+}
+
+func (x *cmdVerifyRepair) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	encoded, err := ioutil.ReadFile(x.Positional.Filename)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read repair: %v"), err)
+	}
+
+	encodedAccKey, err := ioutil.ReadFile(x.AccountKey)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read account-key: %v"), err)
+	}
+	a, err := asserts.Decode(encodedAccKey)
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot decode account-key: %v"), err)
+	}
+	accKey, ok := a.(*asserts.AccountKey)
+	if !ok {
+		// TRANSLATORS: %q is the assertion type
+		return fmt.Errorf(i18n.G("cannot use %q assertion as account-key"), a.Type().Name)
+	}
+
+	repair, err := signtool.VerifyRepair(encoded, accKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = Stdout.Write(signtool.FormatRepair(repair))
+	return err
+}
@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+type SnapSignRepairSuite struct {
+	BaseSnapSuite
+
+	keypairDir     string
+	keyID          string
+	accountKeyFile string
+	storeDir       string
+	script         string
+}
+
+var _ = Suite(&SnapSignRepairSuite{})
+
+func (s *SnapSignRepairSuite) SetUpTest(c *C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	tmpdir := c.MkDir()
+	s.keypairDir = filepath.Join(tmpdir, "keys")
+	fskm, err := asserts.OpenFSKeypairManager(s.keypairDir)
+	c.Assert(err, IsNil)
+	privKey, _ := assertstest.GenerateKey(752)
+	c.Assert(fskm.Put(privKey), IsNil)
+	s.keyID = privKey.PublicKey().ID()
+
+	rootPrivKey, _ := assertstest.GenerateKey(1024)
+	storePrivKey, _ := assertstest.GenerateKey(752)
+	storeSigning := assertstest.NewStoreStack("can0nical", rootPrivKey, storePrivKey)
+	brandAcct := assertstest.NewAccount(storeSigning, "my-brand", map[string]interface{}{
+		"account-id": "my-brand",
+	}, "")
+	brandAcctKey := assertstest.NewAccountKey(storeSigning, brandAcct, map[string]interface{}{
+		"since": "2017-01-01T00:00:00Z",
+	}, privKey.PublicKey(), "")
+	s.accountKeyFile = filepath.Join(tmpdir, "account-key")
+	c.Assert(ioutil.WriteFile(s.accountKeyFile, asserts.Encode(brandAcctKey), 0644), IsNil)
+
+	s.storeDir = filepath.Join(tmpdir, "repairs")
+	s.script = filepath.Join(tmpdir, "script")
+	c.Assert(ioutil.WriteFile(s.script, []byte("#!/bin/sh\necho fixing\n"), 0644), IsNil)
+}
+
+func (s *SnapSignRepairSuite) signRepair(c *C, extra ...string) asserts.Assertion {
+	s.ResetStdStreams()
+	args := append([]string{"sign-repair", "--brand-id", "my-brand", "--keypair-dir", s.keypairDir, "--key-id=" + s.keyID, "--store-dir", s.storeDir}, extra...)
+	_, err := snap.Parser().ParseArgs(append(args, s.script))
+	c.Assert(err, IsNil)
+	a, err := asserts.Decode([]byte(s.Stdout()))
+	c.Assert(err, IsNil)
+	return a
+}
+
+func (s *SnapSignRepairSuite) TestSignRepairMandatoryFlags(c *C) {
+	_, err := snap.Parser().ParseArgs([]string{"sign-repair", s.script})
+	c.Assert(err, NotNil)
+	c.Check(err.Error(), Equals, "the required flag `--brand-id' was not specified")
+}
+
+func (s *SnapSignRepairSuite) TestSignRepairMissingKeyID(c *C) {
+	_, err := snap.Parser().ParseArgs([]string{"sign-repair", "--brand-id", "my-brand", "--keypair-dir", s.keypairDir, s.script})
+	c.Assert(err, ErrorMatches, "cannot use a keypair directory without --key-id")
+}
+
+func (s *SnapSignRepairSuite) TestSignRepairWorks(c *C) {
+	a := s.signRepair(c, "--series", "16", "--arch", "amd64", "--arch", "arm64", "--model", "my-brand/my-model", "--summary", "fix it")
+	c.Assert(a.Type(), Equals, asserts.RepairType)
+	repair := a.(*asserts.Repair)
+	c.Check(repair.BrandID(), Equals, "my-brand")
+	c.Check(repair.RepairID(), Equals, "1")
+	c.Check(repair.Series(), DeepEquals, []string{"16"})
+	c.Check(repair.Architectures(), DeepEquals, []string{"amd64", "arm64"})
+	c.Check(repair.Models(), DeepEquals, []string{"my-brand/my-model"})
+	c.Check(repair.HeaderString("summary"), Equals, "fix it")
+	c.Check(repair.SignKeyID(), Equals, s.keyID)
+	c.Check(string(repair.Body()), Equals, "#!/bin/sh\necho fixing\n")
+
+	// the next one gets a new repair-id
+	a = s.signRepair(c)
+	c.Check(a.(*asserts.Repair).RepairID(), Equals, "2")
+
+	// or a new revision of an existing one
+	a = s.signRepair(c, "--repair-id", "1", "--disabled")
+	c.Check(a.(*asserts.Repair).RepairID(), Equals, "1")
+	c.Check(a.Revision(), Equals, 1)
+	c.Check(a.(*asserts.Repair).Disabled(), Equals, true)
+}
+
+func (s *SnapSignRepairSuite) TestVerifyRepairWorks(c *C) {
+	s.signRepair(c, "--series", "16", "--summary", "fix it")
+	repairFile := filepath.Join(c.MkDir(), "repair")
+	c.Assert(ioutil.WriteFile(repairFile, []byte(s.Stdout()), 0644), IsNil)
+
+	// only the public side of the key is needed
+	c.Assert(os.RemoveAll(s.keypairDir), IsNil)
+
+	s.ResetStdStreams()
+	_, err := snap.Parser().ParseArgs([]string{"verify-repair", "--account-key", s.accountKeyFile, repairFile})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `(?s)repair: my-brand-1
+revision: 0
+summary: fix it
+timestamp: .*
+disabled: false
+series: 16
+architectures: all
+models: all
+sign-key-sha3-384: `+s.keyID+`
+script: \|
+  #!/bin/sh
+  echo fixing
+`)
+}
+
+func (s *SnapSignRepairSuite) TestVerifyRepairNotAccountKey(c *C) {
+	s.signRepair(c)
+	repairFile := filepath.Join(c.MkDir(), "repair")
+	c.Assert(ioutil.WriteFile(repairFile, []byte(s.Stdout()), 0644), IsNil)
+
+	_, err := snap.Parser().ParseArgs([]string{"verify-repair", "--account-key", repairFile, repairFile})
+	c.Assert(err, ErrorMatches, `cannot use "repair" assertion as account-key`)
+}
@@ -26,6 +26,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -170,6 +171,11 @@ removed.
 
 var longRefreshHelp = i18n.G(`
 The refresh command refreshes (updates) the named snap.
+
+With --hold=<duration>, automatic refreshes of the named snaps, or of all
+snaps if none is named, are held for the given duration (e.g. 12h or 3d)
+instead; a duration of 0 releases the hold. Holds are limited to 60 days
+after the last automatic refresh, and do not prevent manual refreshes.
 `)
 
 var longTryHelp = i18n.G(`
@@ -579,6 +585,7 @@ type cmdRefresh struct {
 	Revision         string `long:"revision"`
 	List             bool   `long:"list"`
 	Time             bool   `long:"time"`
+	Hold             string `long:"hold"`
 	IgnoreValidation bool   `long:"ignore-validation"`
 	Positional       struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
@@ -647,6 +654,71 @@ func (x *cmdRefresh) showRefreshTimes() error {
 	fmt.Fprintf(Stdout, "schedule: %s\n", sysinfo.Refresh.Schedule)
 	fmt.Fprintf(Stdout, "last: %s\n", sysinfo.Refresh.Last)
 	fmt.Fprintf(Stdout, "next: %s\n", sysinfo.Refresh.Next)
+	if sysinfo.Refresh.Hold != "" {
+		fmt.Fprintf(Stdout, "hold: %s\n", sysinfo.Refresh.Hold)
+	}
+	if len(sysinfo.Refresh.Held) > 0 {
+		names := make([]string, 0, len(sysinfo.Refresh.Held))
+		for name := range sysinfo.Refresh.Held {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(Stdout, "held:\n")
+		for _, name := range names {
+			fmt.Fprintf(Stdout, "  %s: %s\n", name, sysinfo.Refresh.Held[name])
+		}
+	}
+	return nil
+}
+
+// parseHoldDuration parses a duration as understood by
+// time.ParseDuration, additionally accepting a whole number of days
+// such as "3d".
+func parseHoldDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseUint(strings.TrimSuffix(s, "d"), 10, 32)
+		if err == nil {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf(i18n.G("invalid hold duration %q"), s)
+	}
+	return d, nil
+}
+
+func (x *cmdRefresh) holdRefreshes(snaps []string) error {
+	duration, err := parseHoldDuration(x.Hold)
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	changeID, err := cli.HoldRefreshes(snaps, duration)
+	if err != nil {
+		return err
+	}
+
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	what := i18n.G("all snaps")
+	if len(snaps) > 0 {
+		what = strings.Join(snaps, ", ")
+	}
+	if duration == 0 {
+		// TRANSLATORS: the %s is a list of snap names, or "all snaps"
+		fmt.Fprintf(Stdout, i18n.G("Released hold on refreshes of %s.\n"), what)
+	} else {
+		// TRANSLATORS: the first %s is a list of snap names, or "all snaps"
+		fmt.Fprintf(Stdout, i18n.G("Refreshes of %s held for %s.\n"), what, duration)
+	}
+
 	return nil
 }
 
@@ -700,15 +772,24 @@ func (x *cmdRefresh) Execute([]string) error {
 		return x.listRefresh()
 	}
 
+	names := make([]string, len(x.Positional.Snaps))
+	for i, name := range x.Positional.Snaps {
+		names[i] = string(name)
+	}
+
+	if x.Hold != "" {
+		if x.asksForMode() || x.asksForChannel() || x.Revision != "" || x.IgnoreValidation {
+			return errors.New(i18n.G("--hold does not take mode, channel, revision nor validation flags"))
+		}
+
+		return x.holdRefreshes(names)
+	}
+
 	if len(x.Positional.Snaps) == 0 && os.Getenv("SNAP_REFRESH_FROM_TIMER") == "1" {
 		fmt.Fprintf(Stdout, "Ignoring `snap refresh` from the systemd timer")
 		return nil
 	}
 
-	names := make([]string, len(x.Positional.Snaps))
-	for i, name := range x.Positional.Snaps {
-		names[i] = string(name)
-	}
 	if len(x.Positional.Snaps) == 1 {
 		opts := &client.SnapOptions{
 			Channel:          x.Channel,
@@ -940,6 +1021,53 @@ func (x *cmdRevert) Execute(args []string) error {
 	return nil
 }
 
+type cmdSwitch struct {
+	waitMixin
+	channelMixin
+
+	Positional struct {
+		Snap installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortSwitchHelp = i18n.G("Switches snap to a different channel")
+var longSwitchHelp = i18n.G(`
+The switch command switches the given snap to a different channel without
+doing a refresh. The next refresh of the snap, automatic or manual, will
+then come from the new channel.
+`)
+
+func (x *cmdSwitch) Execute(args []string) error {
+	if err := x.setChannelFromCommandline(); err != nil {
+		return err
+	}
+	if x.Channel == "" {
+		return fmt.Errorf(i18n.G("missing --channel=<channel-name> parameter"))
+	}
+
+	cli := Client()
+	name := string(x.Positional.Snap)
+	channel := x.Channel
+	opts := &client.SnapOptions{
+		Channel: channel,
+	}
+	changeID, err := cli.Switch(name, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = x.wait(cli, changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("%q switched to the %q channel\n"), name, channel)
+	return nil
+}
+
 func init() {
 	addCommand("remove", shortRemoveHelp, longRemoveHelp, func() flags.Commander { return &cmdRemove{} },
 		waitDescs.also(map[string]string{"revision": i18n.G("Remove only the given revision")}), nil)
@@ -955,6 +1083,7 @@ func init() {
 			"revision":          i18n.G("Refresh to the given revision"),
 			"list":              i18n.G("Show available snaps for refresh but do not perform a refresh"),
 			"time":              i18n.G("Show auto refresh information but do not perform a refresh"),
+			"hold":              i18n.G("Hold automatic refreshes for the given duration instead of refreshing"),
 			"ignore-validation": i18n.G("Ignore validation by other snaps blocking the refresh"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
@@ -963,4 +1092,11 @@ func init() {
 	addCommand("revert", shortRevertHelp, longRevertHelp, func() flags.Commander { return &cmdRevert{} }, waitDescs.also(modeDescs).also(map[string]string{
 		"revision": "Revert to the given revision",
 	}), nil)
+	addCommand("switch", shortSwitchHelp, longSwitchHelp, func() flags.Commander { return &cmdSwitch{} }, waitDescs.also(map[string]string{
+		"channel":   i18n.G("Switch to the given channel"),
+		"beta":      i18n.G("Switch to the beta channel"),
+		"edge":      i18n.G("Switch to the edge channel"),
+		"candidate": i18n.G("Switch to the candidate channel"),
+		"stable":    i18n.G("Switch to the stable channel"),
+	}), nil)
 }
@@ -512,6 +512,53 @@ func (s *SnapOpSuite) TestRevertMissingName(c *check.C) {
 	c.Assert(err, check.ErrorMatches, "the required argument `<snap>` was not provided")
 }
 
+func (s *SnapOpSuite) TestSwitch(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":  "switch",
+			"channel": "beta",
+		})
+	}
+	s.srv.total = 3
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"switch", "--beta", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `"foo" switched to the "beta" channel`+"\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestSwitchTrack(c *check.C) {
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":  "switch",
+			"channel": "3.4/stable",
+		})
+	}
+	s.srv.total = 3
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	_, err := snap.Parser().ParseArgs([]string{"switch", "--channel=3.4", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `"foo" switched to the "3.4/stable" channel`+"\n")
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestSwitchNoChannel(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"switch", "foo"})
+	c.Assert(err, check.ErrorMatches, `missing --channel=<channel-name> parameter`)
+}
+
+func (s *SnapOpSuite) TestSwitchMissingName(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"switch", "--beta"})
+	c.Assert(err, check.ErrorMatches, "the required argument `<snap>` was not provided")
+}
+
 func (s *SnapSuite) TestRefreshList(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
@@ -564,6 +611,73 @@ next: 2017-04-26 00:58:00 +0200 CEST
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshTimeHeld(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"schedule": "00:00-23:59", "last": "n/a", "next": "2017-04-26 00:58:00 +0200 CEST", "hold": "2017-04-28 00:58:00 +0200 CEST", "held": {"foo": "2017-05-01 10:00:00 +0200 CEST", "bar": "2017-04-30 10:00:00 +0200 CEST"}}}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--time"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `schedule: 00:00-23:59
+last: n/a
+next: 2017-04-26 00:58:00 +0200 CEST
+hold: 2017-04-28 00:58:00 +0200 CEST
+held:
+  bar: 2017-04-30 10:00:00 +0200 CEST
+  foo: 2017-05-01 10:00:00 +0200 CEST
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestRefreshHold(c *check.C) {
+	for _, t := range []struct {
+		args     []string
+		expected map[string]interface{}
+		stdout   string
+	}{
+		{
+			args:     []string{"refresh", "--hold=3d"},
+			expected: map[string]interface{}{"action": "hold", "duration": "72h0m0s"},
+			stdout:   "Refreshes of all snaps held for 72h0m0s.\n",
+		}, {
+			args:     []string{"refresh", "--hold=90m", "foo", "bar"},
+			expected: map[string]interface{}{"action": "hold", "duration": "1h30m0s", "snaps": []interface{}{"foo", "bar"}},
+			stdout:   "Refreshes of foo, bar held for 1h30m0s.\n",
+		}, {
+			args:     []string{"refresh", "--hold=0", "foo"},
+			expected: map[string]interface{}{"action": "hold", "duration": "0s", "snaps": []interface{}{"foo"}},
+			stdout:   "Released hold on refreshes of foo.\n",
+		},
+	} {
+		s.stdout.Reset()
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/snaps":
+				c.Check(r.Method, check.Equals, "POST")
+				c.Check(DecodedRequestBody(c, r), check.DeepEquals, t.expected)
+				fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+			case "/v2/changes/42":
+				fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+			default:
+				c.Fatalf("unexpected path %q", r.URL.Path)
+			}
+		})
+		_, err := snap.Parser().ParseArgs(t.args)
+		c.Assert(err, check.IsNil)
+		c.Check(s.Stdout(), check.Equals, t.stdout)
+	}
+}
+
+func (s *SnapSuite) TestRefreshHoldErrors(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--hold=forever"})
+	c.Check(err, check.ErrorMatches, `invalid hold duration "forever"`)
+	_, err = snap.Parser().ParseArgs([]string{"refresh", "--hold=-1h"})
+	c.Check(err, check.ErrorMatches, `invalid hold duration "-1h"`)
+	_, err = snap.Parser().ParseArgs([]string{"refresh", "--hold=1h", "--beta", "foo"})
+	c.Check(err, check.ErrorMatches, "--hold does not take .* flags")
+}
+
 func (s *SnapSuite) TestRefreshListErr(c *check.C) {
 	s.RedirectClientToTestServer(nil)
 	_, err := snap.Parser().ParseArgs([]string{"refresh", "--list", "--beta"})
@@ -993,6 +1107,7 @@ func (s *SnapOpSuite) TestNoWait(c *check.C) {
 		{"refresh", "--no-wait", "foo", "bar"},
 		{"enable", "--no-wait", "foo"},
 		{"disable", "--no-wait", "foo"},
+		{"switch", "--no-wait", "--beta", "foo"},
 		{"try", "--no-wait", "."},
 	}
 
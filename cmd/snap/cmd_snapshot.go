@@ -0,0 +1,293 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	shortSavedHelp = i18n.G("List currently stored snapshots")
+	longSavedHelp  = i18n.G(`
+The saved command displays a list of snapshots that have been created
+previously with the 'save' command.
+`)
+	shortSaveHelp = i18n.G("Save a snapshot of the current data")
+	longSaveHelp  = i18n.G(`
+The save command saves a snapshot of the current user, system and
+configuration data for one or more snaps.
+
+If no snaps are given, all installed and active snaps are saved. The
+--users option restricts the user data that is saved to that of the
+given users.
+`)
+	shortForgetHelp = i18n.G("Delete a snapshot")
+	longForgetHelp  = i18n.G(`
+The forget command deletes a snapshot. This operation can not be
+undone.
+
+A snapshot contains archives for the user, system and configuration
+data of each snap included in the snapshot. By default, this command
+forgets all the data in a snapshot. Alternatively, you can specify
+the data of which snaps to forget.
+`)
+	shortRestoreHelp = i18n.G("Restore a snapshot")
+	longRestoreHelp  = i18n.G(`
+The restore command replaces the current user, system and
+configuration data of included snaps, with the corresponding data from
+the given snapshot.
+
+By default, the command restores all the data in a snapshot.
+Alternatively, you can specify the data of which snaps to restore, or
+for which users, or a combination of these.
+
+If a snap is included in a restore operation, its system and
+configuration data are restored along with the data of the users
+being restored. If the restore fails, whatever was restored for that
+snap is reverted.
+`)
+)
+
+type savedCmd struct {
+	ID         uint64 `long:"id"`
+	Positional struct {
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+// fmtAge formats the time elapsed since the given time in the largest
+// unit that makes sense, e.g. "3h" or "2d".
+func fmtAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+func (x *savedCmd) Execute([]string) error {
+	list, err := Client().SnapshotSets(x.ID, installedSnapNames(x.Positional.Snaps))
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No snapshots found."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	// TRANSLATORS: 'Set' as in group or bag of things, and 'Rev' as in revision
+	fmt.Fprintln(w, i18n.G("Set\tSnap\tAge\tVersion\tRev\tSize"))
+	for _, sg := range list {
+		for _, sh := range sg.Snapshots {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				sg.ID,
+				sh.Snap,
+				fmtAge(sh.Time),
+				sh.Version,
+				sh.Revision,
+				strutil.SizeToStr(sh.Size),
+			)
+		}
+	}
+
+	return nil
+}
+
+type saveCmd struct {
+	waitMixin
+	Users      string `long:"users"`
+	Positional struct {
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+func (x *saveCmd) Execute([]string) error {
+	cli := Client()
+	setID, changeID, err := cli.SnapshotMany(installedSnapNames(x.Positional.Snaps), splitUsers(x.Users))
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	y := &savedCmd{ID: setID}
+	return y.Execute(nil)
+}
+
+type forgetCmd struct {
+	waitMixin
+	Positional struct {
+		ID    uint64              `positional-arg-name:"<id>"`
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *forgetCmd) Execute([]string) error {
+	cli := Client()
+	snaps := installedSnapNames(x.Positional.Snaps)
+	changeID, err := cli.ForgetSnapshots(x.Positional.ID, snaps)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	if len(snaps) > 0 {
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		fmt.Fprintf(Stdout, i18n.G("Snapshot #%d of snaps %s forgotten.\n"), x.Positional.ID, strutil.Quoted(snaps))
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("Snapshot #%d forgotten.\n"), x.Positional.ID)
+	}
+	return nil
+}
+
+type restoreCmd struct {
+	waitMixin
+	Users      string `long:"users"`
+	Positional struct {
+		ID    uint64              `positional-arg-name:"<id>"`
+		Snaps []installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *restoreCmd) Execute([]string) error {
+	cli := Client()
+	snaps := installedSnapNames(x.Positional.Snaps)
+	users := splitUsers(x.Users)
+	changeID, err := cli.RestoreSnapshots(x.Positional.ID, snaps, users)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, changeID); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	if len(snaps) > 0 {
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		fmt.Fprintf(Stdout, i18n.G("Restored snapshot #%d of snaps %s.\n"), x.Positional.ID, strutil.Quoted(snaps))
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("Restored snapshot #%d.\n"), x.Positional.ID)
+	}
+	return nil
+}
+
+// splitUsers splits the comma-separated list of users given to
+// --users, ignoring empty entries.
+func splitUsers(users string) []string {
+	var split []string
+	for _, user := range strings.Split(users, ",") {
+		if user = strings.TrimSpace(user); user != "" {
+			split = append(split, user)
+		}
+	}
+	return split
+}
+
+func installedSnapNames(snaps []installedSnapName) []string {
+	names := make([]string, len(snaps))
+	for i, name := range snaps {
+		names[i] = string(name)
+	}
+	return names
+}
+
+func init() {
+	addCommand("saved",
+		shortSavedHelp,
+		longSavedHelp,
+		func() flags.Commander {
+			return &savedCmd{}
+		},
+		map[string]string{
+			"id": i18n.G("Show only a specific snapshot."),
+		},
+		nil)
+
+	addCommand("save",
+		shortSaveHelp,
+		longSaveHelp,
+		func() flags.Commander {
+			return &saveCmd{}
+		}, waitDescs.also(map[string]string{
+			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+		}), nil)
+
+	addCommand("restore",
+		shortRestoreHelp,
+		longRestoreHelp,
+		func() flags.Commander {
+			return &restoreCmd{}
+		}, waitDescs.also(map[string]string{
+			"users": i18n.G("Restore data of only specific users (comma-separated) (default: all users)"),
+		}), []argDesc{
+			{
+				name: "<id>",
+				// TRANSLATORS: This should probably not start with a lowercase letter.
+				desc: i18n.G("Set id of snapshot to restore (see 'snap help saved')"),
+			}, {
+				name: "<snap>",
+				// TRANSLATORS: This should probably not start with a lowercase letter.
+				desc: i18n.G("The snap for which data will be restored"),
+			},
+		})
+
+	addCommand("forget",
+		shortForgetHelp,
+		longForgetHelp,
+		func() flags.Commander {
+			return &forgetCmd{}
+		}, waitDescs, []argDesc{
+			{
+				name: "<id>",
+				// TRANSLATORS: This should probably not start with a lowercase letter.
+				desc: i18n.G("Set id of snapshot to delete (see 'snap help saved')"),
+			}, {
+				name: "<snap>",
+				// TRANSLATORS: This should probably not start with a lowercase letter.
+				desc: i18n.G("The snap for which data will be deleted"),
+			},
+		})
+}
@@ -0,0 +1,144 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/cmd/snap"
+)
+
+func snapshotSetsJSON(t time.Time) string {
+	return fmt.Sprintf(`{"type": "sync", "result": [
+  {"id": 1, "snapshots": [
+    {"set": 1, "time": %q, "snap": "foo", "revision": "7", "version": "1.0", "sha3-384": {}, "size": 2048},
+    {"set": 1, "time": %q, "snap": "bar", "revision": "x1", "version": "2.0", "sha3-384": {}, "size": 100}
+  ]}
+]}`, t.Format(time.RFC3339), t.Format(time.RFC3339))
+}
+
+func (s *SnapSuite) TestSaved(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/snapshots")
+		c.Check(r.URL.Query(), DeepEquals, url.Values{"snaps": []string{"foo,bar"}})
+		fmt.Fprintln(w, snapshotSetsJSON(time.Now().Add(-3*time.Hour-time.Minute)))
+	})
+	rest, err := Parser().ParseArgs([]string{"saved", "foo", "bar"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Set  Snap  Age  Version  Rev  Size\n"+
+		"1    foo   3h   1.0      7    2kB\n"+
+		"1    bar   3h   2.0      x1   100B\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestSavedNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query(), DeepEquals, url.Values{"set": []string{"42"}})
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := Parser().ParseArgs([]string{"saved", "--id=42"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No snapshots found.\n")
+}
+
+func (s *SnapSuite) TestSave(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "snapshot",
+				"snaps":  []interface{}{"foo"},
+				"users":  []interface{}{"alice", "bob"},
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "result": {"set-id": 1}, "change": "42"}`)
+		case "/v2/changes/42":
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		case "/v2/snapshots":
+			c.Check(r.URL.Query(), DeepEquals, url.Values{"set": []string{"1"}})
+			fmt.Fprintln(w, snapshotSetsJSON(time.Now().Add(-30*time.Second)))
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+		n++
+	})
+	_, err := Parser().ParseArgs([]string{"save", "--users=alice,bob", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Matches, `(?s)Set  Snap  Age  Version  Rev  Size\n1    foo   \d+s .*`)
+	c.Check(n, Equals, 3)
+}
+
+func (s *SnapSuite) TestSaveNoWait(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{"action": "snapshot"})
+		fmt.Fprintln(w, `{"type":"async", "status-code": 202, "result": {"set-id": 1}, "change": "42"}`)
+	})
+	_, err := Parser().ParseArgs([]string{"save", "--no-wait"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "42\n")
+}
+
+func (s *SnapSuite) TestRestoreAndForget(c *C) {
+	for _, t := range []struct {
+		args     []string
+		expected map[string]interface{}
+		stdout   string
+	}{
+		{[]string{"restore", "3"}, map[string]interface{}{"action": "restore", "set": 3.}, "Restored snapshot #3.\n"},
+		{[]string{"restore", "--users=bob", "3", "foo", "bar"}, map[string]interface{}{"action": "restore", "set": 3., "snaps": []interface{}{"foo", "bar"}, "users": []interface{}{"bob"}}, `Restored snapshot #3 of snaps "foo", "bar".` + "\n"},
+		{[]string{"forget", "3"}, map[string]interface{}{"action": "forget", "set": 3.}, "Snapshot #3 forgotten.\n"},
+		{[]string{"forget", "3", "foo"}, map[string]interface{}{"action": "forget", "set": 3., "snaps": []interface{}{"foo"}}, `Snapshot #3 of snaps "foo" forgotten.` + "\n"},
+	} {
+		s.SetUpTest(c)
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/snapshots":
+				c.Check(r.Method, Equals, "POST")
+				c.Check(DecodedRequestBody(c, r), DeepEquals, t.expected)
+				fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "42"}`)
+			case "/v2/changes/42":
+				fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+			default:
+				c.Fatalf("unexpected path %q", r.URL.Path)
+			}
+		})
+		_, err := Parser().ParseArgs(t.args)
+		c.Assert(err, IsNil)
+		c.Check(s.Stdout(), Equals, t.stdout, Commentf("%v", t.args))
+		c.Check(s.Stderr(), Equals, "")
+		s.TearDownTest(c)
+	}
+}
+
+func (s *SnapSuite) TestRestoreNeedsID(c *C) {
+	_, err := Parser().ParseArgs([]string{"restore"})
+	c.Assert(err, ErrorMatches, `the required argument .* was not provided`)
+}
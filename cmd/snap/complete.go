@@ -29,6 +29,33 @@ func (s installedSnapName) Complete(match string) []flags.Completion {
 	return ret
 }
 
+type serviceName string
+
+func (s serviceName) Complete(match string) []flags.Completion {
+	cli := Client()
+	apps, err := cli.Apps(nil, client.AppOptions{Service: true})
+	if err != nil {
+		return nil
+	}
+
+	snaps := map[string]bool{}
+	var ret []flags.Completion
+	for _, app := range apps {
+		if !snaps[app.Snap] {
+			snaps[app.Snap] = true
+			if strings.HasPrefix(app.Snap, match) {
+				ret = append(ret, flags.Completion{Item: app.Snap})
+			}
+		}
+		name := app.Snap + "." + app.Name
+		if strings.HasPrefix(name, match) {
+			ret = append(ret, flags.Completion{Item: name})
+		}
+	}
+
+	return ret
+}
+
 type remoteSnapName string
 
 func (s remoteSnapName) Complete(match string) []flags.Completion {
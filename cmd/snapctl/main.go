@@ -39,6 +39,12 @@ var clientConfig = client.Config{
 
 func main() {
 	stdout, stderr, err := run()
+	if e, ok := err.(*client.UnsuccessfulError); ok {
+		// the command itself ran fine, only the exit code is relevant
+		os.Stdout.Write(stdout)
+		os.Stderr.Write(stderr)
+		os.Exit(e.ExitCode)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %s\n", err)
 		os.Exit(1)
@@ -41,6 +41,7 @@ type snapctlSuite struct {
 	oldArgs           []string
 	expectedContextID string
 	expectedArgs      []string
+	rsp               string
 }
 
 var _ = Suite(&snapctlSuite{})
@@ -61,7 +62,7 @@ func (s *snapctlSuite) SetUpTest(c *C) {
 			c.Assert(snapctlOptions.ContextID, Equals, s.expectedContextID)
 			c.Assert(snapctlOptions.Args, DeepEquals, s.expectedArgs)
 
-			fmt.Fprintln(w, `{"type": "sync", "result": {"stdout": "test stdout", "stderr": "test stderr"}}`)
+			fmt.Fprintln(w, s.rsp)
 		default:
 			c.Fatalf("expected to get 1 request, now on %d", n+1)
 		}
@@ -73,6 +74,7 @@ func (s *snapctlSuite) SetUpTest(c *C) {
 	os.Args = []string{"snapctl"}
 	s.expectedContextID = "snap-context-test"
 	s.expectedArgs = []string{}
+	s.rsp = `{"type": "sync", "result": {"stdout": "test stdout", "stderr": "test stderr"}}`
 
 	fakeAuthPath := filepath.Join(c.MkDir(), "auth.json")
 	os.Setenv("SNAPD_AUTH_DATA_FILENAME", fakeAuthPath)
@@ -115,3 +117,14 @@ func (s *snapctlSuite) TestSnapctlHelp(c *C) {
 	_, _, err := run()
 	c.Check(err, IsNil)
 }
+
+func (s *snapctlSuite) TestSnapctlUnsuccessful(c *C) {
+	os.Args = []string{"snapctl", "is-connected", "plug"}
+	s.expectedArgs = []string{"is-connected", "plug"}
+	s.rsp = `{"type": "error", "result": {"message": "unsuccessful with exit code: 1", "kind": "unsuccessful", "value": {"stdout": "", "stderr": "", "exit-code": 1}}}`
+
+	stdout, stderr, err := run()
+	c.Check(err, DeepEquals, &client.UnsuccessfulError{ExitCode: 1})
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+}
@@ -55,13 +55,17 @@ import (
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/quotastate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/systemd"
 )
 
 var api = []*Command{
@@ -86,6 +90,11 @@ var api = []*Command{
 	usersCmd,
 	sectionsCmd,
 	aliasesCmd,
+	appsCmd,
+	logsCmd,
+	snapshotCmd,
+	quotasCmd,
+	quotaGroupCmd,
 	debugCmd,
 }
 
@@ -224,6 +233,37 @@ var (
 		GET:    getAliases,
 		POST:   changeAliases,
 	}
+
+	appsCmd = &Command{
+		Path:   "/v2/apps",
+		UserOK: true,
+		GET:    getAppsInfo,
+		POST:   postApps,
+	}
+
+	logsCmd = &Command{
+		Path: "/v2/logs",
+		GET:  getLogs,
+	}
+
+	snapshotCmd = &Command{
+		Path: "/v2/snapshots",
+		GET:  listSnapshots,
+		POST: changeSnapshots,
+	}
+
+	quotasCmd = &Command{
+		Path:   "/v2/quotas",
+		UserOK: true,
+		GET:    getQuotaGroups,
+		POST:   postQuotaGroup,
+	}
+
+	quotaGroupCmd = &Command{
+		Path:   "/v2/quotas/{group}",
+		UserOK: true,
+		GET:    getQuotaGroup,
+	}
 )
 
 func tbd(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -244,12 +284,43 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	nextRefresh := snapMgr.NextRefresh()
 	lastRefresh, _ := snapMgr.LastRefresh()
 	refreshScheduleStr := snapMgr.RefreshSchedule()
+	refreshHold, err := snapstate.EffectiveRefreshHold(st)
+	if err != nil {
+		st.Unlock()
+		return InternalError("cannot get refresh hold: %s", err)
+	}
+	refreshHolds, err := snapstate.RefreshHolds(st)
+	if err != nil {
+		st.Unlock()
+		return InternalError("cannot get refresh holds: %s", err)
+	}
+	proxy, err := configstate.ProxyConfig(st)
+	if err != nil {
+		st.Unlock()
+		return InternalError("cannot get proxy configuration: %s", err)
+	}
 	users, err := auth.Users(st)
 	st.Unlock()
 	if err != nil && err != state.ErrNoState {
 		return InternalError("cannot get user auth data: %s", err)
 	}
 
+	refreshInfo := map[string]interface{}{
+		"schedule": refreshScheduleStr,
+		"last":     formatRefreshTime(lastRefresh),
+		"next":     formatRefreshTime(nextRefresh),
+	}
+	if !refreshHold.IsZero() {
+		refreshInfo["hold"] = formatRefreshTime(refreshHold)
+	}
+	if len(refreshHolds) > 0 {
+		held := make(map[string]string, len(refreshHolds))
+		for name, until := range refreshHolds {
+			held[name] = formatRefreshTime(until)
+		}
+		refreshInfo["held"] = held
+	}
+
 	m := map[string]interface{}{
 		"series":         release.Series,
 		"version":        c.d.Version,
@@ -261,11 +332,23 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 			"snap-mount-dir": dirs.SnapMountDir,
 			"snap-bin-dir":   dirs.SnapBinariesDir,
 		},
-		"refresh": map[string]interface{}{
-			"schedule": refreshScheduleStr,
-			"last":     formatRefreshTime(lastRefresh),
-			"next":     formatRefreshTime(nextRefresh),
-		},
+		"refresh": refreshInfo,
+	}
+	if proxy != nil {
+		// system-info is open to guests, so the credentials of the
+		// proxies are not given out
+		proxy = proxy.WithoutCredentials()
+		proxyInfo := make(map[string]string)
+		for key, value := range map[string]string{
+			"http":     proxy.HTTP,
+			"https":    proxy.HTTPS,
+			"no-proxy": proxy.NoProxy,
+		} {
+			if value != "" {
+				proxyInfo[key] = value
+			}
+		}
+		m["proxy"] = proxyInfo
 	}
 
 	return SyncResponse(m, nil)
@@ -780,6 +863,9 @@ type snapInstruction struct {
 	LeaveOld bool         `json:"temp-dropped-leave-old"`
 	License  *licenseData `json:"license"`
 	Snaps    []string     `json:"snaps"`
+	Users    []string     `json:"users"`
+	// Duration is how long to hold refreshes for, with "hold"
+	Duration string `json:"duration"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
@@ -812,6 +898,17 @@ var (
 	snapstateRemoveMany        = snapstate.RemoveMany
 	snapstateRevert            = snapstate.Revert
 	snapstateRevertToRevision  = snapstate.RevertToRevision
+	snapstateSwitch            = snapstate.Switch
+	snapstateHoldRefresh       = snapstate.HoldRefresh
+
+	snapshotList    = snapshotstate.List
+	snapshotSave    = snapshotstate.Save
+	snapshotRestore = snapshotstate.Restore
+	snapshotForget  = snapshotstate.Forget
+
+	quotastateEnsureQuota = quotastate.EnsureQuota
+	quotastateRemoveQuota = quotastate.RemoveQuota
+	quotastateUsage       = quotastate.Usage
 
 	assertstateRefreshSnapDeclarations = assertstate.RefreshSnapDeclarations
 )
@@ -1021,6 +1118,51 @@ func snapRemoveMany(inst *snapInstruction, st *state.State) (msg string, removed
 	return msg, removed, tasksets, nil
 }
 
+func snapHoldMany(inst *snapInstruction, st *state.State) (msg string, held []string, err error) {
+	duration, err := time.ParseDuration(inst.Duration)
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot parse hold duration: %v", err)
+	}
+	if err := snapstateHoldRefresh(st, inst.Snaps, duration); err != nil {
+		return "", nil, err
+	}
+
+	switch {
+	case len(inst.Snaps) == 0 && duration == 0:
+		msg = i18n.G("Release hold on refreshes")
+	case len(inst.Snaps) == 0:
+		msg = fmt.Sprintf(i18n.G("Hold refreshes for %s"), duration)
+	case duration == 0:
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		msg = fmt.Sprintf(i18n.G("Release hold on refreshes of snaps %s"), strutil.Quoted(inst.Snaps))
+	default:
+		// TRANSLATORS: the first %s is a comma-separated list of quoted snap names
+		msg = fmt.Sprintf(i18n.G("Hold refreshes of snaps %s for %s"), strutil.Quoted(inst.Snaps), duration)
+	}
+
+	return msg, inst.Snaps, nil
+}
+
+func snapshotMany(inst *snapInstruction, st *state.State) (setID uint64, msg string, snapshotted []string, tasksets []*state.TaskSet, err error) {
+	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users)
+	if err != nil {
+		return 0, "", nil, nil, err
+	}
+
+	switch len(snapshotted) {
+	case 0:
+		return 0, "", nil, nil, fmt.Errorf("no snaps to snapshot")
+	case 1:
+		msg = fmt.Sprintf(i18n.G("Snapshot snap %q"), snapshotted[0])
+	default:
+		quoted := strutil.Quoted(snapshotted)
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		msg = fmt.Sprintf(i18n.G("Snapshot snaps %s"), quoted)
+	}
+
+	return setID, msg, snapshotted, []*state.TaskSet{ts}, nil
+}
+
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
 	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision)
 	if err != nil {
@@ -1078,6 +1220,22 @@ func snapDisable(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 	return msg, []*state.TaskSet{ts}, nil
 }
 
+func snapSwitch(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+	if !inst.Revision.Unset() {
+		return "", nil, errors.New("switch takes no revision")
+	}
+	if inst.Channel == "" {
+		return "", nil, errors.New("switch requires a channel")
+	}
+	ts, err := snapstateSwitch(st, inst.Snaps[0], inst.Channel)
+	if err != nil {
+		return "", nil, err
+	}
+
+	msg := fmt.Sprintf(i18n.G("Switch %q snap to %s"), inst.Snaps[0], inst.Channel)
+	return msg, []*state.TaskSet{ts}, nil
+}
+
 type snapActionFunc func(*snapInstruction, *state.State) (string, []*state.TaskSet, error)
 
 var snapInstructionDispTable = map[string]snapActionFunc{
@@ -1087,6 +1245,7 @@ var snapInstructionDispTable = map[string]snapActionFunc{
 	"revert":  snapRevert,
 	"enable":  snapEnable,
 	"disable": snapDisable,
+	"switch":  snapSwitch,
 }
 
 func (inst *snapInstruction) dispatch() snapActionFunc {
@@ -1277,7 +1436,9 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 	var msg string
 	var affected []string
 	var tsets []*state.TaskSet
+	var result map[string]interface{}
 	var err error
+	kind := inst.Action + "-snap"
 	switch inst.Action {
 	case "refresh":
 		msg, affected, tsets, err = snapUpdateMany(&inst, st)
@@ -1285,6 +1446,13 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 		msg, affected, tsets, err = snapInstallMany(&inst, st)
 	case "remove":
 		msg, affected, tsets, err = snapRemoveMany(&inst, st)
+	case "hold":
+		msg, affected, err = snapHoldMany(&inst, st)
+	case "snapshot":
+		var setID uint64
+		setID, msg, affected, tsets, err = snapshotMany(&inst, st)
+		kind = "save-snapshot"
+		result = map[string]interface{}{"set-id": setID}
 	default:
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
@@ -1294,15 +1462,15 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	var chg *state.Change
 	if len(tsets) == 0 {
-		chg = st.NewChange(inst.Action+"-snap", msg)
+		chg = st.NewChange(kind, msg)
 		chg.SetStatus(state.DoneStatus)
 	} else {
-		chg = newChange(st, inst.Action+"-snap", msg, tsets, affected)
+		chg = newChange(st, kind, msg, tsets, affected)
 		ensureStateSoon(st)
 	}
 	chg.Set("api-data", map[string]interface{}{"snap-names": affected})
 
-	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+	return AsyncResponse(result, &Meta{Change: chg.ID()})
 }
 
 func postSnaps(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -2325,10 +2493,34 @@ func runSnapctl(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return BadRequest("error running snapctl: %s", err)
 	}
-	stdout, stderr, err := ctlcmd.Run(context, snapctlOptions.Args)
+
+	// callers whose uid is unknown are treated as nobody
+	uid, err := ucrednetGetUID(r.RemoteAddr)
+	if err != nil && err != errNoUID {
+		return BadRequest("cannot get remote user: %s", err)
+	}
+
+	stdout, stderr, err := ctlcmd.Run(context, snapctlOptions.Args, uid)
 	if err != nil {
-		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
+		if e, ok := err.(*ctlcmd.ForbiddenCommandError); ok {
+			return Forbidden(e.Error())
+		} else if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
 			stdout = []byte(e.Error())
+		} else if e, ok := err.(*ctlcmd.UnsuccessfulError); ok {
+			result := map[string]interface{}{
+				"stdout":    string(stdout),
+				"stderr":    string(stderr),
+				"exit-code": e.ExitCode,
+			}
+			return &resp{
+				Type: ResponseTypeError,
+				Result: &errorResult{
+					Message: e.Error(),
+					Kind:    errorKindUnsuccessful,
+					Value:   result,
+				},
+				Status: 200,
+			}
 		} else {
 			return BadRequest("error running snapctl: %s", err)
 		}
@@ -2338,7 +2530,7 @@ func runSnapctl(c *Command, r *http.Request, user *auth.UserState) Response {
 		context.Lock()
 		defer context.Unlock()
 		if err := context.Done(); err != nil {
-			return BadRequest(i18n.G("set failed: %v"), err)
+			return BadRequest("error running snapctl: %s", err)
 		}
 	}
 
@@ -2510,3 +2702,346 @@ func getAliases(c *Command, r *http.Request, user *auth.UserState) Response {
 
 	return SyncResponse(res, nil)
 }
+
+func getAppsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+
+	opts := appInfoOptions{}
+	switch sel := query.Get("select"); sel {
+	case "":
+		// nothing to do
+	case "service":
+		opts.service = true
+	default:
+		return BadRequest("invalid select parameter: %q", sel)
+	}
+
+	appInfos, rsp := appInfosFor(c.d.overlord.State(), splitQS(query.Get("names")), opts)
+	if rsp != nil {
+		return rsp
+	}
+
+	clientAppInfos, err := clientAppInfosFromSnapAppInfos(appInfos)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	return SyncResponse(clientAppInfos, nil)
+}
+
+// appInstruction is an action performed on the services of snaps
+type appInstruction struct {
+	Action  string   `json:"action"`
+	Names   []string `json:"names"`
+	Enable  bool     `json:"enable"`
+	Disable bool     `json:"disable"`
+}
+
+func postApps(c *Command, r *http.Request, user *auth.UserState) Response {
+	var inst appInstruction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("cannot decode request body into service operation: %v", err)
+	}
+	if len(inst.Names) == 0 {
+		// on POST, don't allow empty to mean all
+		return BadRequest("cannot perform operation on services without a list of services to operate on")
+	}
+
+	st := c.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, inst.Names, appInfoOptions{service: true})
+	if rsp != nil {
+		return rsp
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	// one service-control task per snap, in the order of the snaps
+	var snapNames []string
+	actions := make(map[string]*snapstate.ServiceAction)
+	for _, app := range appInfos {
+		snapName := app.Snap.Name()
+		action := actions[snapName]
+		if action == nil {
+			action = &snapstate.ServiceAction{
+				SnapName: snapName,
+				Action:   inst.Action,
+				Enable:   inst.Enable,
+				Disable:  inst.Disable,
+			}
+			actions[snapName] = action
+			snapNames = append(snapNames, snapName)
+		}
+		action.Services = append(action.Services, app.Name)
+	}
+
+	tasks := make([]*state.Task, len(snapNames))
+	for i, snapName := range snapNames {
+		t, err := snapstate.ServiceControl(st, actions[snapName])
+		if err != nil {
+			return BadRequest("%v", err)
+		}
+		tasks[i] = t
+	}
+
+	summary := tasks[0].Summary()
+	if len(tasks) > 1 {
+		summary = fmt.Sprintf(i18n.G("Run service command %q for services of snaps %s"), inst.Action, strutil.Quoted(snapNames))
+	}
+	chg := newChange(st, "service-control", summary, []*state.TaskSet{state.NewTaskSet(tasks...)}, snapNames)
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
+func getLogs(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	n := 10
+	if s := query.Get("n"); s != "" {
+		m, err := strconv.ParseInt(s, 0, 32)
+		if err != nil {
+			return BadRequest(`invalid value for n: %q: %v`, s, err)
+		}
+		n = int(m)
+	}
+	follow := false
+	if s := query.Get("follow"); s != "" {
+		f, err := strconv.ParseBool(s)
+		if err != nil {
+			return BadRequest(`invalid value for follow: %q: %v`, s, err)
+		}
+		follow = f
+	}
+
+	// only services have logs for now
+	opts := appInfoOptions{service: true}
+	appInfos, rsp := appInfosFor(c.d.overlord.State(), splitQS(query.Get("names")), opts)
+	if rsp != nil {
+		return rsp
+	}
+	if len(appInfos) == 0 {
+		return AppNotFound("no matching services")
+	}
+
+	serviceNames := make([]string, len(appInfos))
+	for i, appInfo := range appInfos {
+		serviceNames[i] = appInfo.ServiceName()
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &progress.NullProgress{})
+	reader, err := sysd.LogReader(serviceNames, n, follow)
+	if err != nil {
+		return InternalError("cannot get logs: %v", err)
+	}
+
+	return &journalLineReaderSeqResponse{
+		ReadCloser: reader,
+		follow:     follow,
+	}
+}
+
+func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+	var setID uint64
+	if sid := query.Get("set"); sid != "" {
+		var err error
+		setID, err = strconv.ParseUint(sid, 10, 64)
+		if err != nil {
+			return BadRequest("'set', if given, must be a positive base 10 number; got %q", sid)
+		}
+	}
+
+	sets, err := snapshotList(setID, splitQS(query.Get("snaps")))
+	if err != nil {
+		return InternalError("cannot list snapshots: %v", err)
+	}
+
+	return SyncResponse(sets, nil)
+}
+
+// snapshotAction is used to request an operation on a snapshot set
+type snapshotAction struct {
+	SetID  uint64   `json:"set"`
+	Action string   `json:"action"`
+	Snaps  []string `json:"snaps,omitempty"`
+	Users  []string `json:"users,omitempty"`
+}
+
+func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
+	var action snapshotAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&action); err != nil {
+		return BadRequest("cannot decode request body into snapshot operation: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found after snapshot operation")
+	}
+
+	if action.SetID == 0 {
+		return BadRequest("snapshot operation requires snapshot set ID")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var kind, msg string
+	var snapNames []string
+	var ts *state.TaskSet
+	var err error
+	switch action.Action {
+	case "restore":
+		kind = "restore-snapshot"
+		snapNames, ts, err = snapshotRestore(st, action.SetID, action.Snaps, action.Users)
+		msg = fmt.Sprintf(i18n.G("Restore snapshot set #%d"), action.SetID)
+	case "forget":
+		if len(action.Users) != 0 {
+			return BadRequest(`snapshot "forget" operation cannot specify users`)
+		}
+		kind = "forget-snapshot"
+		snapNames, ts, err = snapshotForget(st, action.SetID, action.Snaps)
+		msg = fmt.Sprintf(i18n.G("Drop snapshot set #%d"), action.SetID)
+	default:
+		return BadRequest("unknown snapshot operation %q", action.Action)
+	}
+	if err != nil {
+		return BadRequest("cannot %s snapshot set #%d: %v", action.Action, action.SetID, err)
+	}
+
+	if len(action.Snaps) > 0 {
+		// TRANSLATORS: the %s is a comma-separated list of quoted snap names
+		msg += fmt.Sprintf(i18n.G(" of snaps %s"), strutil.Quoted(snapNames))
+	}
+
+	chg := newChange(st, kind, msg, []*state.TaskSet{ts}, snapNames)
+	chg.Set("api-data", map[string]interface{}{"snap-names": snapNames})
+
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
+func quotaGroupResult(grp *quota.Group) (*client.QuotaGroupResult, error) {
+	usage, err := quotastateUsage(grp)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get usage of quota group %q: %v", grp.Name, err)
+	}
+	return &client.QuotaGroupResult{
+		GroupName: grp.Name,
+		Snaps:     grp.Snaps,
+		Constraints: &client.QuotaValues{
+			Memory: grp.MemoryLimit,
+			CPU:    grp.CPULimit,
+		},
+		Current: &client.QuotaUsage{
+			Memory:  usage.Memory,
+			CPUTime: usage.CPUTime,
+		},
+	}, nil
+}
+
+func getQuotaGroups(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	groups, err := quotastate.AllGroups(st)
+	st.Unlock()
+	if err != nil {
+		return InternalError("cannot list quota groups: %v", err)
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]*client.QuotaGroupResult, 0, len(names))
+	for _, name := range names {
+		result, err := quotaGroupResult(groups[name])
+		if err != nil {
+			return InternalError("%v", err)
+		}
+		results = append(results, result)
+	}
+
+	return SyncResponse(results, nil)
+}
+
+func getQuotaGroup(c *Command, r *http.Request, user *auth.UserState) Response {
+	groupName := muxVars(r)["group"]
+
+	st := c.d.overlord.State()
+	st.Lock()
+	grp, err := quotastate.Group(st, groupName)
+	st.Unlock()
+	if err != nil {
+		return NotFound("%v", err)
+	}
+
+	result, err := quotaGroupResult(grp)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	return SyncResponse(result, nil)
+}
+
+// quotaGroupAction is used to request an operation on a quota group
+type quotaGroupAction struct {
+	Action      string              `json:"action"`
+	GroupName   string              `json:"group-name"`
+	Snaps       []string            `json:"snaps,omitempty"`
+	Constraints *client.QuotaValues `json:"constraints,omitempty"`
+}
+
+func postQuotaGroup(c *Command, r *http.Request, user *auth.UserState) Response {
+	var action quotaGroupAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&action); err != nil {
+		return BadRequest("cannot decode request body into quota group operation: %v", err)
+	}
+	if decoder.More() {
+		return BadRequest("extra content found after quota group operation")
+	}
+
+	if action.GroupName == "" {
+		return BadRequest("quota group operation requires a group name")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var kind, msg string
+	var ts *state.TaskSet
+	var err error
+	switch action.Action {
+	case "ensure":
+		limits := action.Constraints
+		if limits == nil {
+			limits = &client.QuotaValues{}
+		}
+		kind = "ensure-quota"
+		ts, err = quotastateEnsureQuota(st, action.GroupName, limits.Memory, limits.CPU, action.Snaps)
+		msg = fmt.Sprintf(i18n.G("Ensure quota group %q"), action.GroupName)
+	case "remove":
+		if len(action.Snaps) != 0 || action.Constraints != nil {
+			return BadRequest(`quota group "remove" operation cannot specify snaps or constraints`)
+		}
+		kind = "remove-quota"
+		ts, err = quotastateRemoveQuota(st, action.GroupName)
+		msg = fmt.Sprintf(i18n.G("Remove quota group %q"), action.GroupName)
+	default:
+		return BadRequest("unknown quota group operation %q", action.Action)
+	}
+	if err != nil {
+		return BadRequest("cannot %s quota group %q: %v", action.Action, action.GroupName, err)
+	}
+
+	chg := newChange(st, kind, msg, []*state.TaskSet{ts}, action.Snaps)
+
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
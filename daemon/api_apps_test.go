@@ -0,0 +1,293 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type appsSuite struct {
+	apiBaseSuite
+
+	journalctlArgs []interface{}
+	jctlRC         io.ReadCloser
+
+	restoreSystemctl  func()
+	restoreJournalctl func()
+}
+
+var _ = check.Suite(&appsSuite{})
+
+func (s *appsSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+
+	oldSystemctl := systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(args ...string) ([]byte, error) {
+		if len(args) == 3 && args[0] == "show" {
+			unit := args[2]
+			activeState, unitFileState := "active", "enabled"
+			if strings.Contains(unit, "svc2") {
+				activeState, unitFileState = "inactive", "disabled"
+			}
+			return []byte(fmt.Sprintf("Id=%s\nLoadState=loaded\nActiveState=%s\nSubState=whatever\nUnitFileState=%s\n", unit, activeState, unitFileState)), nil
+		}
+		return nil, nil
+	}
+	s.restoreSystemctl = func() { systemd.SystemctlCmd = oldSystemctl }
+
+	s.journalctlArgs = nil
+	s.jctlRC = ioutil.NopCloser(strings.NewReader(""))
+	oldJournalctl := systemd.JournalctlStream
+	systemd.JournalctlStream = func(svcs []string, n int, follow bool) (io.ReadCloser, error) {
+		s.journalctlArgs = append(s.journalctlArgs, svcs, n, follow)
+		return s.jctlRC, nil
+	}
+	s.restoreJournalctl = func() { systemd.JournalctlStream = oldJournalctl }
+
+	ensureStateSoon = func(*state.State) {}
+
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "snap-a", "dev", "v1", snap.R(1), true, `apps: {svc1: {daemon: simple}, svc2: {daemon: forking}, app1: {}}`)
+	s.mkInstalledInState(c, d, "snap-b", "dev", "v1", snap.R(1), true, `apps: {svc3: {daemon: simple}}`)
+	s.mkInstalledInState(c, d, "snap-c", "dev", "v1", snap.R(1), true, `apps: {app2: {}}`)
+}
+
+func (s *appsSuite) TearDownTest(c *check.C) {
+	s.restoreSystemctl()
+	s.restoreJournalctl()
+	s.apiBaseSuite.TearDownTest(c)
+}
+
+func (s *appsSuite) getApps(c *check.C, query string) *resp {
+	req, err := http.NewRequest("GET", "/v2/apps"+query, nil)
+	c.Assert(err, check.IsNil)
+	rsp, ok := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	return rsp
+}
+
+func (s *appsSuite) TestGetAppsInfo(c *check.C) {
+	rsp := s.getApps(c, "")
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []*client.AppInfo{
+		{Snap: "snap-a", Name: "app1"},
+		{Snap: "snap-a", Name: "svc1", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "snap-a", Name: "svc2", Daemon: "forking"},
+		{Snap: "snap-b", Name: "svc3", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "snap-c", Name: "app2"},
+	})
+}
+
+func (s *appsSuite) TestGetAppsInfoNames(c *check.C) {
+	rsp := s.getApps(c, "?names=snap-a.svc2,snap-b")
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []*client.AppInfo{
+		{Snap: "snap-a", Name: "svc2", Daemon: "forking"},
+		{Snap: "snap-b", Name: "svc3", Daemon: "simple", Enabled: true, Active: true},
+	})
+}
+
+func (s *appsSuite) TestGetAppsInfoServices(c *check.C) {
+	rsp := s.getApps(c, "?select=service")
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []*client.AppInfo{
+		{Snap: "snap-a", Name: "svc1", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "snap-a", Name: "svc2", Daemon: "forking"},
+		{Snap: "snap-b", Name: "svc3", Daemon: "simple", Enabled: true, Active: true},
+	})
+}
+
+func (s *appsSuite) TestGetAppsInfoErrors(c *check.C) {
+	for _, t := range []struct {
+		query  string
+		status int
+		kind   errorKind
+		msg    string
+	}{
+		{"?select=potato", 400, "", `invalid select parameter: "potato"`},
+		{"?names=snap-x", 404, errorKindSnapNotFound, `snap "snap-x" not found`},
+		{"?names=snap-x.foo", 404, errorKindSnapNotFound, `snap "snap-x" not found`},
+		{"?names=snap-a.foo", 404, errorKindAppNotFound, `snap "snap-a" has no app "foo"`},
+		{"?names=snap-c&select=service", 404, errorKindAppNotFound, `snap "snap-c" has no services`},
+		{"?names=snap-a.app1&select=service", 400, "", `snap "snap-a" has no service "app1"`},
+	} {
+		rsp := s.getApps(c, t.query)
+		c.Check(rsp.Status, check.Equals, t.status, check.Commentf(t.query))
+		c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Result.(*errorResult).Kind, check.Equals, t.kind, check.Commentf(t.query))
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.msg, check.Commentf(t.query))
+	}
+}
+
+func (s *appsSuite) postApps(c *check.C, inst map[string]interface{}) *resp {
+	b, err := json.Marshal(inst)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/apps", bytes.NewReader(b))
+	c.Assert(err, check.IsNil)
+	rsp, ok := postApps(appsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	return rsp
+}
+
+func (s *appsSuite) TestPostApps(c *check.C) {
+	rsp := s.postApps(c, map[string]interface{}{
+		"action":  "stop",
+		"names":   []string{"snap-b", "snap-a.svc2"},
+		"disable": true,
+	})
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "service-control")
+	c.Check(chg.Summary(), check.Equals, `Run service command "stop" for services of snaps "snap-a", "snap-b"`)
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"snap-a", "snap-b"})
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	expected := []snapstate.ServiceAction{
+		{SnapName: "snap-a", Action: "stop", Disable: true, Services: []string{"svc2"}},
+		{SnapName: "snap-b", Action: "stop", Disable: true, Services: []string{"svc3"}},
+	}
+	for i, t := range tasks {
+		c.Check(t.Kind(), check.Equals, "service-control")
+		var action snapstate.ServiceAction
+		c.Assert(t.Get("service-action", &action), check.IsNil)
+		c.Check(action, check.DeepEquals, expected[i])
+	}
+}
+
+func (s *appsSuite) TestPostAppsOneSnap(c *check.C) {
+	rsp := s.postApps(c, map[string]interface{}{
+		"action": "restart",
+		"names":  []string{"snap-a"},
+	})
+	c.Assert(rsp.Status, check.Equals, 202)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Summary(), check.Equals, `Restart services svc1, svc2 of snap "snap-a"`)
+	c.Check(chg.Tasks(), check.HasLen, 1)
+}
+
+func (s *appsSuite) TestPostAppsErrors(c *check.C) {
+	for _, t := range []struct {
+		inst   map[string]interface{}
+		status int
+		msg    string
+	}{
+		{map[string]interface{}{"action": "start"}, 400, `cannot perform operation on services without a list of services to operate on`},
+		{map[string]interface{}{"action": "start", "names": []string{"snap-c"}}, 404, `snap "snap-c" has no services`},
+		{map[string]interface{}{"action": "start", "names": []string{"snap-x"}}, 404, `snap "snap-x" not found`},
+		{map[string]interface{}{"action": "frobnicate", "names": []string{"snap-a"}}, 400, `unknown service action "frobnicate"`},
+		{map[string]interface{}{"action": "start", "disable": true, "names": []string{"snap-a"}}, 400, `cannot disable services when starting them`},
+	} {
+		rsp := s.postApps(c, t.inst)
+		c.Check(rsp.Status, check.Equals, t.status, check.Commentf("%v", t.inst))
+		c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.msg, check.Commentf("%v", t.inst))
+	}
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *appsSuite) TestLogs(c *check.C) {
+	s.jctlRC = ioutil.NopCloser(strings.NewReader(`
+{"MESSAGE": "hello1", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "42"}
+{"MESSAGE": "hello2", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "44"}
+{"MESSAGE": "hello3", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "46"}
+`))
+
+	req, err := http.NewRequest("GET", "/v2/logs?names=snap-a.svc2,snap-b&n=3&follow=true", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
+
+	c.Check(s.journalctlArgs, check.DeepEquals, []interface{}{
+		[]string{"snap.snap-a.svc2.service", "snap.snap-b.svc3.service"}, 3, true,
+	})
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(rec.HeaderMap.Get("Content-Type"), check.Equals, "application/json-seq")
+	c.Check(rec.Body.String(), check.Equals, ""+
+		"\x1e{\"timestamp\":\"1970-01-01T00:00:00.000042Z\",\"message\":\"hello1\",\"sid\":\"xyzzy\",\"pid\":\"42\"}\n"+
+		"\x1e{\"timestamp\":\"1970-01-01T00:00:00.000044Z\",\"message\":\"hello2\",\"sid\":\"xyzzy\",\"pid\":\"42\"}\n"+
+		"\x1e{\"timestamp\":\"1970-01-01T00:00:00.000046Z\",\"message\":\"hello3\",\"sid\":\"xyzzy\",\"pid\":\"42\"}\n")
+}
+
+func (s *appsSuite) TestLogsDefaults(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/logs", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
+
+	c.Check(rec.Code, check.Equals, 200)
+	c.Check(s.journalctlArgs, check.DeepEquals, []interface{}{
+		[]string{"snap.snap-a.svc1.service", "snap.snap-a.svc2.service", "snap.snap-b.svc3.service"}, 10, false,
+	})
+}
+
+func (s *appsSuite) TestLogsBadRequests(c *check.C) {
+	for _, t := range []struct {
+		query  string
+		status int
+		msg    string
+	}{
+		{"?n=foo", 400, `invalid value for n: "foo": .*`},
+		{"?follow=foo", 400, `invalid value for follow: "foo": .*`},
+		{"?names=snap-c", 404, `snap "snap-c" has no services`},
+		{"?names=snap-x", 404, `snap "snap-x" not found`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/logs"+t.query, nil)
+		c.Assert(err, check.IsNil)
+		rsp, ok := getLogs(logsCmd, req, nil).(*resp)
+		c.Assert(ok, check.Equals, true)
+		c.Check(rsp.Status, check.Equals, t.status, check.Commentf(t.query))
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.msg, check.Commentf(t.query))
+	}
+	c.Check(s.journalctlArgs, check.HasLen, 0)
+}
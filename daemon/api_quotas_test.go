@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type quotaSuite struct {
+	apiBaseSuite
+}
+
+var _ = check.Suite(&quotaSuite{})
+
+func (s *quotaSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	ensureStateSoon = func(*state.State) {}
+	s.daemon(c)
+
+	quotastateUsage = func(grp *quota.Group) (*systemd.UnitUsage, error) {
+		return &systemd.UnitUsage{Memory: 4096, CPUTime: time.Second}, nil
+	}
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	st.Set("quota-groups", map[string]*quota.Group{
+		"grp":   {Name: "grp", MemoryLimit: 64 * 1024 * 1024, Snaps: []string{"foo"}},
+		"other": {Name: "other", CPULimit: 50},
+	})
+}
+
+func (s *quotaSuite) TestGetQuotaGroups(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/quotas", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getQuotaGroups(quotasCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []*client.QuotaGroupResult{{
+		GroupName:   "grp",
+		Snaps:       []string{"foo"},
+		Constraints: &client.QuotaValues{Memory: 64 * 1024 * 1024},
+		Current:     &client.QuotaUsage{Memory: 4096, CPUTime: time.Second},
+	}, {
+		GroupName:   "other",
+		Constraints: &client.QuotaValues{CPU: 50},
+		Current:     &client.QuotaUsage{Memory: 4096, CPUTime: time.Second},
+	}})
+}
+
+func (s *quotaSuite) TestGetQuotaGroupsUsageError(c *check.C) {
+	quotastateUsage = func(grp *quota.Group) (*systemd.UnitUsage, error) {
+		return nil, errors.New("bzzt")
+	}
+
+	req, err := http.NewRequest("GET", "/v2/quotas", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getQuotaGroups(quotasCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot get usage of quota group "grp": bzzt`)
+}
+
+func (s *quotaSuite) TestGetQuotaGroup(c *check.C) {
+	s.vars = map[string]string{"group": "other"}
+	req, err := http.NewRequest("GET", "/v2/quotas/other", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getQuotaGroup(quotaGroupCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, &client.QuotaGroupResult{
+		GroupName:   "other",
+		Constraints: &client.QuotaValues{CPU: 50},
+		Current:     &client.QuotaUsage{Memory: 4096, CPUTime: time.Second},
+	})
+
+	s.vars = map[string]string{"group": "missing"}
+	req, err = http.NewRequest("GET", "/v2/quotas/missing", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getQuotaGroup(quotaGroupCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 404)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot find quota group "missing"`)
+}
+
+func (s *quotaSuite) postQuotaGroup(c *check.C, body string) *resp {
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	return postQuotaGroup(quotasCmd, req, nil).(*resp)
+}
+
+func (s *quotaSuite) TestPostEnsureQuota(c *check.C) {
+	quotastateEnsureQuota = func(st *state.State, name string, memoryLimit uint64, cpuLimit int, snapNames []string) (*state.TaskSet, error) {
+		c.Check(name, check.Equals, "grp")
+		c.Check(memoryLimit, check.Equals, uint64(1048576))
+		c.Check(cpuLimit, check.Equals, 20)
+		c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+		t := st.NewTask("fake-quota", "...")
+		return state.NewTaskSet(t), nil
+	}
+
+	rsp := s.postQuotaGroup(c, `{"action": "ensure", "group-name": "grp", "snaps": ["foo", "bar"], "constraints": {"memory": 1048576, "cpu": 20}}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "ensure-quota")
+	c.Check(chg.Summary(), check.Equals, `Ensure quota group "grp"`)
+	var snapNames []string
+	c.Check(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+}
+
+func (s *quotaSuite) TestPostRemoveQuota(c *check.C) {
+	quotastateRemoveQuota = func(st *state.State, name string) (*state.TaskSet, error) {
+		c.Check(name, check.Equals, "grp")
+		t := st.NewTask("fake-quota", "...")
+		return state.NewTaskSet(t), nil
+	}
+
+	rsp := s.postQuotaGroup(c, `{"action": "remove", "group-name": "grp"}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "remove-quota")
+	c.Check(chg.Summary(), check.Equals, `Remove quota group "grp"`)
+}
+
+func (s *quotaSuite) TestPostQuotaGroupErrors(c *check.C) {
+	quotastateEnsureQuota = func(*state.State, string, uint64, int, []string) (*state.TaskSet, error) {
+		return nil, errors.New("bzzt")
+	}
+
+	for _, t := range []struct {
+		body, err string
+	}{
+		{`{"action": "ensure", "group-name": "grp"}`, `cannot ensure quota group "grp": bzzt`},
+		{`{"action": "ensure"}`, `quota group operation requires a group name`},
+		{`{"action": "remove", "group-name": "grp", "snaps": ["foo"]}`, `quota group "remove" operation cannot specify snaps or constraints`},
+		{`{"action": "frobnicate", "group-name": "grp"}`, `unknown quota group operation "frobnicate"`},
+		{`{"action": "ensure", "group-name": "grp"}{}`, `extra content found after quota group operation`},
+		{`{"action": `, `cannot decode request body into quota group operation: unexpected EOF`},
+	} {
+		rsp := s.postQuotaGroup(c, t.body)
+		c.Check(rsp.Status, check.Equals, 400, check.Commentf(t.body))
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err, check.Commentf(t.body))
+	}
+}
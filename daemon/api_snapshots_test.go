@@ -0,0 +1,213 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type snapshotSuite struct {
+	apiBaseSuite
+}
+
+var _ = check.Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	ensureStateSoon = func(*state.State) {}
+	s.daemon(c)
+}
+
+func (s *snapshotSuite) TestListSnapshots(c *check.C) {
+	sets := []client.SnapshotSet{{ID: 1}, {ID: 2}}
+	snapshotList = func(setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+		c.Check(setID, check.Equals, uint64(0))
+		c.Check(snapNames, check.HasLen, 0)
+		return sets, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots", nil)
+	c.Assert(err, check.IsNil)
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, sets)
+}
+
+func (s *snapshotSuite) TestListSnapshotsFiltering(c *check.C) {
+	snapshotList = func(setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots?set=42&snaps=foo,bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+}
+
+func (s *snapshotSuite) TestListSnapshotsBadSet(c *check.C) {
+	snapshotList = func(uint64, []string) ([]client.SnapshotSet, error) {
+		c.Fatalf("unexpected call to list")
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots?set=-1", nil)
+	c.Assert(err, check.IsNil)
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, 400)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `'set', if given, must be a positive base 10 number; got "-1"`)
+}
+
+func (s *snapshotSuite) TestListSnapshotsError(c *check.C) {
+	snapshotList = func(uint64, []string) ([]client.SnapshotSet, error) {
+		return nil, errors.New("bzzt")
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots", nil)
+	c.Assert(err, check.IsNil)
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "cannot list snapshots: bzzt")
+}
+
+func (s *snapshotSuite) TestSnapshotMany(c *check.C) {
+	snapshotSave = func(st *state.State, snapNames []string, users []string) (uint64, []string, *state.TaskSet, error) {
+		c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+		c.Check(users, check.DeepEquals, []string{"alice"})
+		t := st.NewTask("fake-snapshot", "...")
+		return 42, snapNames, state.NewTaskSet(t), nil
+	}
+
+	buf := bytes.NewBufferString(`{"action": "snapshot", "snaps": ["foo", "bar"], "users": ["alice"]}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{"set-id": uint64(42)})
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "save-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Snapshot snaps "foo", "bar"`)
+	var apiData map[string]interface{}
+	c.Check(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData["snap-names"], check.DeepEquals, []interface{}{"foo", "bar"})
+}
+
+func (s *snapshotSuite) TestSnapshotManyError(c *check.C) {
+	snapshotSave = func(*state.State, []string, []string) (uint64, []string, *state.TaskSet, error) {
+		return 0, nil, nil, errors.New("bzzt")
+	}
+
+	buf := bytes.NewBufferString(`{"action": "snapshot", "snaps": ["foo"]}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot snapshot ["foo"]: bzzt`)
+}
+
+func (s *snapshotSuite) postSnapshots(c *check.C, body string) *resp {
+	req, err := http.NewRequest("POST", "/v2/snapshots", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	return changeSnapshots(snapshotCmd, req, nil).(*resp)
+}
+
+func (s *snapshotSuite) TestRestoreSnapshots(c *check.C) {
+	snapshotRestore = func(st *state.State, setID uint64, snapNames []string, users []string) ([]string, *state.TaskSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapNames, check.DeepEquals, []string{"foo"})
+		c.Check(users, check.DeepEquals, []string{"bob"})
+		t := st.NewTask("fake-restore", "...")
+		return snapNames, state.NewTaskSet(t), nil
+	}
+
+	rsp := s.postSnapshots(c, `{"action": "restore", "set": 42, "snaps": ["foo"], "users": ["bob"]}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "restore-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Restore snapshot set #42 of snaps "foo"`)
+}
+
+func (s *snapshotSuite) TestForgetSnapshots(c *check.C) {
+	snapshotForget = func(st *state.State, setID uint64, snapNames []string) ([]string, *state.TaskSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		c.Check(snapNames, check.HasLen, 0)
+		t := st.NewTask("fake-forget", "...")
+		return []string{"foo", "bar"}, state.NewTaskSet(t), nil
+	}
+
+	rsp := s.postSnapshots(c, `{"action": "forget", "set": 42}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "forget-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Drop snapshot set #42`)
+	var snapNames []string
+	c.Check(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+}
+
+func (s *snapshotSuite) TestChangeSnapshotsErrors(c *check.C) {
+	snapshotRestore = func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		return nil, nil, errors.New("bzzt")
+	}
+
+	for _, t := range []struct {
+		body, err string
+	}{
+		{`{"action": "restore"}`, `snapshot operation requires snapshot set ID`},
+		{`{"action": "frobble", "set": 1}`, `unknown snapshot operation "frobble"`},
+		{`{"action": "forget", "set": 1, "users": ["bob"]}`, `snapshot "forget" operation cannot specify users`},
+		{`{"action": "restore", "set": 1}`, `cannot restore snapshot set #1: bzzt`},
+		{`{"action": "restore", "set": 1}{}`, `extra content found after snapshot operation`},
+		{`garbage`, `cannot decode request body into snapshot operation: .*`},
+	} {
+		rsp := s.postSnapshots(c, t.body)
+		c.Check(rsp.Status, check.Equals, 400, check.Commentf(t.body))
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err, check.Commentf(t.body))
+	}
+}
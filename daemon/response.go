@@ -20,15 +20,19 @@
 package daemon
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"mime"
 	"net/http"
 	"path/filepath"
 	"strconv"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/systemd"
 )
 
 // ResponseType is the response type
@@ -137,6 +141,10 @@ const (
 	errorKindSnapNeedsDevMode       = errorKind("snap-needs-devmode")
 	errorKindSnapNeedsClassic       = errorKind("snap-needs-classic")
 	errorKindSnapNeedsClassicSystem = errorKind("snap-needs-classic-system")
+
+	errorKindAppNotFound = errorKind("app-not-found")
+
+	errorKindUnsuccessful = errorKind("unsuccessful")
 )
 
 type errorValue interface{}
@@ -234,6 +242,82 @@ func (ar assertResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// A journalLineReaderSeqResponse's ServeHTTP method reads lines (presumed to
+// be, each one on its own, a JSON dump of a systemd.Log, as output by
+// journalctl -o json) from an io.ReadCloser, loads that into a client.Log,
+// and outputs the json dump of that, padded with RS and LF to make it a valid
+// json-seq response.
+//
+// The reader is always closed when done, which stops the journalctl
+// behind a systemd LogReader; it is also closed as soon as the client
+// goes away, as otherwise a followed log would never end.
+//
+// Tip: "jq" knows how to read this; "jq --seq" both reads and writes this.
+type journalLineReaderSeqResponse struct {
+	io.ReadCloser
+	follow bool
+}
+
+func (rr *journalLineReaderSeqResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json-seq")
+
+	flusher, hasFlusher := w.(http.Flusher)
+
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		done := make(chan struct{})
+		defer close(done)
+		closed := notifier.CloseNotify()
+		go func() {
+			select {
+			case <-closed:
+				rr.Close()
+			case <-done:
+			}
+		}()
+	}
+
+	var err error
+	dec := json.NewDecoder(rr)
+	writer := bufio.NewWriter(w)
+	enc := json.NewEncoder(writer)
+	for {
+		var log systemd.Log
+		if err = dec.Decode(&log); err != nil {
+			break
+		}
+
+		writer.WriteByte(0x1E) // RS -- see ascii(7), and RFC7464
+
+		// ignore the error...
+		t, _ := log.Time()
+		if err = enc.Encode(client.Log{
+			Timestamp: t,
+			Message:   log.Message(),
+			SID:       log.SID(),
+			PID:       log.PID(),
+		}); err != nil {
+			break
+		}
+
+		if rr.follow {
+			if e := writer.Flush(); e != nil {
+				break
+			}
+			if hasFlusher {
+				flusher.Flush()
+			}
+		}
+	}
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(writer, "\x1E{\"error\": %q}\n", err)
+		logger.Noticef("cannot stream response; problem reading: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		logger.Noticef("cannot stream response; problem writing: %v", err)
+	}
+	rr.Close()
+}
+
 // errorResponder is a callable that produces an error Response.
 // e.g., InternalError("something broke: %v", err), etc.
 type errorResponder func(string, ...interface{}) Response
@@ -250,6 +334,19 @@ var (
 	Conflict         = makeErrorResponder(409)
 )
 
+// AppNotFound is an error responder used when an operation is
+// requested on an app that doesn't exist.
+func AppNotFound(format string, v ...interface{}) Response {
+	return &resp{
+		Type: ResponseTypeError,
+		Result: &errorResult{
+			Message: fmt.Sprintf(format, v...),
+			Kind:    errorKindAppNotFound,
+		},
+		Status: 404,
+	}
+}
+
 func SnapNotFound(err error) Response {
 	return &resp{
 		Type: ResponseTypeError,
@@ -25,13 +25,19 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 var errNoSnap = errors.New("snap not installed")
@@ -159,9 +165,26 @@ func allLocalSnapInfos(st *state.State, all bool, wanted map[string]bool) ([]abo
 
 // appJSON contains the json for snap.AppInfo
 type appJSON struct {
-	Name        string `json:"name"`
-	Daemon      string `json:"daemon"`
-	DesktopFile string `json:"desktop-file,omitempty"`
+	Name           string     `json:"name"`
+	Daemon         string     `json:"daemon"`
+	DesktopFile    string     `json:"desktop-file,omitempty"`
+	Timer          string     `json:"timer,omitempty"`
+	NextActivation *time.Time `json:"next-activation,omitempty"`
+}
+
+// appTimer returns the timer of the given app, if any, and when it
+// next activates the app.
+func appTimer(app *snap.AppInfo) (timer string, next *time.Time) {
+	if app.Timer == nil {
+		return "", nil
+	}
+	schedule, err := timeutil.ParseSchedule(app.Timer.Timer)
+	if err != nil {
+		// timers are validated when the snap is installed
+		return app.Timer.Timer, nil
+	}
+	t := timeutil.NextStart(schedule, time.Now())
+	return app.Timer.Timer, &t
 }
 
 // screenshotJSON contains the json for snap.ScreenshotInfo
@@ -191,10 +214,13 @@ func mapLocal(about aboutSnap) map[string]interface{} {
 			installedDesktopFile = app.DesktopFile()
 		}
 
+		timer, next := appTimer(app)
 		apps = append(apps, appJSON{
-			Name:        app.Name,
-			Daemon:      app.Daemon,
-			DesktopFile: installedDesktopFile,
+			Name:           app.Name,
+			Daemon:         app.Daemon,
+			DesktopFile:    installedDesktopFile,
+			Timer:          timer,
+			NextActivation: next,
 		})
 	}
 
@@ -282,3 +308,135 @@ func mapRemote(remoteSnap *snap.Info) map[string]interface{} {
 
 	return result
 }
+
+type appInfoOptions struct {
+	service bool
+}
+
+func (opts appInfoOptions) String() string {
+	if opts.service {
+		return "service"
+	}
+
+	return "app"
+}
+
+// appInfosFor returns a sorted list apps described by names.
+//
+// * If names is empty, returns all apps of the wanted kinds (which
+//   could be an empty list).
+// * An element of names can be a snap name, in which case all apps
+//   from the snap of the wanted kind are included in the result (and
+//   it's an error if the snap has no apps of the wanted kind).
+// * An element of names can instead be snap.app, in which case that app is
+//   included in the result (and it's an error if the snap and app don't
+//   both exist, or if the app is not a wanted kind)
+// On error an appropriate error Response is returned; a nil Response means
+// no error.
+//
+func appInfosFor(st *state.State, names []string, opts appInfoOptions) ([]*snap.AppInfo, Response) {
+	snapNames := make(map[string]bool)
+	requested := make(map[string]bool)
+	for _, name := range names {
+		requested[name] = true
+		name = strings.SplitN(name, ".", 2)[0]
+		snapNames[name] = true
+	}
+
+	snaps, err := allLocalSnapInfos(st, false, snapNames)
+	if err != nil {
+		return nil, InternalError("cannot list local snaps! %v", err)
+	}
+
+	found := make(map[string]bool)
+	installed := make(map[string]bool)
+	appInfos := make([]*snap.AppInfo, 0, len(requested))
+	for _, snp := range snaps {
+		snapName := snp.info.Name()
+		installed[snapName] = true
+		apps := make([]*snap.AppInfo, 0, len(snp.info.Apps))
+		for _, app := range snp.info.Apps {
+			apps = append(apps, app)
+		}
+
+		if len(requested) == 0 || requested[snapName] {
+			for _, app := range apps {
+				if !opts.service || app.IsService() {
+					appInfos = append(appInfos, app)
+					found[snapName] = true
+				}
+			}
+			continue
+		}
+
+		for _, app := range apps {
+			appName := snapName + "." + app.Name
+			if requested[appName] {
+				if opts.service && !app.IsService() {
+					return nil, BadRequest("snap %q has no %s %q", snapName, opts, app.Name)
+				}
+				found[appName] = true
+				appInfos = append(appInfos, app)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if found[name] {
+			continue
+		}
+		snapName, appName := splitAppName(name)
+		if !installed[snapName] {
+			return nil, SnapNotFound(fmt.Errorf("snap %q not found", snapName))
+		}
+		if appName == "" {
+			return nil, AppNotFound("snap %q has no %ss", snapName, opts)
+		}
+		return nil, AppNotFound("snap %q has no %s %q", snapName, opts, appName)
+	}
+
+	sort.Sort(snap.AppInfoBySnapApp(appInfos))
+
+	return appInfos, nil
+}
+
+func splitAppName(s string) (snap, app string) {
+	if idx := strings.IndexByte(s, '.'); idx > -1 {
+		return s[:idx], s[idx+1:]
+	}
+
+	return s, ""
+}
+
+// clientAppInfosFromSnapAppInfos returns the client representation of the
+// given apps, including the systemd status of those that are services.
+func clientAppInfosFromSnapAppInfos(apps []*snap.AppInfo) ([]*client.AppInfo, error) {
+	// TODO: pass in an actual notifier here instead of null
+	//       (Status doesn't _need_ it, but benefits from it)
+	sysd := systemd.New(dirs.GlobalRootDir, &progress.NullProgress{})
+
+	out := make([]*client.AppInfo, len(apps))
+	for i, app := range apps {
+		timer, next := appTimer(app)
+		out[i] = &client.AppInfo{
+			Snap:           app.Snap.Name(),
+			Name:           app.Name,
+			Daemon:         app.Daemon,
+			Timer:          timer,
+			NextActivation: next,
+		}
+		if !app.IsService() {
+			continue
+		}
+
+		status, err := sysd.ServiceStatus(app.ServiceName())
+		if err != nil {
+			return nil, err
+		}
+
+		out[i].Enabled = status.UnitFileState == "enabled"
+		out[i].Active = status.ActiveState == "active"
+	}
+
+	return out, nil
+}
@@ -181,7 +181,7 @@ func Report(snap, errMsg, dupSig string, extra map[string]string) (string, error
 	if err != nil {
 		return "", err
 	}
-	client := &http.Client{}
+	client := httputil.NewHTTPClient(nil)
 	req, err := http.NewRequest("POST", crashDbUrl, bytes.NewBuffer(reportBson))
 	if err != nil {
 		return "", err
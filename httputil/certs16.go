@@ -0,0 +1,48 @@
+// +build !go1.7
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httputil
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+)
+
+// the bundle of the system certificates on the distributions snapd
+// runs on, as x509.SystemCertPool is only available since go 1.7
+var systemCertFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+}
+
+func systemCertPool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, fn := range systemCertFiles {
+		pem, err := ioutil.ReadFile(fn)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		pool.AppendCertsFromPEM(pem)
+	}
+	return pool, nil
+}
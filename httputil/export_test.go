@@ -19,6 +19,10 @@
 
 package httputil
 
+import (
+	"time"
+)
+
 var (
 	GetFlags              = (*LoggedTransport).getFlags
 	StripUnsafeRunes      = stripUnsafeRunes
@@ -32,3 +36,11 @@ func MockUserAgent(mock string) (restore func()) {
 		userAgent = old
 	}
 }
+
+func MockCertsCheckInterval(d time.Duration) (restore func()) {
+	old := certsCheckInterval
+	certsCheckInterval = d
+	return func() {
+		certsCheckInterval = old
+	}
+}
@@ -93,7 +93,9 @@ type ClientOpts struct {
 }
 
 // NewHTTPCLient returns a new http.Client with a LoggedTransport, a
-// Timeout and preservation of range requests across redirects
+// Timeout and preservation of range requests across redirects; its
+// requests go through the proxies and trust the certificates set with
+// ConfigureTransport
 func NewHTTPClient(opts *ClientOpts) *http.Client {
 	if opts == nil {
 		opts = &ClientOpts{}
@@ -101,7 +103,7 @@ func NewHTTPClient(opts *ClientOpts) *http.Client {
 
 	return &http.Client{
 		Transport: &LoggedTransport{
-			Transport: http.DefaultTransport,
+			Transport: configuredTransport{},
 			Key:       "SNAPD_DEBUG_HTTP",
 			body:      opts.MayLogBody,
 		},
@@ -0,0 +1,354 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package httputil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// ProxyConfig sets the proxies used by the clients from NewHTTPClient
+// instead of the ones set in the environment.
+type ProxyConfig struct {
+	// HTTP is the proxy for http requests; direct if empty.
+	HTTP string
+	// HTTPS is the proxy for https requests; direct if empty.
+	HTTPS string
+	// NoProxy is a comma-separated list of the hosts and domains
+	// reached directly, or "*" for all of them.
+	NoProxy string
+}
+
+func parseProxy(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing host in proxy %q", proxy)
+	}
+	// url.Parse leaves the port of the host unchecked
+	if strings.Contains(u.Host, ":") && !strings.HasSuffix(u.Host, "]") {
+		_, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return nil, fmt.Errorf("invalid port %q in proxy %q", port, proxy)
+		}
+	}
+	return u, nil
+}
+
+// Validate checks that the proxies are valid URLs.
+func (pc *ProxyConfig) Validate() error {
+	if _, err := parseProxy(pc.HTTP); err != nil {
+		return fmt.Errorf("invalid http proxy: %v", err)
+	}
+	if _, err := parseProxy(pc.HTTPS); err != nil {
+		return fmt.Errorf("invalid https proxy: %v", err)
+	}
+	return nil
+}
+
+// stripCredentials returns the proxy without its user and password.
+// Proxies that cannot be parsed are dropped, as they could still hold
+// credentials.
+func stripCredentials(proxy string) string {
+	u, err := parseProxy(proxy)
+	if err != nil {
+		return ""
+	}
+	if u == nil || u.User == nil {
+		return proxy
+	}
+	u.User = nil
+	return u.String()
+}
+
+// WithoutCredentials returns a copy of the proxy config with the user
+// and password of the proxies removed, for showing it to anyone.
+func (pc *ProxyConfig) WithoutCredentials() *ProxyConfig {
+	return &ProxyConfig{
+		HTTP:    stripCredentials(pc.HTTP),
+		HTTPS:   stripCredentials(pc.HTTPS),
+		NoProxy: pc.NoProxy,
+	}
+}
+
+// useProxy returns whether the host (and port) can go through a proxy,
+// as it is neither local nor in the no-proxy list.
+func (pc *ProxyConfig) useProxy(addr string) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return false
+	}
+
+	for _, noProxy := range strings.Split(pc.NoProxy, ",") {
+		noProxy = strings.ToLower(strings.TrimSpace(noProxy))
+		if noProxy == "" {
+			continue
+		}
+		if noProxy == "*" {
+			return false
+		}
+		if h, _, err := net.SplitHostPort(noProxy); err == nil {
+			noProxy = h
+		}
+		// both "example.com" and ".example.com" match the
+		// domain and all of its hosts
+		noProxy = strings.TrimPrefix(noProxy, ".")
+		if host == noProxy || strings.HasSuffix(host, "."+noProxy) {
+			return false
+		}
+	}
+	return true
+}
+
+// Proxy returns the proxy to use for the request, if any, following
+// the signature of http.Transport.Proxy.
+func (pc *ProxyConfig) Proxy(req *http.Request) (*url.URL, error) {
+	proxy := pc.HTTP
+	if req.URL.Scheme == "https" {
+		proxy = pc.HTTPS
+	}
+	if proxy == "" || !pc.useProxy(req.URL.Host) {
+		return nil, nil
+	}
+	return parseProxy(proxy)
+}
+
+// isCertFile returns whether the directory entry is one of the files
+// the additional certificates are read from.
+func isCertFile(fi os.FileInfo) bool {
+	ext := filepath.Ext(fi.Name())
+	return !fi.IsDir() && (ext == ".crt" || ext == ".pem")
+}
+
+// certsDirStamp returns a summary of the name, size and modification
+// time of the certificate files in the given directory, which changes
+// whenever one of them is added, removed or rewritten.
+func certsDirStamp(certsDir string) string {
+	if certsDir == "" {
+		return ""
+	}
+	fis, err := ioutil.ReadDir(certsDir)
+	if err != nil {
+		return ""
+	}
+	var stamp bytes.Buffer
+	for _, fi := range fis {
+		if isCertFile(fi) {
+			fmt.Fprintf(&stamp, "%s:%d:%d\n", fi.Name(), fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	return stamp.String()
+}
+
+// extraCerts reads the PEM certificates in the .crt and .pem files of
+// the given directory, skipping the invalid ones.
+func extraCerts(certsDir string) ([]byte, error) {
+	if certsDir == "" {
+		return nil, nil
+	}
+	fis, err := ioutil.ReadDir(certsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var certs bytes.Buffer
+	for _, fi := range fis {
+		if !isCertFile(fi) {
+			continue
+		}
+		p := filepath.Join(certsDir, fi.Name())
+		pem, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			logger.Noticef("cannot use certificates from %q: no valid PEM certificates found", p)
+			continue
+		}
+		certs.Write(pem)
+		certs.WriteByte('\n')
+	}
+	return certs.Bytes(), nil
+}
+
+// transportState is the transport of the clients from NewHTTPClient
+// along with what it was built from.
+type transportState struct {
+	mu        sync.RWMutex
+	transport http.RoundTripper
+	proxy     *ProxyConfig
+	certsDir  string
+	certs     []byte
+	// certsStamp is the certsDirStamp of certsDir when certs was read
+	certsStamp string
+	// certsChecked is when certsDir was last compared to certsStamp
+	certsChecked time.Time
+}
+
+var currentTransport = transportState{transport: http.DefaultTransport}
+
+// certsCheckInterval is how often requests check whether the
+// additional certificates changed; overridden in the tests
+var certsCheckInterval = 10 * time.Second
+
+// certsCheckDue returns whether the additional certificates are to be
+// checked for changes, noting that they are if so.
+func (ts *transportState) certsCheckDue() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.certsDir == "" || time.Since(ts.certsChecked) < certsCheckInterval {
+		return false
+	}
+	ts.certsChecked = time.Now()
+	return true
+}
+
+// ConfigureTransport sets the proxies and the additional trusted CA
+// certificates, read from the given directory, of all the clients
+// from NewHTTPClient, including the ones already built. A nil proxy
+// config means the proxies set in the environment are used.
+//
+// The certificates are read again before a request whenever a .crt or
+// .pem file of the directory is added, removed or modified since the
+// last time they were read, which requests check for at most once
+// every certsCheckInterval.
+func ConfigureTransport(proxy *ProxyConfig, certsDir string) error {
+	if proxy != nil {
+		if err := proxy.Validate(); err != nil {
+			return err
+		}
+		proxyCopy := *proxy
+		proxy = &proxyCopy
+	}
+	// taken before reading the certificates so that changes made
+	// while reading them are picked up by the next request
+	stamp := certsDirStamp(certsDir)
+	certs, err := extraCerts(certsDir)
+	if err != nil {
+		return fmt.Errorf("cannot read the additional certificates: %v", err)
+	}
+
+	ts := &currentTransport
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.certsDir = certsDir
+	ts.certsStamp = stamp
+	ts.certsChecked = time.Now()
+
+	sameProxy := (proxy == nil && ts.proxy == nil) || (proxy != nil && ts.proxy != nil && *proxy == *ts.proxy)
+	if sameProxy && bytes.Equal(certs, ts.certs) {
+		return nil
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if proxy != nil || len(certs) > 0 {
+		transport, err = newTransport(proxy, certs)
+		if err != nil {
+			return err
+		}
+	}
+	if old, ok := ts.transport.(*http.Transport); ok && ts.transport != http.DefaultTransport {
+		old.CloseIdleConnections()
+	}
+	ts.transport = transport
+	ts.proxy = proxy
+	ts.certs = certs
+	return nil
+}
+
+// newTransport returns a transport like http.DefaultTransport but with
+// the given proxies and additional trusted CA certificates.
+func newTransport(proxy *ProxyConfig, certs []byte) (*http.Transport, error) {
+	tr := cloneDefaultTransport()
+	if proxy != nil {
+		tr.Proxy = proxy.Proxy
+	}
+	if len(certs) > 0 {
+		roots, err := systemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the system certificates: %v", err)
+		}
+		roots.AppendCertsFromPEM(certs)
+		tr.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	return tr, nil
+}
+
+// configuredTransport is an http.RoundTripper using the transport set
+// with ConfigureTransport at the time of each request.
+type configuredTransport struct{}
+
+// RoundTrip is from the http.RoundTripper interface.
+func (configuredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ts := &currentTransport
+	if ts.certsCheckDue() {
+		ts.mu.RLock()
+		proxy, certsDir, stamp := ts.proxy, ts.certsDir, ts.certsStamp
+		ts.mu.RUnlock()
+
+		if certsDirStamp(certsDir) != stamp {
+			if err := ConfigureTransport(proxy, certsDir); err != nil {
+				logger.Noticef("cannot reload the additional certificates: %v", err)
+			}
+		}
+	}
+
+	ts.mu.RLock()
+	transport := ts.transport
+	ts.mu.RUnlock()
+	return transport.RoundTrip(req)
+}
@@ -146,6 +146,10 @@ func (b *Backend) Setup(snapInfo *snap.Info, opts interfaces.ConfinementOptions,
 		}
 	}
 
+	// Allow services to use the sockets that activate them, alongside
+	// the snippets derived from the interfaces of the snap.
+	spec.(*Specification).AddSnapSockets(snapInfo)
+
 	// Get the files that this snap should have
 	content, err := b.deriveContent(spec.(*Specification), snapInfo, opts)
 	if err != nil {
@@ -20,10 +20,12 @@
 package apparmor
 
 import (
-	"github.com/snapcore/snapd/interfaces"
-
+	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
 )
 
 // Specification assists in collecting apparmor entries associated with an interface.
@@ -72,6 +74,34 @@ func (spec *Specification) SecurityTags() []string {
 	return tags
 }
 
+// AddSnapSockets adds snippets allowing the services of the snap to use
+// the sockets that activate them.
+func (spec *Specification) AddSnapSockets(si *snap.Info) {
+	for _, app := range si.Apps {
+		names := make([]string, 0, len(app.Sockets))
+		for name := range app.Sockets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		spec.securityTags = []string{app.SecurityTag()}
+		for _, name := range names {
+			spec.AddSnippet(socketSnippet(app.Sockets[name]))
+		}
+		spec.securityTags = nil
+	}
+}
+
+// socketSnippet returns the apparmor snippet allowing the service to use
+// the given socket.
+func socketSnippet(socket *snap.SocketInfo) string {
+	if socket.IsPath() {
+		path := socket.App.Snap.ExpandSnapVariables(socket.ListenStream)
+		return fmt.Sprintf("# Allow using socket %s\n\"%s\" rw,", socket.Name, path)
+	}
+	return fmt.Sprintf("# Allow using socket %s\nnetwork inet stream,\nnetwork inet6 stream,", socket.Name)
+}
+
 // Implementation of methods required by interfaces.Specification
 
 // AddConnectedPlug records apparmor-specific side-effects of having a connected plug.
@@ -99,3 +99,28 @@ func (s *specSuite) TestSpecificationIface(c *C) {
 		"snap.snap2.app2": {"connected-slot", "permanent-slot"},
 	})
 }
+
+func (s *specSuite) TestAddSnapSockets(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: snap1
+apps:
+ app1:
+  daemon: simple
+  sockets:
+   sock2:
+    listen-stream: 8080
+   sock1:
+    listen-stream: $SNAP_COMMON/sock1.socket
+ app2:
+  command: foo
+`))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(42)
+
+	s.spec.AddSnapSockets(info)
+	c.Assert(s.spec.Snippets(), DeepEquals, map[string][]string{
+		"snap.snap1.app1": {
+			"# Allow using socket sock1\n\"/var/snap/snap1/common/sock1.socket\" rw,",
+			"# Allow using socket sock2\nnetwork inet stream,\nnetwork inet6 stream,",
+		},
+	})
+}
@@ -26,7 +26,8 @@
 // Each fstab like file looks like a regular fstab entry:
 //   /src/dir /dst/dir none bind 0 0
 //   /src/dir /dst/dir none bind,rw 0 0
-// but only bind mounts are supported
+// Interfaces only use bind mounts, while the layout of a snap can also ask for
+// tmpfs mounts and symbolic links.
 package mount
 
 import (
@@ -56,6 +57,9 @@ func (b *Backend) Setup(snapInfo *snap.Info, confinement interfaces.ConfinementO
 	if err != nil {
 		return fmt.Errorf("cannot obtain mount security snippets for snap %q: %s", snapName, err)
 	}
+	// Add mount entries for the layout of the snap, alongside those
+	// derived from its interfaces.
+	spec.(*Specification).AddSnapLayout(snapInfo)
 	content := deriveContent(spec.(*Specification), snapInfo)
 	// synchronize the content with the filesystem
 	glob := fmt.Sprintf("snap.%s.*fstab", snapName)
@@ -21,10 +21,10 @@ package mount
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"sort"
 	"strings"
-	"syscall"
 )
 
 // Action represents a mount action (mount, remount, unmount, etc).
@@ -54,19 +54,44 @@ func (c Change) String() string {
 // Perform executes the desired mount or unmount change using system calls.
 // Filesystems that depend on helper programs or multiple independent calls to
 // the kernel (--make-shared, for example) are unsupported.
-func (c *Change) Perform() error {
+//
+// Entries of the "symlink" kind are created and removed as symbolic
+// links rather than mounted. Missing mount points are created before
+// mounting; when they are in a read-only location a writable mimic of
+// the location is constructed first. The synthetic changes made to
+// construct such mimics are returned, even if the change itself fails.
+func (c *Change) Perform() ([]*Change, error) {
 	switch c.Action {
 	case Mount:
+		if XSnapdKind(&c.Entry) == "symlink" {
+			return ensureSymlink(c.Entry.Dir, XSnapdSymlink(&c.Entry), c.Entry.Dir)
+		}
 		flags, err := OptsToFlags(c.Entry.Options)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		changes, err := ensureMountPoint(&c.Entry)
+		if err != nil {
+			return changes, err
 		}
-		return syscall.Mount(c.Entry.Name, c.Entry.Dir, c.Entry.Type, uintptr(flags), "")
+		return changes, sysMount(c.Entry.Name, c.Entry.Dir, c.Entry.Type, uintptr(flags), "")
 	case Unmount:
+		if XSnapdKind(&c.Entry) == "symlink" {
+			return nil, os.Remove(c.Entry.Dir)
+		}
 		const UMOUNT_NOFOLLOW = 8
-		return syscall.Unmount(c.Entry.Dir, UMOUNT_NOFOLLOW)
+		return nil, sysUnmount(c.Entry.Dir, UMOUNT_NOFOLLOW)
 	}
-	return fmt.Errorf("cannot process mount change, unknown action: %q", c.Action)
+	return nil, fmt.Errorf("cannot process mount change, unknown action: %q", c.Action)
+}
+
+// ensureMountPoint creates the directory or, for entries of the "file"
+// kind, the empty file, that the given entry is mounted on.
+func ensureMountPoint(e *Entry) ([]*Change, error) {
+	if XSnapdKind(e) == "file" {
+		return ensureFile(e.Dir, e.Dir)
+	}
+	return ensureDir(e.Dir, e.Dir)
 }
 
 // NeededChanges computes the changes required to change current to desired mount entries.
@@ -102,8 +127,16 @@ func NeededChanges(currentProfile, desiredProfile *Profile) []Change {
 		desiredMap[desired[i].Dir] = &desired[i]
 	}
 
+	// Construct a current directory map.
+	currentMap := make(map[string]*Entry)
+	for i := range current {
+		currentMap[current[i].Dir] = &current[i]
+	}
+
 	// Compute reusable entries: those which are equal in current and desired and which
-	// are not prefixed by another entry that changed.
+	// are not prefixed by another entry that changed. Synthetic entries,
+	// which are never desired, are reusable as long as the entry that
+	// needed them is.
 	var reuse map[string]bool
 	var skipDir string
 	for i := range current {
@@ -112,7 +145,17 @@ func NeededChanges(currentProfile, desiredProfile *Profile) []Change {
 			continue
 		}
 		skipDir = "" // reset skip prefix as it no longer applies
-		if entry, ok := desiredMap[dir]; ok && current[i].Equal(entry) {
+		lookupDir := dir
+		if XSnapdSynthetic(&current[i]) {
+			lookupDir = path.Clean(XSnapdNeededBy(&current[i]))
+		}
+		entry, ok := desiredMap[lookupDir]
+		if ok && lookupDir != dir {
+			// the entry that needed this synthetic one must be unchanged
+			ok = currentMap[lookupDir] != nil && currentMap[lookupDir].Equal(entry)
+			entry = &current[i]
+		}
+		if ok && current[i].Equal(entry) {
 			if reuse == nil {
 				reuse = make(map[string]bool)
 			}
@@ -20,6 +20,9 @@
 package mount_test
 
 import (
+	"os"
+	"path/filepath"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces/mount"
@@ -174,3 +177,50 @@ func (s *changeSuite) TestNeededChangesSmartEntryComparison(c *C) {
 		{Entry: mount.Entry{Dir: "/a/b/c"}, Action: mount.Mount},
 	})
 }
+
+// Symlinks described by mount entries are created and removed.
+func (s *changeSuite) TestPerformSymlink(c *C) {
+	dir := c.MkDir()
+	link := filepath.Join(dir, "usr/lib/foo")
+	entry := mount.Entry{Dir: link, Options: []string{"x-snapd.kind=symlink", "x-snapd.symlink=/snap/foo/1/lib"}}
+
+	change := mount.Change{Action: mount.Mount, Entry: entry}
+	synthesised, err := change.Perform()
+	c.Assert(err, IsNil)
+	c.Check(synthesised, HasLen, 0)
+	target, err := os.Readlink(link)
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "/snap/foo/1/lib")
+
+	change = mount.Change{Action: mount.Unmount, Entry: entry}
+	_, err = change.Perform()
+	c.Assert(err, IsNil)
+	_, err = os.Lstat(link)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+// Synthetic entries are kept as long as the entry that needed them is kept.
+func (s *changeSuite) TestNeededChangesKeepsSyntheticEntries(c *C) {
+	synthetic := []string{"x-snapd.synthetic", "x-snapd.needed-by=/usr/share/foo"}
+	tmpfs := mount.Entry{Name: "tmpfs", Dir: "/usr/share", Type: "tmpfs", Options: synthetic}
+	doc := mount.Entry{Name: "/tmp/.snap/usr/share/doc", Dir: "/usr/share/doc", Options: append([]string{"rbind"}, synthetic...)}
+	foo := mount.Entry{Name: "/snap/foo/1/share", Dir: "/usr/share/foo", Options: []string{"rbind"}}
+	current := &mount.Profile{Entries: []mount.Entry{tmpfs, doc, foo}}
+
+	// The entry that needed the mimic is still desired.
+	desired := &mount.Profile{Entries: []mount.Entry{foo}}
+	changes := mount.NeededChanges(current, desired)
+	c.Assert(changes, DeepEquals, []mount.Change{
+		{Entry: foo, Action: mount.Keep},
+		{Entry: doc, Action: mount.Keep},
+		{Entry: tmpfs, Action: mount.Keep},
+	})
+
+	// The entry that needed the mimic is no longer desired.
+	changes = mount.NeededChanges(current, &mount.Profile{})
+	c.Assert(changes, DeepEquals, []mount.Change{
+		{Entry: foo, Action: mount.Unmount},
+		{Entry: doc, Action: mount.Unmount},
+		{Entry: tmpfs, Action: mount.Unmount},
+	})
+}
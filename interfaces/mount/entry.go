@@ -141,10 +141,61 @@ func ParseEntry(s string) (Entry, error) {
 	return e, nil
 }
 
+// XSnapdKind returns the kind of file system object, "file" or
+// "symlink", that the entry creates, as recorded in the
+// x-snapd.kind=... mount option. Entries without that option create
+// directories.
+func XSnapdKind(e *Entry) string {
+	return xSnapdOption(e, "x-snapd.kind=")
+}
+
+// XSnapdSymlink returns the target of the symlink created by an entry
+// of the "symlink" kind, as recorded in the x-snapd.symlink=... mount
+// option.
+func XSnapdSymlink(e *Entry) string {
+	return xSnapdOption(e, "x-snapd.symlink=")
+}
+
+// XSnapdSynthetic returns whether the entry was synthesized by snapd,
+// rather than requested, as recorded in the x-snapd.synthetic mount
+// option. Synthetic entries are used to construct writable mimics.
+func XSnapdSynthetic(e *Entry) bool {
+	for _, opt := range e.Options {
+		if opt == "x-snapd.synthetic" {
+			return true
+		}
+	}
+	return false
+}
+
+// XSnapdNeededBy returns the directory of the entry that caused a
+// synthetic entry to be created, as recorded in the
+// x-snapd.needed-by=... mount option.
+func XSnapdNeededBy(e *Entry) string {
+	return xSnapdOption(e, "x-snapd.needed-by=")
+}
+
+func xSnapdOption(e *Entry, prefix string) string {
+	for _, opt := range e.Options {
+		if strings.HasPrefix(opt, prefix) {
+			return strings.TrimPrefix(opt, prefix)
+		}
+	}
+	return ""
+}
+
 // OptsToFlags converts mount options strings to a mount flag.
+//
+// Options starting with "x-snapd." are used by snapd to describe the
+// entry and are ignored.
 func OptsToFlags(opts []string) (flags int, err error) {
 	for _, opt := range opts {
+		if strings.HasPrefix(opt, "x-snapd.") {
+			continue
+		}
 		switch opt {
+		case "rw", "defaults":
+			// no flags needed
 		case "ro":
 			flags |= syscall.MS_RDONLY
 		case "nosuid":
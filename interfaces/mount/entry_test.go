@@ -161,4 +161,17 @@ func (s *entrySuite) TestOptsToFlags(c *C) {
 	c.Assert(flags, Equals, syscall.MS_RDONLY|syscall.MS_NODEV|syscall.MS_NOSUID)
 	_, err = mount.OptsToFlags([]string{"bogus"})
 	c.Assert(err, ErrorMatches, `unsupported mount option: "bogus"`)
+	flags, err = mount.OptsToFlags([]string{"bind", "rw", "x-snapd.kind=file"})
+	c.Assert(err, IsNil)
+	c.Assert(flags, Equals, syscall.MS_BIND)
+}
+
+func (s *entrySuite) TestXSnapdOptions(c *C) {
+	e := &mount.Entry{}
+	c.Check(mount.XSnapdKind(e), Equals, "")
+	c.Check(mount.XSnapdSymlink(e), Equals, "")
+
+	e = &mount.Entry{Options: []string{"x-snapd.kind=symlink", "x-snapd.symlink=/foo"}}
+	c.Check(mount.XSnapdKind(e), Equals, "symlink")
+	c.Check(mount.XSnapdSymlink(e), Equals, "/foo")
 }
@@ -0,0 +1,41 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mount
+
+import (
+	"os"
+)
+
+// MockSafeKeepingDir replaces the location where read-only directories
+// are kept while writable mimics are constructed.
+func MockSafeKeepingDir(dir string) (restore func()) {
+	old := safeKeepingDir
+	safeKeepingDir = dir
+	return func() { safeKeepingDir = old }
+}
+
+// MockSystemCalls replaces the system calls used to perform changes.
+func MockSystemCalls(mount func(string, string, string, uintptr, string) error, unmount func(string, int) error, mkdir func(string, os.FileMode) error) (restore func()) {
+	oldMount, oldUnmount, oldMkdir := sysMount, sysUnmount, osMkdir
+	sysMount, sysUnmount, osMkdir = mount, unmount, mkdir
+	return func() {
+		sysMount, sysUnmount, osMkdir = oldMount, oldUnmount, oldMkdir
+	}
+}
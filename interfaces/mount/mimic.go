@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mount
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+// safeKeepingDir is where a read-only directory is temporarily made
+// available while a writable mimic is constructed over it.
+var safeKeepingDir = "/tmp/.snap"
+
+var (
+	sysMount   = syscall.Mount
+	sysUnmount = syscall.Unmount
+	osMkdir    = os.Mkdir
+)
+
+// isReadOnly returns whether the given error was caused by a read-only
+// file system.
+func isReadOnly(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		return e.Err == syscall.EROFS
+	case *os.LinkError:
+		return e.Err == syscall.EROFS
+	}
+	return err == syscall.EROFS
+}
+
+// withMimic calls create and, if it fails because dir is read-only,
+// constructs a writable mimic of dir and calls create again. It returns
+// the changes made to construct the mimic.
+func withMimic(dir, neededBy string, create func() error) ([]*Change, error) {
+	err := create()
+	if !isReadOnly(err) {
+		return nil, err
+	}
+	changes, err := createWritableMimic(dir, neededBy)
+	if err != nil {
+		return changes, err
+	}
+	return changes, create()
+}
+
+// ensureDir creates the given directory and any missing parents,
+// constructing writable mimics of read-only parents as needed.
+func ensureDir(dir, neededBy string) ([]*Change, error) {
+	var changes []*Change
+	parent := "/"
+	for _, name := range strings.Split(strings.Trim(path.Clean(dir), "/"), "/") {
+		if name == "" {
+			continue
+		}
+		current := path.Join(parent, name)
+		synthetic, err := withMimic(parent, neededBy, func() error {
+			if err := osMkdir(current, 0755); err != nil && !os.IsExist(err) {
+				return err
+			}
+			return nil
+		})
+		changes = append(changes, synthetic...)
+		if err != nil {
+			return changes, fmt.Errorf("cannot create directory %q: %s", current, err)
+		}
+		parent = current
+	}
+	return changes, nil
+}
+
+// ensureFile creates the given empty file and any missing parents,
+// constructing writable mimics of read-only parents as needed.
+func ensureFile(name, neededBy string) ([]*Change, error) {
+	changes, err := ensureDir(path.Dir(name), neededBy)
+	if err != nil {
+		return changes, err
+	}
+	synthetic, err := withMimic(path.Dir(name), neededBy, func() error {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_RDONLY, 0644)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	})
+	changes = append(changes, synthetic...)
+	if err != nil {
+		return changes, fmt.Errorf("cannot create file %q: %s", name, err)
+	}
+	return changes, nil
+}
+
+// ensureSymlink creates the given symbolic link and any missing
+// parents, constructing writable mimics of read-only parents as needed.
+func ensureSymlink(name, target, neededBy string) ([]*Change, error) {
+	changes, err := ensureDir(path.Dir(name), neededBy)
+	if err != nil {
+		return changes, err
+	}
+	synthetic, err := withMimic(path.Dir(name), neededBy, func() error {
+		return os.Symlink(target, name)
+	})
+	changes = append(changes, synthetic...)
+	if err != nil {
+		return changes, fmt.Errorf("cannot create symlink %q: %s", name, err)
+	}
+	return changes, nil
+}
+
+// createWritableMimic replaces the given read-only directory with a
+// tmpfs that replicates its original content.
+//
+// The original directory is first bind mounted to a safe keeping
+// location. A tmpfs is then mounted over the directory and each of its
+// original entries is recreated inside it: directories and files are
+// bind mounted from the safe keeping location and symbolic links are
+// copied. The tmpfs gets the mode and ownership of the original
+// directory, so that the mimic is no more writable than it was, and
+// the changes made are returned, all marked as synthetic and
+// as needed by the given path, so that they can be torn down once
+// they are no longer needed.
+func createWritableMimic(dir, neededBy string) ([]*Change, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot inspect directory %q: %s", dir, err)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil, fmt.Errorf("cannot get ownership of directory %q", dir)
+	}
+	tmpfsOpts := fmt.Sprintf("mode=%#o,uid=%d,gid=%d", st.Mode&07777, st.Uid, st.Gid)
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read directory %q: %s", dir, err)
+	}
+	// Symbolic links are copied, so read their targets while they are
+	// still visible.
+	targets := make(map[string]string)
+	for _, fi := range entries {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		name := path.Join(dir, fi.Name())
+		target, err := os.Readlink(name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read symlink %q: %s", name, err)
+		}
+		targets[fi.Name()] = target
+	}
+
+	safeDir := path.Join(safeKeepingDir, dir)
+	if err := os.MkdirAll(safeDir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create directory %q: %s", safeDir, err)
+	}
+	if err := sysMount(dir, safeDir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return nil, fmt.Errorf("cannot bind mount %q to %q: %s", dir, safeDir, err)
+	}
+	defer sysUnmount(safeDir, syscall.MNT_DETACH)
+
+	syntheticOpts := []string{"x-snapd.synthetic", "x-snapd.needed-by=" + neededBy}
+	var changes []*Change
+	// undo reverts the changes made so far, in reverse order.
+	undo := func() {
+		for i := len(changes) - 1; i >= 0; i-- {
+			undoChange := &Change{Action: Unmount, Entry: changes[i].Entry}
+			undoChange.Perform()
+		}
+	}
+
+	if err := sysMount("tmpfs", dir, "tmpfs", 0, tmpfsOpts); err != nil {
+		return nil, fmt.Errorf("cannot mount tmpfs on %q: %s", dir, err)
+	}
+	changes = append(changes, &Change{Action: Mount, Entry: Entry{
+		Name: "tmpfs", Dir: dir, Type: "tmpfs", Options: syntheticOpts,
+	}})
+
+	for _, fi := range entries {
+		name := path.Join(dir, fi.Name())
+		safeName := path.Join(safeDir, fi.Name())
+		var entry Entry
+		switch mode := fi.Mode(); {
+		case mode&os.ModeSymlink != 0:
+			target := targets[fi.Name()]
+			if err := os.Symlink(target, name); err != nil && !os.IsExist(err) {
+				undo()
+				return nil, fmt.Errorf("cannot create symlink %q: %s", name, err)
+			}
+			entry = Entry{Dir: name, Options: append([]string{"x-snapd.kind=symlink", "x-snapd.symlink=" + target}, syntheticOpts...)}
+		case mode.IsDir():
+			if err := osMkdir(name, mode.Perm()); err != nil && !os.IsExist(err) {
+				undo()
+				return nil, fmt.Errorf("cannot create directory %q: %s", name, err)
+			}
+			if err := sysMount(safeName, name, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+				undo()
+				return nil, fmt.Errorf("cannot bind mount %q to %q: %s", safeName, name, err)
+			}
+			entry = Entry{Name: safeName, Dir: name, Options: append([]string{"rbind"}, syntheticOpts...)}
+		default:
+			f, err := os.OpenFile(name, os.O_CREATE|os.O_RDONLY, mode.Perm())
+			if err != nil {
+				undo()
+				return nil, fmt.Errorf("cannot create file %q: %s", name, err)
+			}
+			f.Close()
+			if err := sysMount(safeName, name, "", syscall.MS_BIND, ""); err != nil {
+				undo()
+				return nil, fmt.Errorf("cannot bind mount %q to %q: %s", safeName, name, err)
+			}
+			entry = Entry{Name: safeName, Dir: name, Options: append([]string{"bind", "x-snapd.kind=file"}, syntheticOpts...)}
+		}
+		changes = append(changes, &Change{Action: Mount, Entry: entry})
+	}
+
+	return changes, nil
+}
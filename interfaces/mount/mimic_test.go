@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mount_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/mount"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type mimicSuite struct {
+	root     string
+	readOnly map[string]bool
+	calls    []string
+	restore  []func()
+}
+
+var _ = Suite(&mimicSuite{})
+
+func (s *mimicSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	s.readOnly = make(map[string]bool)
+	s.calls = nil
+	s.restore = []func(){
+		mount.MockSafeKeepingDir(filepath.Join(s.root, "safe")),
+		mount.MockSystemCalls(s.mount, s.unmount, s.mkdir),
+	}
+}
+
+func (s *mimicSuite) TearDownTest(c *C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+}
+
+func (s *mimicSuite) mount(source, target, fstype string, flags uintptr, data string) error {
+	call := fmt.Sprintf("mount %s %s %s %d", source, target, fstype, flags)
+	if data != "" {
+		call += " " + data
+	}
+	s.calls = append(s.calls, call)
+	if fstype == "tmpfs" {
+		// the mimic makes the directory writable
+		delete(s.readOnly, target)
+	}
+	return nil
+}
+
+func (s *mimicSuite) unmount(target string, flags int) error {
+	s.calls = append(s.calls, fmt.Sprintf("unmount %s %d", target, flags))
+	return nil
+}
+
+func (s *mimicSuite) mkdir(name string, perm os.FileMode) error {
+	if s.readOnly[filepath.Dir(name)] {
+		return &os.PathError{Op: "mkdir", Path: name, Err: syscall.EROFS}
+	}
+	return os.Mkdir(name, perm)
+}
+
+// Mount points in writable locations are simply created.
+func (s *mimicSuite) TestPerformCreatesMountPoint(c *C) {
+	dir := filepath.Join(s.root, "a/b/c")
+	change := &mount.Change{Action: mount.Mount, Entry: mount.Entry{Name: "/src", Dir: dir, Options: []string{"bind"}}}
+	synthesised, err := change.Perform()
+	c.Assert(err, IsNil)
+	c.Check(synthesised, HasLen, 0)
+	c.Check(s.calls, DeepEquals, []string{fmt.Sprintf("mount /src %s  %d", dir, syscall.MS_BIND)})
+	fi, err := os.Stat(dir)
+	c.Assert(err, IsNil)
+	c.Check(fi.IsDir(), Equals, true)
+
+	file := filepath.Join(s.root, "d/file")
+	change = &mount.Change{Action: mount.Mount, Entry: mount.Entry{Name: "/src-file", Dir: file, Options: []string{"bind", "x-snapd.kind=file"}}}
+	synthesised, err = change.Perform()
+	c.Assert(err, IsNil)
+	c.Check(synthesised, HasLen, 0)
+	fi, err = os.Stat(file)
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().IsRegular(), Equals, true)
+}
+
+// Mount points in read-only locations are created in a writable mimic.
+func (s *mimicSuite) TestPerformCreatesWritableMimic(c *C) {
+	ro := filepath.Join(s.root, "ro")
+	c.Assert(os.MkdirAll(filepath.Join(ro, "dir"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(ro, "file"), nil, 0644), IsNil)
+	c.Assert(os.Symlink("file", filepath.Join(ro, "link")), IsNil)
+	c.Assert(os.Chmod(ro, 0755), IsNil)
+	s.readOnly[ro] = true
+
+	dir := filepath.Join(ro, "new/dir")
+	safe := filepath.Join(s.root, "safe", ro)
+	change := &mount.Change{Action: mount.Mount, Entry: mount.Entry{Name: "/src", Dir: dir, Options: []string{"rbind"}}}
+	synthesised, err := change.Perform()
+	c.Assert(err, IsNil)
+
+	synthetic := []string{"x-snapd.synthetic", "x-snapd.needed-by=" + dir}
+	c.Check(synthesised, DeepEquals, []*mount.Change{
+		{Action: mount.Mount, Entry: mount.Entry{Name: "tmpfs", Dir: ro, Type: "tmpfs", Options: synthetic}},
+		{Action: mount.Mount, Entry: mount.Entry{Name: filepath.Join(safe, "dir"), Dir: filepath.Join(ro, "dir"), Options: append([]string{"rbind"}, synthetic...)}},
+		{Action: mount.Mount, Entry: mount.Entry{Name: filepath.Join(safe, "file"), Dir: filepath.Join(ro, "file"), Options: append([]string{"bind", "x-snapd.kind=file"}, synthetic...)}},
+		{Action: mount.Mount, Entry: mount.Entry{Dir: filepath.Join(ro, "link"), Options: append([]string{"x-snapd.kind=symlink", "x-snapd.symlink=file"}, synthetic...)}},
+	})
+	c.Check(s.calls, DeepEquals, []string{
+		fmt.Sprintf("mount %s %s  %d", ro, safe, syscall.MS_BIND|syscall.MS_REC),
+		fmt.Sprintf("mount tmpfs %s tmpfs 0 mode=0755,uid=%d,gid=%d", ro, os.Getuid(), os.Getgid()),
+		fmt.Sprintf("mount %s/dir %s/dir  %d", safe, ro, syscall.MS_BIND|syscall.MS_REC),
+		fmt.Sprintf("mount %s/file %s/file  %d", safe, ro, syscall.MS_BIND),
+		fmt.Sprintf("unmount %s %d", safe, syscall.MNT_DETACH),
+		fmt.Sprintf("mount /src %s  %d", dir, syscall.MS_BIND|syscall.MS_REC),
+	})
+	fi, err := os.Stat(dir)
+	c.Assert(err, IsNil)
+	c.Check(fi.IsDir(), Equals, true)
+}
+
+// The tmpfs of the mimic keeps the mode and ownership of the original
+// directory rather than being world-writable.
+func (s *mimicSuite) TestWritableMimicKeepsModeAndOwnership(c *C) {
+	ro := filepath.Join(s.root, "ro")
+	c.Assert(os.MkdirAll(ro, 0755), IsNil)
+	c.Assert(os.Chmod(ro, 0750|os.ModeSetgid), IsNil)
+	s.readOnly[ro] = true
+
+	change := &mount.Change{Action: mount.Mount, Entry: mount.Entry{Name: "/src", Dir: filepath.Join(ro, "dir"), Options: []string{"bind"}}}
+	_, err := change.Perform()
+	c.Assert(err, IsNil)
+	c.Check(s.calls, testutil.Contains, fmt.Sprintf("mount tmpfs %s tmpfs 0 mode=02750,uid=%d,gid=%d", ro, os.Getuid(), os.Getgid()))
+}
+
+// Synthetic changes are reported even if the change itself fails.
+func (s *mimicSuite) TestPerformReportsMimicOnFailure(c *C) {
+	ro := filepath.Join(s.root, "ro")
+	c.Assert(os.MkdirAll(ro, 0755), IsNil)
+	s.readOnly[ro] = true
+	restore := mount.MockSystemCalls(func(source, target, fstype string, flags uintptr, data string) error {
+		if target == filepath.Join(ro, "dir") {
+			return syscall.EPERM
+		}
+		return s.mount(source, target, fstype, flags, data)
+	}, s.unmount, s.mkdir)
+	defer restore()
+
+	change := &mount.Change{Action: mount.Mount, Entry: mount.Entry{Name: "/src", Dir: filepath.Join(ro, "dir"), Options: []string{"bind"}}}
+	synthesised, err := change.Perform()
+	c.Assert(err, Equals, syscall.EPERM)
+	c.Assert(synthesised, HasLen, 1)
+	c.Check(synthesised[0].Entry.Type, Equals, "tmpfs")
+}
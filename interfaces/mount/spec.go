@@ -20,7 +20,10 @@
 package mount
 
 import (
+	"sort"
+
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
 )
 
 // Specification assists in collecting mount entries associated with an interface.
@@ -45,6 +48,42 @@ func (spec *Specification) MountEntries() []Entry {
 	return result
 }
 
+// AddSnapLayout adds mount entries based on the layout of the snap.
+func (spec *Specification) AddSnapLayout(si *snap.Info) {
+	paths := make([]string, 0, len(si.Layout))
+	for path := range si.Layout {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		spec.AddMountEntry(layoutEntry(si.Layout[path]))
+	}
+}
+
+// layoutEntry returns the mount entry corresponding to a single element
+// of the layout of a snap.
+func layoutEntry(l *snap.Layout) Entry {
+	si := l.Snap
+	entry := Entry{Dir: si.ExpandSnapVariables(l.Path)}
+	switch {
+	case l.Bind != "":
+		entry.Name = si.ExpandSnapVariables(l.Bind)
+		entry.Options = []string{"rbind", "rw"}
+	case l.BindFile != "":
+		entry.Name = si.ExpandSnapVariables(l.BindFile)
+		entry.Options = []string{"bind", "rw", "x-snapd.kind=file"}
+	case l.Type != "":
+		entry.Name = l.Type
+		entry.Type = l.Type
+		entry.Options = []string{"rw"}
+	case l.Symlink != "":
+		entry.Options = []string{"x-snapd.kind=symlink", "x-snapd.symlink=" + si.ExpandSnapVariables(l.Symlink)}
+	}
+	entry.Options = append(entry.Options, "x-snapd.origin=layout")
+	return entry
+}
+
 // Implementation of methods required by interfaces.Specification
 
 // AddConnectedPlug records mount-specific side-effects of having a connected plug.
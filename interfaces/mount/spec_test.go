@@ -91,3 +91,35 @@ func (s *specSuite) TestSpecificationIface(c *C) {
 		{Name: "connected-plug"}, {Name: "connected-slot"},
 		{Name: "permanent-plug"}, {Name: "permanent-slot"}})
 }
+
+// The layout of a snap is turned into mount entries
+func (s *specSuite) TestAddSnapLayout(c *C) {
+	info := &snap.Info{
+		SuggestedName: "vanguard",
+		SideInfo:      snap.SideInfo{Revision: snap.R(42)},
+	}
+	info.Layout = map[string]*snap.Layout{
+		"/usr/share/foo": {Snap: info, Path: "/usr/share/foo", Bind: "$SNAP/usr/share/foo"},
+		"/etc/foo.conf":  {Snap: info, Path: "/etc/foo.conf", BindFile: "$SNAP_DATA/foo.conf"},
+		"/var/cache/foo": {Snap: info, Path: "/var/cache/foo", Type: "tmpfs"},
+		"/usr/lib/foo":   {Snap: info, Path: "/usr/lib/foo", Symlink: "$SNAP_COMMON/lib/foo"},
+	}
+	s.spec.AddSnapLayout(info)
+	c.Assert(s.spec.MountEntries(), DeepEquals, []mount.Entry{{
+		Name:    info.DataDir() + "/foo.conf",
+		Dir:     "/etc/foo.conf",
+		Options: []string{"bind", "rw", "x-snapd.kind=file", "x-snapd.origin=layout"},
+	}, {
+		Dir:     "/usr/lib/foo",
+		Options: []string{"x-snapd.kind=symlink", "x-snapd.symlink=" + info.CommonDataDir() + "/lib/foo", "x-snapd.origin=layout"},
+	}, {
+		Name:    info.MountDir() + "/usr/share/foo",
+		Dir:     "/usr/share/foo",
+		Options: []string{"rbind", "rw", "x-snapd.origin=layout"},
+	}, {
+		Name:    "tmpfs",
+		Dir:     "/var/cache/foo",
+		Type:    "tmpfs",
+		Options: []string{"rw", "x-snapd.origin=layout"},
+	}})
+}
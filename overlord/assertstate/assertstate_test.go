@@ -39,6 +39,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/progress"
@@ -450,6 +451,110 @@ func (s *assertMgrSuite) TestValidateSnap(c *C) {
 	c.Check(snapRev.(*asserts.SnapRevision).SnapRevision(), Equals, 10)
 }
 
+// useStoreDirectory puts foo revision 10 with the given assertions in a
+// directory that is then used as the store
+func (s *assertMgrSuite) useStoreDirectory(c *C, refs ...*asserts.Ref) {
+	dir := c.MkDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "foo_10.snap"), fakeSnap(10), 0644)
+	c.Assert(err, IsNil)
+
+	var buf bytes.Buffer
+	enc := asserts.NewEncoder(&buf)
+	for _, ref := range refs {
+		a, err := ref.Resolve(s.storeSigning.Find)
+		c.Assert(err, IsNil)
+		c.Assert(enc.Encode(a), IsNil)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "foo.assert"), buf.Bytes(), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "store.directory", dir), IsNil)
+	tr.Commit()
+}
+
+func (s *assertMgrSuite) TestValidateSnapFromStoreDirectory(c *C) {
+	s.prereqSnapAssertions(c, 10)
+	s.useStoreDirectory(c,
+		&asserts.Ref{Type: asserts.SnapDeclarationType, PrimaryKey: []string{"16", "snap-id-1"}},
+		&asserts.Ref{Type: asserts.SnapRevisionType, PrimaryKey: []string{makeDigest(10)}},
+		&asserts.Ref{Type: asserts.AccountType, PrimaryKey: []string{s.dev1Acct.AccountID()}},
+		s.storeSigning.StoreAccountKey("").Ref(),
+	)
+
+	tempdir := c.MkDir()
+	snapPath := filepath.Join(tempdir, "foo.snap")
+	err := ioutil.WriteFile(snapPath, fakeSnap(10), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("validate-snap", "Fetch and check snap assertions")
+	t.Set("snap-setup", snapstate.SnapSetup{
+		SnapPath: snapPath,
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "snap-id-1",
+			Revision: snap.R(10),
+		},
+	})
+	chg.AddTask(t)
+
+	s.state.Unlock()
+	defer s.mgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+
+	snapRev, err := assertstate.DB(s.state).Find(asserts.SnapRevisionType, map[string]string{
+		"snap-id":       "snap-id-1",
+		"snap-sha3-384": makeDigest(10),
+	})
+	c.Assert(err, IsNil)
+	c.Check(snapRev.(*asserts.SnapRevision).SnapRevision(), Equals, 10)
+}
+
+func (s *assertMgrSuite) TestValidateSnapFromStoreDirectoryUnsigned(c *C) {
+	s.prereqSnapAssertions(c, 10)
+	// the snap-revision is missing from the directory
+	s.useStoreDirectory(c,
+		&asserts.Ref{Type: asserts.SnapDeclarationType, PrimaryKey: []string{"16", "snap-id-1"}},
+		s.storeSigning.StoreAccountKey("").Ref(),
+	)
+
+	tempdir := c.MkDir()
+	snapPath := filepath.Join(tempdir, "foo.snap")
+	err := ioutil.WriteFile(snapPath, fakeSnap(10), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("validate-snap", "Fetch and check snap assertions")
+	t.Set("snap-setup", snapstate.SnapSetup{
+		SnapPath: snapPath,
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			SnapID:   "snap-id-1",
+			Revision: snap.R(10),
+		},
+	})
+	chg.AddTask(t)
+
+	s.state.Unlock()
+	defer s.mgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), ErrorMatches, `(?s).*cannot verify snap "foo", no matching signatures found.*`)
+}
+
 func (s *assertMgrSuite) TestValidateSnapNotFound(c *C) {
 	tempdir := c.MkDir()
 	snapPath := filepath.Join(tempdir, "foo.snap")
@@ -226,3 +226,42 @@ func DeleteSnapConfig(st *state.State, snapName string) error {
 	}
 	return nil
 }
+
+// GetSnapConfig retrieves the raw configuration of a given snap, or nil
+// if the snap has no configuration.
+// The caller is responsible for locking the state.
+func GetSnapConfig(st *state.State, snapName string) (*json.RawMessage, error) {
+	var config map[string]*json.RawMessage // snap => configuration
+
+	err := st.Get("config", &config)
+	if err == state.ErrNoState {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("internal error: cannot unmarshal configuration: %v", err)
+	}
+
+	return config[snapName], nil
+}
+
+// SetSnapConfig replaces the configuration of a given snap with the
+// given raw configuration; a nil one removes the configuration of the
+// snap altogether.
+// The caller is responsible for locking the state.
+func SetSnapConfig(st *state.State, snapName string, snapcfg *json.RawMessage) error {
+	var config map[string]*json.RawMessage // snap => configuration
+
+	err := st.Get("config", &config)
+	if err == state.ErrNoState {
+		config = make(map[string]*json.RawMessage)
+	} else if err != nil {
+		return fmt.Errorf("internal error: cannot unmarshal configuration: %v", err)
+	}
+
+	if snapcfg == nil {
+		delete(config, snapName)
+	} else {
+		config[snapName] = snapcfg
+	}
+	st.Set("config", config)
+	return nil
+}
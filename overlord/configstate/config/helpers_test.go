@@ -136,3 +136,43 @@ func (s *configHelpersSuite) TestConfigSnapshotNoConfigs(c *C) {
 	// no configuration to restore in revision-config
 	c.Assert(config.RestoreRevisionConfig(s.state, "snap1", snap.R(1)), IsNil)
 }
+
+func (s *configHelpersSuite) TestGetSetSnapConfig(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// no config at all
+	cfg, err := config.GetSnapConfig(s.state, "snap1")
+	c.Assert(err, IsNil)
+	c.Check(cfg, IsNil)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("snap1", "foo", "a"), IsNil)
+	c.Assert(tr.Set("snap2", "bar", "q"), IsNil)
+	tr.Commit()
+
+	cfg, err = config.GetSnapConfig(s.state, "snap1")
+	c.Assert(err, IsNil)
+	c.Assert(cfg, NotNil)
+	c.Check(string(*cfg), Equals, `{"foo":"a"}`)
+
+	// replace the config of snap1 with that of snap2
+	cfg, err = config.GetSnapConfig(s.state, "snap2")
+	c.Assert(err, IsNil)
+	c.Assert(config.SetSnapConfig(s.state, "snap1", cfg), IsNil)
+
+	var value string
+	tr = config.NewTransaction(s.state)
+	c.Check(tr.Get("snap1", "bar", &value), IsNil)
+	c.Check(value, Equals, "q")
+	c.Check(config.IsNoOption(tr.Get("snap1", "foo", &value)), Equals, true)
+
+	// a nil config removes it altogether
+	c.Assert(config.SetSnapConfig(s.state, "snap1", nil), IsNil)
+	cfg, err = config.GetSnapConfig(s.state, "snap1")
+	c.Assert(err, IsNil)
+	c.Check(cfg, IsNil)
+	cfg, err = config.GetSnapConfig(s.state, "snap2")
+	c.Assert(err, IsNil)
+	c.Check(cfg, NotNil)
+}
@@ -40,5 +40,9 @@ func Manager(s *state.State, hookManager *hookstate.HookManager) (*ConfigManager
 
 	hookManager.Register(regexp.MustCompile("^configure$"), newConfigureHandler)
 
+	s.Lock()
+	applyProxyConfig(s)
+	s.Unlock()
+
 	return manager, nil
 }
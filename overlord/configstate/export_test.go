@@ -19,4 +19,14 @@
 
 package configstate
 
+import (
+	"github.com/snapcore/snapd/httputil"
+)
+
 var NewConfigureHandler = newConfigureHandler
+
+func MockConfigureTransport(mock func(proxy *httputil.ProxyConfig, certsDir string) error) (restore func()) {
+	old := httputilConfigureTransport
+	httputilConfigureTransport = mock
+	return func() { httputilConfigureTransport = old }
+}
@@ -51,6 +51,8 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 
 	context.OnDone(func() error {
 		tr.Commit()
+		// apply the proxies right away, with no need to restart
+		applyProxyConfig(context.State())
 		return nil
 	})
 
@@ -114,7 +116,15 @@ func (h *configureHandler) Before() error {
 // Done is called by the HookManager after the configure hook has exited
 // successfully.
 func (h *configureHandler) Done() error {
-	return nil
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	if h.context.SnapName() != "core" {
+		return nil
+	}
+	// the proxies are refused before being committed, rather than
+	// when applying them
+	return validateProxyConfig(ContextTransaction(h.context))
 }
 
 // Error is called by the HookManager after the configure hook has exited
@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// overridden in the tests
+var httputilConfigureTransport = httputil.ConfigureTransport
+
+// ProxyConfig returns the proxies set with the proxy.http, proxy.https
+// and proxy.no-proxy core options, or nil if none of them is set.
+func ProxyConfig(st *state.State) (*httputil.ProxyConfig, error) {
+	return proxyConfig(config.NewTransaction(st))
+}
+
+// proxyConfig is ProxyConfig as seen by the given transaction.
+func proxyConfig(tr *config.Transaction) (*httputil.ProxyConfig, error) {
+	var proxy httputil.ProxyConfig
+	for _, opt := range []struct {
+		key   string
+		value *string
+	}{
+		{"proxy.http", &proxy.HTTP},
+		{"proxy.https", &proxy.HTTPS},
+		{"proxy.no-proxy", &proxy.NoProxy},
+	} {
+		if err := tr.Get("core", opt.key, opt.value); err != nil && !config.IsNoOption(err) {
+			return nil, err
+		}
+	}
+	if proxy == (httputil.ProxyConfig{}) {
+		return nil, nil
+	}
+	return &proxy, nil
+}
+
+// validateProxyConfig checks that the proxies set in the transaction
+// can be used.
+func validateProxyConfig(tr *config.Transaction) error {
+	proxy, err := proxyConfig(tr)
+	if err != nil {
+		return err
+	}
+	if proxy == nil {
+		return nil
+	}
+	return proxy.Validate()
+}
+
+// applyProxyConfig makes the HTTP clients of snapd use the configured
+// proxies and trust the certificates in the directory of additional
+// certificates. Later changes to that directory are picked up by the
+// clients on their own, without calling this again.
+func applyProxyConfig(st *state.State) {
+	proxy, err := ProxyConfig(st)
+	if err != nil {
+		logger.Noticef("cannot use proxy configuration: %v", err)
+		proxy = nil
+	}
+	if err := httputilConfigureTransport(proxy, dirs.SnapdExtraCertsDir); err != nil {
+		logger.Noticef("cannot use proxy configuration: %v", err)
+	}
+}
@@ -93,6 +93,12 @@ func (c *Context) ID() string {
 	return c.id
 }
 
+// Task returns the task associated with the hook or (nil, false) if the context is ephemeral
+// and the task is not available.
+func (c *Context) Task() (*state.Task, bool) {
+	return c.task, c.task != nil
+}
+
 // Handler returns the handler for this context
 func (c *Context) Handler() Handler {
 	return c.handler
@@ -151,3 +151,15 @@ func (s *contextSuite) TestEphemeralContextGetSet(c *C) {
 	// Test another non-existing key, but after the context data was created.
 	c.Check(context.Get("baz", &output), NotNil)
 }
+
+func (s *contextSuite) TestTask(c *C) {
+	task, ok := s.context.Task()
+	c.Check(ok, Equals, true)
+	c.Check(task, Equals, s.task)
+
+	context, err := NewContext(nil, s.state, &HookSetup{Snap: "test-snap"}, nil, "")
+	c.Assert(err, IsNil)
+	task, ok = context.Task()
+	c.Check(ok, Equals, false)
+	c.Check(task, IsNil)
+}
@@ -35,6 +35,7 @@ type baseCommand struct {
 	stdout io.Writer
 	stderr io.Writer
 	c      *hookstate.Context
+	uid    uint32
 }
 
 func (c *baseCommand) setStdout(w io.Writer) {
@@ -65,6 +66,41 @@ func (c *baseCommand) context() *hookstate.Context {
 	return c.c
 }
 
+func (c *baseCommand) setUid(uid uint32) {
+	c.uid = uid
+}
+
+// ensureRoot returns a ForbiddenCommandError unless the command was
+// invoked by root. Commands that change the snap or its services use
+// it, as in an app context snapctl can be run by any user.
+func (c *baseCommand) ensureRoot(name string) error {
+	if c.uid != 0 {
+		return &ForbiddenCommandError{Message: fmt.Sprintf("cannot use %q with uid %d, try with sudo", name, c.uid)}
+	}
+	return nil
+}
+
+// ForbiddenCommandError is returned when a command is invoked by a
+// user that is not allowed to use it.
+type ForbiddenCommandError struct {
+	Message string
+}
+
+func (e *ForbiddenCommandError) Error() string {
+	return e.Message
+}
+
+// UnsuccessfulError is returned by commands that ran fine but want snapctl
+// to exit with the given non-zero code, such as when answering a query
+// negatively.
+type UnsuccessfulError struct {
+	ExitCode int
+}
+
+func (e *UnsuccessfulError) Error() string {
+	return fmt.Sprintf("unsuccessful with exit code: %d", e.ExitCode)
+}
+
 type command interface {
 	setStdout(w io.Writer)
 	setStderr(w io.Writer)
@@ -72,6 +108,8 @@ type command interface {
 	setContext(context *hookstate.Context)
 	context() *hookstate.Context
 
+	setUid(uid uint32)
+
 	Execute(args []string) error
 }
 
@@ -91,8 +129,8 @@ func addCommand(name, shortHelp, longHelp string, generator func() command) {
 	}
 }
 
-// Run runs the requested command.
-func Run(context *hookstate.Context, args []string) (stdout, stderr []byte, err error) {
+// Run runs the requested command on behalf of the user with the given uid.
+func Run(context *hookstate.Context, args []string, uid uint32) (stdout, stderr []byte, err error) {
 	parser := flags.NewParser(nil, flags.PassDoubleDash|flags.HelpFlag)
 
 	// Create stdout/stderr buffers, and make sure commands use them.
@@ -103,6 +141,7 @@ func Run(context *hookstate.Context, args []string) (stdout, stderr []byte, err
 		cmd.setStdout(&stdoutBuffer)
 		cmd.setStderr(&stderrBuffer)
 		cmd.setContext(context)
+		cmd.setUid(uid)
 
 		_, err = parser.AddCommand(name, cmdInfo.shortHelp, cmdInfo.longHelp, cmd)
 		if err != nil {
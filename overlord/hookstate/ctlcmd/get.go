@@ -196,6 +196,8 @@ const (
 	prepareSlotHook
 	connectPlugHook
 	connectSlotHook
+	disconnectPlugHook
+	disconnectSlotHook
 	unknownHook
 )
 
@@ -208,6 +210,10 @@ func interfaceHookType(hookName string) (ifaceHookType, error) {
 		return prepareSlotHook, nil
 	} else if strings.HasPrefix(hookName, "connect-slot-") {
 		return connectSlotHook, nil
+	} else if strings.HasPrefix(hookName, "disconnect-plug-") {
+		return disconnectPlugHook, nil
+	} else if strings.HasPrefix(hookName, "disconnect-slot-") {
+		return disconnectSlotHook, nil
 	}
 	return unknownHook, fmt.Errorf("unknown hook type")
 }
@@ -275,7 +281,7 @@ func (c *getCommand) getInterfaceSetting(context *hookstate.Context, plugOrSlot
 		return fmt.Errorf("cannot use --plug and --slot together")
 	}
 
-	isPlugSide := (hookType == preparePlugHook || hookType == connectPlugHook)
+	isPlugSide := (hookType == preparePlugHook || hookType == connectPlugHook || hookType == disconnectPlugHook)
 	if err = validatePlugOrSlot(attrsTask, isPlugSide, plugOrSlot); err != nil {
 		return err
 	}
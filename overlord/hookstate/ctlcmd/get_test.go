@@ -135,7 +135,7 @@ func (s *getSuite) TestGetTests(c *C) {
 
 		state.Unlock()
 
-		stdout, stderr, err := ctlcmd.Run(mockContext, strings.Fields(test.args))
+		stdout, stderr, err := ctlcmd.Run(mockContext, strings.Fields(test.args), 0)
 		if test.error != "" {
 			c.Check(err, ErrorMatches, test.error)
 		} else {
@@ -147,7 +147,7 @@ func (s *getSuite) TestGetTests(c *C) {
 }
 
 func (s *getSuite) TestCommandWithoutContext(c *C) {
-	_, _, err := ctlcmd.Run(nil, []string{"get", "foo"})
+	_, _, err := ctlcmd.Run(nil, []string{"get", "foo"}, 0)
 	c.Check(err, ErrorMatches, ".*cannot get without a context.*")
 }
 
@@ -206,52 +206,86 @@ func (s *getAttrSuite) SetUpTest(c *C) {
 }
 
 func (s *getAttrSuite) TestGetPlugAttributesInPlugHook(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":aplug", "aattr"})
+	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":aplug", "aattr"}, 0)
 	c.Check(err, IsNil)
 	c.Check(string(stdout), Equals, "foo\n")
 	c.Check(string(stderr), Equals, "")
 
-	stdout, stderr, err = ctlcmd.Run(s.mockPlugHookContext, []string{"get", "-d", ":aplug", "baz"})
+	stdout, stderr, err = ctlcmd.Run(s.mockPlugHookContext, []string{"get", "-d", ":aplug", "baz"}, 0)
 	c.Check(err, IsNil)
 	c.Check(string(stdout), Equals, "{\n\t\"baz\": [\n\t\t\"a\",\n\t\t\"b\"\n\t]\n}\n")
 	c.Check(string(stderr), Equals, "")
 
 	// The --plug parameter doesn't do anything if used on plug side
-	stdout, stderr, err = ctlcmd.Run(s.mockPlugHookContext, []string{"get", "--plug", ":aplug", "aattr"})
+	stdout, stderr, err = ctlcmd.Run(s.mockPlugHookContext, []string{"get", "--plug", ":aplug", "aattr"}, 0)
 	c.Check(err, IsNil)
 	c.Check(string(stdout), Equals, "foo\n")
 	c.Check(string(stderr), Equals, "")
 }
 
 func (s *getAttrSuite) TestGetSlotAttributesInSlotHook(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", ":bslot", "battr"})
+	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", ":bslot", "battr"}, 0)
 	c.Check(err, IsNil)
 	c.Check(string(stdout), Equals, "bar\n")
 	c.Check(string(stderr), Equals, "")
 
 	// The --slot parameter doesn't do anything if used on slot side
-	stdout, stderr, err = ctlcmd.Run(s.mockSlotHookContext, []string{"get", "--slot", ":bslot", "battr"})
+	stdout, stderr, err = ctlcmd.Run(s.mockSlotHookContext, []string{"get", "--slot", ":bslot", "battr"}, 0)
 	c.Check(err, IsNil)
 	c.Check(string(stdout), Equals, "bar\n")
 	c.Check(string(stderr), Equals, "")
 }
 
 func (s *getAttrSuite) TestGetSlotAttributeInPlugHook(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", "--slot", ":aplug", "battr"})
+	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", "--slot", ":aplug", "battr"}, 0)
 	c.Check(err, IsNil)
 	c.Check(string(stdout), Equals, "bar\n")
 	c.Check(string(stderr), Equals, "")
 }
 
 func (s *getAttrSuite) TestGetPlugAttributeInSlotHook(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", "--plug", ":bslot", "aattr"})
+	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", "--plug", ":bslot", "aattr"}, 0)
 	c.Check(err, IsNil)
 	c.Check(string(stdout), Equals, "foo\n")
 	c.Check(string(stderr), Equals, "")
 }
 
+func (s *getAttrSuite) TestGetAttributesInDisconnectHooks(c *C) {
+	var attrsTaskID string
+	s.mockPlugHookContext.Lock()
+	c.Assert(s.mockPlugHookContext.Get("attrs-task", &attrsTaskID), IsNil)
+	s.mockPlugHookContext.Unlock()
+
+	st := s.mockPlugHookContext.State()
+	for _, t := range []struct {
+		hook     string
+		args     []string
+		expected string
+	}{
+		{"disconnect-plug-aplug", []string{"get", ":aplug", "aattr"}, "foo\n"},
+		{"disconnect-plug-aplug", []string{"get", "--slot", ":aplug", "battr"}, "bar\n"},
+		{"disconnect-slot-bslot", []string{"get", ":bslot", "battr"}, "bar\n"},
+		{"disconnect-slot-bslot", []string{"get", "--plug", ":bslot", "aattr"}, "foo\n"},
+	} {
+		st.Lock()
+		task := st.NewTask("run-hook", "my test task")
+		st.Unlock()
+		setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: t.hook}
+		context, err := hookstate.NewContext(task, st, setup, s.mockHandler, "")
+		c.Assert(err, IsNil)
+		context.Lock()
+		context.Set("attrs-task", attrsTaskID)
+		context.Unlock()
+
+		stdout, stderr, err := ctlcmd.Run(context, t.args, 0)
+		c.Check(err, IsNil)
+		c.Check(string(stdout), Equals, t.expected)
+		c.Check(string(stderr), Equals, "")
+	}
+}
+
 func (s *getAttrSuite) TestUnknownPlugAttribute(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":aplug", "x"})
+	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":aplug", "x"}, 0)
 	c.Check(err, NotNil)
 	c.Check(err.Error(), Equals, `unknown attribute "x"`)
 	c.Check(string(stdout), Equals, "")
@@ -259,7 +293,7 @@ func (s *getAttrSuite) TestUnknownPlugAttribute(c *C) {
 }
 
 func (s *getAttrSuite) TestUnknownSlotAttribute(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", ":bslot", "x"})
+	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", ":bslot", "x"}, 0)
 	c.Check(err, NotNil)
 	c.Check(err.Error(), Equals, `unknown attribute "x"`)
 	c.Check(string(stdout), Equals, "")
@@ -267,7 +301,7 @@ func (s *getAttrSuite) TestUnknownSlotAttribute(c *C) {
 }
 
 func (s *getAttrSuite) TestUsingPlugNameInSlotHookFails(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", ":aplug", "x"})
+	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", ":aplug", "x"}, 0)
 	c.Check(err, NotNil)
 	c.Check(err.Error(), Equals, `unknown plug or slot "aplug"`)
 	c.Check(string(stdout), Equals, "")
@@ -275,7 +309,7 @@ func (s *getAttrSuite) TestUsingPlugNameInSlotHookFails(c *C) {
 }
 
 func (s *getAttrSuite) TestUsingSlotNameInPlugHookFails(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":bslot", "x"})
+	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":bslot", "x"}, 0)
 	c.Check(err, NotNil)
 	c.Check(err.Error(), Equals, `unknown plug or slot "bslot"`)
 	c.Check(string(stdout), Equals, "")
@@ -283,7 +317,7 @@ func (s *getAttrSuite) TestUsingSlotNameInPlugHookFails(c *C) {
 }
 
 func (s *getAttrSuite) TestForcePlugOrSlotMutuallyExclusive(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", "--slot", "--plug", ":aplug", "x"})
+	stdout, stderr, err := ctlcmd.Run(s.mockSlotHookContext, []string{"get", "--slot", "--plug", ":aplug", "x"}, 0)
 	c.Check(err, NotNil)
 	c.Check(err.Error(), Equals, `cannot use --plug and --slot together`)
 	c.Check(string(stdout), Equals, "")
@@ -291,7 +325,7 @@ func (s *getAttrSuite) TestForcePlugOrSlotMutuallyExclusive(c *C) {
 }
 
 func (s *getAttrSuite) TestPlugOrSlotEmpty(c *C) {
-	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":", "foo"})
+	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":", "foo"}, 0)
 	c.Check(err.Error(), Equals, "plug or slot name not provided")
 	c.Check(string(stdout), Equals, "")
 	c.Check(string(stderr), Equals, "")
@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n/dumb"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+)
+
+type isConnectedCommand struct {
+	baseCommand
+
+	Positional struct {
+		PlugOrSlot string `positional-arg-name:"<plug|slot>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortIsConnectedHelp = i18n.G("Return success if the given plug or slot is connected")
+var longIsConnectedHelp = i18n.G(`
+The is-connected command returns success if the given plug or slot of the
+calling snap is connected, and failure otherwise.
+
+    $ if snapctl is-connected plug; then echo "connected"; fi
+`)
+
+func init() {
+	addCommand("is-connected", shortIsConnectedHelp, longIsConnectedHelp, func() command { return &isConnectedCommand{} })
+}
+
+func (c *isConnectedCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot check connection status without a context")
+	}
+
+	context.Lock()
+	repo := ifacestate.Repository(context.State())
+	context.Unlock()
+
+	conns, err := repo.Connected(context.SnapName(), c.Positional.PlugOrSlot)
+	if err != nil {
+		return err
+	}
+	if len(conns) == 0 {
+		return &UnsuccessfulError{ExitCode: 1}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type isConnectedSuite struct {
+	state       *state.State
+	mockContext *hookstate.Context
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&isConnectedSuite{})
+
+const isConnectedConsumerYaml = `name: consumer
+version: 1
+plugs:
+  plug1:
+    interface: test
+  plug2:
+    interface: test
+`
+
+const isConnectedProducerYaml = `name: producer
+version: 1
+slots:
+  slot:
+    interface: test
+`
+
+func (s *isConnectedSuite) SetUpTest(c *C) {
+	s.mockHandler = hooktest.NewMockHandler()
+	s.state = state.New(nil)
+
+	repo := interfaces.NewRepository()
+	c.Assert(repo.AddInterface(&ifacetest.TestInterface{InterfaceName: "test"}), IsNil)
+	consumer := snaptest.MockInfo(c, isConnectedConsumerYaml, nil)
+	producer := snaptest.MockInfo(c, isConnectedProducerYaml, nil)
+	c.Assert(repo.AddSnap(consumer), IsNil)
+	c.Assert(repo.AddSnap(producer), IsNil)
+	c.Assert(repo.Connect(interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug1"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot"},
+	}), IsNil)
+
+	s.state.Lock()
+	ifacestate.ReplaceRepository(s.state, repo)
+	s.state.Unlock()
+
+	// snapctl is-connected is also used from apps, in an ephemeral context
+	var err error
+	s.mockContext, err = hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "consumer"}, s.mockHandler, "")
+	c.Assert(err, IsNil)
+}
+
+func (s *isConnectedSuite) TestConnected(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "plug1"}, 0)
+	c.Check(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *isConnectedSuite) TestSlotConnected(c *C) {
+	s.state.Lock()
+	task := s.state.NewTask("run-hook", "my hook task")
+	s.state.Unlock()
+	setup := &hookstate.HookSetup{Snap: "producer", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, s.state, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"is-connected", "slot"}, 0)
+	c.Check(err, IsNil)
+}
+
+func (s *isConnectedSuite) TestNotConnected(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "plug2"}, 0)
+	c.Check(err, DeepEquals, &ctlcmd.UnsuccessfulError{ExitCode: 1})
+}
+
+func (s *isConnectedSuite) TestUnknownPlugOrSlot(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "slot"}, 0)
+	c.Check(err, ErrorMatches, `snap "consumer" has no plug or slot named "slot"`)
+}
+
+func (s *isConnectedSuite) TestMissingArgument(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected"}, 0)
+	c.Check(err, ErrorMatches, "the required argument `<plug|slot>` was not provided")
+}
+
+func (s *isConnectedSuite) TestWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"is-connected", "plug1"}, 0)
+	c.Check(err, ErrorMatches, "cannot check connection status without a context")
+}
@@ -0,0 +1,298 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n/dumb"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type serviceNames struct {
+	ServiceNames []string `positional-arg-name:"<snap.app>" required:"1"`
+}
+
+type startCommand struct {
+	baseCommand
+
+	Positional serviceNames `positional-args:"yes" required:"yes"`
+	Enable     bool         `long:"enable" description:"also enable the services, so that they are started at boot"`
+}
+
+type stopCommand struct {
+	baseCommand
+
+	Positional serviceNames `positional-args:"yes" required:"yes"`
+	Disable    bool         `long:"disable" description:"also disable the services, so that they are not started at boot"`
+}
+
+type restartCommand struct {
+	baseCommand
+
+	Positional serviceNames `positional-args:"yes" required:"yes"`
+}
+
+type servicesCommand struct {
+	baseCommand
+
+	Positional struct {
+		ServiceNames []string `positional-arg-name:"<snap.app>"`
+	} `positional-args:"yes"`
+}
+
+var (
+	shortStartHelp = i18n.G("Start services")
+	longStartHelp  = i18n.G(`
+The start command starts the given services of the snap. If executed from a
+hook, the services are started after the hook completes.
+
+    $ snapctl start mysnap.mydaemon
+
+With --enable the services are also enabled, so that they are started at boot.
+`)
+
+	shortStopHelp = i18n.G("Stop services")
+	longStopHelp  = i18n.G(`
+The stop command stops the given services of the snap. If executed from a
+hook, the services are stopped after the hook completes.
+
+    $ snapctl stop mysnap.mydaemon
+
+With --disable the services are also disabled, so that they are not started
+at boot.
+`)
+
+	shortRestartHelp = i18n.G("Restart services")
+	longRestartHelp  = i18n.G(`
+The restart command restarts the given services of the snap. If executed from
+a hook, the services are restarted after the hook completes.
+
+    $ snapctl restart mysnap.mydaemon
+`)
+
+	shortServicesHelp = i18n.G("Query the status of services")
+	longServicesHelp  = i18n.G(`
+The services command lists the services of the snap, or just the given ones,
+showing whether they are enabled to start at boot and whether they are
+currently active.
+
+    $ snapctl services
+    Service           Startup  Current
+    mysnap.mydaemon   enabled  active
+`)
+)
+
+func init() {
+	addCommand("start", shortStartHelp, longStartHelp, func() command { return &startCommand{} })
+	addCommand("stop", shortStopHelp, longStopHelp, func() command { return &stopCommand{} })
+	addCommand("restart", shortRestartHelp, longRestartHelp, func() command { return &restartCommand{} })
+	addCommand("services", shortServicesHelp, longServicesHelp, func() command { return &servicesCommand{} })
+}
+
+func (c *startCommand) Execute(args []string) error {
+	if err := c.ensureRoot("start"); err != nil {
+		return err
+	}
+	return queueServiceControl(c.context(), &snapstate.ServiceAction{
+		Action: "start",
+		Enable: c.Enable,
+	}, c.Positional.ServiceNames)
+}
+
+func (c *stopCommand) Execute(args []string) error {
+	if err := c.ensureRoot("stop"); err != nil {
+		return err
+	}
+	return queueServiceControl(c.context(), &snapstate.ServiceAction{
+		Action:  "stop",
+		Disable: c.Disable,
+	}, c.Positional.ServiceNames)
+}
+
+func (c *restartCommand) Execute(args []string) error {
+	if err := c.ensureRoot("restart"); err != nil {
+		return err
+	}
+	return queueServiceControl(c.context(), &snapstate.ServiceAction{
+		Action: "restart",
+	}, c.Positional.ServiceNames)
+}
+
+func (c *servicesCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return errors.New(i18n.G("cannot query services without a context"))
+	}
+
+	context.Lock()
+	info, err := snapstate.CurrentInfo(context.State(), context.SnapName())
+	context.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var svcs []*snap.AppInfo
+	if len(c.Positional.ServiceNames) == 0 {
+		svcs = info.Services()
+		sort.Sort(snap.AppInfoBySnapApp(svcs))
+	} else {
+		svcs, err = snapServices(info, "query", c.Positional.ServiceNames)
+		if err != nil {
+			return err
+		}
+	}
+	if len(svcs) == 0 {
+		return nil
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &progress.NullProgress{})
+	w := tabwriter.NewWriter(c.stdout, 5, 3, 2, ' ', 0)
+	fmt.Fprintln(w, i18n.G("Service\tStartup\tCurrent"))
+	for _, app := range svcs {
+		status, err := sysd.ServiceStatus(app.ServiceName())
+		if err != nil {
+			return err
+		}
+		startup := i18n.G("disabled")
+		if status.UnitFileState == "enabled" {
+			startup = i18n.G("enabled")
+		}
+		current := i18n.G("inactive")
+		if status.ActiveState == "active" {
+			current = i18n.G("active")
+		}
+		fmt.Fprintf(w, "%s.%s\t%s\t%s\n", info.Name(), app.Name, startup, current)
+	}
+	return w.Flush()
+}
+
+// snapServices returns the services of the snap with the given
+// <snap>.<app> names, which must all belong to it.
+func snapServices(info *snap.Info, verb string, names []string) ([]*snap.AppInfo, error) {
+	svcs := make([]*snap.AppInfo, 0, len(names))
+	for _, name := range names {
+		parts := strings.SplitN(name, ".", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf(i18n.G("invalid service name %q (want <snap>.<app>)"), name)
+		}
+		if parts[0] != info.Name() {
+			return nil, fmt.Errorf(i18n.G("cannot %s service %q of snap %q from snap %q"), verb, name, parts[0], info.Name())
+		}
+		app, ok := info.Apps[parts[1]]
+		if !ok || !app.IsService() {
+			return nil, fmt.Errorf(i18n.G("unknown service: %q"), name)
+		}
+		svcs = append(svcs, app)
+	}
+	return svcs, nil
+}
+
+// serviceControlKey is the context cache key under which the service
+// actions requested in a context are accumulated.
+type serviceControlKey struct{}
+
+// queueServiceControl arranges for the action to be performed on the named
+// services of the snap of the context, once the context is done. In a hook
+// context the action is performed by a service-control task that runs right
+// after the hook, otherwise by a new change.
+func queueServiceControl(context *hookstate.Context, action *snapstate.ServiceAction, names []string) error {
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot %s services without a context"), action.Action)
+	}
+
+	context.Lock()
+	defer context.Unlock()
+
+	snapName := context.SnapName()
+	st := context.State()
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return err
+	}
+
+	svcs, err := snapServices(info, action.Action, names)
+	if err != nil {
+		return err
+	}
+	action.SnapName = snapName
+	for _, app := range svcs {
+		action.Services = append(action.Services, app.Name)
+	}
+
+	// all the requested actions are queued at once, in order, when the
+	// context is done
+	actions, _ := context.Cached(serviceControlKey{}).([]*snapstate.ServiceAction)
+	if actions == nil {
+		context.OnDone(func() error {
+			return injectServiceControl(context)
+		})
+	}
+	context.Cache(serviceControlKey{}, append(actions, action))
+	return nil
+}
+
+// injectServiceControl queues the service actions requested in the context.
+// The context must be locked by the caller.
+func injectServiceControl(context *hookstate.Context) error {
+	st := context.State()
+	actions, _ := context.Cached(serviceControlKey{}).([]*snapstate.ServiceAction)
+
+	tasks := make([]*state.Task, 0, len(actions))
+	for _, action := range actions {
+		t, err := snapstate.ServiceControl(st, action)
+		if err != nil {
+			return fmt.Errorf(i18n.G("cannot %s services of snap %q: %v"), action.Action, action.SnapName, err)
+		}
+		tasks = append(tasks, t)
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if hookTask, ok := context.Task(); ok {
+		snapstate.InjectTasks(hookTask, tasks)
+		return nil
+	}
+
+	summary := tasks[0].Summary()
+	if len(tasks) > 1 {
+		summary = fmt.Sprintf(i18n.G("Control services of snap %q"), context.SnapName())
+	}
+	chg := st.NewChange("service-control", summary)
+	for i, t := range tasks {
+		if i > 0 {
+			t.WaitFor(tasks[i-1])
+		}
+		chg.AddTask(t)
+	}
+	st.EnsureBefore(0)
+	return nil
+}
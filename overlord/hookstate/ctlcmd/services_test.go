@@ -0,0 +1,275 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+)
+
+type servicesSuite struct {
+	state       *state.State
+	hookTask    *state.Task
+	nextTask    *state.Task
+	mockContext *hookstate.Context
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&servicesSuite{})
+
+const servicesSnapYaml = `name: test-snap
+version: 1
+apps:
+  cmd:
+    command: bin/cmd
+  svc1:
+    command: bin/svc1
+    daemon: simple
+  svc2:
+    command: bin/svc2
+    daemon: simple
+`
+
+func (s *servicesSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, servicesSnapYaml, "", si)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+
+	chg := s.state.NewChange("test-change", "...")
+	s.hookTask = s.state.NewTask("run-hook", "my hook task")
+	s.nextTask = s.state.NewTask("next-task", "runs after the hook")
+	s.nextTask.WaitFor(s.hookTask)
+	chg.AddTask(s.hookTask)
+	chg.AddTask(s.nextTask)
+
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	var err error
+	s.mockContext, err = hookstate.NewContext(s.hookTask, s.state, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+}
+
+func (s *servicesSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (s *servicesSuite) mockSystemctl(c *C, status map[string]string) (restore func()) {
+	old := systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(args ...string) ([]byte, error) {
+		c.Assert(args, HasLen, 3)
+		c.Assert(args[0:2], DeepEquals, []string{"show", "--property=Id,LoadState,ActiveState,SubState,UnitFileState"})
+		st, ok := status[args[2]]
+		if !ok {
+			return nil, fmt.Errorf("unexpected unit %q", args[2])
+		}
+		return []byte(st), nil
+	}
+	return func() { systemd.SystemctlCmd = old }
+}
+
+func (s *servicesSuite) TestServices(c *C) {
+	restore := s.mockSystemctl(c, map[string]string{
+		"snap.test-snap.svc1.service": "Id=snap.test-snap.svc1.service\nLoadState=loaded\nActiveState=active\nSubState=running\nUnitFileState=enabled\n",
+		"snap.test-snap.svc2.service": "Id=snap.test-snap.svc2.service\nLoadState=loaded\nActiveState=inactive\nSubState=dead\nUnitFileState=disabled\n",
+	})
+	defer restore()
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"services"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `Service         Startup   Current
+test-snap.svc1  enabled   active
+test-snap.svc2  disabled  inactive
+`)
+	c.Check(string(stderr), Equals, "")
+
+	stdout, _, err = ctlcmd.Run(s.mockContext, []string{"services", "test-snap.svc2"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `Service         Startup   Current
+test-snap.svc2  disabled  inactive
+`)
+}
+
+func (s *servicesSuite) TestServicesEphemeralContext(c *C) {
+	restore := s.mockSystemctl(c, map[string]string{
+		"snap.test-snap.svc1.service": "Id=snap.test-snap.svc1.service\nLoadState=loaded\nActiveState=active\nSubState=running\nUnitFileState=enabled\n",
+	})
+	defer restore()
+
+	context, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap"}, nil, "")
+	c.Assert(err, IsNil)
+
+	stdout, _, err := ctlcmd.Run(context, []string{"services", "test-snap.svc1"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `Service         Startup  Current
+test-snap.svc1  enabled  active
+`)
+}
+
+func (s *servicesSuite) TestServicesErrors(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"services", "other-snap.svc1"}, 0)
+	c.Check(err, ErrorMatches, `cannot query service "other-snap.svc1" of snap "other-snap" from snap "test-snap"`)
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"services", "test-snap.cmd"}, 0)
+	c.Check(err, ErrorMatches, `unknown service: "test-snap.cmd"`)
+	_, _, err = ctlcmd.Run(nil, []string{"services"}, 0)
+	c.Check(err, ErrorMatches, "cannot query services without a context")
+}
+
+func (s *servicesSuite) TestInvalidArguments(c *C) {
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"start"}, "the required argument `<snap.app> \\(at least 1 argument\\)` was not provided"},
+		{[]string{"stop", "svc1"}, `invalid service name "svc1" \(want <snap>.<app>\)`},
+		{[]string{"restart", "test-snap."}, `invalid service name "test-snap." \(want <snap>.<app>\)`},
+		{[]string{"start", "other-snap.svc1"}, `cannot start service "other-snap.svc1" of snap "other-snap" from snap "test-snap"`},
+		{[]string{"start", "test-snap.svc3"}, `unknown service: "test-snap.svc3"`},
+		{[]string{"stop", "test-snap.cmd"}, `unknown service: "test-snap.cmd"`},
+		{[]string{"restart", "--enable", "test-snap.svc1"}, `unknown flag .enable.`},
+	} {
+		_, _, err := ctlcmd.Run(s.mockContext, t.args, 0)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+
+	s.mockContext.Lock()
+	defer s.mockContext.Unlock()
+	c.Check(s.mockContext.Done(), IsNil)
+	c.Check(s.hookTask.Change().Tasks(), HasLen, 2)
+}
+
+func (s *servicesSuite) TestWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"restart", "test-snap.svc1"}, 0)
+	c.Check(err, ErrorMatches, "cannot restart services without a context")
+}
+
+func (s *servicesSuite) TestQueuedAfterHook(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"stop", "--disable", "test-snap.svc1"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+	_, _, err = ctlcmd.Run(s.mockContext, []string{"start", "--enable", "test-snap.svc2", "test-snap.svc1"}, 0)
+	c.Assert(err, IsNil)
+
+	s.mockContext.Lock()
+	defer s.mockContext.Unlock()
+
+	// nothing is queued while the hook is running
+	c.Check(s.hookTask.Change().Tasks(), HasLen, 2)
+
+	c.Assert(s.mockContext.Done(), IsNil)
+
+	tasks := s.hookTask.Change().Tasks()
+	c.Assert(tasks, HasLen, 4)
+	stop, start := tasks[2], tasks[3]
+
+	c.Check(stop.Kind(), Equals, "service-control")
+	c.Check(stop.Summary(), Equals, `Stop services svc1 of snap "test-snap"`)
+	var action snapstate.ServiceAction
+	c.Assert(stop.Get("service-action", &action), IsNil)
+	c.Check(action, DeepEquals, snapstate.ServiceAction{
+		SnapName: "test-snap",
+		Action:   "stop",
+		Disable:  true,
+		Services: []string{"svc1"},
+	})
+	c.Check(stop.WaitTasks(), DeepEquals, []*state.Task{s.hookTask})
+
+	c.Check(start.Kind(), Equals, "service-control")
+	c.Check(start.Summary(), Equals, `Start services svc1, svc2 of snap "test-snap"`)
+	action = snapstate.ServiceAction{}
+	c.Assert(start.Get("service-action", &action), IsNil)
+	c.Check(action, DeepEquals, snapstate.ServiceAction{
+		SnapName: "test-snap",
+		Action:   "start",
+		Enable:   true,
+		Services: []string{"svc2", "svc1"},
+	})
+	c.Check(start.WaitTasks(), DeepEquals, []*state.Task{stop})
+
+	// tasks that were waiting for the hook now also wait for the services
+	c.Check(s.nextTask.WaitTasks(), DeepEquals, []*state.Task{s.hookTask, start})
+}
+
+func (s *servicesSuite) TestEphemeralContextCreatesChange(c *C) {
+	context, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap"}, nil, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"restart", "test-snap.svc1"}, 0)
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+	c.Assert(context.Done(), IsNil)
+
+	var chg *state.Change
+	for _, ch := range s.state.Changes() {
+		if ch.Kind() == "service-control" {
+			chg = ch
+		}
+	}
+	c.Assert(chg, NotNil)
+	c.Check(chg.Summary(), Equals, `Restart services svc1 of snap "test-snap"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "service-control")
+}
+
+func (s *servicesSuite) TestEphemeralContextFailure(c *C) {
+	context, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "test-snap"}, nil, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"stop", "test-snap.svc1"}, 0)
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	defer context.Unlock()
+	// the snap went away in the meantime
+	snapstate.Set(s.state, "test-snap", nil)
+	c.Check(context.Done(), ErrorMatches, `cannot stop services of snap "test-snap": .*`)
+}
+
+func (s *servicesSuite) TestForbiddenForNonRoot(c *C) {
+	for _, cmd := range []string{"start", "stop", "restart"} {
+		_, _, err := ctlcmd.Run(s.mockContext, []string{cmd, "test-snap.svc1"}, 1000)
+		c.Check(err, FitsTypeOf, &ctlcmd.ForbiddenCommandError{})
+		c.Check(err, ErrorMatches, fmt.Sprintf(`cannot use %q with uid 1000, try with sudo`, cmd))
+	}
+}
@@ -62,6 +62,9 @@ func init() {
 }
 
 func (s *setCommand) Execute(args []string) error {
+	if err := s.ensureRoot("set"); err != nil {
+		return err
+	}
 	if s.Positional.PlugOrSlotSpec == "" && len(s.Positional.ConfValues) == 0 {
 		return fmt.Errorf(i18n.G("set which option?"))
 	}
@@ -0,0 +1,156 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package hookstate_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type snapHooksSuite struct {
+	state   *state.State
+	manager *hookstate.HookManager
+}
+
+var _ = Suite(&snapHooksSuite{})
+
+func (s *snapHooksSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	manager, err := hookstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.manager = manager
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	sideInfo := &snap.SideInfo{RealName: "test-snap", SnapID: "some-snap-id", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: test-snap
+version: 1.0
+hooks:
+    install:
+    pre-refresh:
+    post-refresh:
+`, "", sideInfo)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{sideInfo},
+		Current:  snap.R(1),
+	})
+}
+
+func (s *snapHooksSuite) TearDownTest(c *C) {
+	s.manager.Stop()
+	dirs.SetRootDir("")
+}
+
+func (s *snapHooksSuite) TestSetupHookTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		setup   func(*state.State, string) *state.Task
+		hook    string
+		summary string
+	}{
+		{hookstate.SetupInstallHook, "install", `Run install hook of "test-snap" snap if present`},
+		{hookstate.SetupPreRefreshHook, "pre-refresh", `Run pre-refresh hook of "test-snap" snap if present`},
+		{hookstate.SetupPostRefreshHook, "post-refresh", `Run post-refresh hook of "test-snap" snap if present`},
+		{hookstate.SetupRemoveHook, "remove", `Run remove hook of "test-snap" snap if present`},
+	} {
+		task := t.setup(s.state, "test-snap")
+		c.Check(task.Kind(), Equals, "run-hook")
+		c.Check(task.Summary(), Equals, t.summary)
+
+		var hooksup hookstate.HookSetup
+		c.Assert(task.Get("hook-setup", &hooksup), IsNil)
+		c.Check(hooksup, DeepEquals, hookstate.HookSetup{
+			Snap:     "test-snap",
+			Hook:     t.hook,
+			Optional: true,
+		})
+	}
+}
+
+func (s *snapHooksSuite) runHook(c *C, task *state.Task) *state.Change {
+	s.state.Lock()
+	chg := s.state.NewChange("kind", "summary")
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	return chg
+}
+
+func (s *snapHooksSuite) TestSnapHooksAreHandled(c *C) {
+	var hooks []string
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		hooks = append(hooks, ctx.HookName())
+		return nil, nil
+	})
+	defer restore()
+
+	for _, setup := range []func(*state.State, string) *state.Task{
+		hookstate.SetupInstallHook,
+		hookstate.SetupPreRefreshHook,
+		hookstate.SetupPostRefreshHook,
+		hookstate.SetupRemoveHook,
+	} {
+		s.state.Lock()
+		task := setup(s.state, "test-snap")
+		s.state.Unlock()
+
+		chg := s.runHook(c, task)
+		s.state.Lock()
+		c.Check(chg.Status(), Equals, state.DoneStatus)
+		s.state.Unlock()
+	}
+	// the snap has no remove hook
+	c.Check(hooks, DeepEquals, []string{"install", "pre-refresh", "post-refresh"})
+}
+
+func (s *snapHooksSuite) TestSnapHookFailureErrorsTask(c *C) {
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		return []byte("output"), errors.New("boom")
+	})
+	defer restore()
+
+	s.state.Lock()
+	task := hookstate.SetupPreRefreshHook(s.state, "test-snap")
+	s.state.Unlock()
+
+	chg := s.runHook(c, task)
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	// the hook output is reported to the user
+	c.Check(chg.Err(), ErrorMatches, `(?s).*run hook "pre-refresh": output.*`)
+}
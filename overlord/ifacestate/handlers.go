@@ -292,8 +292,14 @@ func (m *InterfaceManager) doDiscardConns(task *state.Task, _ *tomb.Tomb) error
 	if err != nil {
 		return err
 	}
-	removed := make(map[string]connState)
+	ids := make([]string, 0, len(conns))
 	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	removed := make(map[string]connState)
+	var disconnectHooks []*state.Task
+	for _, id := range ids {
 		connRef, err := interfaces.ParseConnRef(id)
 		if err != nil {
 			return err
@@ -301,13 +307,49 @@ func (m *InterfaceManager) doDiscardConns(task *state.Task, _ *tomb.Tomb) error
 		if connRef.PlugRef.Snap == snapName || connRef.SlotRef.Snap == snapName {
 			removed[id] = conns[id]
 			delete(conns, id)
+			if hook := counterpartDisconnectHook(st, snapName, connRef); hook != nil {
+				disconnectHooks = append(disconnectHooks, hook)
+			}
 		}
 	}
 	task.Set("removed", removed)
 	setConns(st, conns)
+	snapstate.InjectTasks(task, disconnectHooks)
 	return nil
 }
 
+// counterpartDisconnectHook returns a task running the disconnect hook
+// of the snap on the other side of the connection with the removed
+// snap, or nil if that snap is gone as well. The hook runs after the
+// removed snap was discarded, which cannot be undone, so its failure
+// is ignored rather than failing the removal.
+func counterpartDisconnectHook(st *state.State, removedSnap string, connRef interfaces.ConnRef) *state.Task {
+	var snapName, hookName string
+	switch {
+	case connRef.PlugRef.Snap == connRef.SlotRef.Snap:
+		return nil
+	case connRef.PlugRef.Snap == removedSnap:
+		snapName = connRef.SlotRef.Snap
+		hookName = "disconnect-slot-" + connRef.SlotRef.Name
+	default:
+		snapName = connRef.PlugRef.Snap
+		hookName = "disconnect-plug-" + connRef.PlugRef.Name
+	}
+
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil || !snapst.HasCurrent() {
+		return nil
+	}
+
+	hook := disconnectHookTask(st, snapName, hookName, nil, true)
+	// the hook task carries the connection attributes itself
+	hook.Set("plug", connRef.PlugRef)
+	hook.Set("slot", connRef.SlotRef)
+	setDisconnectAttributes(hook, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+	hook.Set("hook-context", map[string]interface{}{"attrs-task": hook.ID()})
+	return hook
+}
+
 func (m *InterfaceManager) undoDiscardConns(task *state.Task, _ *tomb.Tomb) error {
 	st := task.State()
 	st.Lock()
@@ -53,6 +53,10 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, extraInterfaces
 		return nil, err
 	}
 
+	s.Lock()
+	ReplaceRepository(s, m.repo)
+	s.Unlock()
+
 	// interface tasks might touch more than the immediate task target snap, serialize them
 	runner.SetBlocked(func(_ *state.Task, running []*state.Task) bool {
 		return len(running) != 0
@@ -99,6 +103,23 @@ func (m *InterfaceManager) Repository() *interfaces.Repository {
 	return m.repo
 }
 
+type cachedRepoKey struct{}
+
+// ReplaceRepository replaces the interface repository used by the manager.
+func ReplaceRepository(state *state.State, repo *interfaces.Repository) {
+	state.Cache(cachedRepoKey{}, repo)
+}
+
+// Repository returns the interface repository maintained by the interface
+// manager, for read-only consultation by other parts of the system.
+func Repository(st *state.State) *interfaces.Repository {
+	repo := st.Cached(cachedRepoKey{})
+	if repo == nil {
+		panic("internal error: needing the interface repository before the interface manager is initialized")
+	}
+	return repo.(*interfaces.Repository)
+}
+
 // MockSecurityBackends mocks the list of security backends that are used for setting up security.
 //
 // This function is public because it is referenced in the daemon
@@ -164,12 +164,71 @@ func Disconnect(st *state.State, plugSnap, plugName, slotSnap, slotName string)
 		return nil, err
 	}
 
+	// Create a series of tasks:
+	//  - disconnect-plug-<plug> hook
+	//  - disconnect-slot-<slot> hook
+	//  - disconnect task
+	// The tasks run in sequence (are serialized by WaitFor), so the
+	// connection is left in place if any of the hooks fails.
+	// The hooks can read the attributes of the connection via
+	// 'snapctl get', they are kept in the disconnect task.
 	summary := fmt.Sprintf(i18n.G("Disconnect %s:%s from %s:%s"),
 		plugSnap, plugName, slotSnap, slotName)
-	task := st.NewTask("disconnect", summary)
-	task.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
-	task.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
-	return state.NewTaskSet(task), nil
+	disconnectInterface := st.NewTask("disconnect", summary)
+	disconnectInterface.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
+	disconnectInterface.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
+	setDisconnectAttributes(disconnectInterface, plugSnap, plugName, slotSnap, slotName)
+
+	initialContext := make(map[string]interface{})
+	initialContext["attrs-task"] = disconnectInterface.ID()
+
+	disconnectPlug := disconnectHookTask(st, plugSnap, "disconnect-plug-"+plugName, initialContext, false)
+	disconnectSlot := disconnectHookTask(st, slotSnap, "disconnect-slot-"+slotName, initialContext, false)
+	disconnectSlot.WaitFor(disconnectPlug)
+	disconnectInterface.WaitFor(disconnectSlot)
+
+	return state.NewTaskSet(disconnectPlug, disconnectSlot, disconnectInterface), nil
+}
+
+// disconnectHookTask returns a task running the given disconnect hook
+// of the snap; with ignoreError a failure of the hook is only logged
+// in the task and does not fail the change.
+func disconnectHookTask(st *state.State, snapName, hookName string, contextData map[string]interface{}, ignoreError bool) *state.Task {
+	hooksup := &hookstate.HookSetup{
+		Snap:        snapName,
+		Hook:        hookName,
+		Optional:    true,
+		IgnoreError: ignoreError,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run hook %s of snap %q"), hooksup.Hook, hooksup.Snap)
+	return hookstate.HookTask(st, summary, hooksup, contextData)
+}
+
+// setDisconnectAttributes sets the attributes of the plug and slot
+// being disconnected in the given task, as far as they can still be
+// found: the disconnect itself copes with snaps that are gone.
+func setDisconnectAttributes(t *state.Task, plugSnap string, plugName string, slotSnap string, slotName string) {
+	st := t.State()
+
+	var snapst snapstate.SnapState
+	if snapstate.Get(st, plugSnap, &snapst) == nil {
+		if snapInfo, err := snapst.CurrentInfo(); err == nil {
+			if plug, ok := snapInfo.Plugs[plugName]; ok {
+				t.Set("plug-attrs", plug.Attrs)
+			}
+		}
+	}
+
+	snapst = snapstate.SnapState{}
+	if snapstate.Get(st, slotSnap, &snapst) == nil {
+		if snapInfo, err := snapst.CurrentInfo(); err == nil {
+			addImplicitSlots(snapInfo)
+			if slot, ok := snapInfo.Slots[slotName]; ok {
+				t.Set("slot-attrs", slot.Attrs)
+			}
+		}
+	}
 }
 
 // CheckInterfaces checks whether plugs and slots of snap are allowed for installation.
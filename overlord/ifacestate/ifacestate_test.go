@@ -26,6 +26,7 @@ import (
 	"time"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
@@ -140,6 +141,14 @@ func (s *interfaceManagerSuite) TestSmoke(c *C) {
 	mgr.Wait()
 }
 
+func (s *interfaceManagerSuite) TestRepositoryIsCached(c *C) {
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(ifacestate.Repository(s.state), Equals, mgr.Repository())
+}
+
 func (s *interfaceManagerSuite) TestConnectTask(c *C) {
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
 	s.mockSnap(c, consumerYaml)
@@ -468,14 +477,45 @@ slots:
 }
 
 func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
 	s.state.Lock()
 	defer s.state.Unlock()
 
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
 	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 3)
 
+	var hs hookstate.HookSetup
 	task := ts.Tasks()[0]
+	c.Check(task.Kind(), Equals, "run-hook")
+	err = task.Get("hook-setup", &hs)
+	c.Assert(err, IsNil)
+	c.Assert(hs, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "disconnect-plug-plug", Optional: true})
+	task = ts.Tasks()[1]
+	c.Check(task.Kind(), Equals, "run-hook")
+	err = task.Get("hook-setup", &hs)
+	c.Assert(err, IsNil)
+	c.Assert(hs, Equals, hookstate.HookSetup{Snap: "producer", Hook: "disconnect-slot-slot", Optional: true})
+	var context map[string]interface{}
+	err = task.Get("hook-context", &context)
+	c.Assert(err, IsNil)
+	c.Check(context, DeepEquals, map[string]interface{}{"attrs-task": ts.Tasks()[2].ID()})
+
+	task = ts.Tasks()[2]
 	c.Assert(task.Kind(), Equals, "disconnect")
+	c.Check(task.WaitTasks(), DeepEquals, []*state.Task{ts.Tasks()[1]})
+	// the hooks can read the attributes from the disconnect task
+	var attrs map[string]interface{}
+	err = task.Get("plug-attrs", &attrs)
+	c.Assert(err, IsNil)
+	c.Check(attrs["attr1"], Equals, "value1")
+	err = task.Get("slot-attrs", &attrs)
+	c.Assert(err, IsNil)
+	c.Check(attrs["attr2"], Equals, "value2")
 	var plug interfaces.PlugRef
 	err = task.Get("plug", &plug)
 	c.Assert(err, IsNil)
@@ -524,15 +564,14 @@ func (s *interfaceManagerSuite) testDisconnect(c *C, plugSnap, plugName, slotSna
 	c.Assert(err, IsNil)
 	change.AddAll(ts)
 	s.state.Unlock()
-	mgr.Ensure()
-	mgr.Wait()
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
 
 	// Ensure that the task succeeded.
 	c.Assert(change.Err(), IsNil)
-	task := change.Tasks()[0]
+	task := change.Tasks()[2]
 	c.Check(task.Kind(), Equals, "disconnect")
 	c.Check(task.Status(), Equals, state.DoneStatus)
 
@@ -1441,6 +1480,172 @@ func (s *interfaceManagerSuite) testUndoDicardConns(c *C, snapName string) {
 	c.Check(err, Equals, state.ErrNoState)
 }
 
+var consumerWithHooksYaml = `
+name: consumer
+version: 1
+plugs:
+ plug:
+  interface: test
+  attr1: value1
+hooks:
+ disconnect-plug-plug:
+`
+
+var producerWithHooksYaml = `
+name: producer
+version: 1
+slots:
+ slot:
+  interface: test
+  attr2: value2
+hooks:
+ disconnect-slot-slot:
+`
+
+func (s *interfaceManagerSuite) TestDisconnectHookFailureKeepsConnection(c *C) {
+	var hooks []string
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		hooks = append(hooks, ctx.HookName())
+		if ctx.HookName() == "disconnect-slot-slot" {
+			return []byte("cannot clean up"), fmt.Errorf("exit status 1")
+		}
+		return nil, nil
+	})
+	defer restore()
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerWithHooksYaml)
+	s.mockSnap(c, producerWithHooksYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("disconnect", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(hooks, DeepEquals, []string{"disconnect-plug-plug", "disconnect-slot-slot"})
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(change.Err(), ErrorMatches, `(?s).*run hook "disconnect-slot-slot": cannot clean up.*`)
+
+	// the connection is still there
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	repo := mgr.Repository()
+	c.Check(repo.Plug("consumer", "plug").Connections, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestDoDiscardConnsRunsCounterpartDisconnectHook(c *C) {
+	var hooks []string
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		hooks = append(hooks, ctx.SnapName()+":"+ctx.HookName())
+		return nil, nil
+	})
+	defer restore()
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, producerWithHooksYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	// the consumer snap is being removed
+	snapstate.Set(s.state, "consumer", &snapstate.SnapState{})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	change := s.addDiscardConnsChange(c, "consumer")
+	s.state.Lock()
+	// a task waiting for discard-conns waits for the hooks as well
+	last := s.state.NewTask("dummy", "")
+	last.WaitFor(change.Tasks()[0])
+	s.state.Unlock()
+
+	s.settle(c)
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(hooks, DeepEquals, []string{"producer:disconnect-slot-slot"})
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	c.Assert(change.Tasks(), HasLen, 2)
+	hook := change.Tasks()[1]
+	c.Check(hook.Kind(), Equals, "run-hook")
+	c.Check(last.WaitTasks(), DeepEquals, []*state.Task{change.Tasks()[0], hook})
+
+	// the hook can read the attributes of the connection
+	var attrs map[string]interface{}
+	err := hook.Get("slot-attrs", &attrs)
+	c.Assert(err, IsNil)
+	c.Check(attrs["attr2"], Equals, "value2")
+	var context map[string]interface{}
+	err = hook.Get("hook-context", &context)
+	c.Assert(err, IsNil)
+	c.Check(context, DeepEquals, map[string]interface{}{"attrs-task": hook.ID()})
+}
+
+func (s *interfaceManagerSuite) TestDoDiscardConnsIgnoresCounterpartDisconnectHookFailure(c *C) {
+	restore := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		return []byte("cannot clean up"), fmt.Errorf("exit status 1")
+	})
+	defer restore()
+
+	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, producerWithHooksYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	// the consumer snap is being removed
+	snapstate.Set(s.state, "consumer", &snapstate.SnapState{})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	change := s.addDiscardConnsChange(c, "consumer")
+
+	s.settle(c)
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the removal is not failed by the hook of the other snap
+	c.Check(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+	c.Assert(change.Tasks(), HasLen, 2)
+	hook := change.Tasks()[1]
+	c.Check(strings.Join(hook.Log(), ""), Matches, `.*ignoring failure in hook "disconnect-slot-slot": cannot clean up`)
+
+	// and the connection stays discarded
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+}
+
 func (s *interfaceManagerSuite) TestDoRemove(c *C) {
 	s.mockIface(c, &ifacetest.TestInterface{InterfaceName: "test"})
 	s.mockSnap(c, consumerYaml)
@@ -1588,8 +1793,7 @@ func (s *interfaceManagerSuite) TestDisconnectSetsUpSecurity(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
+	s.settle(c)
 	mgr.Stop()
 
 	s.state.Lock()
@@ -1632,8 +1836,7 @@ func (s *interfaceManagerSuite) TestDisconnectTracksConnectionsInState(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
+	s.settle(c)
 	mgr.Stop()
 
 	s.state.Lock()
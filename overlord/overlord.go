@@ -40,6 +40,8 @@ import (
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/quotastate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/store"
@@ -73,6 +75,8 @@ type Overlord struct {
 	hookMgr   *hookstate.HookManager
 	configMgr *configstate.ConfigManager
 	deviceMgr *devicestate.DeviceManager
+	shotMgr   *snapshotstate.SnapshotManager
+	quotaMgr  *quotastate.QuotaManager
 }
 
 var storeNew = store.New
@@ -136,6 +140,12 @@ func New() (*Overlord, error) {
 	o.deviceMgr = deviceMgr
 	o.stateEng.AddManager(o.deviceMgr)
 
+	o.shotMgr = snapshotstate.Manager(s)
+	o.stateEng.AddManager(o.shotMgr)
+
+	o.quotaMgr = quotastate.Manager(s)
+	o.stateEng.AddManager(o.quotaMgr)
+
 	// setting up the store
 	authContext := auth.NewAuthContext(s, o.deviceMgr)
 	sto := storeNew(nil, authContext)
@@ -330,3 +340,15 @@ func (o *Overlord) HookManager() *hookstate.HookManager {
 func (o *Overlord) DeviceManager() *devicestate.DeviceManager {
 	return o.deviceMgr
 }
+
+// SnapshotManager returns the snapshot manager responsible for
+// snapshots of snap data under the overlord.
+func (o *Overlord) SnapshotManager() *snapshotstate.SnapshotManager {
+	return o.shotMgr
+}
+
+// QuotaManager returns the quota manager responsible for the quota
+// groups of snap services under the overlord.
+func (o *Overlord) QuotaManager() *quotastate.QuotaManager {
+	return o.quotaMgr
+}
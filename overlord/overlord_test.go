@@ -70,6 +70,7 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.AssertManager(), NotNil)
 	c.Check(o.InterfaceManager(), NotNil)
 	c.Check(o.DeviceManager(), NotNil)
+	c.Check(o.QuotaManager(), NotNil)
 
 	s := o.State()
 	c.Check(s, NotNil)
@@ -0,0 +1,53 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package quotastate
+
+import (
+	"errors"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+func MockWrappers(ensure func(*quota.Group, []*snap.Info, progress.Meter) error, removeSnaps func([]*snap.Info, progress.Meter) error, remove func(*quota.Group, progress.Meter) error) (restore func()) {
+	oldEnsure := wrappersEnsureQuotaGroup
+	oldRemoveSnaps := wrappersRemoveSnapsFromQuotaGroup
+	oldRemove := wrappersRemoveQuotaGroup
+	wrappersEnsureQuotaGroup = ensure
+	wrappersRemoveSnapsFromQuotaGroup = removeSnaps
+	wrappersRemoveQuotaGroup = remove
+	return func() {
+		wrappersEnsureQuotaGroup = oldEnsure
+		wrappersRemoveSnapsFromQuotaGroup = oldRemoveSnaps
+		wrappersRemoveQuotaGroup = oldRemove
+	}
+}
+
+// AddErrorTrigger registers a handler for a task that always fails,
+// to test the undoing of changes.
+func (m *QuotaManager) AddErrorTrigger() {
+	m.runner.AddHandler("error-trigger", func(*state.Task, *tomb.Tomb) error {
+		return errors.New("error out")
+	}, nil)
+}
@@ -0,0 +1,192 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package quotastate
+
+import (
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+var (
+	wrappersEnsureQuotaGroup = func(grp *quota.Group, infos []*snap.Info, meter progress.Meter) error {
+		return wrappers.EnsureQuotaGroup(grp, infos, meter)
+	}
+	wrappersRemoveSnapsFromQuotaGroup = func(infos []*snap.Info, meter progress.Meter) error {
+		return wrappers.RemoveSnapsFromQuotaGroup(infos, meter)
+	}
+	wrappersRemoveQuotaGroup = func(grp *quota.Group, meter progress.Meter) error {
+		return wrappers.RemoveQuotaGroup(grp, meter)
+	}
+)
+
+// QuotaManager is responsible for the quota groups that the services of
+// snaps are placed in.
+type QuotaManager struct {
+	runner *state.TaskRunner
+}
+
+// Manager returns a new quota manager.
+func Manager(st *state.State) *QuotaManager {
+	runner := state.NewTaskRunner(st)
+
+	runner.AddHandler("quota-control", doQuotaControl, undoQuotaControl)
+
+	return &QuotaManager{runner: runner}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *QuotaManager) Ensure() error {
+	m.runner.Ensure()
+	return nil
+}
+
+// Wait implements StateManager.Wait.
+func (m *QuotaManager) Wait() {
+	m.runner.Wait()
+}
+
+// Stop implements StateManager.Stop.
+func (m *QuotaManager) Stop() {
+	m.runner.Stop()
+}
+
+// activeSnapInfos returns the current info of the given snaps that are
+// installed and active, the others have no services to place in a slice.
+func activeSnapInfos(st *state.State, snapNames []string) ([]*snap.Info, error) {
+	infos := make([]*snap.Info, 0, len(snapNames))
+	for _, snapName := range snapNames {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, snapName, &snapst); err != nil {
+			if err == state.ErrNoState {
+				continue
+			}
+			return nil, err
+		}
+		if !snapst.Active {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// switchQuotaGroup changes the quota group with the given name from one
+// definition to another, either of which can be nil if the group does
+// not exist before or after the switch.
+func switchQuotaGroup(st *state.State, name string, from, to *quota.Group) error {
+	var leaving []string
+	if from != nil {
+		for _, snapName := range from.Snaps {
+			if to == nil || !to.HasSnap(snapName) {
+				leaving = append(leaving, snapName)
+			}
+		}
+	}
+	leavingInfos, err := activeSnapInfos(st, leaving)
+	if err != nil {
+		return err
+	}
+	var infos []*snap.Info
+	if to != nil {
+		infos, err = activeSnapInfos(st, to.Snaps)
+		if err != nil {
+			return err
+		}
+	}
+
+	pb := &progress.NullProgress{}
+	st.Unlock()
+	err = wrappersRemoveSnapsFromQuotaGroup(leavingInfos, pb)
+	if err == nil {
+		if to != nil {
+			err = wrappersEnsureQuotaGroup(to, infos, pb)
+		} else {
+			err = wrappersRemoveQuotaGroup(from, pb)
+		}
+	}
+	st.Lock()
+	if err != nil {
+		return err
+	}
+
+	return setGroup(st, name, to)
+}
+
+func doQuotaControl(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var action quotaAction
+	if err := task.Get("quota-action", &action); err != nil {
+		return err
+	}
+	groups, err := AllGroups(st)
+	if err != nil {
+		return err
+	}
+	oldGrp := groups[action.GroupName]
+
+	var newGrp *quota.Group
+	if action.Action == "ensure" {
+		newGrp = action.Group
+	}
+	if err := switchQuotaGroup(st, action.GroupName, oldGrp, newGrp); err != nil {
+		return err
+	}
+
+	// save for undoQuotaControl
+	if oldGrp != nil {
+		task.Set("old-quota-group", oldGrp)
+	}
+
+	return nil
+}
+
+func undoQuotaControl(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var action quotaAction
+	if err := task.Get("quota-action", &action); err != nil {
+		return err
+	}
+	var oldGrp *quota.Group
+	if err := task.Get("old-quota-group", &oldGrp); err != nil && err != state.ErrNoState {
+		return err
+	}
+	groups, err := AllGroups(st)
+	if err != nil {
+		return err
+	}
+
+	return switchQuotaGroup(st, action.GroupName, groups[action.GroupName], oldGrp)
+}
@@ -0,0 +1,269 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package quotastate implements the manager and state aspects
+// responsible for the resource quota groups that the services of snaps
+// are placed in.
+package quotastate
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func init() {
+	snapstate.EnsureSnapQuotaGroup = EnsureSnapQuotaGroup
+	snapstate.RemoveSnapFromQuotaGroup = RemoveSnapFromQuotaGroup
+	// moving the services of snaps around slices conflicts with
+	// changes refreshing or removing them
+	snapstate.AddAffectedSnapsByKind("quota-control", quotaControlAffectedSnaps)
+}
+
+// quotaAction holds the parameters of a quota-control task.
+type quotaAction struct {
+	Action    string       `json:"action"`
+	GroupName string       `json:"group-name"`
+	Group     *quota.Group `json:"group,omitempty"`
+}
+
+// AllGroups returns all the quota groups in the system, by name.
+func AllGroups(st *state.State) (map[string]*quota.Group, error) {
+	var groups map[string]*quota.Group
+	if err := st.Get("quota-groups", &groups); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if groups == nil {
+		groups = make(map[string]*quota.Group)
+	}
+	return groups, nil
+}
+
+// Group returns the quota group with the given name.
+func Group(st *state.State, name string) (*quota.Group, error) {
+	groups, err := AllGroups(st)
+	if err != nil {
+		return nil, err
+	}
+	grp := groups[name]
+	if grp == nil {
+		return nil, fmt.Errorf("cannot find quota group %q", name)
+	}
+	return grp, nil
+}
+
+func setGroup(st *state.State, name string, grp *quota.Group) error {
+	groups, err := AllGroups(st)
+	if err != nil {
+		return err
+	}
+	if grp == nil {
+		delete(groups, name)
+	} else {
+		groups[name] = grp
+	}
+	st.Set("quota-groups", groups)
+	return nil
+}
+
+// snapGroup returns the quota group the given snap is in, if any.
+func snapGroup(st *state.State, snapName string) (*quota.Group, error) {
+	groups, err := AllGroups(st)
+	if err != nil {
+		return nil, err
+	}
+	for _, grp := range groups {
+		if grp.HasSnap(snapName) {
+			return grp, nil
+		}
+	}
+	return nil, nil
+}
+
+// quotaControlAffectedSnaps returns the snaps whose services the
+// given quota-control task moves: the ones in the group before and
+// after the task.
+func quotaControlAffectedSnaps(task *state.Task) ([]string, error) {
+	var action quotaAction
+	if err := task.Get("quota-action", &action); err != nil {
+		return nil, err
+	}
+	var oldGrp *quota.Group
+	if err := task.Get("old-quota-group", &oldGrp); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	groups, err := AllGroups(task.State())
+	if err != nil {
+		return nil, err
+	}
+
+	var snapNames []string
+	for _, grp := range []*quota.Group{action.Group, oldGrp, groups[action.GroupName]} {
+		if grp != nil {
+			snapNames = append(snapNames, grp.Snaps...)
+		}
+	}
+	return snapNames, nil
+}
+
+// checkQuotaChangeConflict checks that no quota group is being changed.
+func checkQuotaChangeConflict(st *state.State, groupName string) error {
+	for _, task := range st.Tasks() {
+		chg := task.Change()
+		if chg == nil || chg.Status().Ready() {
+			continue
+		}
+		if task.Kind() != "quota-control" {
+			continue
+		}
+		var action quotaAction
+		if err := task.Get("quota-action", &action); err != nil {
+			return fmt.Errorf("internal error: cannot obtain quota action from task: %s", task.Summary())
+		}
+		if action.GroupName == groupName {
+			return fmt.Errorf("cannot change quota group %q while change %q is in progress", groupName, chg.ID())
+		}
+		return fmt.Errorf("cannot change quota group %q while change %q on quota group %q is in progress", groupName, chg.ID(), action.GroupName)
+	}
+	return nil
+}
+
+// checkSnapsChangeConflict checks that none of the given snaps, whose
+// services a quota change moves, has changes in progress.
+func checkSnapsChangeConflict(st *state.State, snapNames []string) error {
+	for _, snapName := range snapNames {
+		if err := snapstate.CheckChangeConflict(st, snapName, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureQuota returns a task set to create the quota group with the
+// given name, or update it if it exists, with the given limits and
+// snaps. Zero limits keep the current ones of an existing group, and
+// the snaps are added to the ones already in it.
+func EnsureQuota(st *state.State, name string, memoryLimit uint64, cpuLimit int, snapNames []string) (*state.TaskSet, error) {
+	if err := quota.ValidateGroupName(name); err != nil {
+		return nil, err
+	}
+	if err := checkQuotaChangeConflict(st, name); err != nil {
+		return nil, err
+	}
+
+	groups, err := AllGroups(st)
+	if err != nil {
+		return nil, err
+	}
+	grp := groups[name]
+	if grp == nil {
+		grp = &quota.Group{Name: name}
+	}
+	grp = grp.AddSnaps(snapNames)
+	if memoryLimit != 0 {
+		grp.MemoryLimit = memoryLimit
+	}
+	if cpuLimit != 0 {
+		grp.CPULimit = cpuLimit
+	}
+	if err := grp.Validate(); err != nil {
+		return nil, err
+	}
+
+	for _, snapName := range snapNames {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, snapName, &snapst); err != nil {
+			if err == state.ErrNoState {
+				return nil, fmt.Errorf("snap %q is not installed", snapName)
+			}
+			return nil, err
+		}
+		for _, other := range groups {
+			if other.Name != name && other.HasSnap(snapName) {
+				return nil, fmt.Errorf("snap %q is already in quota group %q", snapName, other.Name)
+			}
+		}
+	}
+	if err := checkSnapsChangeConflict(st, grp.Snaps); err != nil {
+		return nil, err
+	}
+
+	task := st.NewTask("quota-control", fmt.Sprintf(i18n.G("Ensure quota group %q"), name))
+	task.Set("quota-action", &quotaAction{Action: "ensure", GroupName: name, Group: grp})
+
+	return state.NewTaskSet(task), nil
+}
+
+// RemoveQuota returns a task set to remove the quota group with the
+// given name, taking the services of its snaps out of it.
+func RemoveQuota(st *state.State, name string) (*state.TaskSet, error) {
+	grp, err := Group(st, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQuotaChangeConflict(st, name); err != nil {
+		return nil, err
+	}
+	if err := checkSnapsChangeConflict(st, grp.Snaps); err != nil {
+		return nil, err
+	}
+
+	task := st.NewTask("quota-control", fmt.Sprintf(i18n.G("Remove quota group %q"), name))
+	task.Set("quota-action", &quotaAction{Action: "remove", GroupName: name})
+
+	return state.NewTaskSet(task), nil
+}
+
+// EnsureSnapQuotaGroup places the services of the given snap, which
+// was just linked, in the slice of its quota group if it has one.
+// The state must be locked by the caller, it is unlocked while
+// systemd is told about the slice.
+func EnsureSnapQuotaGroup(st *state.State, info *snap.Info) error {
+	grp, err := snapGroup(st, info.Name())
+	if err != nil || grp == nil {
+		return err
+	}
+	st.Unlock()
+	defer st.Lock()
+	return wrappersEnsureQuotaGroup(grp, []*snap.Info{info}, &progress.NullProgress{})
+}
+
+// RemoveSnapFromQuotaGroup drops the given snap, which was removed
+// from the system, from its quota group if it has one. The services
+// of the snap are already gone, so only the state is updated.
+func RemoveSnapFromQuotaGroup(st *state.State, snapName string) error {
+	grp, err := snapGroup(st, snapName)
+	if err != nil || grp == nil {
+		return err
+	}
+	return setGroup(st, grp.Name, grp.RemoveSnap(snapName))
+}
+
+// Usage returns the current resource usage of the given quota group
+// as reported by systemd.
+func Usage(grp *quota.Group) (*systemd.UnitUsage, error) {
+	return systemd.New(dirs.GlobalRootDir, nil).UnitUsage(grp.SliceName())
+}
@@ -0,0 +1,410 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package quotastate_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/quotastate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type quotaSuite struct {
+	state *state.State
+	mgr   *quotastate.QuotaManager
+	calls []string
+
+	restore func()
+}
+
+var _ = Suite(&quotaSuite{})
+
+func snapNames(infos []*snap.Info) string {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (s *quotaSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	s.mgr = quotastate.Manager(s.state)
+	s.mgr.AddErrorTrigger()
+
+	s.calls = nil
+	s.restore = quotastate.MockWrappers(func(grp *quota.Group, infos []*snap.Info, meter progress.Meter) error {
+		s.calls = append(s.calls, fmt.Sprintf("ensure %s mem:%d cpu:%d [%s]", grp.Name, grp.MemoryLimit, grp.CPULimit, snapNames(infos)))
+		return nil
+	}, func(infos []*snap.Info, meter progress.Meter) error {
+		s.calls = append(s.calls, fmt.Sprintf("remove-snaps [%s]", snapNames(infos)))
+		return nil
+	}, func(grp *quota.Group, meter progress.Meter) error {
+		s.calls = append(s.calls, fmt.Sprintf("remove %s", grp.Name))
+		return nil
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	for _, name := range []string{"foo", "bar"} {
+		si := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+		snaptest.MockSnap(c, "name: "+name+"\nversion: v1\napps:\n svc:\n  daemon: simple\n", "", si)
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{si},
+			Current:  si.Revision,
+		})
+	}
+	snapstate.Set(s.state, "inactive", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "inactive", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+}
+
+func (s *quotaSuite) TearDownTest(c *C) {
+	s.restore()
+	dirs.SetRootDir("")
+}
+
+func (s *quotaSuite) settle(c *C, chg *state.Change) {
+	for i := 0; i < 10 && !chg.Status().Ready(); i++ {
+		s.state.Unlock()
+		s.mgr.Ensure()
+		s.mgr.Wait()
+		s.state.Lock()
+	}
+	c.Assert(chg.Status().Ready(), Equals, true)
+}
+
+func (s *quotaSuite) runChange(c *C, ts *state.TaskSet, fail bool) *state.Change {
+	chg := s.state.NewChange("quota", "...")
+	chg.AddAll(ts)
+	if fail {
+		errTask := s.state.NewTask("error-trigger", "provoking undo")
+		errTask.WaitAll(ts)
+		chg.AddTask(errTask)
+	}
+	s.settle(c, chg)
+	return chg
+}
+
+func (s *quotaSuite) TestEnsureQuotaCreatesAndUpdates(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 64*1024*1024, 0, []string{"foo", "inactive"})
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Ensure quota group "grp"`)
+	chg := s.runChange(c, ts, false)
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+
+	grp, err := quotastate.Group(s.state, "grp")
+	c.Assert(err, IsNil)
+	c.Check(grp, DeepEquals, &quota.Group{Name: "grp", MemoryLimit: 64 * 1024 * 1024, Snaps: []string{"foo", "inactive"}})
+
+	// limits not given are kept and snaps are added
+	ts, err = quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"bar"})
+	c.Assert(err, IsNil)
+	chg = s.runChange(c, ts, false)
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+
+	grp, err = quotastate.Group(s.state, "grp")
+	c.Assert(err, IsNil)
+	c.Check(grp, DeepEquals, &quota.Group{Name: "grp", MemoryLimit: 64 * 1024 * 1024, CPULimit: 50, Snaps: []string{"bar", "foo", "inactive"}})
+
+	c.Check(s.calls, DeepEquals, []string{
+		"remove-snaps []",
+		"ensure grp mem:67108864 cpu:0 [foo]",
+		"remove-snaps []",
+		"ensure grp mem:67108864 cpu:50 [bar,foo]",
+	})
+}
+
+func (s *quotaSuite) TestEnsureQuotaUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"foo"})
+	c.Assert(err, IsNil)
+	s.runChange(c, ts, false)
+
+	s.calls = nil
+	ts, err = quotastate.EnsureQuota(s.state, "grp", 0, 20, []string{"bar"})
+	c.Assert(err, IsNil)
+	chg := s.runChange(c, ts, true)
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+
+	grp, err := quotastate.Group(s.state, "grp")
+	c.Assert(err, IsNil)
+	c.Check(grp, DeepEquals, &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"foo"}})
+	c.Check(s.calls, DeepEquals, []string{
+		"remove-snaps []",
+		"ensure grp mem:0 cpu:20 [bar,foo]",
+		"remove-snaps [bar]",
+		"ensure grp mem:0 cpu:50 [foo]",
+	})
+
+	// undoing the creation of a group removes it
+	s.calls = nil
+	ts, err = quotastate.EnsureQuota(s.state, "other", 0, 10, []string{"bar"})
+	c.Assert(err, IsNil)
+	chg = s.runChange(c, ts, true)
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+
+	_, err = quotastate.Group(s.state, "other")
+	c.Check(err, ErrorMatches, `cannot find quota group "other"`)
+	c.Check(s.calls, DeepEquals, []string{
+		"remove-snaps []",
+		"ensure other mem:0 cpu:10 [bar]",
+		"remove-snaps [bar]",
+		"remove other",
+	})
+}
+
+func (s *quotaSuite) TestRemoveQuotaAndUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"foo"})
+	c.Assert(err, IsNil)
+	s.runChange(c, ts, false)
+
+	s.calls = nil
+	ts, err = quotastate.RemoveQuota(s.state, "grp")
+	c.Assert(err, IsNil)
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Remove quota group "grp"`)
+	chg := s.runChange(c, ts, true)
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+
+	_, err = quotastate.Group(s.state, "grp")
+	c.Check(err, IsNil)
+
+	ts, err = quotastate.RemoveQuota(s.state, "grp")
+	c.Assert(err, IsNil)
+	chg = s.runChange(c, ts, false)
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+
+	groups, err := quotastate.AllGroups(s.state)
+	c.Assert(err, IsNil)
+	c.Check(groups, HasLen, 0)
+	c.Check(s.calls, DeepEquals, []string{
+		"remove-snaps [foo]",
+		"remove grp",
+		"remove-snaps []",
+		"ensure grp mem:0 cpu:50 [foo]",
+		"remove-snaps [foo]",
+		"remove grp",
+	})
+}
+
+func (s *quotaSuite) TestEnsureQuotaErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"foo"})
+	c.Assert(err, IsNil)
+	s.runChange(c, ts, false)
+
+	for _, t := range []struct {
+		name  string
+		mem   uint64
+		cpu   int
+		snaps []string
+		err   string
+	}{
+		{"Grp", 0, 50, nil, `invalid quota group name: "Grp"`},
+		{"other", 0, 0, nil, `quota group "other" must have a memory or CPU limit`},
+		{"other", 1024, 0, nil, `memory limit of quota group "other" is too small: must be at least 4MB`},
+		{"other", 0, 50, []string{"missing"}, `snap "missing" is not installed`},
+		{"other", 0, 50, []string{"foo"}, `snap "foo" is already in quota group "grp"`},
+	} {
+		_, err := quotastate.EnsureQuota(s.state, t.name, t.mem, t.cpu, t.snaps)
+		c.Check(err, ErrorMatches, t.err)
+	}
+
+	_, err = quotastate.RemoveQuota(s.state, "other")
+	c.Check(err, ErrorMatches, `cannot find quota group "other"`)
+}
+
+func (s *quotaSuite) TestQuotaChangeConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"foo"})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("quota", "...")
+	chg.AddAll(ts)
+
+	_, err = quotastate.EnsureQuota(s.state, "other", 0, 50, []string{"bar"})
+	c.Check(err, ErrorMatches, fmt.Sprintf(`cannot change quota group "other" while change %q on quota group "grp" is in progress`, chg.ID()))
+	_, err = quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"bar"})
+	c.Check(err, ErrorMatches, fmt.Sprintf(`cannot change quota group "grp" while change %q is in progress`, chg.ID()))
+}
+
+func (s *quotaSuite) TestQuotaChangeConflictsWithSnapChanges(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"foo"})
+	c.Assert(err, IsNil)
+	s.runChange(c, ts, false)
+
+	// the snaps added to the group are affected
+	ts, err = quotastate.EnsureQuota(s.state, "grp", 0, 20, []string{"bar"})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("quota", "...")
+	chg.AddAll(ts)
+	c.Check(snapstate.CheckChangeConflict(s.state, "foo", nil, nil), ErrorMatches, `snap "foo" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflict(s.state, "bar", nil, nil), ErrorMatches, `snap "bar" has changes in progress`)
+	chg.SetStatus(state.DoneStatus)
+	c.Check(snapstate.CheckChangeConflict(s.state, "foo", nil, nil), IsNil)
+
+	// and so are the ones of a group being removed
+	ts, err = quotastate.RemoveQuota(s.state, "grp")
+	c.Assert(err, IsNil)
+	chg = s.state.NewChange("quota", "...")
+	chg.AddAll(ts)
+	c.Check(snapstate.CheckChangeConflict(s.state, "foo", nil, nil), ErrorMatches, `snap "foo" has changes in progress`)
+}
+
+func (s *quotaSuite) TestSnapChangeConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"foo"})
+	c.Assert(err, IsNil)
+	s.runChange(c, ts, false)
+
+	// foo is being refreshed
+	chg := s.state.NewChange("refresh", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "foo"}})
+	chg.AddTask(t)
+
+	_, err = quotastate.EnsureQuota(s.state, "other", 0, 50, []string{"foo"})
+	c.Check(err, ErrorMatches, `snap "foo" is already in quota group "grp"`)
+	// the snaps already in the group are affected as well
+	_, err = quotastate.EnsureQuota(s.state, "grp", 0, 20, []string{"bar"})
+	c.Check(err, ErrorMatches, `snap "foo" has changes in progress`)
+	_, err = quotastate.RemoveQuota(s.state, "grp")
+	c.Check(err, ErrorMatches, `snap "foo" has changes in progress`)
+
+	// other snaps can still be put in groups
+	_, err = quotastate.EnsureQuota(s.state, "other", 0, 50, []string{"bar"})
+	c.Check(err, IsNil)
+}
+
+func (s *quotaSuite) TestEnsureSnapQuotaGroup(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := quotastate.EnsureQuota(s.state, "grp", 0, 50, []string{"foo"})
+	c.Assert(err, IsNil)
+	s.runChange(c, ts, false)
+
+	s.calls = nil
+	for _, name := range []string{"foo", "bar"} {
+		info, err := snapstate.CurrentInfo(s.state, name)
+		c.Assert(err, IsNil)
+		c.Assert(snapstate.EnsureSnapQuotaGroup(s.state, info), IsNil)
+	}
+	c.Check(s.calls, DeepEquals, []string{"ensure grp mem:0 cpu:50 [foo]"})
+}
+
+func (s *quotaSuite) TestEnsureSnapQuotaGroupUnlocksState(c *C) {
+	restore := quotastate.MockWrappers(func(grp *quota.Group, infos []*snap.Info, meter progress.Meter) error {
+		// the state can be locked while systemd is busy
+		locked := make(chan bool)
+		go func() {
+			s.state.Lock()
+			s.state.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(5 * time.Second):
+			c.Fatalf("state is still locked")
+		}
+		return nil
+	}, nil, nil)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("quota-groups", map[string]*quota.Group{
+		"grp": {Name: "grp", CPULimit: 50, Snaps: []string{"foo"}},
+	})
+	info, err := snapstate.CurrentInfo(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(snapstate.EnsureSnapQuotaGroup(s.state, info), IsNil)
+}
+
+func (s *quotaSuite) TestRemoveSnapFromQuotaGroup(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("quota-groups", map[string]*quota.Group{
+		"grp": {Name: "grp", CPULimit: 50, Snaps: []string{"bar", "foo"}},
+	})
+
+	c.Assert(snapstate.RemoveSnapFromQuotaGroup(s.state, "foo"), IsNil)
+	grp, err := quotastate.Group(s.state, "grp")
+	c.Assert(err, IsNil)
+	c.Check(grp, DeepEquals, &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"bar"}})
+
+	// snaps not in a group are fine
+	c.Assert(snapstate.RemoveSnapFromQuotaGroup(s.state, "other"), IsNil)
+	// no systemd calls are needed
+	c.Check(s.calls, HasLen, 0)
+}
+
+func (s *quotaSuite) TestUsage(c *C) {
+	var sysdLog [][]string
+	restore := systemd.SystemctlCmd
+	defer func() { systemd.SystemctlCmd = restore }()
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("MemoryCurrent=4096\nCPUUsageNSec=1000000000\n"), nil
+	}
+
+	usage, err := quotastate.Usage(&quota.Group{Name: "grp"})
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, &systemd.UnitUsage{Memory: 4096, CPUTime: time.Second})
+	c.Check(sysdLog, DeepEquals, [][]string{{"show", "--property=MemoryCurrent,CPUUsageNSec", "snap.grp.slice"}})
+}
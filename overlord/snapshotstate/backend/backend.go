@@ -0,0 +1,306 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package backend implements the storage of snapshots of snap data on
+// disk, as zip files holding a tarball of the system data, one of the
+// data of each user, and their metadata.
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "golang.org/x/crypto/sha3" // expected for digests
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	archiveName  = "archive.tgz"
+	metadataName = "meta.json"
+	metaHashName = "meta.sha3_384"
+
+	userArchivePrefix = "user/"
+	userArchiveSuffix = ".tgz"
+)
+
+// Filename of the given client.Snapshot in this backend.
+func Filename(snapshot *client.Snapshot) string {
+	return filepath.Join(dirs.SnapshotsDir, fmt.Sprintf("%d_%s_%s_%s.zip", snapshot.SetID, snapshot.Snap, snapshot.Version, snapshot.Revision))
+}
+
+// Iter loops over all the snapshots in the snapshots directory, calling
+// f for each of them. Snapshots that cannot be opened are skipped. The
+// reader given to f is closed after it returns.
+func Iter(f func(*Reader) error) error {
+	dir, err := os.Open(dirs.SnapshotsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// no snapshots
+			return nil
+		}
+		return fmt.Errorf("cannot open snapshots directory: %v", err)
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return fmt.Errorf("cannot read snapshots directory: %v", err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if filepath.Ext(name) != ".zip" {
+			continue
+		}
+		filename := filepath.Join(dirs.SnapshotsDir, name)
+		rsh, err := Open(filename)
+		if err != nil {
+			logger.Noticef("Cannot open snapshot %q: %v.", name, err)
+			continue
+		}
+		err = f(rsh)
+		rsh.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type bySetAndSnap []*client.Snapshot
+
+func (ss bySetAndSnap) Len() int      { return len(ss) }
+func (ss bySetAndSnap) Swap(i, j int) { ss[i], ss[j] = ss[j], ss[i] }
+func (ss bySetAndSnap) Less(i, j int) bool {
+	if ss[i].SetID != ss[j].SetID {
+		return ss[i].SetID < ss[j].SetID
+	}
+	return ss[i].Snap < ss[j].Snap
+}
+
+// List the snapshot sets in the system, or just the one with the given
+// ID if it is not zero, restricted to the snapshots of the given snaps
+// if any.
+func List(setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+	wanted := make(map[string]bool, len(snapNames))
+	for _, name := range snapNames {
+		wanted[name] = true
+	}
+
+	var snapshots []*client.Snapshot
+	err := Iter(func(rsh *Reader) error {
+		if setID != 0 && rsh.SetID != setID {
+			return nil
+		}
+		if len(wanted) > 0 && !wanted[rsh.Snap] {
+			return nil
+		}
+		snapshot := rsh.Snapshot
+		snapshots = append(snapshots, &snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(bySetAndSnap(snapshots))
+
+	var sets []client.SnapshotSet
+	for _, snapshot := range snapshots {
+		if n := len(sets); n == 0 || sets[n-1].ID != snapshot.SetID {
+			sets = append(sets, client.SnapshotSet{ID: snapshot.SetID})
+		}
+		set := &sets[len(sets)-1]
+		set.Snapshots = append(set.Snapshots, snapshot)
+	}
+
+	return sets, nil
+}
+
+// userDataParent returns the directory holding the per-revision and
+// common data directories of the given snap in the home of the given
+// user.
+func userDataParent(username, snapName string) string {
+	return filepath.Join(strings.Replace(dirs.SnapDataHomeGlob, "*", username, 1), snapName)
+}
+
+// usersWithData returns the users that have data of the given snap in
+// their home, restricted to the given usernames if any.
+func usersWithData(snapName string, usernames []string) ([]string, error) {
+	wanted := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		wanted[username] = true
+	}
+
+	parents, err := filepath.Glob(filepath.Join(dirs.SnapDataHomeGlob, snapName))
+	if err != nil {
+		return nil, err
+	}
+	users := make([]string, 0, len(parents))
+	for _, parent := range parents {
+		// parent is /home/<username>/snap/<snap>
+		username := filepath.Base(filepath.Dir(filepath.Dir(parent)))
+		if len(wanted) > 0 && !wanted[username] {
+			continue
+		}
+		users = append(users, username)
+	}
+	sort.Strings(users)
+
+	return users, nil
+}
+
+// Save a snapshot of the data of the given snap, for the given users
+// (or all of them if none is given), as part of the snapshot set with
+// the given ID.
+func Save(id uint64, si *snap.Info, cfg *json.RawMessage, usernames []string) (*client.Snapshot, error) {
+	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
+		return nil, err
+	}
+
+	snapshot := &client.Snapshot{
+		SetID:    id,
+		Snap:     si.Name(),
+		Revision: si.Revision,
+		Version:  si.Version,
+		Time:     time.Now(),
+		SHA3_384: make(map[string]string),
+		Conf:     cfg,
+	}
+
+	filename := Filename(snapshot)
+	tmpname := filename + ".tmp"
+	f, err := os.OpenFile(tmpname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		f.Close()
+		// a no-op if the snapshot was committed
+		os.Remove(tmpname)
+	}()
+
+	w := zip.NewWriter(f)
+	revdir := filepath.Base(si.DataDir())
+
+	if err := addDirToZip(snapshot, w, archiveName, filepath.Dir(si.DataDir()), revdir); err != nil {
+		return nil, err
+	}
+
+	users, err := usersWithData(si.Name(), usernames)
+	if err != nil {
+		return nil, err
+	}
+	for _, username := range users {
+		entry := userArchivePrefix + username + userArchiveSuffix
+		if err := addDirToZip(snapshot, w, entry, userDataParent(username, si.Name()), revdir); err != nil {
+			return nil, err
+		}
+	}
+
+	metaWriter, err := w.Create(metadataName)
+	if err != nil {
+		return nil, err
+	}
+	hasher := crypto.SHA3_384.New()
+	enc := json.NewEncoder(io.MultiWriter(metaWriter, hasher))
+	if err := enc.Encode(snapshot); err != nil {
+		return nil, err
+	}
+
+	hashWriter, err := w.Create(metaHashName)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(hashWriter, "%x\n", hasher.Sum(nil))
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpname, filename); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter int64
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	*cw += countingWriter(len(p))
+	return len(p), nil
+}
+
+// addDirToZip adds to the zip an entry with the given name holding a
+// tarball of the revision and common data directories found in
+// parent, if any.
+func addDirToZip(snapshot *client.Snapshot, w *zip.Writer, entry, parent, revdir string) error {
+	var names []string
+	for _, name := range []string{revdir, "common"} {
+		if fi, err := os.Stat(filepath.Join(parent, name)); err == nil && fi.IsDir() {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		// nothing to save
+		return nil
+	}
+
+	// the tarball is already compressed
+	zw, err := w.CreateHeader(&zip.FileHeader{
+		Name:   entry,
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+
+	var size countingWriter
+	var errBuf bytes.Buffer
+	hasher := crypto.SHA3_384.New()
+
+	cmd := exec.Command("tar", append([]string{"--create", "--sparse", "--gzip", "--directory", parent}, names...)...)
+	cmd.Stdout = io.MultiWriter(zw, hasher, &size)
+	cmd.Stderr = &errBuf
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cannot create archive of %q: %v (%s)", parent, err, strings.TrimSpace(errBuf.String()))
+	}
+
+	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
+	snapshot.Size += int64(size)
+
+	return nil
+}
@@ -0,0 +1,295 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type backendSuite struct {
+	root string
+	info *snap.Info
+}
+
+var _ = Suite(&backendSuite{})
+
+func (s *backendSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	dirs.SetRootDir(s.root)
+
+	s.info = &snap.Info{
+		SuggestedName: "hello-snap",
+		Version:       "v1.33",
+		SideInfo:      snap.SideInfo{Revision: snap.R(42)},
+	}
+
+	s.mkData(c, filepath.Join(dirs.SnapDataDir, "hello-snap"), "system")
+	s.mkData(c, filepath.Join(s.root, "home/alice/snap/hello-snap"), "alice")
+	s.mkData(c, filepath.Join(s.root, "home/bob/snap/hello-snap"), "bob")
+}
+
+func (s *backendSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *backendSuite) mkData(c *C, parent, content string) {
+	for _, dir := range []string{"42", "common"} {
+		c.Assert(os.MkdirAll(filepath.Join(parent, dir), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(parent, dir, "data"), []byte(content+" "+dir), 0644), IsNil)
+	}
+}
+
+func (s *backendSuite) checkData(c *C, parent, dir, content string) {
+	buf, err := ioutil.ReadFile(filepath.Join(parent, dir, "data"))
+	c.Assert(err, IsNil)
+	c.Check(string(buf), Equals, content)
+}
+
+func (s *backendSuite) TestSaveOpenAndList(c *C) {
+	cfg := json.RawMessage(`{"some":"config"}`)
+	shot, err := backend.Save(12, s.info, &cfg, nil)
+	c.Assert(err, IsNil)
+	c.Check(shot.SetID, Equals, uint64(12))
+	c.Check(shot.Snap, Equals, "hello-snap")
+	c.Check(shot.Revision, Equals, snap.R(42))
+	c.Check(shot.Version, Equals, "v1.33")
+	c.Check(shot.Size > 0, Equals, true)
+	c.Check(shot.SHA3_384, HasLen, 3)
+	for _, entry := range []string{"archive.tgz", "user/alice.tgz", "user/bob.tgz"} {
+		c.Check(shot.SHA3_384[entry], HasLen, 96)
+	}
+
+	filename := backend.Filename(shot)
+	c.Check(filename, Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
+
+	rsh, err := backend.Open(filename)
+	c.Assert(err, IsNil)
+	defer rsh.Close()
+	c.Check(rsh.Name, Equals, filename)
+	c.Check(rsh.SHA3_384, DeepEquals, shot.SHA3_384)
+	c.Check(string(*rsh.Conf), Equals, `{"some":"config"}`)
+	c.Check(rsh.Check(nil), IsNil)
+
+	sets, err := backend.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sets, HasLen, 1)
+	c.Check(sets[0].ID, Equals, uint64(12))
+	c.Assert(sets[0].Snapshots, HasLen, 1)
+	c.Check(sets[0].Snapshots[0].Snap, Equals, "hello-snap")
+
+	sets, err = backend.List(13, nil)
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+
+	sets, err = backend.List(0, []string{"other-snap"})
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+}
+
+func (s *backendSuite) TestSaveSomeUsers(c *C) {
+	shot, err := backend.Save(1, s.info, nil, []string{"bob"})
+	c.Assert(err, IsNil)
+	c.Check(shot.SHA3_384, HasLen, 2)
+	c.Check(shot.SHA3_384["user/bob.tgz"], Not(Equals), "")
+	c.Check(shot.SHA3_384["user/alice.tgz"], Equals, "")
+}
+
+func (s *backendSuite) TestListSkipsBroken(c *C) {
+	_, err := backend.Save(1, s.info, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapshotsDir, "2_broken_1_1.zip"), []byte("not a zip"), 0600), IsNil)
+
+	sets, err := backend.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sets, HasLen, 1)
+	c.Check(sets[0].ID, Equals, uint64(1))
+}
+
+func (s *backendSuite) TestListNoDir(c *C) {
+	sets, err := backend.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+}
+
+func (s *backendSuite) TestRestoreIntoNewRevision(c *C) {
+	shot, err := backend.Save(1, s.info, nil, nil)
+	c.Assert(err, IsNil)
+
+	// change the data, and move to a new revision
+	systemDir := filepath.Join(dirs.SnapDataDir, "hello-snap")
+	s.mkData(c, systemDir, "changed")
+	c.Assert(os.Rename(filepath.Join(systemDir, "42"), filepath.Join(systemDir, "43")), IsNil)
+
+	rsh, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer rsh.Close()
+
+	info := *s.info
+	info.Revision = snap.R(43)
+	var logs []string
+	rs, err := rsh.Restore(&info, []string{"alice"}, func(format string, args ...interface{}) {
+		logs = append(logs, format)
+	})
+	c.Assert(err, IsNil)
+	c.Check(logs, HasLen, 0)
+
+	s.checkData(c, systemDir, "43", "system 42")
+	s.checkData(c, systemDir, "common", "system common")
+	c.Check(rs.Moved, DeepEquals, []string{
+		filepath.Join(systemDir, "43"),
+		filepath.Join(systemDir, "common"),
+		// alice had no data for revision 43
+		filepath.Join(s.root, "home/alice/snap/hello-snap/common"),
+	})
+	s.checkData(c, systemDir, "43"+rs.Suffix, "changed 42")
+
+	rs.Revert()
+	s.checkData(c, systemDir, "43", "changed 42")
+	s.checkData(c, systemDir, "common", "changed common")
+	_, err = os.Stat(filepath.Join(systemDir, "43"+rs.Suffix))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *backendSuite) TestRestoreCleanup(c *C) {
+	shot, err := backend.Save(1, s.info, nil, nil)
+	c.Assert(err, IsNil)
+	systemDir := filepath.Join(dirs.SnapDataDir, "hello-snap")
+	s.mkData(c, systemDir, "changed")
+
+	rsh, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer rsh.Close()
+
+	rs, err := rsh.Restore(s.info, nil, func(string, ...interface{}) {})
+	c.Assert(err, IsNil)
+	s.checkData(c, systemDir, "42", "system 42")
+	s.checkData(c, filepath.Join(s.root, "home/bob/snap/hello-snap"), "42", "bob 42")
+
+	rs.Cleanup()
+	s.checkData(c, systemDir, "42", "system 42")
+	_, err = os.Stat(filepath.Join(systemDir, "42"+rs.Suffix))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *backendSuite) TestRestoreSkipsMissingUsers(c *C) {
+	shot, err := backend.Save(1, s.info, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "home/bob")), IsNil)
+
+	rsh, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer rsh.Close()
+
+	var logs []string
+	_, err = rsh.Restore(s.info, nil, func(format string, args ...interface{}) {
+		logs = append(logs, format)
+	})
+	c.Assert(err, IsNil)
+	c.Check(logs, HasLen, 1)
+	_, err = os.Stat(filepath.Join(s.root, "home/bob"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *backendSuite) TestRestoreCreatesUserDirsOwnedByUser(c *C) {
+	if os.Getuid() != 0 {
+		c.Skip("changing the ownership of files needs root")
+	}
+	restore := backend.MockUserLookup(func(username string) (*user.User, error) {
+		c.Check(username, Equals, "alice")
+		return &user.User{Username: username, Uid: "1234", Gid: "4321"}, nil
+	})
+	defer restore()
+
+	shot, err := backend.Save(1, s.info, nil, []string{"alice"})
+	c.Assert(err, IsNil)
+	// alice has no snap data at all anymore
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "home/alice/snap")), IsNil)
+
+	rsh, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer rsh.Close()
+
+	rs, err := rsh.Restore(s.info, []string{"alice"}, func(string, ...interface{}) {})
+	c.Assert(err, IsNil)
+	s.checkData(c, filepath.Join(s.root, "home/alice/snap/hello-snap"), "42", "alice 42")
+	for _, dir := range []string{"home/alice/snap", "home/alice/snap/hello-snap"} {
+		fi, err := os.Stat(filepath.Join(s.root, dir))
+		c.Assert(err, IsNil)
+		st := fi.Sys().(*syscall.Stat_t)
+		c.Check(st.Uid, Equals, uint32(1234), Commentf(dir))
+		c.Check(st.Gid, Equals, uint32(4321), Commentf(dir))
+	}
+
+	// the topmost created directory goes away on revert
+	rs.Revert()
+	_, err = os.Stat(filepath.Join(s.root, "home/alice/snap"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *backendSuite) TestRestoreUnknownUser(c *C) {
+	restore := backend.MockUserLookup(func(username string) (*user.User, error) {
+		return nil, user.UnknownUserError(username)
+	})
+	defer restore()
+
+	shot, err := backend.Save(1, s.info, nil, []string{"alice"})
+	c.Assert(err, IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "home/alice/snap")), IsNil)
+
+	rsh, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer rsh.Close()
+
+	_, err = rsh.Restore(s.info, []string{"alice"}, func(string, ...interface{}) {})
+	c.Check(err, ErrorMatches, `cannot lookup user "alice": user: unknown user alice`)
+}
+
+func (s *backendSuite) TestCheckBadHash(c *C) {
+	shot, err := backend.Save(1, s.info, nil, nil)
+	c.Assert(err, IsNil)
+
+	rsh, err := backend.Open(backend.Filename(shot))
+	c.Assert(err, IsNil)
+	defer rsh.Close()
+	rsh.SHA3_384["archive.tgz"] = "bad"
+
+	c.Check(rsh.Check(nil), ErrorMatches, `snapshot archive "archive.tgz" hash mismatch: .*`)
+	// the data is left as it was
+	systemDir := filepath.Join(dirs.SnapDataDir, "hello-snap")
+	s.mkData(c, systemDir, "changed")
+	_, err = rsh.Restore(s.info, nil, func(string, ...interface{}) {})
+	c.Check(err, ErrorMatches, `snapshot archive "archive.tgz" hash mismatch: .*`)
+	s.checkData(c, systemDir, "42", "changed 42")
+	s.checkData(c, systemDir, "common", "changed common")
+}
@@ -0,0 +1,345 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// A Reader is a snapshot that's been opened for reading.
+type Reader struct {
+	client.Snapshot
+	Name string
+
+	file *os.File
+	zip  *zip.Reader
+}
+
+// Open a snapshot, given its filename. The metadata of the snapshot is
+// checked against its hash.
+func Open(fn string) (rsh *Reader, err error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	rsh = &Reader{
+		Name: fn,
+		file: f,
+		zip:  zr,
+	}
+
+	metaFile := rsh.entry(metadataName)
+	hashFile := rsh.entry(metaHashName)
+	if metaFile == nil || hashFile == nil {
+		return nil, errors.New("snapshot is missing its metadata")
+	}
+
+	metaBuf, err := readEntry(metaFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot metadata: %v", err)
+	}
+	hashBuf, err := readEntry(hashFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot metadata hash: %v", err)
+	}
+
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(metaBuf)
+	if expected, actual := strings.TrimSpace(string(hashBuf)), fmt.Sprintf("%x", hasher.Sum(nil)); expected != actual {
+		return nil, fmt.Errorf("snapshot metadata hash mismatch: expected %q, got %q", expected, actual)
+	}
+
+	if err := json.Unmarshal(metaBuf, &rsh.Snapshot); err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot metadata: %v", err)
+	}
+
+	return rsh, nil
+}
+
+// Close the snapshot.
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+func (r *Reader) entry(name string) *zip.File {
+	for _, f := range r.zip.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// entries returns the names of the archives in the snapshot that are
+// relevant for the given users (or all users, if none is given), in
+// order.
+func (r *Reader) entries(usernames []string) []string {
+	wanted := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		wanted[username] = true
+	}
+
+	entries := make([]string, 0, len(r.SHA3_384))
+	for entry := range r.SHA3_384 {
+		if len(wanted) > 0 && strings.HasPrefix(entry, userArchivePrefix) {
+			username := strings.TrimSuffix(strings.TrimPrefix(entry, userArchivePrefix), userArchiveSuffix)
+			if !wanted[username] {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+
+	return entries
+}
+
+// copyEntry copies the given archive to w, checking its hash.
+func (r *Reader) copyEntry(entry string, w io.Writer) error {
+	f := r.entry(entry)
+	if f == nil {
+		return fmt.Errorf("snapshot is missing archive %q", entry)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	hasher := crypto.SHA3_384.New()
+	if _, err := io.Copy(io.MultiWriter(w, hasher), rc); err != nil {
+		return err
+	}
+
+	if expected, actual := r.SHA3_384[entry], fmt.Sprintf("%x", hasher.Sum(nil)); expected != actual {
+		return fmt.Errorf("snapshot archive %q hash mismatch: expected %q, got %q", entry, expected, actual)
+	}
+
+	return nil
+}
+
+// Check that the archives in the snapshot for the given users (or all
+// of them, if none is given) match their hashes.
+func (r *Reader) Check(usernames []string) error {
+	for _, entry := range r.entries(usernames) {
+		if err := r.copyEntry(entry, ioutil.Discard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreState stores the information needed to undo or finish a
+// restore.
+type RestoreState struct {
+	// Created holds the directories created by the restore
+	Created []string `json:"created,omitempty"`
+	// Moved holds the directories that were moved aside (by appending
+	// Suffix to their names) to make room for the restored ones
+	Moved  []string `json:"moved,omitempty"`
+	Suffix string   `json:"suffix"`
+}
+
+// Revert the restore, removing the restored directories and moving
+// back the ones they replaced.
+func (rs *RestoreState) Revert() {
+	for i := len(rs.Created) - 1; i >= 0; i-- {
+		if err := os.RemoveAll(rs.Created[i]); err != nil {
+			logger.Noticef("Cannot remove %q: %v.", rs.Created[i], err)
+		}
+	}
+	for i := len(rs.Moved) - 1; i >= 0; i-- {
+		dir := rs.Moved[i]
+		if err := os.Rename(dir+rs.Suffix, dir); err != nil {
+			logger.Noticef("Cannot restore %q: %v.", dir, err)
+		}
+	}
+}
+
+// Cleanup removes the directories that were moved aside by the restore.
+func (rs *RestoreState) Cleanup() {
+	for _, dir := range rs.Moved {
+		if err := os.RemoveAll(dir + rs.Suffix); err != nil {
+			logger.Noticef("Cannot remove %q: %v.", dir+rs.Suffix, err)
+		}
+	}
+}
+
+// Restore the data in the snapshot for the given users (or all of
+// them, if none is given) into the data directories of the given
+// snap. If the restore fails, whatever was restored is reverted.
+func (r *Reader) Restore(si *snap.Info, usernames []string, logf func(format string, args ...interface{})) (*RestoreState, error) {
+	rs := &RestoreState{
+		Suffix: fmt.Sprintf(".~%x~", time.Now().UnixNano()),
+	}
+
+	for _, entry := range r.entries(usernames) {
+		var parent, username string
+		if entry == archiveName {
+			parent = filepath.Dir(si.DataDir())
+		} else {
+			username = strings.TrimSuffix(strings.TrimPrefix(entry, userArchivePrefix), userArchiveSuffix)
+			home := strings.Replace(dirs.SnapDataHomeGlob, "*", username, 1)
+			if !osutil.IsDirectory(filepath.Dir(home)) {
+				logf("Skipping restore of %q: user %q has no home directory.", entry, username)
+				continue
+			}
+			parent = userDataParent(username, si.Name())
+		}
+
+		if err := r.restoreEntry(rs, entry, parent, username, si.Revision); err != nil {
+			rs.Revert()
+			return nil, err
+		}
+	}
+
+	return rs, nil
+}
+
+var userLookup = user.Lookup
+
+// mkdirAllUser is like os.MkdirAll, but the directories it creates
+// are owned by the given user, if any.
+func mkdirAllUser(dir, username string) error {
+	if username == "" {
+		return os.MkdirAll(dir, 0755)
+	}
+	usr, err := userLookup(username)
+	if err != nil {
+		return fmt.Errorf("cannot lookup user %q: %s", username, err)
+	}
+	uid, err := strconv.Atoi(usr.Uid)
+	if err != nil {
+		return fmt.Errorf("cannot get uid of user %q: %s", username, err)
+	}
+	gid, err := strconv.Atoi(usr.Gid)
+	if err != nil {
+		return fmt.Errorf("cannot get gid of user %q: %s", username, err)
+	}
+	return osutil.MkdirAllChown(dir, 0755, uid, gid)
+}
+
+func (r *Reader) restoreEntry(rs *RestoreState, entry, parent, username string, revision snap.Revision) error {
+	if !osutil.IsDirectory(parent) {
+		// the topmost missing directory is the one to remove on revert
+		created := parent
+		for dir := filepath.Dir(parent); !osutil.FileExists(dir); dir = filepath.Dir(dir) {
+			created = dir
+		}
+		if err := mkdirAllUser(parent, username); err != nil {
+			return err
+		}
+		rs.Created = append(rs.Created, created)
+	}
+
+	tempdir, err := ioutil.TempDir(parent, ".snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	var errBuf bytes.Buffer
+	cmd := exec.Command("tar", "--extract", "--preserve-permissions", "--preserve-order", "--gunzip", "--directory", tempdir)
+	cmd.Stderr = &errBuf
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	copyErr := r.copyEntry(entry, stdin)
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("cannot unpack %q: %v (%s)", entry, err, strings.TrimSpace(errBuf.String()))
+	}
+	if copyErr != nil {
+		return copyErr
+	}
+
+	for _, name := range []string{r.Revision.String(), "common"} {
+		source := filepath.Join(tempdir, name)
+		if !osutil.IsDirectory(source) {
+			continue
+		}
+		target := filepath.Join(parent, name)
+		if name != "common" {
+			// the data is restored into the current revision
+			target = filepath.Join(parent, revision.String())
+		}
+		if osutil.FileExists(target) {
+			if err := os.Rename(target, target+rs.Suffix); err != nil {
+				return err
+			}
+			rs.Moved = append(rs.Moved, target)
+		}
+		if err := os.Rename(source, target); err != nil {
+			return err
+		}
+		rs.Created = append(rs.Created, target)
+	}
+
+	return nil
+}
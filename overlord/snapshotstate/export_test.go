@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func MockBackendIter(f func(func(*backend.Reader) error) error) (restore func()) {
+	old := backendIter
+	backendIter = f
+	return func() {
+		backendIter = old
+	}
+}
+
+func MockBackendSave(f func(uint64, *snap.Info, *json.RawMessage, []string) (*client.Snapshot, error)) (restore func()) {
+	old := backendSave
+	backendSave = f
+	return func() {
+		backendSave = old
+	}
+}
+
+// AddErrorTrigger registers a handler for a task that always fails,
+// to test the undoing of changes.
+func (m *SnapshotManager) AddErrorTrigger() {
+	m.runner.AddHandler("error-trigger", func(*state.Task, *tomb.Tomb) error {
+		return errors.New("error out")
+	}, nil)
+}
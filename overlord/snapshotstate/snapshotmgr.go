@@ -0,0 +1,250 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// SnapshotManager is responsible for the saving, restoring and
+// forgetting of snapshots of the data of snaps.
+type SnapshotManager struct {
+	runner *state.TaskRunner
+}
+
+// Manager returns a new snapshot manager.
+func Manager(st *state.State) *SnapshotManager {
+	runner := state.NewTaskRunner(st)
+
+	runner.AddHandler("save-snapshot", doSave, undoSave)
+	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
+	runner.AddHandler("cleanup-after-restore", doCleanupAfterRestore, nil)
+	runner.AddHandler("forget-snapshot", doForget, nil)
+
+	return &SnapshotManager{runner: runner}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *SnapshotManager) Ensure() error {
+	m.runner.Ensure()
+	return nil
+}
+
+// Wait implements StateManager.Wait.
+func (m *SnapshotManager) Wait() {
+	m.runner.Wait()
+}
+
+// Stop implements StateManager.Stop.
+func (m *SnapshotManager) Stop() {
+	m.runner.Stop()
+}
+
+// snapInfoAndConfig returns the current info and the configuration of
+// the given snap.
+func snapInfoAndConfig(st *state.State, snapName string) (*snap.Info, *json.RawMessage, error) {
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil {
+		if err == state.ErrNoState {
+			return nil, nil, fmt.Errorf("snap %q is not installed", snapName)
+		}
+		return nil, nil, err
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := config.GetSnapConfig(st, snapName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return info, cfg, nil
+}
+
+func doSave(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	setup, err := taskSnapshotSetup(task)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	info, cfg, err := snapInfoAndConfig(st, setup.Snap)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := backendSave(setup.SetID, info, cfg, setup.Users)
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	setup.Filename = backend.Filename(snapshot)
+	task.Set("snapshot-setup", setup)
+
+	return nil
+}
+
+func undoSave(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	setup, err := taskSnapshotSetup(task)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if setup.Filename == "" {
+		return nil
+	}
+	if err := os.Remove(setup.Filename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove snapshot %q: %v", setup.Filename, err)
+	}
+
+	return nil
+}
+
+func doRestore(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	setup, err := taskSnapshotSetup(task)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	info, oldCfg, err := snapInfoAndConfig(st, setup.Snap)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	rsh, err := backendOpen(setup.Filename)
+	if err != nil {
+		return fmt.Errorf("cannot open snapshot: %v", err)
+	}
+	defer rsh.Close()
+
+	logf := func(format string, args ...interface{}) {
+		st.Lock()
+		defer st.Unlock()
+		task.Logf(format, args...)
+	}
+
+	restoreState, err := rsh.Restore(info, setup.Users, logf)
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	if err := config.SetSnapConfig(st, setup.Snap, rsh.Conf); err != nil {
+		restoreState.Revert()
+		return fmt.Errorf("cannot restore configuration of snap %q: %v", setup.Snap, err)
+	}
+
+	task.Set("restore-state", restoreState)
+	task.Set("old-config", oldCfg)
+
+	return nil
+}
+
+func undoRestore(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var restoreState backend.RestoreState
+	var oldCfg *json.RawMessage
+	if err := task.Get("restore-state", &restoreState); err != nil {
+		return err
+	}
+	if err := task.Get("old-config", &oldCfg); err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	setup, err := taskSnapshotSetup(task)
+	if err != nil {
+		return err
+	}
+	if err := config.SetSnapConfig(st, setup.Snap, oldCfg); err != nil {
+		return fmt.Errorf("cannot restore previous configuration of snap %q: %v", setup.Snap, err)
+	}
+
+	st.Unlock()
+	restoreState.Revert()
+	st.Lock()
+
+	return nil
+}
+
+func doCleanupAfterRestore(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	var restoreStates []*backend.RestoreState
+	for _, t := range task.WaitTasks() {
+		if t.Kind() != "restore-snapshot" {
+			continue
+		}
+		var restoreState backend.RestoreState
+		if err := t.Get("restore-state", &restoreState); err != nil {
+			st.Unlock()
+			return err
+		}
+		restoreStates = append(restoreStates, &restoreState)
+	}
+	st.Unlock()
+
+	for _, restoreState := range restoreStates {
+		restoreState.Cleanup()
+	}
+
+	return nil
+}
+
+func doForget(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	setup, err := taskSnapshotSetup(task)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(setup.Filename); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove snapshot %q: %v", setup.Filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,324 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package snapshotstate implements the manager and state aspects
+// responsible for the saving, restoring and forgetting of snapshots
+// of the data of snaps.
+package snapshotstate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var (
+	backendIter = backend.Iter
+	backendList = backend.List
+	backendSave = backend.Save
+	backendOpen = backend.Open
+)
+
+func init() {
+	snapstate.AutomaticSnapshot = AutomaticSnapshot
+	// saving or restoring the data of a snap conflicts with changes
+	// removing or refreshing it
+	snapstate.AddAffectedSnapsByKind("save-snapshot", snapshotAffectedSnaps)
+	snapstate.AddAffectedSnapsByKind("restore-snapshot", snapshotAffectedSnaps)
+}
+
+// snapshotSetup holds the parameters of a snapshot task.
+type snapshotSetup struct {
+	SetID    uint64   `json:"set-id"`
+	Snap     string   `json:"snap"`
+	Users    []string `json:"users,omitempty"`
+	Filename string   `json:"filename,omitempty"`
+}
+
+func taskSnapshotSetup(t *state.Task) (*snapshotSetup, error) {
+	var setup snapshotSetup
+	if err := t.Get("snapshot-setup", &setup); err != nil {
+		return nil, err
+	}
+	return &setup, nil
+}
+
+func snapshotAffectedSnaps(t *state.Task) ([]string, error) {
+	setup, err := taskSnapshotSetup(t)
+	if err != nil {
+		return nil, err
+	}
+	return []string{setup.Snap}, nil
+}
+
+// checkSnapshotTaskConflict checks that no task of the given kinds
+// operating on the given snapshot set is in progress.
+func checkSnapshotTaskConflict(st *state.State, setID uint64, conflictingKinds ...string) error {
+	for _, task := range st.Tasks() {
+		chg := task.Change()
+		if chg == nil || chg.Status().Ready() {
+			continue
+		}
+		if !strutil.ListContains(conflictingKinds, task.Kind()) {
+			continue
+		}
+		setup, err := taskSnapshotSetup(task)
+		if err != nil {
+			return fmt.Errorf("internal error: cannot obtain snapshot setup from task: %s", task.Summary())
+		}
+		if setup.SetID == setID {
+			return fmt.Errorf("cannot operate on snapshot set #%d while change %q is in progress", setID, chg.ID())
+		}
+	}
+	return nil
+}
+
+func newSnapshotSetID(st *state.State) (uint64, error) {
+	var lastSetID uint64
+	err := st.Get("last-snapshot-set-id", &lastSetID)
+	if err != nil && err != state.ErrNoState {
+		return 0, err
+	}
+	lastSetID++
+	st.Set("last-snapshot-set-id", lastSetID)
+
+	return lastSetID, nil
+}
+
+func allActiveSnapNames(st *state.State) ([]string, error) {
+	all, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(all))
+	for name, snapst := range all {
+		if snapst.Active {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// List the snapshot sets in the system, or just the one with the given
+// ID if it is not zero, restricted to the snapshots of the given snaps
+// if any.
+func List(setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+	return backendList(setID, snapNames)
+}
+
+// Save creates a taskset for taking snapshots of the data of the given
+// snaps, or of all the active snaps if none is given, for the given
+// users, or for all of them if none is given. It returns the ID of the
+// new snapshot set and the names of the snaps that will be saved.
+func Save(st *state.State, snapNames []string, users []string) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	if len(snapNames) == 0 {
+		snapNames, err = allActiveSnapNames(st)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	for _, name := range snapNames {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, name, &snapst); err != nil {
+			if err == state.ErrNoState {
+				return 0, nil, nil, fmt.Errorf("snap %q is not installed", name)
+			}
+			return 0, nil, nil, err
+		}
+		if err := snapstate.CheckChangeConflict(st, name, nil, nil); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	setID, err = newSnapshotSetID(st)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	ts = state.NewTaskSet()
+	for _, name := range snapNames {
+		desc := fmt.Sprintf(i18n.G("Save data of snap %q in snapshot set #%d"), name, setID)
+		task := st.NewTask("save-snapshot", desc)
+		task.Set("snapshot-setup", &snapshotSetup{
+			SetID: setID,
+			Snap:  name,
+			Users: users,
+		})
+		ts.AddTask(task)
+	}
+
+	return setID, snapNames, ts, nil
+}
+
+// AutomaticSnapshot creates a taskset for taking a snapshot of the
+// data of the given snap, which is about to be removed, if the
+// snapshots.automatic core option is set. It returns a nil taskset
+// otherwise.
+func AutomaticSnapshot(st *state.State, snapName string) (*state.TaskSet, error) {
+	var automatic bool
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "snapshots.automatic", &automatic)
+	if err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if !automatic {
+		return nil, nil
+	}
+
+	setID, err := newSnapshotSetID(st)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := fmt.Sprintf(i18n.G("Save data of snap %q in automatic snapshot set #%d"), snapName, setID)
+	task := st.NewTask("save-snapshot", desc)
+	task.Set("snapshot-setup", &snapshotSetup{
+		SetID: setID,
+		Snap:  snapName,
+	})
+
+	return state.NewTaskSet(task), nil
+}
+
+// snapshotsInSet returns the setups of the snapshots in the given set,
+// restricted to the given snaps if any, keyed by snap name.
+func snapshotsInSet(setID uint64, snapNames []string) (map[string]*snapshotSetup, error) {
+	setups := make(map[string]*snapshotSetup)
+	found := false
+	err := backendIter(func(rsh *backend.Reader) error {
+		if rsh.SetID != setID {
+			return nil
+		}
+		found = true
+		if len(snapNames) > 0 && !strutil.ListContains(snapNames, rsh.Snap) {
+			return nil
+		}
+		setups[rsh.Snap] = &snapshotSetup{
+			SetID:    setID,
+			Snap:     rsh.Snap,
+			Filename: rsh.Name,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf("snapshot set #%d not found", setID)
+	}
+	for _, name := range snapNames {
+		if setups[name] == nil {
+			return nil, fmt.Errorf("snapshot set #%d has no snapshot of snap %q", setID, name)
+		}
+	}
+
+	return setups, nil
+}
+
+func sortedSnapNames(setups map[string]*snapshotSetup) []string {
+	names := make([]string, 0, len(setups))
+	for name := range setups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Restore creates a taskset for restoring the data of the given snaps,
+// or of all of them if none is given, from the given snapshot set, for
+// the given users, or for all of them if none is given. The snaps
+// must be installed. It returns the names of the snaps that will be
+// restored.
+func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	setups, err := snapshotsInSet(setID, snapNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapsFound = sortedSnapNames(setups)
+
+	if err := checkSnapshotTaskConflict(st, setID, "forget-snapshot"); err != nil {
+		return nil, nil, err
+	}
+
+	for _, name := range snapsFound {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, name, &snapst); err != nil {
+			if err == state.ErrNoState {
+				return nil, nil, fmt.Errorf("cannot restore snapshot of snap %q: snap is not installed", name)
+			}
+			return nil, nil, err
+		}
+		if err := snapstate.CheckChangeConflict(st, name, nil, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ts = state.NewTaskSet()
+	cleanup := st.NewTask("cleanup-after-restore", fmt.Sprintf(i18n.G("Clean up after restoring snapshot set #%d"), setID))
+	for _, name := range snapsFound {
+		setup := setups[name]
+		setup.Users = users
+
+		desc := fmt.Sprintf(i18n.G("Restore data of snap %q from snapshot set #%d"), name, setID)
+		task := st.NewTask("restore-snapshot", desc)
+		task.Set("snapshot-setup", setup)
+		cleanup.WaitFor(task)
+		ts.AddTask(task)
+	}
+	ts.AddTask(cleanup)
+
+	return snapsFound, ts, nil
+}
+
+// Forget creates a taskset for permanently removing the snapshots of
+// the given snaps, or of all of them if none is given, from the given
+// snapshot set. It returns the names of the snaps whose snapshots will
+// be removed.
+func Forget(st *state.State, setID uint64, snapNames []string) (snapsFound []string, ts *state.TaskSet, err error) {
+	setups, err := snapshotsInSet(setID, snapNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapsFound = sortedSnapNames(setups)
+
+	if err := checkSnapshotTaskConflict(st, setID, "restore-snapshot"); err != nil {
+		return nil, nil, err
+	}
+
+	ts = state.NewTaskSet()
+	for _, name := range snapsFound {
+		desc := fmt.Sprintf(i18n.G("Drop data of snap %q from snapshot set #%d"), name, setID)
+		task := st.NewTask("forget-snapshot", desc)
+		task.Set("snapshot-setup", setups[name])
+		ts.AddTask(task)
+	}
+
+	return snapsFound, ts, nil
+}
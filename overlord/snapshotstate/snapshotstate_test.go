@@ -0,0 +1,409 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type snapshotSuite struct {
+	state *state.State
+	mgr   *snapshotstate.SnapshotManager
+}
+
+var _ = Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.state = state.New(nil)
+	s.mgr = snapshotstate.Manager(s.state)
+	s.mgr.AddErrorTrigger()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	for _, name := range []string{"foo", "bar"} {
+		si := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+		snaptest.MockSnap(c, "name: "+name+"\nversion: v1\n", "", si)
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{si},
+			Current:  si.Revision,
+		})
+	}
+	snapstate.Set(s.state, "inactive", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "inactive", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+	})
+}
+
+func (s *snapshotSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *snapshotSuite) settle(c *C, chg *state.Change) {
+	for i := 0; i < 10 && !chg.Status().Ready(); i++ {
+		s.state.Unlock()
+		s.mgr.Ensure()
+		s.mgr.Wait()
+		s.state.Lock()
+	}
+	c.Assert(chg.Status().Ready(), Equals, true)
+}
+
+func taskSetups(c *C, ts *state.TaskSet) []map[string]interface{} {
+	var setups []map[string]interface{}
+	for _, t := range ts.Tasks() {
+		var setup map[string]interface{}
+		if err := t.Get("snapshot-setup", &setup); err == state.ErrNoState {
+			continue
+		} else {
+			c.Assert(err, IsNil)
+		}
+		setups = append(setups, setup)
+	}
+	return setups
+}
+
+func (s *snapshotSuite) TestSaveAllActive(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setID, saved, ts, err := snapshotstate.Save(s.state, nil, []string{"alice"})
+	c.Assert(err, IsNil)
+	c.Check(setID, Equals, uint64(1))
+	c.Check(saved, DeepEquals, []string{"bar", "foo"})
+	c.Check(taskSetups(c, ts), DeepEquals, []map[string]interface{}{
+		{"set-id": 1., "snap": "bar", "users": []interface{}{"alice"}},
+		{"set-id": 1., "snap": "foo", "users": []interface{}{"alice"}},
+	})
+
+	setID, _, _, err = snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(setID, Equals, uint64(2))
+}
+
+func (s *snapshotSuite) TestSaveNotInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, _, _, err := snapshotstate.Save(s.state, []string{"foo", "baz"}, nil)
+	c.Assert(err, ErrorMatches, `snap "baz" is not installed`)
+}
+
+func (s *snapshotSuite) TestSaveConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "foo"}})
+	chg.AddTask(t)
+
+	_, _, _, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, ErrorMatches, `snap "foo" has changes in progress`)
+}
+
+func (s *snapshotSuite) TestSaveConflictsWithSnapChanges(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, _, ts, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("save-snapshot", "...")
+	chg.AddAll(ts)
+
+	c.Check(snapstate.CheckChangeConflict(s.state, "foo", nil, nil), ErrorMatches, `snap "foo" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflict(s.state, "bar", nil, nil), IsNil)
+
+	chg.SetStatus(state.DoneStatus)
+	c.Check(snapstate.CheckChangeConflict(s.state, "foo", nil, nil), IsNil)
+}
+
+func (s *snapshotSuite) TestRestoreConflictsWithSnapChanges(c *C) {
+	defer mockIter(client.Snapshot{SetID: 1, Snap: "foo"})()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, ts, err := snapshotstate.Restore(s.state, 1, nil, nil)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("restore-snapshot", "...")
+	chg.AddAll(ts)
+
+	c.Check(snapstate.CheckChangeConflict(s.state, "foo", nil, nil), ErrorMatches, `snap "foo" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflict(s.state, "bar", nil, nil), IsNil)
+}
+
+func (s *snapshotSuite) TestAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapshotstate.AutomaticSnapshot(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(ts, IsNil)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "snapshots.automatic", true), IsNil)
+	tr.Commit()
+
+	ts, err = snapshotstate.AutomaticSnapshot(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Kind(), Equals, "save-snapshot")
+	c.Check(taskSetups(c, ts), DeepEquals, []map[string]interface{}{
+		{"set-id": 1., "snap": "foo"},
+	})
+}
+
+func (s *snapshotSuite) TestAutomaticSnapshotHookedIntoRemove(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "snapshots.automatic", true), IsNil)
+	tr.Commit()
+
+	ts, err := snapstate.AutomaticSnapshot(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(ts.Tasks(), HasLen, 1)
+}
+
+func mockIter(snapshots ...client.Snapshot) func() {
+	return snapshotstate.MockBackendIter(func(f func(*backend.Reader) error) error {
+		for _, snapshot := range snapshots {
+			rsh := &backend.Reader{
+				Snapshot: snapshot,
+				Name:     "/some/" + snapshot.Snap + ".zip",
+			}
+			if err := f(rsh); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *snapshotSuite) TestRestoreTasks(c *C) {
+	defer mockIter(
+		client.Snapshot{SetID: 1, Snap: "foo"},
+		client.Snapshot{SetID: 2, Snap: "foo"},
+		client.Snapshot{SetID: 2, Snap: "bar"},
+	)()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snaps, ts, err := snapshotstate.Restore(s.state, 2, nil, []string{"bob"})
+	c.Assert(err, IsNil)
+	c.Check(snaps, DeepEquals, []string{"bar", "foo"})
+	tasks := ts.Tasks()
+	c.Assert(tasks, HasLen, 3)
+	c.Check(tasks[0].Kind(), Equals, "restore-snapshot")
+	c.Check(tasks[1].Kind(), Equals, "restore-snapshot")
+	c.Check(tasks[2].Kind(), Equals, "cleanup-after-restore")
+	c.Check(tasks[2].WaitTasks(), DeepEquals, tasks[:2])
+	c.Check(taskSetups(c, ts), DeepEquals, []map[string]interface{}{
+		{"set-id": 2., "snap": "bar", "filename": "/some/bar.zip", "users": []interface{}{"bob"}},
+		{"set-id": 2., "snap": "foo", "filename": "/some/foo.zip", "users": []interface{}{"bob"}},
+	})
+}
+
+func (s *snapshotSuite) TestRestoreErrors(c *C) {
+	defer mockIter(
+		client.Snapshot{SetID: 1, Snap: "foo"},
+		client.Snapshot{SetID: 1, Snap: "baz"},
+	)()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, _, err := snapshotstate.Restore(s.state, 2, nil, nil)
+	c.Check(err, ErrorMatches, `snapshot set #2 not found`)
+	_, _, err = snapshotstate.Restore(s.state, 1, []string{"bar"}, nil)
+	c.Check(err, ErrorMatches, `snapshot set #1 has no snapshot of snap "bar"`)
+	_, _, err = snapshotstate.Restore(s.state, 1, nil, nil)
+	c.Check(err, ErrorMatches, `cannot restore snapshot of snap "baz": snap is not installed`)
+}
+
+func (s *snapshotSuite) TestForgetTasks(c *C) {
+	defer mockIter(
+		client.Snapshot{SetID: 1, Snap: "foo"},
+		client.Snapshot{SetID: 1, Snap: "baz"},
+	)()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// snaps need not be installed to forget their snapshots
+	snaps, ts, err := snapshotstate.Forget(s.state, 1, nil)
+	c.Assert(err, IsNil)
+	c.Check(snaps, DeepEquals, []string{"baz", "foo"})
+	c.Check(taskSetups(c, ts), DeepEquals, []map[string]interface{}{
+		{"set-id": 1., "snap": "baz", "filename": "/some/baz.zip"},
+		{"set-id": 1., "snap": "foo", "filename": "/some/foo.zip"},
+	})
+}
+
+func (s *snapshotSuite) TestForgetRestoreConflict(c *C) {
+	defer mockIter(client.Snapshot{SetID: 1, Snap: "foo"})()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, ts, err := snapshotstate.Restore(s.state, 1, nil, nil)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("restore-snapshot", "...")
+	chg.AddAll(ts)
+
+	_, _, err = snapshotstate.Forget(s.state, 1, nil)
+	c.Check(err, ErrorMatches, `cannot operate on snapshot set #1 while change "1" is in progress`)
+
+	chg.SetStatus(state.DoneStatus)
+	_, ts, err = snapshotstate.Forget(s.state, 1, nil)
+	c.Assert(err, IsNil)
+	chg = s.state.NewChange("forget-snapshot", "...")
+	chg.AddAll(ts)
+
+	_, _, err = snapshotstate.Restore(s.state, 1, nil, nil)
+	c.Check(err, ErrorMatches, `cannot operate on snapshot set #1 while change "2" is in progress`)
+}
+
+func (s *snapshotSuite) TestSaveError(c *C) {
+	defer snapshotstate.MockBackendSave(func(uint64, *snap.Info, *json.RawMessage, []string) (*client.Snapshot, error) {
+		return nil, errors.New("bzzt")
+	})()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, _, ts, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("save-snapshot", "...")
+	chg.AddAll(ts)
+
+	s.settle(c, chg)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*bzzt.*`)
+}
+
+func writeData(c *C, snapName, content string) {
+	dir := filepath.Join(dirs.SnapDataDir, snapName, "1")
+	c.Assert(os.MkdirAll(dir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "data"), []byte(content), 0644), IsNil)
+}
+
+func checkData(c *C, snapName, content string) {
+	buf, err := ioutil.ReadFile(filepath.Join(dirs.SnapDataDir, snapName, "1", "data"))
+	c.Assert(err, IsNil)
+	c.Check(string(buf), Equals, content)
+}
+
+func (s *snapshotSuite) TestSaveRestoreForgetRunThrough(c *C) {
+	writeData(c, "foo", "saved")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	savedCfg := json.RawMessage(`{"key":"saved"}`)
+	c.Assert(config.SetSnapConfig(s.state, "foo", &savedCfg), IsNil)
+
+	setID, _, ts, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("save-snapshot", "...")
+	chg.AddAll(ts)
+	s.settle(c, chg)
+	c.Assert(chg.Err(), IsNil)
+
+	sets, err := snapshotstate.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sets, HasLen, 1)
+	c.Check(sets[0].ID, Equals, setID)
+	c.Check(string(*sets[0].Snapshots[0].Conf), Equals, `{"key":"saved"}`)
+
+	// change things, then restore them, and fail
+	writeData(c, "foo", "changed")
+	changedCfg := json.RawMessage(`{"key":"changed"}`)
+	c.Assert(config.SetSnapConfig(s.state, "foo", &changedCfg), IsNil)
+
+	_, ts, err = snapshotstate.Restore(s.state, setID, nil, nil)
+	c.Assert(err, IsNil)
+	chg = s.state.NewChange("restore-snapshot", "...")
+	chg.AddAll(ts)
+	tasks := ts.Tasks()
+	errTask := s.state.NewTask("error-trigger", "...")
+	errTask.WaitFor(tasks[0])
+	tasks[1].WaitFor(errTask)
+	chg.AddTask(errTask)
+	s.settle(c, chg)
+	c.Assert(chg.Err(), NotNil)
+	c.Check(tasks[0].Status(), Equals, state.UndoneStatus)
+
+	checkData(c, "foo", "changed")
+	cfg, err := config.GetSnapConfig(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(string(*cfg), Equals, `{"key":"changed"}`)
+
+	// restore them for real
+	_, ts, err = snapshotstate.Restore(s.state, setID, nil, nil)
+	c.Assert(err, IsNil)
+	chg = s.state.NewChange("restore-snapshot", "...")
+	chg.AddAll(ts)
+	s.settle(c, chg)
+	c.Assert(chg.Err(), IsNil)
+
+	checkData(c, "foo", "saved")
+	cfg, err = config.GetSnapConfig(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(string(*cfg), Equals, `{"key":"saved"}`)
+	// the moved aside data is cleaned up
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapDataDir, "foo", "1.*"))
+	c.Assert(err, IsNil)
+	c.Check(matches, HasLen, 0)
+
+	// and forget them
+	_, ts, err = snapshotstate.Forget(s.state, setID, nil)
+	c.Assert(err, IsNil)
+	chg = s.state.NewChange("forget-snapshot", "...")
+	chg.AddAll(ts)
+	s.settle(c, chg)
+	c.Assert(chg.Err(), IsNil)
+
+	sets, err = snapshotstate.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+}
@@ -54,6 +54,11 @@ type managerBackend interface {
 	StartServices(svcs []*snap.AppInfo, meter progress.Meter) error
 	StopServices(svcs []*snap.AppInfo, meter progress.Meter) error
 
+	// service control related
+	RestartServices(svcs []*snap.AppInfo, meter progress.Meter) error
+	EnableServices(svcs []*snap.AppInfo, meter progress.Meter) error
+	DisableServices(svcs []*snap.AppInfo, meter progress.Meter) error
+
 	// the undoers for install
 	UndoSetupSnap(s snap.PlaceInfo, typ snap.Type, meter progress.Meter) error
 	UndoCopySnapData(newSnap, oldSnap *snap.Info, meter progress.Meter) error
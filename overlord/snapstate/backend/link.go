@@ -82,6 +82,18 @@ func (b Backend) StopServices(apps []*snap.AppInfo, meter progress.Meter) error
 	return wrappers.StopServices(apps, meter)
 }
 
+func (b Backend) RestartServices(apps []*snap.AppInfo, meter progress.Meter) error {
+	return wrappers.RestartServices(apps, meter)
+}
+
+func (b Backend) EnableServices(apps []*snap.AppInfo, meter progress.Meter) error {
+	return wrappers.EnableServices(apps, meter)
+}
+
+func (b Backend) DisableServices(apps []*snap.AppInfo, meter progress.Meter) error {
+	return wrappers.DisableServices(apps, meter)
+}
+
 func generateWrappers(s *snap.Info) error {
 	// add the CLI apps from the snap.yaml
 	if err := wrappers.AddSnapBinaries(s); err != nil {
@@ -51,6 +51,8 @@ type fakeOp struct {
 
 	aliases   []*backend.Alias
 	rmAliases []*backend.Alias
+
+	services []string
 }
 
 type fakeOps []fakeOp
@@ -123,8 +125,14 @@ func (f *fakeStore) SnapInfo(spec store.SnapSpec, user *auth.UserState) (*snap.I
 	}
 
 	typ := snap.TypeApp
-	if spec.Name == "some-core" {
+	var base string
+	switch {
+	case spec.Name == "some-core":
 		typ = snap.TypeOS
+	case spec.Name == "some-base":
+		typ = snap.TypeBase
+	case strings.HasSuffix(spec.Name, "-with-base"):
+		base = "some-base"
 	}
 
 	info := &snap.Info{
@@ -141,6 +149,7 @@ func (f *fakeStore) SnapInfo(spec store.SnapSpec, user *auth.UserState) (*snap.I
 		},
 		Confinement: confinement,
 		Type:        typ,
+		Base:        base,
 	}
 	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-snap", name: spec.Name, revno: spec.Revision})
 
@@ -187,6 +196,10 @@ func (f *fakeStore) LookupRefresh(cand *store.RefreshCandidate, user *auth.UserS
 	case "channel-for-devmode":
 		confinement = snap.DevModeConfinement
 	}
+	var epoch snap.Epoch
+	if cand.Channel == "channel-for-epoch-2" {
+		epoch = snap.E("2")
+	}
 
 	info := &snap.Info{
 		SideInfo: snap.SideInfo{
@@ -201,6 +214,7 @@ func (f *fakeStore) LookupRefresh(cand *store.RefreshCandidate, user *auth.UserS
 		},
 		Confinement:   confinement,
 		Architectures: []string{"all"},
+		Epoch:         epoch,
 	}
 
 	var hit snap.Revision
@@ -336,11 +350,16 @@ func (f *fakeSnappyBackend) ReadInfo(name string, si *snap.SideInfo) (*snap.Info
 	if strings.Contains(name, "alias-snap") {
 		name = "alias-snap"
 	}
+	if strings.HasSuffix(name, "-with-base") {
+		info.Base = "some-base"
+	}
 	switch name {
 	case "gadget":
 		info.Type = snap.TypeGadget
 	case "core":
 		info.Type = snap.TypeOS
+	case "some-base":
+		info.Type = snap.TypeBase
 	case "services-snap":
 		var err error
 		info, err = snap.InfoFromSnapYaml([]byte(`name: services-snap
@@ -349,6 +368,18 @@ apps:
     daemon: simple
   svc2:
     daemon: simple
+`))
+		if err != nil {
+			panic(err)
+		}
+		info.SideInfo = *si
+	case "endure-snap":
+		var err error
+		info, err = snap.InfoFromSnapYaml([]byte(`name: endure-snap
+apps:
+  svc:
+    daemon: simple
+    refresh-mode: endure
 `))
 		if err != nil {
 			panic(err)
@@ -455,6 +486,41 @@ func (f *fakeSnappyBackend) StopServices(svcs []*snap.AppInfo, meter progress.Me
 	return nil
 }
 
+func svcNames(svcs []*snap.AppInfo) []string {
+	names := make([]string, len(svcs))
+	for i, svc := range svcs {
+		names[i] = svc.Name
+	}
+	return names
+}
+
+func (f *fakeSnappyBackend) RestartServices(svcs []*snap.AppInfo, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:       "restart-snap-services",
+		name:     svcSnapMountDir(svcs),
+		services: svcNames(svcs),
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) EnableServices(svcs []*snap.AppInfo, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:       "enable-snap-services",
+		name:     svcSnapMountDir(svcs),
+		services: svcNames(svcs),
+	})
+	return nil
+}
+
+func (f *fakeSnappyBackend) DisableServices(svcs []*snap.AppInfo, meter progress.Meter) error {
+	f.ops = append(f.ops, fakeOp{
+		op:       "disable-snap-services",
+		name:     svcSnapMountDir(svcs),
+		services: svcNames(svcs),
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) UndoSetupSnap(s snap.PlaceInfo, typ snap.Type, p progress.Meter) error {
 	p.Notify("setup-snap")
 	f.ops = append(f.ops, fakeOp{
@@ -180,6 +180,16 @@ func validateInfoAndFlags(info *snap.Info, snapst *SnapState, flags Flags) error
 	return nil
 }
 
+// checkEpoch checks that the revision described by info can read the
+// data written by the current revision of the snap (curInfo), before
+// refreshing or reverting to it (op).
+func checkEpoch(op string, info, curInfo *snap.Info) error {
+	if !info.Epoch.CanRead(curInfo.Epoch) {
+		return fmt.Errorf("cannot %s %q to revision %s: its epoch %s cannot read the data of the current epoch %s", op, info.Name(), info.Revision, info.Epoch, curInfo.Epoch)
+	}
+	return nil
+}
+
 var openSnapFile = backend.OpenSnapFile
 
 // checkSnap ensures that the snap can be installed.
@@ -195,6 +205,14 @@ func checkSnap(st *state.State, snapFilePath string, si *snap.SideInfo, curInfo
 		return err
 	}
 
+	// refreshing from a local file skips the epoch check done
+	// when looking up the refresh in the store
+	if curInfo != nil {
+		if err := checkEpoch("refresh", s, curInfo); err != nil {
+			return err
+		}
+	}
+
 	st.Lock()
 	defer st.Unlock()
 
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// cachePolicySetter is implemented by stores that can cache downloads.
+type cachePolicySetter interface {
+	SetCachePolicy(policy store.CachePolicy) error
+}
+
+// downloadCachePolicy returns the policy of the download cache, as set
+// by the download-cache.max-size core option, either a number of bytes
+// or a size like "2GB", and the download-cache.max-age one, a duration
+// like "720h". Downloads are not cached unless download-cache.max-size
+// is set.
+func downloadCachePolicy(st *state.State) store.CachePolicy {
+	policy := store.DefaultCachePolicy
+	tr := config.NewTransaction(st)
+
+	var maxSize interface{}
+	err := tr.Get("core", "download-cache.max-size", &maxSize)
+	if err == nil {
+		switch v := maxSize.(type) {
+		case float64:
+			if v < 0 {
+				err = fmt.Errorf("size cannot be negative")
+			} else {
+				policy.MaxSize = int64(v)
+			}
+		case string:
+			var size int64
+			size, err = strutil.ParseByteSize(v)
+			if err == nil {
+				policy.MaxSize = size
+			}
+		default:
+			err = fmt.Errorf("invalid size %v", v)
+		}
+	}
+	if err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot use download-cache.max-size configuration: %v", err)
+	}
+
+	var maxAge string
+	err = tr.Get("core", "download-cache.max-age", &maxAge)
+	if err == nil {
+		var age time.Duration
+		age, err = time.ParseDuration(maxAge)
+		if err == nil && age < 0 {
+			err = fmt.Errorf("duration cannot be negative")
+		}
+		if err == nil {
+			policy.MaxAge = age
+		}
+	}
+	if err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot use download-cache.max-age configuration: %v", err)
+	}
+
+	return policy
+}
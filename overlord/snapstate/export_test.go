@@ -89,6 +89,12 @@ func MockOpenSnapFile(mock func(path string, si *snap.SideInfo) (*snap.Info, sna
 	return func() { openSnapFile = prevOpenSnapFile }
 }
 
+func MockMaxConcurrentDownloads(n int) (restore func()) {
+	old := maxConcurrentDownloads
+	maxConcurrentDownloads = n
+	return func() { maxConcurrentDownloads = old }
+}
+
 func MockErrtrackerReport(mock func(string, string, string, map[string]string) (string, error)) (restore func()) {
 	prev := errtrackerReport
 	errtrackerReport = mock
@@ -102,6 +108,9 @@ var (
 	CachedStore            = cachedStore
 	DefaultRefreshSchedule = defaultRefreshSchedule
 	NameAndRevnoFromSnap   = nameAndRevnoFromSnap
+	DownloadCachePolicy    = downloadCachePolicy
+	RefreshRateLimit       = refreshRateLimit
+	TooManyDownloads       = tooManyDownloads
 )
 
 func PreviousSideInfo(snapst *SnapState) *snap.SideInfo {
@@ -36,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
@@ -225,11 +226,23 @@ func (m *SnapManager) doDownloadSnap(t *state.Task, tomb *tomb.Tomb) error {
 	st.Lock()
 	theStore := Store(st)
 	user, err := userFromUserID(st, snapsup.UserID)
+	cachePolicy := downloadCachePolicy(st)
+	rateLimit := refreshRateLimit(st)
 	st.Unlock()
 	if err != nil {
 		return err
 	}
 
+	if limiter, ok := theStore.(rateLimitSetter); ok {
+		limiter.SetDownloadRateLimit(rateLimit)
+	}
+
+	if cacher, ok := theStore.(cachePolicySetter); ok {
+		if err := cacher.SetCachePolicy(cachePolicy); err != nil {
+			logger.Noticef("Cannot apply the download cache policy: %v", err)
+		}
+	}
+
 	meter := NewTaskProgressAdapterUnlocked(t)
 	targetFn := snapsup.MountFile()
 	if snapsup.DownloadInfo == nil {
@@ -385,6 +398,11 @@ func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	snapst.Active = true
 	err = m.backend.LinkSnap(oldInfo)
+	if err == nil {
+		// the services of the old revision go back in the slice
+		// of the quota group of the snap, if any
+		err = EnsureSnapQuotaGroup(st, oldInfo)
+	}
 	if err != nil {
 		return err
 	}
@@ -563,6 +581,9 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	// XXX: this block is slightly ugly, find a pattern when we have more examples
 	err = m.backend.LinkSnap(newInfo)
+	if err == nil {
+		err = EnsureSnapQuotaGroup(st, newInfo)
+	}
 	if err != nil {
 		pb := NewTaskProgressAdapterLocked(t)
 		err := m.backend.UnlinkSnap(newInfo, pb)
@@ -670,6 +691,9 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 		if err := m.removeSnapCookie(st, snapsup.Name()); err != nil {
 			return fmt.Errorf("cannot remove snap context: %v", err)
 		}
+		if err := RemoveSnapFromQuotaGroup(st, snapsup.Name()); err != nil {
+			return err
+		}
 	}
 
 	isRevert := snapsup.Revert
@@ -767,6 +791,27 @@ func (m *SnapManager) startSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	return err
 }
 
+// stopReasonRefresh is the reason recorded on "stop-snap-services"
+// tasks stopping the services of a snap that is being refreshed.
+const stopReasonRefresh = "refresh"
+
+// servicesToStop returns the services of the snap to stop for the given
+// reason: services that endure refreshes are left running across them.
+func servicesToStop(info *snap.Info, stopReason string) []*snap.AppInfo {
+	svcs := info.Services()
+	if stopReason != stopReasonRefresh {
+		return svcs
+	}
+	toStop := make([]*snap.AppInfo, 0, len(svcs))
+	for _, app := range svcs {
+		if app.RefreshMode == "endure" {
+			continue
+		}
+		toStop = append(toStop, app)
+	}
+	return toStop
+}
+
 func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -781,7 +826,11 @@ func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
-	svcs := currentInfo.Services()
+	var stopReason string
+	if err := t.Get("stop-reason", &stopReason); err != nil && err != state.ErrNoState {
+		return err
+	}
+	svcs := servicesToStop(currentInfo, stopReason)
 	if len(svcs) == 0 {
 		return nil
 	}
@@ -793,6 +842,58 @@ func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	return err
 }
 
+func (m *SnapManager) doServiceControl(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var action ServiceAction
+	if err := t.Get("service-action", &action); err != nil {
+		return err
+	}
+	if err := action.validate(); err != nil {
+		return err
+	}
+
+	info, err := CurrentInfo(st, action.SnapName)
+	if err != nil {
+		return err
+	}
+	svcs, err := action.serviceApps(info)
+	if err != nil {
+		return err
+	}
+
+	pb := NewTaskProgressAdapterUnlocked(t)
+	st.Unlock()
+	err = m.controlServices(&action, svcs, pb)
+	st.Lock()
+	return err
+}
+
+func (m *SnapManager) controlServices(action *ServiceAction, svcs []*snap.AppInfo, meter progress.Meter) error {
+	switch action.Action {
+	case "start":
+		if action.Enable {
+			if err := m.backend.EnableServices(svcs, meter); err != nil {
+				return err
+			}
+		}
+		return m.backend.StartServices(svcs, meter)
+	case "stop":
+		if err := m.backend.StopServices(svcs, meter); err != nil {
+			return err
+		}
+		if action.Disable {
+			return m.backend.DisableServices(svcs, meter)
+		}
+		return nil
+	case "restart":
+		return m.backend.RestartServices(svcs, meter)
+	}
+	return fmt.Errorf("internal error: unknown service action %q", action.Action)
+}
+
 func (m *SnapManager) doUnlinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	// invoked only if snap has a current active revision
 	st := t.State()
@@ -907,6 +1008,9 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 		if err := m.removeSnapCookie(st, snapsup.Name()); err != nil {
 			return fmt.Errorf("cannot remove snap context: %v", err)
 		}
+		if err := RemoveSnapFromQuotaGroup(st, snapsup.Name()); err != nil {
+			return err
+		}
 	}
 	if err = config.DiscardRevisionConfig(st, snapsup.Name(), snapsup.Revision()); err != nil {
 		return err
@@ -20,11 +20,15 @@
 package snapstate_test
 
 import (
+	"time"
+
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
 )
 
 type downloadSnapSuite struct {
@@ -157,6 +161,176 @@ func (s *downloadSnapSuite) TestDoDownloadSnapNormal(c *C) {
 	c.Check(t.Status(), Equals, state.DoneStatus)
 }
 
+// cachingStore is a fakeStore that can cache downloads
+type cachingStore struct {
+	*fakeStore
+	policies   []store.CachePolicy
+	rateLimits []int64
+}
+
+func (cs *cachingStore) SetDownloadRateLimit(bytesPerSecond int64) {
+	cs.rateLimits = append(cs.rateLimits, bytesPerSecond)
+}
+
+func (cs *cachingStore) SetCachePolicy(policy store.CachePolicy) error {
+	cs.policies = append(cs.policies, policy)
+	return nil
+}
+
+func (s *downloadSnapSuite) TestDoDownloadSnapSetsCachePolicyAndRateLimit(c *C) {
+	s.state.Lock()
+	cs := &cachingStore{fakeStore: s.fakeStore}
+	snapstate.ReplaceStore(s.state, cs)
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "download-cache.max-size", "2GB"), IsNil)
+	c.Assert(tr.Set("core", "refresh.rate-limit", "1MB"), IsNil)
+	tr.Commit()
+
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	s.state.NewChange("dummy", "...").AddTask(t)
+
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	c.Check(cs.policies, DeepEquals, []store.CachePolicy{{
+		MaxSize: 2000000000,
+		MaxAge:  store.DefaultCachePolicy.MaxAge,
+	}})
+	c.Check(cs.rateLimits, DeepEquals, []int64{1000000})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+}
+
+func (s *downloadSnapSuite) TestDownloadCachePolicy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(snapstate.DownloadCachePolicy(s.state), Equals, store.DefaultCachePolicy)
+
+	for _, t := range []struct {
+		maxSize interface{}
+		maxAge  interface{}
+		policy  store.CachePolicy
+	}{
+		{"512MB", "24h", store.CachePolicy{MaxSize: 512000000, MaxAge: 24 * time.Hour}},
+		{1000, "0s", store.CachePolicy{MaxSize: 1000}},
+		{0, "", store.CachePolicy{MaxAge: store.DefaultCachePolicy.MaxAge}},
+		// bogus values are ignored
+		{"lots", "forever", store.DefaultCachePolicy},
+		{-1, "-1h", store.DefaultCachePolicy},
+		{true, 42, store.DefaultCachePolicy},
+	} {
+		tr := config.NewTransaction(s.state)
+		c.Assert(tr.Set("core", "download-cache.max-size", t.maxSize), IsNil)
+		c.Assert(tr.Set("core", "download-cache.max-age", t.maxAge), IsNil)
+		tr.Commit()
+
+		c.Check(snapstate.DownloadCachePolicy(s.state), Equals, t.policy, Commentf("%v %v", t.maxSize, t.maxAge))
+	}
+}
+
+func (s *downloadSnapSuite) TestRefreshRateLimit(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(snapstate.RefreshRateLimit(s.state), Equals, int64(0))
+
+	for _, t := range []struct {
+		rateLimit interface{}
+		rate      int64
+	}{
+		{"512kB", 512000},
+		{"2MB", 2000000},
+		{4096, 4096},
+		{0, 0},
+		{"", 0},
+		// bogus values are ignored
+		{"fast", 0},
+		{-1, 0},
+		{true, 0},
+	} {
+		tr := config.NewTransaction(s.state)
+		c.Assert(tr.Set("core", "refresh.rate-limit", t.rateLimit), IsNil)
+		tr.Commit()
+
+		c.Check(snapstate.RefreshRateLimit(s.state), Equals, t.rate, Commentf("%v", t.rateLimit))
+	}
+}
+
+func (s *downloadSnapSuite) TestTooManyDownloads(c *C) {
+	restore := snapstate.MockMaxConcurrentDownloads(2)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	newTask := func(kind string, status state.Status) *state.Task {
+		t := s.state.NewTask(kind, "test")
+		t.SetStatus(status)
+		return t
+	}
+
+	cand := newTask("download-snap", state.DoStatus)
+	download1 := newTask("download-snap", state.DoingStatus)
+	download2 := newTask("download-snap", state.DoingStatus)
+	undoing := newTask("download-snap", state.UndoingStatus)
+	other := newTask("mount-snap", state.DoingStatus)
+
+	c.Check(snapstate.TooManyDownloads(cand, nil), Equals, false)
+	c.Check(snapstate.TooManyDownloads(cand, []*state.Task{download1, undoing, other}), Equals, false)
+	c.Check(snapstate.TooManyDownloads(cand, []*state.Task{download1, download2}), Equals, true)
+
+	// only downloads yet to be done wait
+	c.Check(snapstate.TooManyDownloads(undoing, []*state.Task{download1, download2}), Equals, false)
+	c.Check(snapstate.TooManyDownloads(other, []*state.Task{download1, download2}), Equals, false)
+}
+
+func (s *downloadSnapSuite) TestDownloadsRunConcurrently(c *C) {
+	restore := snapstate.MockMaxConcurrentDownloads(2)
+	defer restore()
+
+	s.state.Lock()
+	chg := s.state.NewChange("dummy", "...")
+	for _, name := range []string{"foo", "bar", "baz"} {
+		t := s.state.NewTask("download-snap", "test")
+		t.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{
+				RealName: name,
+				Revision: snap.R(11),
+			},
+			DownloadInfo: &snap.DownloadInfo{
+				DownloadURL: "http://some-url.com/" + name,
+			},
+		})
+		chg.AddTask(t)
+	}
+	s.state.Unlock()
+
+	for i := 0; i < 3; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.fakeBackend.ops.Ops(), HasLen, 3)
+}
+
 func (s *downloadSnapSuite) TestDoUndoDownloadSnap(c *C) {
 	s.state.Lock()
 	si := &snap.SideInfo{
@@ -0,0 +1,231 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type serviceControlSuite struct {
+	state   *state.State
+	snapmgr *snapstate.SnapManager
+
+	fakeBackend *fakeSnappyBackend
+
+	reset func()
+}
+
+var _ = Suite(&serviceControlSuite{})
+
+func (s *serviceControlSuite) SetUpTest(c *C) {
+	s.fakeBackend = &fakeSnappyBackend{}
+	s.state = state.New(nil)
+
+	var err error
+	s.snapmgr, err = snapstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.snapmgr.AddForeignTaskHandlers(s.fakeBackend)
+
+	snapstate.SetSnapManagerBackend(s.snapmgr, s.fakeBackend)
+
+	s.reset = snapstate.MockReadInfo(s.fakeBackend.ReadInfo)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "services-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+}
+
+func (s *serviceControlSuite) TearDownTest(c *C) {
+	s.reset()
+}
+
+func (s *serviceControlSuite) runServiceControl(c *C, action *snapstate.ServiceAction) *state.Task {
+	s.state.Lock()
+	t, err := snapstate.ServiceControl(s.state, action)
+	c.Assert(err, IsNil)
+	s.state.NewChange("dummy", "...").AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	return t
+}
+
+func (s *serviceControlSuite) TestServiceControlTask(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	t, err := snapstate.ServiceControl(s.state, &snapstate.ServiceAction{
+		SnapName: "services-snap",
+		Action:   "restart",
+		Services: []string{"svc2", "svc1"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(t.Kind(), Equals, "service-control")
+	c.Check(t.Summary(), Equals, `Restart services svc1, svc2 of snap "services-snap"`)
+
+	var action snapstate.ServiceAction
+	c.Assert(t.Get("service-action", &action), IsNil)
+	c.Check(action, DeepEquals, snapstate.ServiceAction{
+		SnapName: "services-snap",
+		Action:   "restart",
+		Services: []string{"svc2", "svc1"},
+	})
+}
+
+func (s *serviceControlSuite) TestServiceControlErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		action *snapstate.ServiceAction
+		err    string
+	}{
+		{&snapstate.ServiceAction{SnapName: "services-snap", Action: "frobnicate", Services: []string{"svc1"}}, `unknown service action "frobnicate"`},
+		{&snapstate.ServiceAction{SnapName: "services-snap", Action: "start"}, `no services to start for snap "services-snap"`},
+		{&snapstate.ServiceAction{SnapName: "services-snap", Action: "start", Disable: true, Services: []string{"svc1"}}, `cannot disable services when starting them`},
+		{&snapstate.ServiceAction{SnapName: "services-snap", Action: "stop", Enable: true, Services: []string{"svc1"}}, `cannot enable services when stopping them`},
+		{&snapstate.ServiceAction{SnapName: "services-snap", Action: "restart", Enable: true, Services: []string{"svc1"}}, `cannot enable or disable services when restarting them`},
+		{&snapstate.ServiceAction{SnapName: "services-snap", Action: "start", Services: []string{"svc3"}}, `snap "services-snap" has no service "svc3"`},
+		{&snapstate.ServiceAction{SnapName: "other-snap", Action: "start", Services: []string{"svc1"}}, `cannot find snap "other-snap"`},
+	} {
+		_, err := snapstate.ServiceControl(s.state, t.action)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *serviceControlSuite) TestDoServiceControl(c *C) {
+	mountDir := filepath.Join(dirs.SnapMountDir, "services-snap/11")
+
+	for _, t := range []struct {
+		action *snapstate.ServiceAction
+		ops    fakeOps
+	}{
+		{
+			&snapstate.ServiceAction{SnapName: "services-snap", Action: "start", Services: []string{"svc1"}},
+			fakeOps{
+				{op: "start-snap-services", name: mountDir},
+			},
+		}, {
+			&snapstate.ServiceAction{SnapName: "services-snap", Action: "start", Enable: true, Services: []string{"svc1"}},
+			fakeOps{
+				{op: "enable-snap-services", name: mountDir, services: []string{"svc1"}},
+				{op: "start-snap-services", name: mountDir},
+			},
+		}, {
+			&snapstate.ServiceAction{SnapName: "services-snap", Action: "stop", Services: []string{"svc1", "svc2"}},
+			fakeOps{
+				{op: "stop-snap-services", name: mountDir},
+			},
+		}, {
+			&snapstate.ServiceAction{SnapName: "services-snap", Action: "stop", Disable: true, Services: []string{"svc2"}},
+			fakeOps{
+				{op: "stop-snap-services", name: mountDir},
+				{op: "disable-snap-services", name: mountDir, services: []string{"svc2"}},
+			},
+		}, {
+			&snapstate.ServiceAction{SnapName: "services-snap", Action: "restart", Services: []string{"svc2", "svc1"}},
+			fakeOps{
+				{op: "restart-snap-services", name: mountDir, services: []string{"svc2", "svc1"}},
+			},
+		},
+	} {
+		s.fakeBackend.ops = nil
+
+		task := s.runServiceControl(c, t.action)
+
+		s.state.Lock()
+		c.Check(task.Status(), Equals, state.DoneStatus)
+		s.state.Unlock()
+		c.Check(s.fakeBackend.ops, DeepEquals, t.ops)
+	}
+}
+
+func (s *serviceControlSuite) TestDoServiceControlSnapGone(c *C) {
+	s.state.Lock()
+	t, err := snapstate.ServiceControl(s.state, &snapstate.ServiceAction{
+		SnapName: "services-snap",
+		Action:   "start",
+		Services: []string{"svc1"},
+	})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+	snapstate.Set(s.state, "services-snap", nil)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot find snap "services-snap".*`)
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+}
+
+func (s *serviceControlSuite) TestStopSnapServicesOnRefreshSkipsEnduringServices(c *C) {
+	s.state.Lock()
+	snapstate.Set(s.state, "endure-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "endure-snap", Revision: snap.R(3)},
+		},
+		Current: snap.R(3),
+	})
+	chg := s.state.NewChange("dummy", "...")
+	for _, reason := range []string{"refresh", ""} {
+		t := s.state.NewTask("stop-snap-services", "...")
+		t.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo: &snap.SideInfo{RealName: "endure-snap", Revision: snap.R(3)},
+		})
+		if reason != "" {
+			t.Set("stop-reason", reason)
+		}
+		chg.AddTask(t)
+
+		s.state.Unlock()
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+		s.state.Lock()
+
+		c.Assert(t.Status(), Equals, state.DoneStatus)
+	}
+	s.state.Unlock()
+
+	// only the stop without a reason reached the backend
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{op: "stop-snap-services", name: filepath.Join(dirs.SnapMountDir, "endure-snap/3")},
+	})
+}
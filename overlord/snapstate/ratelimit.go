@@ -0,0 +1,86 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// maxConcurrentDownloads is how many download-snap tasks can run at
+// once, across all changes.
+var maxConcurrentDownloads = 3
+
+// rateLimitSetter is implemented by stores that can limit the rate of
+// downloads.
+type rateLimitSetter interface {
+	SetDownloadRateLimit(bytesPerSecond int64)
+}
+
+// refreshRateLimit returns the overall limit of the download rate in
+// bytes per second, as set by the refresh.rate-limit core option,
+// either a number of bytes or a size like "2MB"; zero means no limit.
+func refreshRateLimit(st *state.State) int64 {
+	var rateLimit interface{}
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "refresh.rate-limit", &rateLimit)
+	if config.IsNoOption(err) {
+		return 0
+	}
+
+	var rate int64
+	if err == nil {
+		switch v := rateLimit.(type) {
+		case float64:
+			rate = int64(v)
+		case string:
+			rate, err = strutil.ParseByteSize(v)
+		default:
+			err = fmt.Errorf("invalid rate %v", v)
+		}
+	}
+	if err == nil && rate < 0 {
+		err = fmt.Errorf("rate cannot be negative")
+	}
+	if err != nil {
+		logger.Noticef("cannot use refresh.rate-limit configuration: %v", err)
+		return 0
+	}
+	return rate
+}
+
+// tooManyDownloads returns whether the candidate task is a download
+// that has to wait for some of the running ones to finish.
+func tooManyDownloads(cand *state.Task, running []*state.Task) bool {
+	if cand.Kind() != "download-snap" || cand.Status() != state.DoStatus {
+		return false
+	}
+	downloads := 0
+	for _, t := range running {
+		if t.Kind() == "download-snap" && t.Status() == state.DoingStatus {
+			downloads++
+		}
+	}
+	return downloads >= maxConcurrentDownloads
+}
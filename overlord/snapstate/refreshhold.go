@@ -0,0 +1,163 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// maxRefreshHold is how long after the last auto-refresh refreshes can
+// be held for at most, be it via the refresh.hold core option or for
+// specific snaps.
+const maxRefreshHold = 60 * 24 * time.Hour
+
+// refreshHoldLimit returns the time beyond which refreshes cannot be
+// held, given the time of the last auto-refresh (if any).
+func refreshHoldLimit(st *state.State) (time.Time, error) {
+	var lastRefresh time.Time
+	err := st.Get("last-refresh", &lastRefresh)
+	if err != nil && err != state.ErrNoState {
+		return time.Time{}, err
+	}
+	if lastRefresh.IsZero() {
+		lastRefresh = time.Now()
+	}
+	return lastRefresh.Add(maxRefreshHold), nil
+}
+
+// EffectiveRefreshHold returns the time until which all auto-refreshes
+// are held by the refresh.hold core option, capped to the maximum
+// hold. It returns the zero time if auto-refreshes are not held.
+// Note that the state must be locked by the caller.
+func EffectiveRefreshHold(st *state.State) (time.Time, error) {
+	var holdStr string
+	tr := config.NewTransaction(st)
+	err := tr.Get("core", "refresh.hold", &holdStr)
+	if err != nil && !config.IsNoOption(err) {
+		return time.Time{}, err
+	}
+	if holdStr == "" {
+		return time.Time{}, nil
+	}
+
+	hold, err := time.Parse(time.RFC3339, holdStr)
+	if err != nil {
+		logger.Noticef("cannot use refresh.hold configuration: %s", err)
+		return time.Time{}, nil
+	}
+
+	limit, err := refreshHoldLimit(st)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if hold.After(limit) {
+		hold = limit
+	}
+	if !hold.After(time.Now()) {
+		return time.Time{}, nil
+	}
+
+	return hold, nil
+}
+
+// RefreshHolds returns the snaps whose auto-refreshes are currently
+// held, with the time until which they are held.
+// Note that the state must be locked by the caller.
+func RefreshHolds(st *state.State) (map[string]time.Time, error) {
+	var holds map[string]time.Time
+	err := st.Get("refresh-holds", &holds)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := make(map[string]time.Time, len(holds))
+	for name, until := range holds {
+		if until.After(now) {
+			active[name] = until
+		}
+	}
+
+	return active, nil
+}
+
+// HoldRefresh holds the auto-refreshes of the given snaps, or of all
+// snaps via the refresh.hold core option if none is given, for the
+// given duration. A zero duration releases the holds. Manual refreshes
+// are not affected.
+// Note that the state must be locked by the caller.
+func HoldRefresh(st *state.State, names []string, duration time.Duration) error {
+	if duration < 0 {
+		return fmt.Errorf("cannot hold refreshes for a negative duration")
+	}
+
+	for _, name := range names {
+		var snapst SnapState
+		if err := Get(st, name, &snapst); err != nil && err != state.ErrNoState {
+			return err
+		}
+		if !snapst.HasCurrent() {
+			return fmt.Errorf("cannot hold refreshes of snap %q: snap is not installed", name)
+		}
+	}
+
+	until := time.Now().Add(duration)
+	limit, err := refreshHoldLimit(st)
+	if err != nil {
+		return err
+	}
+	if duration > 0 && until.After(limit) {
+		return fmt.Errorf("cannot hold refreshes beyond %s (%s after the last auto-refresh)", limit.Truncate(time.Minute), maxRefreshHold)
+	}
+
+	if len(names) == 0 {
+		holdStr := ""
+		if duration > 0 {
+			holdStr = until.Format(time.RFC3339)
+		}
+		tr := config.NewTransaction(st)
+		if err := tr.Set("core", "refresh.hold", holdStr); err != nil {
+			return err
+		}
+		tr.Commit()
+		return nil
+	}
+
+	// only the holds that are still active are kept
+	holds, err := RefreshHolds(st)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if duration == 0 {
+			delete(holds, name)
+		} else {
+			holds[name] = until
+		}
+	}
+	st.Set("refresh-holds", holds)
+
+	return nil
+}
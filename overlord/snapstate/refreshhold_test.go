@@ -0,0 +1,178 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) setRefreshHold(c *C, hold time.Time) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.hold", hold.Format(time.RFC3339)), IsNil)
+	tr.Commit()
+}
+
+func (s *snapmgrTestSuite) setSomeSnap() {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+}
+
+func (s *snapmgrTestSuite) TestEffectiveRefreshHold(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	hold, err := snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.IsZero(), Equals, true)
+
+	// a hold in the past is no hold
+	s.setRefreshHold(c, time.Now().Add(-time.Hour))
+	hold, err = snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.IsZero(), Equals, true)
+
+	until := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	s.setRefreshHold(c, until)
+	hold, err = snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.Equal(until), Equals, true)
+
+	// holds are capped to 60 days after the last refresh
+	lastRefresh := time.Now().Add(-59 * 24 * time.Hour).Truncate(time.Second)
+	s.state.Set("last-refresh", lastRefresh)
+	hold, err = snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.Equal(lastRefresh.Add(60*24*time.Hour)), Equals, true)
+
+	s.state.Set("last-refresh", time.Now().Add(-61*24*time.Hour))
+	hold, err = snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.IsZero(), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestEffectiveRefreshHoldInvalid(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.hold", "tomorrow"), IsNil)
+	tr.Commit()
+
+	hold, err := snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.IsZero(), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestEnsureRefreshesHeld(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+
+	s.setSomeSnap()
+	s.setRefreshHold(c, time.Now().Add(time.Hour))
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.state.Lock()
+
+	// no auto-refresh, and the last refresh time is left alone
+	c.Check(s.state.Changes(), HasLen, 0)
+	var lastRefresh time.Time
+	c.Check(s.state.Get("last-refresh", &lastRefresh), Equals, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestHoldRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnap()
+
+	c.Assert(snapstate.HoldRefresh(s.state, []string{"some-snap"}, 24*time.Hour), IsNil)
+	holds, err := snapstate.RefreshHolds(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(holds, HasLen, 1)
+	c.Check(holds["some-snap"].After(time.Now().Add(23*time.Hour)), Equals, true)
+
+	c.Assert(snapstate.HoldRefresh(s.state, []string{"some-snap"}, 0), IsNil)
+	holds, err = snapstate.RefreshHolds(s.state)
+	c.Assert(err, IsNil)
+	c.Check(holds, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestHoldRefreshAll(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(snapstate.HoldRefresh(s.state, nil, 24*time.Hour), IsNil)
+	hold, err := snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.After(time.Now().Add(23*time.Hour)), Equals, true)
+
+	c.Assert(snapstate.HoldRefresh(s.state, nil, 0), IsNil)
+	hold, err = snapstate.EffectiveRefreshHold(s.state)
+	c.Assert(err, IsNil)
+	c.Check(hold.IsZero(), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestHoldRefreshErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnap()
+
+	err := snapstate.HoldRefresh(s.state, []string{"some-snap"}, -time.Hour)
+	c.Check(err, ErrorMatches, `cannot hold refreshes for a negative duration`)
+	err = snapstate.HoldRefresh(s.state, []string{"some-snap", "other-snap"}, time.Hour)
+	c.Check(err, ErrorMatches, `cannot hold refreshes of snap "other-snap": snap is not installed`)
+	err = snapstate.HoldRefresh(s.state, []string{"some-snap"}, 61*24*time.Hour)
+	c.Check(err, ErrorMatches, `cannot hold refreshes beyond .* \(1440h0m0s after the last auto-refresh\)`)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshSkipsHeldSnaps(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnap()
+	c.Assert(snapstate.HoldRefresh(s.state, []string{"some-snap"}, time.Hour), IsNil)
+
+	updated, tss, err := snapstate.AutoRefresh(s.state)
+	c.Assert(err, IsNil)
+	c.Check(updated, HasLen, 0)
+	c.Check(tss, HasLen, 0)
+
+	// manual refreshes still work
+	updated, tss, err = snapstate.UpdateMany(s.state, nil, 0)
+	c.Assert(err, IsNil)
+	c.Check(updated, DeepEquals, []string{"some-snap"})
+	c.Check(tss, HasLen, 1)
+}
@@ -0,0 +1,60 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// the number of revisions of a snap, including the current one, kept
+// around after a refresh, and the bounds of the refresh.retain option
+const (
+	defaultRefreshRetain = 3
+	minRefreshRetain     = 2
+	maxRefreshRetain     = 20
+)
+
+// refreshRetain returns how many revisions of the given snap, including
+// the one being refreshed to, are kept after a refresh. It is taken
+// from the refresh.retain-snap.<snap> core option if set, otherwise
+// from the refresh.retain one.
+func refreshRetain(st *state.State, snapName string) int {
+	tr := config.NewTransaction(st)
+	for _, key := range []string{"refresh.retain-snap." + snapName, "refresh.retain"} {
+		var retain int
+		err := tr.Get("core", key, &retain)
+		if config.IsNoOption(err) {
+			continue
+		}
+		if err == nil && (retain < minRefreshRetain || retain > maxRefreshRetain) {
+			err = fmt.Errorf("%d is not between %d and %d", retain, minRefreshRetain, maxRefreshRetain)
+		}
+		if err != nil {
+			logger.Noticef("cannot use %s configuration: %v", key, err)
+			continue
+		}
+		return retain
+	}
+	return defaultRefreshRetain
+}
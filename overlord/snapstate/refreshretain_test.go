@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) setCoreOption(c *C, key string, value interface{}) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", key, value), IsNil)
+	tr.Commit()
+}
+
+// setSomeSnapRevisions installs some-snap with revisions 1 to n, the
+// last one being current.
+func (s *snapmgrTestSuite) setSomeSnapRevisions(n int) {
+	var seq []*snap.SideInfo
+	for i := 1; i <= n; i++ {
+		seq = append(seq, &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(i)})
+	}
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: seq,
+		Current:  snap.R(n),
+		SnapType: "app",
+	})
+}
+
+func (s *snapmgrTestSuite) testUpdateRefreshRetain(c *C, options map[string]interface{}, discards int) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setSomeSnapRevisions(6)
+	for key, value := range options {
+		s.setCoreOption(c, key, value)
+	}
+
+	ts, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	verifyInstallUpdateTasks(c, unlinkBefore|cleanupAfter, discards, ts, s.state)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainDefault(c *C) {
+	s.testUpdateRefreshRetain(c, nil, 4)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainFewer(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{"refresh.retain": 2}, 5)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainMore(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{"refresh.retain": 5}, 2)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainAll(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{"refresh.retain": 20}, 0)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainOutOfBounds(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{"refresh.retain": 1}, 4)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainTooMany(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{"refresh.retain": 21}, 4)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainBogus(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{"refresh.retain": "many"}, 4)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainPerSnap(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{
+		"refresh.retain":                2,
+		"refresh.retain-snap.some-snap": 6,
+	}, 1)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainPerSnapOtherSnap(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{
+		"refresh.retain":                 2,
+		"refresh.retain-snap.other-snap": 6,
+	}, 5)
+}
+
+func (s *snapmgrTestSuite) TestUpdateRefreshRetainPerSnapOutOfBounds(c *C) {
+	s.testUpdateRefreshRetain(c, map[string]interface{}{
+		"refresh.retain":                5,
+		"refresh.retain-snap.some-snap": 30,
+	}, 2)
+}
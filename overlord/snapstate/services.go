@@ -0,0 +1,113 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/i18n/dumb"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ServiceAction describes an action to be performed on some of the
+// services of a snap by a service-control task.
+type ServiceAction struct {
+	SnapName string   `json:"snap-name"`
+	Action   string   `json:"action"`
+	Services []string `json:"services"`
+	// Enable makes a start action also enable the services at boot.
+	Enable bool `json:"enable,omitempty"`
+	// Disable makes a stop action also disable the services at boot.
+	Disable bool `json:"disable,omitempty"`
+}
+
+func (action *ServiceAction) validate() error {
+	switch action.Action {
+	case "start":
+		if action.Disable {
+			return fmt.Errorf("cannot disable services when starting them")
+		}
+	case "stop":
+		if action.Enable {
+			return fmt.Errorf("cannot enable services when stopping them")
+		}
+	case "restart":
+		if action.Enable || action.Disable {
+			return fmt.Errorf("cannot enable or disable services when restarting them")
+		}
+	default:
+		return fmt.Errorf("unknown service action %q", action.Action)
+	}
+	if len(action.Services) == 0 {
+		return fmt.Errorf("no services to %s for snap %q", action.Action, action.SnapName)
+	}
+	return nil
+}
+
+// serviceApps returns the services of the given snap named in the action.
+func (action *ServiceAction) serviceApps(info *snap.Info) ([]*snap.AppInfo, error) {
+	svcs := make([]*snap.AppInfo, 0, len(action.Services))
+	for _, name := range action.Services {
+		app, ok := info.Apps[name]
+		if !ok || !app.IsService() {
+			return nil, fmt.Errorf("snap %q has no service %q", info.Name(), name)
+		}
+		svcs = append(svcs, app)
+	}
+	return svcs, nil
+}
+
+// ServiceControl returns a task that will perform the given action on
+// the named services of the snap once run. The services must belong to
+// the current revision of the snap.
+func ServiceControl(st *state.State, action *ServiceAction) (*state.Task, error) {
+	if err := action.validate(); err != nil {
+		return nil, err
+	}
+
+	info, err := CurrentInfo(st, action.SnapName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := action.serviceApps(info); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(action.Services))
+	copy(names, action.Services)
+	sort.Strings(names)
+
+	var summary string
+	switch action.Action {
+	case "start":
+		summary = fmt.Sprintf(i18n.G("Start services %s of snap %q"), strings.Join(names, ", "), action.SnapName)
+	case "stop":
+		summary = fmt.Sprintf(i18n.G("Stop services %s of snap %q"), strings.Join(names, ", "), action.SnapName)
+	case "restart":
+		summary = fmt.Sprintf(i18n.G("Restart services %s of snap %q"), strings.Join(names, ", "), action.SnapName)
+	}
+
+	t := st.NewTask("service-control", summary)
+	t.Set("service-action", action)
+	return t, nil
+}
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"gopkg.in/tomb.v2"
@@ -282,11 +283,46 @@ func cachedStore(st *state.State) StoreService {
 	return ubuntuStore.(StoreService)
 }
 
-// the store implementation has the interface consumed here
-var _ StoreService = (*store.Store)(nil)
+// the store implementations have the interface consumed here
+var (
+	_ StoreService = (*store.Store)(nil)
+	_ StoreService = (*store.DirStore)(nil)
+)
+
+type dirStoreKey struct{}
+
+// dirStore returns the store serving the directory set with the
+// store.directory core option, if any.
+func dirStore(st *state.State) StoreService {
+	var dir string
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", "store.directory", &dir); err != nil && !config.IsNoOption(err) {
+		logger.Noticef("cannot use store.directory configuration: %v", err)
+		return nil
+	}
+	if dir == "" {
+		return nil
+	}
+	if !filepath.IsAbs(dir) {
+		logger.Noticef("cannot use store.directory configuration: %q is not an absolute path", dir)
+		return nil
+	}
+
+	dirStore, _ := st.Cached(dirStoreKey{}).(*store.DirStore)
+	if dirStore == nil || dirStore.Dir() != dir {
+		dirStore = store.NewDirStore(dir)
+		st.Cache(dirStoreKey{}, dirStore)
+	}
+	return dirStore
+}
 
-// Store returns the store service used by the snapstate package.
+// Store returns the store service used by the snapstate package,
+// which serves the directory set with the store.directory core
+// option, if any, instead of the store set by the overlord.
 func Store(st *state.State) StoreService {
+	if dirStore := dirStore(st); dirStore != nil {
+		return dirStore
+	}
 	if cachedStore := cachedStore(st); cachedStore != nil {
 		return cachedStore
 	}
@@ -322,6 +358,7 @@ func Manager(st *state.State) (*SnapManager, error) {
 	runner.AddHandler("link-snap", m.doLinkSnap, m.undoLinkSnap)
 	runner.AddHandler("start-snap-services", m.startSnapServices, m.stopSnapServices)
 	runner.AddHandler("switch-snap-channel", m.doSwitchSnapChannel, nil)
+	runner.AddHandler("service-control", m.doServiceControl, nil)
 
 	// FIXME: drop the task entirely after a while
 	// (having this wart here avoids yet-another-patch)
@@ -361,7 +398,7 @@ func Manager(st *state.State) (*SnapManager, error) {
 }
 
 func (m *SnapManager) blockedTask(cand *state.Task, running []*state.Task) bool {
-	return false
+	return tooManyDownloads(cand, running)
 }
 
 var CanAutoRefresh func(st *state.State) (bool, error)
@@ -522,6 +559,16 @@ func (m *SnapManager) ensureRefreshes() error {
 		return nil
 	}
 
+	// auto-refreshes can be held via the refresh.hold option
+	hold, err := EffectiveRefreshHold(m.state)
+	if err != nil {
+		return err
+	}
+	if !hold.IsZero() {
+		logger.Debugf("Auto-refresh held until %s.", hold)
+		return nil
+	}
+
 	// do refresh attempt (if needed)
 	if !m.nextRefresh.After(time.Now()) {
 		err = m.launchAutoRefresh()
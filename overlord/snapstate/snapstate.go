@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"time"
 
 	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
@@ -36,6 +37,7 @@ import (
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/strutil"
 )
 
 // control flags for doInstall
@@ -112,6 +114,16 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 		prev = checkAsserts
 	}
 
+	// refreshes run the pre-refresh hook before anything is changed,
+	// letting the snap veto the refresh, and the post-refresh hook once
+	// the new revision is linked
+	runRefreshHooks := snapst.HasCurrent() && !snapsup.Flags.Revert
+	if runRefreshHooks {
+		preRefreshHook := SetupPreRefreshHook(st, snapsup.Name())
+		addTask(preRefreshHook)
+		prev = preRefreshHook
+	}
+
 	// mount
 	if !revisionIsLocal {
 		mount := st.NewTask("mount-snap", fmt.Sprintf(i18n.G("Mount snap %q%s"), snapsup.Name(), revisionStr))
@@ -122,6 +134,7 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	if snapst.Active {
 		// unlink-current-snap (will stop services for copy-data)
 		stop := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q services"), snapsup.Name()))
+		stop.Set("stop-reason", stopReasonRefresh)
 		addTask(stop)
 		prev = stop
 
@@ -168,6 +181,18 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 	addTask(setupAliases)
 	prev = setupAliases
 
+	// run the install hook on first install only
+	if !snapst.HasCurrent() {
+		installHook := SetupInstallHook(st, snapsup.Name())
+		addTask(installHook)
+		prev = installHook
+	}
+	if runRefreshHooks {
+		postRefreshHook := SetupPostRefreshHook(st, snapsup.Name())
+		addTask(postRefreshHook)
+		prev = postRefreshHook
+	}
+
 	// run new serices
 	startSnapServices := st.NewTask("start-snap-services", fmt.Sprintf(i18n.G("Start snap %q%s services"), snapsup.Name(), revisionStr))
 	addTask(startSnapServices)
@@ -205,8 +230,11 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 			}
 		}
 
-		// normal garbage collect
-		for i := 0; i <= currentIndex-2; i++ {
+		// normal garbage collect, keeping the current revision and as
+		// many older ones as needed to retain the configured number
+		// once the new one is in
+		retain := refreshRetain(st, snapsup.Name())
+		for i := 0; i <= currentIndex-retain+1; i++ {
 			si := seq[i]
 			if boot.InUse(snapsup.Name(), si.Revision) {
 				continue
@@ -256,19 +284,50 @@ var Configure = func(st *state.State, snapName string, patch map[string]interfac
 	panic("internal error: snapstate.Configure is unset")
 }
 
+var SetupInstallHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupInstallHook is unset")
+}
+
+var SetupPreRefreshHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupPreRefreshHook is unset")
+}
+
+var SetupPostRefreshHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupPostRefreshHook is unset")
+}
+
+var SetupRemoveHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupRemoveHook is unset")
+}
+
 // snapTopicalTasks are tasks that characterize changes on a snap that
 // cannot be run concurrently and should conflict with each other.
 var snapTopicalTasks = map[string]bool{
-	"link-snap":          true,
-	"unlink-snap":        true,
-	"refresh-aliases":    true,
-	"prune-auto-aliases": true,
-	"alias":              true,
-	"unalias":            true,
-	"disable-aliases":    true,
-	"prefer-aliases":     true,
-	"connect":            true,
-	"disconnect":         true,
+	"link-snap":           true,
+	"unlink-snap":         true,
+	"switch-snap-channel": true,
+	"refresh-aliases":     true,
+	"prune-auto-aliases":  true,
+	"alias":               true,
+	"unalias":             true,
+	"disable-aliases":     true,
+	"prefer-aliases":      true,
+	"connect":             true,
+	"disconnect":          true,
+}
+
+// AffectedSnapsFunc returns the names of the snaps affected by a task.
+type AffectedSnapsFunc func(task *state.Task) ([]string, error)
+
+// affectedSnapsByKind holds the AffectedSnapsFunc of the task kinds,
+// from outside of snapstate, that conflict with changes on a snap.
+var affectedSnapsByKind = make(map[string]AffectedSnapsFunc)
+
+// AddAffectedSnapsByKind registers the function giving the snaps
+// affected by the tasks of the given kind, so that tasks of that kind
+// in progress conflict with other changes on those snaps.
+func AddAffectedSnapsByKind(kind string, f AffectedSnapsFunc) {
+	affectedSnapsByKind[kind] = f
 }
 
 func getPlugAndSlotRefs(task *state.Task) (*interfaces.PlugRef, *interfaces.SlotRef, error) {
@@ -300,6 +359,16 @@ func CheckChangeConflict(st *state.State, snapName string, checkConflictPredicat
 	for _, task := range st.Tasks() {
 		k := task.Kind()
 		chg := task.Change()
+		if f := affectedSnapsByKind[k]; f != nil && (chg == nil || !chg.Status().Ready()) {
+			snapNames, err := f(task)
+			if err != nil {
+				return fmt.Errorf("internal error: cannot obtain affected snaps from task: %s", task.Summary())
+			}
+			if strutil.ListContains(snapNames, snapName) && (checkConflictPredicate == nil || checkConflictPredicate(k)) {
+				return fmt.Errorf("snap %q has changes in progress", snapName)
+			}
+			continue
+		}
 		if snapTopicalTasks[k] && (chg == nil || !chg.Status().Ready()) {
 			if k == "connect" || k == "disconnect" {
 				plugRef, slotRef, err := getPlugAndSlotRefs(task)
@@ -390,9 +459,18 @@ func TryPath(st *state.State, name, path string, flags Flags) (*state.TaskSet, e
 	return InstallPath(st, &snap.SideInfo{RealName: name}, path, "", flags)
 }
 
-// Install returns a set of tasks for installing snap.
+// Install returns a set of tasks for installing snap, and the base
+// it declares if that is not installed yet.
 // Note that the state must be locked by the caller.
 func Install(st *state.State, name, channel string, revision snap.Revision, userID int, flags Flags) (*state.TaskSet, error) {
+	return install(st, name, channel, revision, userID, flags, make(map[string]*state.TaskSet))
+}
+
+// install returns a set of tasks for installing snap, preceded by the
+// installation of its base unless that is installed or already being
+// installed by one of the task sets in bases, which map the names of
+// snaps to the tasks installing them.
+func install(st *state.State, name, channel string, revision snap.Revision, userID int, flags Flags, bases map[string]*state.TaskSet) (*state.TaskSet, error) {
 	if channel == "" {
 		channel = "stable"
 	}
@@ -423,7 +501,46 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 		SideInfo:     &info.SideInfo,
 	}
 
-	return doInstall(st, &snapst, snapsup, needsMaybeCore(info.Type))
+	baseTs, installingBase := bases[info.Base]
+	if info.Base != "" && !installingBase {
+		baseTs, err = installBase(st, info, userID)
+		if err != nil {
+			return nil, err
+		}
+		bases[info.Base] = baseTs
+	}
+
+	ts, err := doInstall(st, &snapst, snapsup, needsMaybeCore(info.Type))
+	if err != nil {
+		return nil, err
+	}
+	if baseTs == nil {
+		return ts, nil
+	}
+	ts.WaitAll(baseTs)
+	if installingBase {
+		return ts, nil
+	}
+	return state.NewTaskSet(append(baseTs.Tasks(), ts.Tasks()...)...), nil
+}
+
+// installBase returns a set of tasks for installing the base of the
+// given snap, or nil if it is installed already.
+func installBase(st *state.State, info *snap.Info, userID int) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, info.Base, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if snapst.HasCurrent() {
+		return nil, nil
+	}
+
+	ts, err := Install(st, info.Base, "stable", snap.R(0), userID, Flags{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot install base %q of snap %q: %v", info.Base, info.Name(), err)
+	}
+	return ts, nil
 }
 
 // InstallMany installs everything from the given list of names.
@@ -431,8 +548,13 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 func InstallMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
 	installed := make([]string, 0, len(names))
 	tasksets := make([]*state.TaskSet, 0, len(names))
+	bases := make(map[string]*state.TaskSet)
 	for _, name := range names {
-		ts, err := Install(st, name, "", snap.R(0), userID, Flags{})
+		if _, ok := bases[name]; ok {
+			// already pulled in as the base of another snap
+			continue
+		}
+		ts, err := install(st, name, "", snap.R(0), userID, Flags{}, bases)
 		// FIXME: is this expected behavior?
 		if _, ok := err.(*snap.AlreadyInstalledError); ok {
 			continue
@@ -442,6 +564,7 @@ func InstallMany(st *state.State, names []string, userID int) ([]string, []*stat
 		}
 		installed = append(installed, name)
 		tasksets = append(tasksets, ts)
+		bases[name] = ts
 	}
 
 	return installed, tasksets, nil
@@ -461,16 +584,27 @@ func contains(ns []string, n string) bool {
 // RefreshCandidates gets a list of candidates for update
 // Note that the state must be locked by the caller.
 func RefreshCandidates(st *state.State, user *auth.UserState) ([]*snap.Info, error) {
-	updates, _, err := refreshCandidates(st, nil, user)
+	updates, _, err := refreshCandidates(st, nil, user, false)
 	return updates, err
 }
 
-func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([]*snap.Info, map[string]*SnapState, error) {
+// refreshCandidates gets the candidates for update among the given
+// snaps, or all of them if none is given. When auto is set, snaps
+// whose refreshes are held are not considered.
+func refreshCandidates(st *state.State, names []string, user *auth.UserState, auto bool) ([]*snap.Info, map[string]*SnapState, error) {
 	snapStates, err := All(st)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	var held map[string]time.Time
+	if auto {
+		held, err = RefreshHolds(st)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	sort.Strings(names)
 
 	stateByID := make(map[string]*SnapState, len(snapStates))
@@ -502,6 +636,11 @@ func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([
 			continue
 		}
 
+		if until, ok := held[snapInfo.Name()]; ok {
+			logger.Debugf("Auto-refresh of snap %q held until %s.", snapInfo.Name(), until)
+			continue
+		}
+
 		stateByID[snapInfo.SnapID] = snapst
 
 		// get confinement preference from the snapstate
@@ -539,12 +678,16 @@ var ValidateRefreshes func(st *state.State, refreshes []*snap.Info, userID int)
 // store says is updateable. If the list is empty, update everything.
 // Note that the state must be locked by the caller.
 func UpdateMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	return updateMany(st, names, userID, false)
+}
+
+func updateMany(st *state.State, names []string, userID int, auto bool) ([]string, []*state.TaskSet, error) {
 	user, err := userFromUserID(st, userID)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	updates, stateByID, err := refreshCandidates(st, names, user)
+	updates, stateByID, err := refreshCandidates(st, names, user, auto)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -622,6 +765,18 @@ func doUpdate(st *state.State, names []string, updates []*snap.Info, params func
 			return nil, nil, err
 		}
 
+		curInfo, err := snapst.CurrentInfo()
+		if err == nil {
+			err = checkEpoch("refresh", update, curInfo)
+		}
+		if err != nil {
+			if refreshAll {
+				logger.Noticef("cannot update %q: %v", update.Name(), err)
+				continue
+			}
+			return nil, nil, err
+		}
+
 		snapsup := &SnapSetup{
 			Channel:      channel,
 			UserID:       userID,
@@ -855,6 +1010,35 @@ func Update(st *state.State, name, channel string, revision snap.Revision, userI
 	return flat, nil
 }
 
+// Switch initiates a change switching the channel tracked by a snap,
+// without refreshing it; the next refresh uses the new channel.
+// Note that the state must be locked by the caller.
+func Switch(st *state.State, name, channel string) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, name, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if !snapst.HasCurrent() {
+		return nil, fmt.Errorf("cannot find snap %q", name)
+	}
+
+	if err := CheckChangeConflict(st, name, nil, nil); err != nil {
+		return nil, err
+	}
+
+	snapsup := &SnapSetup{
+		// only the tracked channel changes, not that of the current revision
+		SideInfo: &snap.SideInfo{RealName: name},
+		Channel:  channel,
+	}
+
+	switchSnap := st.NewTask("switch-snap-channel", fmt.Sprintf(i18n.G("Switch snap %q from %s to %s"), name, snapst.Channel, channel))
+	switchSnap.Set("snap-setup", &snapsup)
+
+	return state.NewTaskSet(switchSnap), nil
+}
+
 func infoForUpdate(st *state.State, snapst *SnapState, name, channel string, revision snap.Revision, userID int, flags Flags) (*snap.Info, error) {
 	if revision.Unset() {
 		// good ol' refresh
@@ -894,8 +1078,8 @@ func infoForUpdate(st *state.State, snapst *SnapState, name, channel string, rev
 var AutoRefreshAssertions func(st *state.State, userID int) error
 
 // AutoRefresh is the wrapper that will do a refresh of all the installed
-// snaps on the system. In addition to that it will also refresh important
-// assertions.
+// snaps on the system, except those whose refreshes are held. In
+// addition to that it will also refresh important assertions.
 func AutoRefresh(st *state.State) ([]string, []*state.TaskSet, error) {
 	userID := 0
 
@@ -905,7 +1089,7 @@ func AutoRefresh(st *state.State) ([]string, []*state.TaskSet, error) {
 		}
 	}
 
-	return UpdateMany(st, nil, userID)
+	return updateMany(st, nil, userID, true)
 }
 
 // Enable sets a snap to the active state
@@ -1068,6 +1252,50 @@ func canRemove(si *snap.Info, snapst *SnapState, removeAll bool) bool {
 	return true
 }
 
+// baseUsers returns the sorted names of the installed snaps that use
+// the given snap as their base.
+func baseUsers(st *state.State, base string) ([]string, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	for name, snapst := range snapStates {
+		if !snapst.HasCurrent() {
+			continue
+		}
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			// broken snaps cannot run on any base
+			continue
+		}
+		if info.Base == base {
+			users = append(users, name)
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// AutomaticSnapshot allows to hook taking a snapshot of the data of a
+// snap that is about to be removed. It returns a nil task set if no
+// snapshot should be taken.
+var AutomaticSnapshot = func(st *state.State, snapName string) (*state.TaskSet, error) {
+	panic("internal error: snapstate.AutomaticSnapshot is unset")
+}
+
+// EnsureSnapQuotaGroup allows to hook placing the services of a snap
+// that was just linked in the slice of its quota group, if any.
+var EnsureSnapQuotaGroup = func(st *state.State, info *snap.Info) error {
+	panic("internal error: snapstate.EnsureSnapQuotaGroup is unset")
+}
+
+// RemoveSnapFromQuotaGroup allows to hook dropping a snap that was
+// removed from the system from its quota group, if any.
+var RemoveSnapFromQuotaGroup = func(st *state.State, snapName string) error {
+	panic("internal error: snapstate.RemoveSnapFromQuotaGroup is unset")
+}
+
 // Remove returns a set of tasks for removing snap.
 // Note that the state must be locked by the caller.
 func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSet, error) {
@@ -1119,6 +1347,16 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		return nil, fmt.Errorf("snap %q is not removable", name)
 	}
 
+	if removeAll && info.Type == snap.TypeBase {
+		users, err := baseUsers(st, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) > 0 {
+			return nil, fmt.Errorf("cannot remove snap %q: used as base by snaps %s", name, strutil.Quoted(users))
+		}
+	}
+
 	// main/current SnapSetup
 	snapsup := SnapSetup{
 		SideInfo: &snap.SideInfo{
@@ -1140,12 +1378,27 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		chain = ts
 	}
 
+	// the remove hook runs only when the snap goes away completely,
+	// while it is still available and before its data is discarded
+	var removeHook *state.Task
+	if removeAll {
+		removeHook = SetupRemoveHook(st, name)
+	}
+
 	if active { // unlink
 		stopSnapServices := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q services"), name))
 		stopSnapServices.Set("snap-setup", snapsup)
+		tasks := []*state.Task{stopSnapServices}
+		prev := stopSnapServices
+
+		if removeHook != nil {
+			removeHook.WaitFor(prev)
+			tasks = append(tasks, removeHook)
+			prev = removeHook
+		}
 
 		removeAliases := st.NewTask("remove-aliases", fmt.Sprintf(i18n.G("Remove aliases for snap %q"), name))
-		removeAliases.WaitFor(stopSnapServices)
+		removeAliases.WaitFor(prev)
 		removeAliases.Set("snap-setup-task", stopSnapServices.ID())
 
 		unlink := st.NewTask("unlink-snap", fmt.Sprintf(i18n.G("Make snap %q unavailable to the system"), name))
@@ -1156,7 +1409,21 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		removeSecurity.WaitFor(unlink)
 		removeSecurity.Set("snap-setup-task", stopSnapServices.ID())
 
-		addNext(state.NewTaskSet(stopSnapServices, removeAliases, unlink, removeSecurity))
+		tasks = append(tasks, removeAliases, unlink, removeSecurity)
+		addNext(state.NewTaskSet(tasks...))
+	} else if removeHook != nil {
+		addNext(state.NewTaskSet(removeHook))
+	}
+
+	if removeAll {
+		// save the data before it is discarded, if so configured
+		ts, err := AutomaticSnapshot(st, name)
+		if err != nil {
+			return nil, err
+		}
+		if ts != nil {
+			addNext(ts)
+		}
 	}
 
 	if removeAll {
@@ -1258,6 +1525,17 @@ func RevertToRevision(st *state.State, name string, rev snap.Revision, flags Fla
 	if err != nil {
 		return nil, err
 	}
+	info, err := readInfo(name, snapst.Sequence[i])
+	if err != nil {
+		return nil, err
+	}
+	curInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEpoch("revert", info, curInfo); err != nil {
+		return nil, err
+	}
 	flags.Revert = true
 	snapsup := &SnapSetup{
 		SideInfo: snapst.Sequence[i],
@@ -1361,6 +1639,32 @@ func Info(st *state.State, name string, revision snap.Revision) (*snap.Info, err
 	return nil, fmt.Errorf("cannot find snap %q at revision %s", name, revision.String())
 }
 
+// InjectTasks makes the given tasks run in sequence right after
+// mainTask, in its change and lanes, and before the tasks waiting for
+// mainTask.
+func InjectTasks(mainTask *state.Task, tasks []*state.Task) {
+	if len(tasks) == 0 {
+		return
+	}
+
+	chg := mainTask.Change()
+	haltTasks := mainTask.HaltTasks()
+	prev := mainTask
+	for _, t := range tasks {
+		t.WaitFor(prev)
+		for _, lane := range mainTask.Lanes() {
+			t.JoinLane(lane)
+		}
+		if chg != nil {
+			chg.AddTask(t)
+		}
+		prev = t
+	}
+	for _, ht := range haltTasks {
+		ht.WaitFor(prev)
+	}
+}
+
 // CurrentInfo returns the information about the current revision of a snap with the given name.
 func CurrentInfo(st *state.State, name string) (*snap.Info, error) {
 	var snapst SnapState
@@ -32,6 +32,7 @@ import (
 	"time"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
@@ -51,6 +52,9 @@ import (
 
 	// So it registers Configure.
 	_ "github.com/snapcore/snapd/overlord/configstate"
+	// So they register the quota group and automatic snapshot hooks.
+	_ "github.com/snapcore/snapd/overlord/quotastate"
+	_ "github.com/snapcore/snapd/overlord/snapshotstate"
 )
 
 func TestSnapManager(t *testing.T) { TestingT(t) }
@@ -137,6 +141,39 @@ func (s *snapmgrTestSuite) TestStore(c *C) {
 	c.Check(store2, Equals, sto)
 }
 
+func (s *snapmgrTestSuite) TestStoreDirectory(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	sto := &store.Store{}
+	snapstate.ReplaceStore(s.state, sto)
+
+	setDir := func(dir string) {
+		tr := config.NewTransaction(s.state)
+		c.Assert(tr.Set("core", "store.directory", dir), IsNil)
+		tr.Commit()
+	}
+
+	setDir("/media/usb/snaps")
+	dirStore, ok := snapstate.Store(s.state).(*store.DirStore)
+	c.Assert(ok, Equals, true)
+	c.Check(dirStore.Dir(), Equals, "/media/usb/snaps")
+	// cached
+	c.Check(snapstate.Store(s.state), Equals, dirStore)
+
+	setDir("/media/other")
+	dirStore, ok = snapstate.Store(s.state).(*store.DirStore)
+	c.Assert(ok, Equals, true)
+	c.Check(dirStore.Dir(), Equals, "/media/other")
+
+	// relative directories are ignored
+	setDir("snaps")
+	c.Check(snapstate.Store(s.state), Equals, sto)
+
+	setDir("")
+	c.Check(snapstate.Store(s.state), Equals, sto)
+}
+
 const (
 	unlinkBefore = 1 << iota
 	cleanupAfter
@@ -157,8 +194,11 @@ func verifyInstallUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *s
 	expected := []string{
 		"download-snap",
 		"validate-snap",
-		"mount-snap",
 	}
+	if opts&unlinkBefore != 0 {
+		expected = append(expected, "run-hook")
+	}
+	expected = append(expected, "mount-snap")
 	if opts&unlinkBefore != 0 {
 		expected = append(expected,
 			"stop-snap-services",
@@ -177,6 +217,7 @@ func verifyInstallUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *s
 	expected = append(expected,
 		"set-auto-aliases",
 		"setup-aliases",
+		"run-hook",
 		"start-snap-services",
 	)
 	for i := 0; i < discards; i++ {
@@ -200,6 +241,7 @@ func verifyInstallUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *s
 func verifyRemoveTasks(c *C, ts *state.TaskSet) {
 	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 		"stop-snap-services",
+		"run-hook",
 		"remove-aliases",
 		"unlink-snap",
 		"remove-profiles",
@@ -281,6 +323,14 @@ func (s *snapmgrTestSuite) TestInstallTasks(c *C) {
 
 	verifyInstallUpdateTasks(c, 0, 0, ts, s.state)
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
+
+	// the install hook runs before the services are started
+	installHook := hookTask(ts, "install")
+	c.Assert(installHook, NotNil)
+	c.Check(hookTask(ts, "post-refresh"), IsNil)
+	startSnapServices := ts.Tasks()[len(ts.Tasks())-2]
+	c.Assert(startSnapServices.Kind(), Equals, "start-snap-services")
+	c.Check(startSnapServices.WaitTasks(), DeepEquals, []*state.Task{installHook})
 }
 
 func (s *snapmgrTestSuite) TestCoreInstallTasks(c *C) {
@@ -390,6 +440,17 @@ func (s *snapmgrTestSuite) TestUpdateCreatesGCTasks(c *C) {
 
 	verifyInstallUpdateTasks(c, unlinkBefore|cleanupAfter, 2, ts, s.state)
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
+
+	// the post-refresh hook runs once the new revision is linked
+	c.Check(hookTask(ts, "install"), IsNil)
+	postRefreshHook := hookTask(ts, "post-refresh")
+	c.Assert(postRefreshHook, NotNil)
+	c.Check(postRefreshHook.WaitTasks()[0].Kind(), Equals, "setup-aliases")
+	// while the pre-refresh hook runs before anything is changed
+	preRefreshHook := hookTask(ts, "pre-refresh")
+	c.Assert(preRefreshHook, NotNil)
+	c.Check(preRefreshHook.WaitTasks()[0].Kind(), Equals, "validate-snap")
+	c.Check(preRefreshHook.HaltTasks()[0].Kind(), Equals, "mount-snap")
 }
 
 func (s *snapmgrTestSuite) TestUpdateCreatesDiscardAfterCurrentTasks(c *C) {
@@ -441,6 +502,62 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 }
 
+func (s *snapmgrTestSuite) TestUpdateManyPreRefreshHookVeto(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, name := range []string{"some-snap", "services-snap"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, SnapID: name + "-id", Revision: snap.R(7)},
+			},
+			Current:  snap.R(7),
+			SnapType: "app",
+		})
+	}
+
+	// services-snap does not want to be refreshed now
+	s.snapmgr.AddAdhocTaskHandler("run-hook", func(task *state.Task, _ *tomb.Tomb) error {
+		var hooksup hookstate.HookSetup
+		st := task.State()
+		st.Lock()
+		err := task.Get("hook-setup", &hooksup)
+		st.Unlock()
+		c.Assert(err, IsNil)
+		if hooksup.Snap == "services-snap" && hooksup.Hook == "pre-refresh" {
+			return fmt.Errorf(`run hook "pre-refresh": busy collecting data`)
+		}
+		return nil
+	}, nil)
+
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0)
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	sort.Strings(updates)
+	c.Check(updates, DeepEquals, []string{"services-snap", "some-snap"})
+
+	chg := s.state.NewChange("refresh", "refresh all snaps")
+	for _, ts := range tts {
+		chg.AddAll(ts)
+	}
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*run hook "pre-refresh": busy collecting data.*`)
+
+	// the vetoed snap was left alone while the other one was refreshed
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "services-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(7))
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(11))
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyDevModeConfinementFiltering(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -459,6 +576,69 @@ func (s *snapmgrTestSuite) TestUpdateManyDevModeConfinementFiltering(c *C) {
 	c.Assert(tts, HasLen, 0)
 }
 
+func (s *snapmgrTestSuite) TestUpdateEpochCannotRead(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "channel-for-epoch-2",
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	_, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot refresh "some-snap" to revision 11: its epoch 2 cannot read the data of the current epoch 0`)
+
+	// updatemany skips it
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, s.user.ID)
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+	c.Check(tts, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestInstallPathEpochCannotRead(c *C) {
+	restore := snapstate.MockOpenSnapFile(func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
+		info := &snap.Info{Architectures: []string{"all"}, Epoch: snap.E("2")}
+		if si != nil {
+			info.SideInfo = *si
+		}
+		return info, nil, nil
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("install", "install a local snap")
+	ts, err := snapstate.InstallPath(s.state, &snap.SideInfo{RealName: "some-snap"}, "some-snap-path", "", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*cannot refresh "some-snap" to revision x1: its epoch 2 cannot read the data of the current epoch 0.*`)
+
+	// the current revision is untouched
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Current, Equals, snap.R(7))
+	c.Check(snapst.Sequence, HasLen, 1)
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyClassicConfinementFiltering(c *C) {
 	if !dirs.SupportsClassicConfinement() {
 		return
@@ -1196,6 +1376,111 @@ func (s *snapmgrTestSuite) TestRemoveTasks(c *C) {
 
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 	verifyRemoveTasks(c, ts)
+	c.Check(hookTask(ts, "remove"), NotNil)
+}
+
+func (s *snapmgrTestSuite) TestRemoveInactiveRunsRemoveHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: false,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0))
+	c.Assert(err, IsNil)
+
+	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"run-hook",
+		"clear-snap",
+		"discard-snap",
+		"discard-conns",
+	})
+	c.Check(hookTask(ts, "remove"), NotNil)
+}
+
+func (s *snapmgrTestSuite) TestRemoveTakesAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	oldAutomaticSnapshot := snapstate.AutomaticSnapshot
+	defer func() { snapstate.AutomaticSnapshot = oldAutomaticSnapshot }()
+	var snapNames []string
+	snapstate.AutomaticSnapshot = func(st *state.State, snapName string) (*state.TaskSet, error) {
+		snapNames = append(snapNames, snapName)
+		return state.NewTaskSet(st.NewTask("save-snapshot", "...")), nil
+	}
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: false,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0))
+	c.Assert(err, IsNil)
+
+	c.Check(snapNames, DeepEquals, []string{"foo"})
+	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"run-hook",
+		"save-snapshot",
+		"clear-snap",
+		"discard-snap",
+		"discard-conns",
+	})
+	c.Check(ts.Tasks()[2].WaitTasks(), DeepEquals, []*state.Task{ts.Tasks()[1]})
+}
+
+func (s *snapmgrTestSuite) TestRemoveRevisionTakesNoAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	oldAutomaticSnapshot := snapstate.AutomaticSnapshot
+	defer func() { snapstate.AutomaticSnapshot = oldAutomaticSnapshot }()
+	snapstate.AutomaticSnapshot = func(st *state.State, snapName string) (*state.TaskSet, error) {
+		c.Fatalf("unexpected automatic snapshot")
+		return nil, nil
+	}
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(11)},
+			{RealName: "foo", Revision: snap.R(12)},
+		},
+		Current: snap.R(12),
+	})
+
+	_, err := snapstate.Remove(s.state, "foo", snap.R(11))
+	c.Assert(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestRemoveRevisionDoesNotRunRemoveHook(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", Revision: snap.R(5)},
+			{RealName: "foo", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+	})
+
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(5))
+	c.Assert(err, IsNil)
+
+	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"clear-snap",
+		"discard-snap",
+	})
 }
 
 func (s *snapmgrTestSuite) TestRemoveConflict(c *C) {
@@ -1317,7 +1602,7 @@ func (s *snapmgrTestSuite) TestInstallRunThrough(c *C) {
 	c.Check(task.Summary(), Equals, `Download snap "some-snap" (42) from channel "some-channel"`)
 
 	// check link/start snap summary
-	linkTask := ta[len(ta)-5]
+	linkTask := ta[len(ta)-6]
 	c.Check(linkTask.Summary(), Equals, `Make snap "some-snap" (42) available to the system`)
 	startTask := ta[len(ta)-2]
 	c.Check(startTask.Summary(), Equals, `Start snap "some-snap" (42) services`)
@@ -1394,7 +1679,7 @@ func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 				Channel:  "some-channel",
 				SnapID:   "services-snap-id",
 				Revision: snap.R(7),
-				Epoch:    "0",
+				Epoch:    snap.E("0"),
 			},
 			revno: snap.R(11),
 		},
@@ -1532,6 +1817,49 @@ func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 	})
 }
 
+func (s *snapmgrTestSuite) TestUpdateUndoPutsBackQuotaGroup(c *C) {
+	oldEnsureSnapQuotaGroup := snapstate.EnsureSnapQuotaGroup
+	defer func() { snapstate.EnsureSnapQuotaGroup = oldEnsureSnapQuotaGroup }()
+	var ensured []string
+	snapstate.EnsureSnapQuotaGroup = func(st *state.State, info *snap.Info) error {
+		ensured = append(ensured, fmt.Sprintf("%s/%s", info.Name(), info.Revision))
+		return nil
+	}
+
+	si := snap.SideInfo{
+		RealName: "some-snap",
+		SnapID:   "some-snap-id",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.fakeBackend.linkSnapFailTrigger = filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "/some-snap/11")
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	// the services of the reverted revision are put back in the
+	// quota group, the ones of the new one never were
+	c.Check(ensured, DeepEquals, []string{"some-snap/7"})
+}
+
 func (s *snapmgrTestSuite) TestUpdateUndoRunThrough(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -1568,7 +1896,7 @@ func (s *snapmgrTestSuite) TestUpdateUndoRunThrough(c *C) {
 				Channel:  "some-channel",
 				SnapID:   "some-snap-id",
 				Revision: snap.R(7),
-				Epoch:    "",
+				Epoch:    snap.Epoch{},
 			},
 			revno: snap.R(11),
 		},
@@ -1728,7 +2056,7 @@ func (s *snapmgrTestSuite) TestUpdateTotalUndoRunThrough(c *C) {
 				Channel:  "some-channel",
 				SnapID:   "some-snap-id",
 				Revision: snap.R(7),
-				Epoch:    "",
+				Epoch:    snap.Epoch{},
 			},
 			revno: snap.R(11),
 		},
@@ -1934,7 +2262,7 @@ func (s *snapmgrTestSuite) TestUpdateSameRevisionSwitchChannelRunThrough(c *C) {
 				Channel:  "channel-for-7",
 				SnapID:   "some-snap-id",
 				Revision: snap.R(7),
-				Epoch:    "",
+				Epoch:    snap.Epoch{},
 			},
 		},
 	}
@@ -1974,6 +2302,92 @@ func (s *snapmgrTestSuite) TestUpdateSameRevisionSwitchChannelRunThrough(c *C) {
 	})
 }
 
+func (s *snapmgrTestSuite) TestSwitchTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+		Channel:  "edge",
+	})
+
+	ts, err := snapstate.Switch(s.state, "some-snap", "beta")
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Kind(), Equals, "switch-snap-channel")
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Switch snap "some-snap" from edge to beta`)
+}
+
+func (s *snapmgrTestSuite) TestSwitchConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+	})
+
+	ts, err := snapstate.Switch(s.state, "some-snap", "beta")
+	c.Assert(err, IsNil)
+	// need a change to make the tasks visible
+	s.state.NewChange("switch-snap", "...").AddAll(ts)
+
+	_, err = snapstate.Switch(s.state, "some-snap", "edge")
+	c.Assert(err, ErrorMatches, `snap "some-snap" has changes in progress`)
+}
+
+func (s *snapmgrTestSuite) TestSwitchUnknownSnap(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.Switch(s.state, "non-existing-snap", "beta")
+	c.Assert(err, ErrorMatches, `cannot find snap "non-existing-snap"`)
+}
+
+func (s *snapmgrTestSuite) TestSwitchRunThrough(c *C) {
+	si := snap.SideInfo{
+		RealName: "some-snap",
+		SnapID:   "some-snap-id",
+		Channel:  "edge",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si},
+		Channel:  "edge",
+		Current:  si.Revision,
+	})
+
+	ts, err := snapstate.Switch(s.state, "some-snap", "beta")
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("switch-snap", "switch a snap")
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	// nothing is downloaded or installed
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Channel, Equals, "beta")
+	c.Check(snapst.Current, Equals, snap.R(7))
+	// the current revision still comes from the old channel
+	c.Check(snapst.Sequence[0].Channel, Equals, "edge")
+}
+
 func (s *snapmgrTestSuite) TestUpdateValidateRefreshesSaysNo(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -2070,7 +2484,7 @@ func (s *snapmgrTestSuite) TestSingleUpdateBlockedRevision(c *C) {
 		cand: store.RefreshCandidate{
 			SnapID:   "some-snap-id",
 			Revision: snap.R(7),
-			Epoch:    "",
+			Epoch:    snap.Epoch{},
 			Channel:  "some-channel",
 		},
 	})
@@ -2417,7 +2831,7 @@ func (s *snapmgrTestSuite) TestUpdateOneAutoAliasesScenarios(c *C) {
 		}
 		if scenario.update {
 			first := tasks[j]
-			j += 14
+			j += 16
 			c.Check(first.Kind(), Equals, "download-snap")
 			wait := false
 			if expectedPruned["other-snap"]["aliasA"] {
@@ -2926,6 +3340,9 @@ func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	// verify snapSetup info
 	tasks := ts.Tasks()
 	for _, t := range tasks {
+		if t.Kind() == "run-hook" {
+			continue
+		}
 		snapsup, err := snapstate.TaskSnapSetup(t)
 		c.Assert(err, IsNil)
 
@@ -3052,6 +3469,9 @@ func (s *snapmgrTestSuite) TestRemoveWithManyRevisionsRunThrough(c *C) {
 	revnos := []snap.Revision{{N: 7}, {N: 3}, {N: 5}}
 	whichRevno := 0
 	for _, t := range tasks {
+		if t.Kind() == "run-hook" {
+			continue
+		}
 		snapsup, err := snapstate.TaskSnapSetup(t)
 		c.Assert(err, IsNil)
 
@@ -3159,6 +3579,56 @@ func (s *snapmgrTestSuite) TestRemoveOneRevisionRunThrough(c *C) {
 	c.Check(snapst.Sequence, HasLen, 2)
 }
 
+func (s *snapmgrTestSuite) TestRemoveDropsSnapFromQuotaGroup(c *C) {
+	oldRemoveSnapFromQuotaGroup := snapstate.RemoveSnapFromQuotaGroup
+	defer func() { snapstate.RemoveSnapFromQuotaGroup = oldRemoveSnapFromQuotaGroup }()
+	var dropped []string
+	snapstate.RemoveSnapFromQuotaGroup = func(st *state.State, snapName string) error {
+		dropped = append(dropped, snapName)
+		return nil
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(3)},
+			{RealName: "some-snap", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	// removing a revision keeps the snap in its group
+	chg := s.state.NewChange("remove", "remove a revision")
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(3))
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(dropped, HasLen, 0)
+
+	// removing the snap drops it
+	chg = s.state.NewChange("remove", "remove a snap")
+	ts, err = snapstate.Remove(s.state, "some-snap", snap.R(0))
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(dropped, DeepEquals, []string{"some-snap"})
+}
+
 func (s *snapmgrTestSuite) TestRemoveLastRevisionRunThrough(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -3216,6 +3686,9 @@ func (s *snapmgrTestSuite) TestRemoveLastRevisionRunThrough(c *C) {
 	// verify snapSetup info
 	tasks := ts.Tasks()
 	for _, t := range tasks {
+		if t.Kind() == "run-hook" {
+			continue
+		}
 		snapsup, err := snapstate.TaskSnapSetup(t)
 		c.Assert(err, IsNil)
 
@@ -3679,6 +4152,40 @@ func (s *snapmgrTestSuite) TestRevertToRevisionNoValidVersion(c *C) {
 	c.Assert(ts, IsNil)
 }
 
+func (s *snapmgrTestSuite) TestRevertToRevisionEpochCannotRead(c *C) {
+	si := snap.SideInfo{
+		RealName: "some-snap",
+		Revision: snap.R(7),
+	}
+	si2 := snap.SideInfo{
+		RealName: "some-snap",
+		Revision: snap.R(77),
+	}
+
+	restore := snapstate.MockReadInfo(func(name string, si *snap.SideInfo) (*snap.Info, error) {
+		info, err := s.fakeBackend.ReadInfo(name, si)
+		if err == nil && si.Revision == snap.R(77) {
+			info.Epoch = snap.E("1*")
+		}
+		return info, err
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si, &si2},
+		Current:  snap.R(77),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.RevertToRevision(s.state, "some-snap", snap.R("7"), snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot revert "some-snap" to revision 7: its epoch 0 cannot read the data of the current epoch 1\*`)
+	c.Assert(ts, IsNil)
+}
+
 func (s *snapmgrTestSuite) TestRevertToRevisionAlreadyCurrent(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -4391,6 +4898,7 @@ link-snap: Error
  ERROR fail
 set-auto-aliases: Hold
 setup-aliases: Hold
+run-hook: Hold
 start-snap-services: Hold
 cleanup: Hold
 run-hook: Hold`)
@@ -4404,6 +4912,7 @@ link-snap: Error
  ERROR fail
 set-auto-aliases: Hold
 setup-aliases: Hold
+run-hook: Hold
 start-snap-services: Hold
 cleanup: Hold
 run-hook: Hold`)
@@ -5065,6 +5574,7 @@ func (s *snapmgrTestSuite) testTrySetsTryMode(flags snapstate.Flags, c *C) {
 		"setup-profiles",
 		"set-auto-aliases",
 		"setup-aliases",
+		"run-hook",
 		"start-snap-services",
 		"run-hook",
 	})
@@ -5722,6 +6232,106 @@ func (s *snapmgrTestSuite) TestInstallMany(c *C) {
 	}
 }
 
+func (s *snapmgrTestSuite) TestInstallWithBaseTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.Install(s.state, "some-snap-with-base", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
+
+	// the base is installed first, from the stable channel
+	var downloads []*state.Task
+	for _, t := range ts.Tasks() {
+		if t.Kind() == "download-snap" {
+			downloads = append(downloads, t)
+		}
+	}
+	c.Assert(downloads, HasLen, 2)
+	baseSup, err := snapstate.TaskSnapSetup(downloads[0])
+	c.Assert(err, IsNil)
+	c.Check(baseSup.Name(), Equals, "some-base")
+	c.Check(baseSup.Channel, Equals, "stable")
+	snapsup, err := snapstate.TaskSnapSetup(downloads[1])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Name(), Equals, "some-snap-with-base")
+
+	// and the snap waits for all of it
+	n := len(ts.Tasks()) / 2
+	for _, t := range ts.Tasks()[:n] {
+		c.Check(downloads[1].WaitTasks(), testutil.Contains, t)
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallWithBaseInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-base", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-base", Revision: snap.R(1)},
+		},
+		Current: snap.R(1),
+	})
+
+	ts, err := snapstate.Install(s.state, "some-snap-with-base", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	verifyInstallUpdateTasks(c, 0, 0, ts, s.state)
+}
+
+func (s *snapmgrTestSuite) TestInstallManySharedBase(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	installed, tts, err := snapstate.InstallMany(s.state, []string{"one-with-base", "two-with-base", "some-base"}, 0)
+	c.Assert(err, IsNil)
+	c.Check(installed, DeepEquals, []string{"one-with-base", "two-with-base"})
+	c.Assert(tts, HasLen, 2)
+
+	// the base is installed only once, along with the first snap
+	var baseDownloads int
+	for _, ts := range tts {
+		for _, t := range ts.Tasks() {
+			if t.Kind() != "download-snap" {
+				continue
+			}
+			snapsup, err := snapstate.TaskSnapSetup(t)
+			c.Assert(err, IsNil)
+			if snapsup.Name() == "some-base" {
+				baseDownloads++
+			}
+		}
+	}
+	c.Check(baseDownloads, Equals, 1)
+	verifyInstallUpdateTasks(c, 0, 0, tts[1], s.state)
+	lastBaseTask := tts[0].Tasks()[len(tts[0].Tasks())/2-1]
+	c.Check(tts[1].Tasks()[0].WaitTasks(), testutil.Contains, lastBaseTask)
+}
+
+func (s *snapmgrTestSuite) TestRemoveBaseInUse(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, name := range []string{"some-base", "some-snap-with-base"} {
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1)},
+			},
+			Current: snap.R(1),
+		})
+	}
+
+	_, err := snapstate.Remove(s.state, "some-base", snap.R(0))
+	c.Check(err, ErrorMatches, `cannot remove snap "some-base": used as base by snaps "some-snap-with-base"`)
+
+	// once its users are gone the base can be removed
+	snapstate.Set(s.state, "some-snap-with-base", nil)
+	_, err = snapstate.Remove(s.state, "some-base", snap.R(0))
+	c.Check(err, IsNil)
+}
+
 func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -5746,10 +6356,11 @@ func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 	c.Assert(tts, HasLen, 2)
 	c.Check(removed, DeepEquals, []string{"one", "two"})
 
-	c.Assert(s.state.TaskCount(), Equals, 7*2)
+	c.Assert(s.state.TaskCount(), Equals, 8*2)
 	for _, ts := range tts {
 		c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 			"stop-snap-services",
+			"run-hook",
 			"remove-aliases",
 			"unlink-snap",
 			"remove-profiles",
@@ -5760,9 +6371,13 @@ func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 	}
 }
 
-func taskWithKind(ts *state.TaskSet, kind string) *state.Task {
+func hookTask(ts *state.TaskSet, hookName string) *state.Task {
 	for _, task := range ts.Tasks() {
-		if task.Kind() == kind {
+		if task.Kind() != "run-hook" {
+			continue
+		}
+		var hooksup hookstate.HookSetup
+		if task.Get("hook-setup", &hooksup) == nil && hooksup.Hook == hookName {
 			return task
 		}
 	}
@@ -5903,8 +6518,8 @@ func (s *snapmgrTestSuite) TestGadgetDefaults(c *C) {
 	c.Assert(err, IsNil)
 
 	var m map[string]interface{}
-	runHook := taskWithKind(ts, "run-hook")
-	c.Assert(runHook.Kind(), Equals, "run-hook")
+	runHook := hookTask(ts, "configure")
+	c.Assert(runHook, NotNil)
 	err = runHook.Get("hook-context", &m)
 	c.Assert(err, IsNil)
 	c.Assert(m, DeepEquals, map[string]interface{}{"use-defaults": true})
@@ -5929,7 +6544,7 @@ func (s *snapmgrTestSuite) TestInstallPathSkipConfigure(c *C) {
 	ts, err := snapstate.InstallPath(s.state, &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)}, snapPath, "edge", snapstate.Flags{SkipConfigure: true})
 	c.Assert(err, IsNil)
 
-	runHook := taskWithKind(ts, "run-hook")
+	runHook := hookTask(ts, "configure")
 	c.Assert(runHook, IsNil)
 
 	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
@@ -5964,8 +6579,8 @@ func (s *snapmgrTestSuite) TestGadgetDefaultsInstalled(c *C) {
 	c.Assert(err, IsNil)
 
 	var m map[string]interface{}
-	runHook := taskWithKind(ts, "run-hook")
-	c.Assert(runHook.Kind(), Equals, "run-hook")
+	runHook := hookTask(ts, "configure")
+	c.Assert(runHook, NotNil)
 	err = runHook.Get("hook-context", &m)
 	c.Assert(err, Equals, state.ErrNoState)
 }
@@ -6651,3 +7266,31 @@ func (s *canDisableSuite) TestCanDisable(c *C) {
 		c.Check(snapstate.CanDisable(info), Equals, tt.canDisable)
 	}
 }
+
+func (s *snapmgrTestSuite) TestInjectTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	lane := s.state.NewLane()
+
+	chg := s.state.NewChange("change", "...")
+	mainTask := s.state.NewTask("main-task", "...")
+	mainTask.JoinLane(lane)
+	nextTask := s.state.NewTask("next-task", "...")
+	nextTask.WaitFor(mainTask)
+	chg.AddTask(mainTask)
+	chg.AddTask(nextTask)
+
+	t1 := s.state.NewTask("injected-1", "...")
+	t2 := s.state.NewTask("injected-2", "...")
+	snapstate.InjectTasks(mainTask, []*state.Task{t1, t2})
+
+	c.Check(chg.Tasks(), HasLen, 4)
+	c.Check(t1.Change(), Equals, chg)
+	c.Check(t2.Change(), Equals, chg)
+	c.Check(t1.Lanes(), DeepEquals, []int{lane})
+	c.Check(t2.Lanes(), DeepEquals, []int{lane})
+	c.Check(t1.WaitTasks(), DeepEquals, []*state.Task{mainTask})
+	c.Check(t2.WaitTasks(), DeepEquals, []*state.Task{t1})
+	c.Check(nextTask.WaitTasks(), DeepEquals, []*state.Task{mainTask, t2})
+}
@@ -0,0 +1,246 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxEpochLen is the maximum number of entries in an epoch list.
+const maxEpochLen = 10
+
+// An Epoch describes the formats of the data of a snap that a revision
+// can read and write. Revisions can only be refreshed to, or reverted
+// to, when they can read the data written by the current one.
+//
+// The zero value is epoch 0, which reads and writes only epoch 0; epoch
+// 0 is always represented by the zero value.
+type Epoch struct {
+	Read  []uint32 `yaml:"read"`
+	Write []uint32 `yaml:"write"`
+}
+
+// E returns the epoch represented by the given string, panicking if it
+// is not valid. It is meant for tests.
+func E(s string) Epoch {
+	e, err := ParseEpoch(s)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// ParseEpoch parses an epoch in its string form: "N", which reads and
+// writes epoch N, or "N*", which reads epochs N-1 and N and writes N.
+func ParseEpoch(s string) (Epoch, error) {
+	star := strings.HasSuffix(s, "*")
+	n, err := parseEpochNumber(strings.TrimSuffix(s, "*"))
+	if err != nil {
+		return Epoch{}, fmt.Errorf("invalid epoch %q: %v", s, err)
+	}
+	if !star {
+		return Epoch{Read: []uint32{n}, Write: []uint32{n}}.canonical(), nil
+	}
+	if n == 0 {
+		return Epoch{}, fmt.Errorf(`invalid epoch %q: 0* is an invalid epoch`, s)
+	}
+	return Epoch{Read: []uint32{n - 1, n}, Write: []uint32{n}}, nil
+}
+
+func parseEpochNumber(s string) (uint32, error) {
+	if s == "" {
+		return 0, fmt.Errorf("epoch cannot be empty")
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("epoch number cannot have leading zeros")
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("epoch number must be a non-negative integer")
+	}
+	return uint32(n), nil
+}
+
+// canonical returns the epoch with the lists defaulting to each other
+// when only one is given, and the zero value for epoch 0.
+func (e Epoch) canonical() Epoch {
+	if e.Read == nil {
+		e.Read = e.Write
+	}
+	if e.Write == nil {
+		e.Write = e.Read
+	}
+	if len(e.Read) == 1 && e.Read[0] == 0 && len(e.Write) == 1 && e.Write[0] == 0 {
+		return Epoch{}
+	}
+	return e
+}
+
+// readList returns the epochs that can be read, with epoch 0 explicit.
+func (e Epoch) readList() []uint32 {
+	if len(e.Read) == 0 {
+		return []uint32{0}
+	}
+	return e.Read
+}
+
+// writeList returns the epochs that can be written, with epoch 0 explicit.
+func (e Epoch) writeList() []uint32 {
+	if len(e.Write) == 0 {
+		return []uint32{0}
+	}
+	return e.Write
+}
+
+func validateEpochList(name string, l []uint32) error {
+	if len(l) > maxEpochLen {
+		return fmt.Errorf("%s list cannot have more than %d entries", name, maxEpochLen)
+	}
+	for i := 1; i < len(l); i++ {
+		if l[i] <= l[i-1] {
+			return fmt.Errorf("%s list must be a strictly increasing sequence", name)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the epoch is well formed.
+func (e Epoch) Validate() error {
+	if e.Read != nil && len(e.Read) == 0 || e.Write != nil && len(e.Write) == 0 {
+		return fmt.Errorf("invalid epoch %s: read and write lists cannot be empty", e)
+	}
+	if err := validateEpochList("read", e.Read); err != nil {
+		return fmt.Errorf("invalid epoch %s: %v", e, err)
+	}
+	if err := validateEpochList("write", e.Write); err != nil {
+		return fmt.Errorf("invalid epoch %s: %v", e, err)
+	}
+	read := e.readList()
+	for _, w := range e.writeList() {
+		if !epochListContains(read, w) {
+			return fmt.Errorf("invalid epoch %s: cannot write epoch %d that is not read", e, w)
+		}
+	}
+	return nil
+}
+
+func epochListContains(l []uint32, n uint32) bool {
+	for _, m := range l {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRead returns whether a revision with this epoch can read the data
+// written by a revision with the other epoch.
+func (e Epoch) CanRead(other Epoch) bool {
+	read := e.readList()
+	for _, w := range other.writeList() {
+		if epochListContains(read, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the shorthand form of the epoch when it has one, and
+// its structured form otherwise.
+func (e Epoch) String() string {
+	read, write := e.readList(), e.writeList()
+	if len(write) == 1 {
+		n := write[0]
+		if len(read) == 1 && read[0] == n {
+			return strconv.FormatUint(uint64(n), 10)
+		}
+		if n > 0 && len(read) == 2 && read[0] == n-1 && read[1] == n {
+			return strconv.FormatUint(uint64(n), 10) + "*"
+		}
+	}
+	bs, _ := e.MarshalJSON()
+	return string(bs)
+}
+
+type structuredEpoch struct {
+	Read  []uint32 `json:"read" yaml:"read"`
+	Write []uint32 `json:"write" yaml:"write"`
+}
+
+// MarshalJSON implements json.Marshaler, always using the structured form.
+func (e Epoch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&structuredEpoch{Read: e.readList(), Write: e.writeList()})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both the
+// shorthand and the structured forms.
+func (e *Epoch) UnmarshalJSON(bs []byte) error {
+	var s string
+	if err := json.Unmarshal(bs, &s); err == nil {
+		return e.fromString(s)
+	}
+	var structured structuredEpoch
+	if err := json.Unmarshal(bs, &structured); err != nil {
+		return fmt.Errorf("invalid epoch: %v", err)
+	}
+	return e.fromStructured(&structured)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, accepting both the
+// shorthand and the structured forms.
+func (e *Epoch) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		return e.fromString(s)
+	}
+	var structured structuredEpoch
+	if err := unmarshal(&structured); err != nil {
+		return fmt.Errorf("invalid epoch: %v", err)
+	}
+	return e.fromStructured(&structured)
+}
+
+func (e *Epoch) fromString(s string) error {
+	if s == "" {
+		*e = Epoch{}
+		return nil
+	}
+	parsed, err := ParseEpoch(s)
+	if err != nil {
+		return err
+	}
+	*e = parsed
+	return nil
+}
+
+func (e *Epoch) fromStructured(structured *structuredEpoch) error {
+	if structured.Read == nil && structured.Write == nil {
+		return fmt.Errorf("invalid epoch: read and write lists cannot both be missing")
+	}
+	parsed := Epoch{Read: structured.Read, Write: structured.Write}.canonical()
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	*e = parsed
+	return nil
+}
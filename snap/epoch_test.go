@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type epochSuite struct{}
+
+var _ = Suite(&epochSuite{})
+
+func (s epochSuite) TestParseEpoch(c *C) {
+	for _, t := range []struct {
+		s string
+		e snap.Epoch
+	}{
+		{"0", snap.Epoch{}},
+		{"1", snap.Epoch{Read: []uint32{1}, Write: []uint32{1}}},
+		{"1*", snap.Epoch{Read: []uint32{0, 1}, Write: []uint32{1}}},
+		{"400*", snap.Epoch{Read: []uint32{399, 400}, Write: []uint32{400}}},
+		{"1234", snap.Epoch{Read: []uint32{1234}, Write: []uint32{1234}}},
+	} {
+		e, err := snap.ParseEpoch(t.s)
+		c.Assert(err, IsNil, Commentf(t.s))
+		c.Check(e, DeepEquals, t.e, Commentf(t.s))
+		c.Check(e.String(), Equals, t.s)
+	}
+
+	for _, s := range []string{
+		"", "0*", "_", "1-", "1+", "-1", "+1", "-1*", "a", "1a", "1**", "01", "4294967296",
+	} {
+		_, err := snap.ParseEpoch(s)
+		c.Check(err, ErrorMatches, `invalid epoch ".*": .*`, Commentf(s))
+	}
+}
+
+func (s epochSuite) TestValidate(c *C) {
+	for _, e := range []snap.Epoch{
+		{},
+		{Read: []uint32{0}, Write: []uint32{0}},
+		{Read: []uint32{1, 2, 3}, Write: []uint32{2, 3}},
+		{Read: []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, Write: []uint32{9}},
+	} {
+		c.Check(e.Validate(), IsNil, Commentf("%s", e))
+	}
+
+	for _, t := range []struct {
+		e   snap.Epoch
+		err string
+	}{
+		{snap.Epoch{Read: []uint32{}, Write: []uint32{1}}, `.*: read and write lists cannot be empty`},
+		{snap.Epoch{Read: []uint32{2, 1}, Write: []uint32{1}}, `.*: read list must be a strictly increasing sequence`},
+		{snap.Epoch{Read: []uint32{1, 2}, Write: []uint32{2, 2}}, `.*: write list must be a strictly increasing sequence`},
+		{snap.Epoch{Read: []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, Write: []uint32{9}}, `.*: read list cannot have more than 10 entries`},
+		{snap.Epoch{Read: []uint32{1}, Write: []uint32{2}}, `.*: cannot write epoch 2 that is not read`},
+	} {
+		c.Check(t.e.Validate(), ErrorMatches, `invalid epoch `+t.err, Commentf("%s", t.e))
+	}
+}
+
+func (s epochSuite) TestString(c *C) {
+	c.Check(snap.Epoch{}.String(), Equals, "0")
+	c.Check(snap.Epoch{Read: []uint32{1, 2}, Write: []uint32{1, 2}}.String(), Equals, `{"read":[1,2],"write":[1,2]}`)
+}
+
+func (s epochSuite) TestJSON(c *C) {
+	for _, t := range []struct {
+		json string
+		e    snap.Epoch
+	}{
+		{`"0"`, snap.Epoch{}},
+		{`""`, snap.Epoch{}},
+		{`"1*"`, snap.E("1*")},
+		{`{"read":[0]}`, snap.Epoch{}},
+		{`{"read":[1,2],"write":[2]}`, snap.E("2*")},
+		{`{"write":[3]}`, snap.E("3")},
+	} {
+		var e snap.Epoch
+		c.Assert(json.Unmarshal([]byte(t.json), &e), IsNil, Commentf(t.json))
+		c.Check(e, DeepEquals, t.e, Commentf(t.json))
+	}
+
+	for _, t := range []struct {
+		json string
+		err  string
+	}{
+		{`"0*"`, `invalid epoch "0\*": 0\* is an invalid epoch`},
+		{`{}`, `invalid epoch: read and write lists cannot both be missing`},
+		{`{"read":[1],"write":[2]}`, `invalid epoch .*: cannot write epoch 2 that is not read`},
+		{`42`, `invalid epoch: .*`},
+	} {
+		var e snap.Epoch
+		c.Check(json.Unmarshal([]byte(t.json), &e), ErrorMatches, t.err, Commentf(t.json))
+	}
+
+	bs, err := json.Marshal(snap.Epoch{})
+	c.Assert(err, IsNil)
+	c.Check(string(bs), Equals, `{"read":[0],"write":[0]}`)
+	bs, err = json.Marshal(snap.E("1*"))
+	c.Assert(err, IsNil)
+	c.Check(string(bs), Equals, `{"read":[0,1],"write":[1]}`)
+}
+
+func (s epochSuite) TestYAML(c *C) {
+	for _, t := range []struct {
+		yaml string
+		e    snap.Epoch
+	}{
+		{`epoch: 0`, snap.Epoch{}},
+		{`epoch: 1*`, snap.E("1*")},
+		{`epoch: 7`, snap.E("7")},
+		{"epoch:\n  read: [1, 2]\n  write: [2]", snap.E("2*")},
+	} {
+		var v struct{ Epoch snap.Epoch }
+		c.Assert(yaml.Unmarshal([]byte(t.yaml), &v), IsNil, Commentf(t.yaml))
+		c.Check(v.Epoch, DeepEquals, t.e, Commentf(t.yaml))
+	}
+
+	var v struct{ Epoch snap.Epoch }
+	c.Check(yaml.Unmarshal([]byte("epoch:\n  read: [3]\n  write: [1]"), &v), ErrorMatches, `invalid epoch .*: cannot write epoch 1 that is not read`)
+}
+
+func (s epochSuite) TestCanRead(c *C) {
+	for _, t := range []struct {
+		a, b    snap.Epoch
+		canRead bool
+	}{
+		{snap.Epoch{}, snap.Epoch{}, true},
+		{snap.E("1*"), snap.Epoch{}, true},
+		{snap.Epoch{}, snap.E("1*"), false},
+		{snap.E("1"), snap.Epoch{}, false},
+		{snap.E("2*"), snap.E("1"), true},
+		{snap.E("2*"), snap.E("1*"), true},
+		{snap.E("3*"), snap.E("1*"), false},
+		{snap.Epoch{Read: []uint32{1, 2, 3}, Write: []uint32{3}}, snap.Epoch{Read: []uint32{1, 2}, Write: []uint32{1, 2}}, true},
+	} {
+		c.Check(t.a.CanRead(t.b), Equals, t.canRead, Commentf("%s reading %s", t.a, t.b))
+	}
+}
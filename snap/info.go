@@ -147,6 +147,10 @@ type Info struct {
 	Architectures []string
 	Assumes       []string
 
+	// Base is the name of the snap providing the runtime of this snap,
+	// the core snap when empty.
+	Base string
+
 	OriginalSummary     string
 	OriginalDescription string
 
@@ -154,7 +158,7 @@ type Info struct {
 
 	LicenseAgreement string
 	LicenseVersion   string
-	Epoch            string
+	Epoch            Epoch
 	Confinement      ConfinementType
 	Apps             map[string]*AppInfo
 	LegacyAliases    map[string]*AppInfo // FIXME: eventually drop this
@@ -162,6 +166,10 @@ type Info struct {
 	Plugs            map[string]*PlugInfo
 	Slots            map[string]*SlotInfo
 
+	// Layout maps paths in the mount namespace of the snap to the way
+	// they should be populated.
+	Layout map[string]*Layout
+
 	// The information in all the remaining fields is not sourced from the snap blob itself.
 	SideInfo
 
@@ -194,7 +202,7 @@ type ChannelSnapInfo struct {
 	Confinement ConfinementType `json:"confinement"`
 	Version     string          `json:"version"`
 	Channel     string          `json:"channel"`
-	Epoch       string          `json:"epoch"`
+	Epoch       Epoch           `json:"epoch"`
 	Size        int64           `json:"size"`
 }
 
@@ -272,6 +280,22 @@ func (s *Info) CommonDataHomeDir() string {
 	return filepath.Join(dirs.SnapDataHomeGlob, s.Name(), "common")
 }
 
+// ExpandSnapVariables expands the $SNAP, $SNAP_DATA and $SNAP_COMMON
+// variables in the given path. Other variables are left untouched.
+func (s *Info) ExpandSnapVariables(path string) string {
+	return os.Expand(path, func(v string) string {
+		switch v {
+		case "SNAP":
+			return s.MountDir()
+		case "SNAP_DATA":
+			return s.DataDir()
+		case "SNAP_COMMON":
+			return s.CommonDataDir()
+		}
+		return "${" + v + "}"
+	})
+}
+
 // UserXdgRuntimeDir returns the XDG_RUNTIME_DIR directory of the snap for a particular user.
 func (s *Info) UserXdgRuntimeDir(euid int) string {
 	return filepath.Join("/run/user", fmt.Sprintf("%d/snap.%s", euid, s.Name()))
@@ -393,10 +417,13 @@ type AppInfo struct {
 
 	Daemon          string
 	StopTimeout     timeout.Timeout
+	WatchdogTimeout timeout.Timeout
 	StopCommand     string
 	ReloadCommand   string
 	PostStopCommand string
 	RestartCond     systemd.RestartCondition
+	StopMode        StopModeType
+	RefreshMode     string
 	Completer       string
 
 	// TODO: this should go away once we have more plumbing and can change
@@ -408,6 +435,157 @@ type AppInfo struct {
 	Slots map[string]*SlotInfo
 
 	Environment strutil.OrderedMap
+
+	Timer   *TimerInfo
+	Sockets map[string]*SocketInfo
+
+	// Before and After name the services of the same snap that this
+	// service must be started before and after, respectively.
+	Before []string
+	After  []string
+}
+
+// StopModeType is the type of the "stop-mode" of a service: the signal
+// it is sent to stop, and whether that is sent to all of its processes
+// ("-all" suffix) or only to its main one.
+type StopModeType string
+
+// Validate checks that the stop mode is known.
+func (st StopModeType) Validate() error {
+	switch st {
+	case "", "sigterm", "sigterm-all", "sighup", "sighup-all", "sigusr1", "sigusr1-all", "sigusr2", "sigusr2-all":
+		return nil
+	}
+	return fmt.Errorf(`"stop-mode" field contains invalid value %q`, st)
+}
+
+// KillAll returns whether the stop signal is sent to all the processes
+// of the service rather than only to its main process.
+func (st StopModeType) KillAll() bool {
+	return st == "" || strings.HasSuffix(string(st), "-all")
+}
+
+// KillSignal returns the name of the signal sent to stop the service,
+// or the empty string for the default one.
+func (st StopModeType) KillSignal() string {
+	return strings.ToUpper(strings.TrimSuffix(string(st), "-all"))
+}
+
+// TimerInfo provides information about the timer that activates a
+// service.
+type TimerInfo struct {
+	App *AppInfo
+
+	// Timer is the schedule of the timer, in the format understood by
+	// timeutil.ParseSchedule. The service is activated once in each
+	// window of the schedule, at a random time within the shortest
+	// window length after its start.
+	Timer string
+}
+
+// SocketInfo provides information about a socket that activates a
+// service.
+type SocketInfo struct {
+	App *AppInfo
+
+	Name         string
+	ListenStream string
+	SocketMode   os.FileMode
+}
+
+// SocketName returns the systemd socket name for the socket.
+func (socket *SocketInfo) SocketName() string {
+	return socket.App.SecurityTag() + "." + socket.Name + ".socket"
+}
+
+// File returns the systemd socket file path for the socket.
+func (socket *SocketInfo) File() string {
+	return filepath.Join(dirs.SnapServicesDir, socket.SocketName())
+}
+
+// IsPath returns whether the socket listens on a file system path
+// rather than on a network port.
+func (socket *SocketInfo) IsPath() bool {
+	return strings.HasPrefix(socket.ListenStream, "/") || strings.HasPrefix(socket.ListenStream, "$")
+}
+
+// AppInfoBySnapApp supports sorting the given slice of app infos by
+// (snap name, app name).
+type AppInfoBySnapApp []*AppInfo
+
+func (a AppInfoBySnapApp) Len() int      { return len(a) }
+func (a AppInfoBySnapApp) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a AppInfoBySnapApp) Less(i, j int) bool {
+	iName := a[i].Snap.Name()
+	jName := a[j].Snap.Name()
+	if iName == jName {
+		return a[i].Name < a[j].Name
+	}
+	return iName < jName
+}
+
+// SortServices sorts the given services such that starting them in the
+// returned order satisfies their Before and After declarations, breaking
+// ties by (snap name, app name). Declarations naming services that are
+// not given are ignored. It returns an error if the declarations form a
+// cycle.
+func SortServices(apps []*AppInfo) ([]*AppInfo, error) {
+	byName := make(map[string]*AppInfo, len(apps))
+	for _, app := range apps {
+		byName[JoinSnapApp(app.Snap.Name(), app.Name)] = app
+	}
+	lookup := func(app *AppInfo, name string) *AppInfo {
+		return byName[JoinSnapApp(app.Snap.Name(), name)]
+	}
+
+	predecessors := make(map[*AppInfo]int, len(apps))
+	successors := make(map[*AppInfo][]*AppInfo, len(apps))
+	for _, app := range apps {
+		for _, name := range app.After {
+			if other := lookup(app, name); other != nil {
+				successors[other] = append(successors[other], app)
+				predecessors[app]++
+			}
+		}
+		for _, name := range app.Before {
+			if other := lookup(app, name); other != nil {
+				successors[app] = append(successors[app], other)
+				predecessors[other]++
+			}
+		}
+	}
+
+	var ready []*AppInfo
+	for _, app := range apps {
+		if predecessors[app] == 0 {
+			ready = append(ready, app)
+		}
+	}
+	sorted := make([]*AppInfo, 0, len(apps))
+	for len(ready) > 0 {
+		sort.Sort(AppInfoBySnapApp(ready))
+		app := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, app)
+		for _, next := range successors[app] {
+			predecessors[next]--
+			if predecessors[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+
+	if len(sorted) != len(apps) {
+		var cycle []string
+		for _, app := range apps {
+			if predecessors[app] > 0 {
+				cycle = append(cycle, JoinSnapApp(app.Snap.Name(), app.Name))
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("cannot order applications %s: before/after cycle detected", strings.Join(cycle, ", "))
+	}
+	return sorted, nil
 }
 
 // ScreenshotInfo provides information about a screenshot.
@@ -417,6 +595,19 @@ type ScreenshotInfo struct {
 	Height int64
 }
 
+// Layout describes a single element of the layout section of a snap.
+//
+// Exactly one of Bind, BindFile, Type and Symlink is set.
+type Layout struct {
+	Snap *Info
+
+	Path     string
+	Bind     string
+	BindFile string
+	Type     string
+	Symlink  string
+}
+
 // HookInfo provides information about a hook.
 type HookInfo struct {
 	Snap *Info
@@ -494,6 +685,18 @@ func (app *AppInfo) ServiceSocketFile() string {
 	return filepath.Join(dirs.SnapServicesDir, app.SecurityTag()+".socket")
 }
 
+// ServiceTimerName returns the systemd timer name for the timer
+// activated daemon app.
+func (app *AppInfo) ServiceTimerName() string {
+	return app.SecurityTag() + ".timer"
+}
+
+// ServiceTimerFile returns the systemd timer file path for the timer
+// activated daemon app.
+func (app *AppInfo) ServiceTimerFile() string {
+	return filepath.Join(dirs.SnapServicesDir, app.ServiceTimerName())
+}
+
 // Env returns the app specific environment overrides
 func (app *AppInfo) Env() []string {
 	env := []string{}
@@ -21,6 +21,7 @@ package snap
 
 import (
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 
@@ -37,17 +38,19 @@ type snapYaml struct {
 	Type             Type                   `yaml:"type"`
 	Architectures    []string               `yaml:"architectures,omitempty"`
 	Assumes          []string               `yaml:"assumes"`
+	Base             string                 `yaml:"base,omitempty"`
 	Description      string                 `yaml:"description"`
 	Summary          string                 `yaml:"summary"`
 	LicenseAgreement string                 `yaml:"license-agreement,omitempty"`
 	LicenseVersion   string                 `yaml:"license-version,omitempty"`
-	Epoch            string                 `yaml:"epoch,omitempty"`
+	Epoch            Epoch                  `yaml:"epoch,omitempty"`
 	Confinement      ConfinementType        `yaml:"confinement,omitempty"`
 	Environment      strutil.OrderedMap     `yaml:"environment,omitempty"`
 	Plugs            map[string]interface{} `yaml:"plugs,omitempty"`
 	Slots            map[string]interface{} `yaml:"slots,omitempty"`
 	Apps             map[string]appYaml     `yaml:"apps,omitempty"`
 	Hooks            map[string]hookYaml    `yaml:"hooks,omitempty"`
+	Layout           map[string]layoutYaml  `yaml:"layout,omitempty"`
 }
 
 type appYaml struct {
@@ -61,8 +64,12 @@ type appYaml struct {
 	ReloadCommand   string          `yaml:"reload-command,omitempty"`
 	PostStopCommand string          `yaml:"post-stop-command,omitempty"`
 	StopTimeout     timeout.Timeout `yaml:"stop-timeout,omitempty"`
+	WatchdogTimeout timeout.Timeout `yaml:"watchdog-timeout,omitempty"`
 	Completer       string          `yaml:"completer,omitempty"`
 
+	StopMode    StopModeType `yaml:"stop-mode,omitempty"`
+	RefreshMode string       `yaml:"refresh-mode,omitempty"`
+
 	RestartCond systemd.RestartCondition `yaml:"restart-condition,omitempty"`
 	SlotNames   []string                 `yaml:"slots,omitempty"`
 	PlugNames   []string                 `yaml:"plugs,omitempty"`
@@ -70,12 +77,31 @@ type appYaml struct {
 	BusName string `yaml:"bus-name,omitempty"`
 
 	Environment strutil.OrderedMap `yaml:"environment,omitempty"`
+
+	Timer string `yaml:"timer,omitempty"`
+
+	Sockets map[string]socketsYaml `yaml:"sockets,omitempty"`
+
+	Before []string `yaml:"before,omitempty"`
+	After  []string `yaml:"after,omitempty"`
+}
+
+type socketsYaml struct {
+	ListenStream string      `yaml:"listen-stream,omitempty"`
+	SocketMode   os.FileMode `yaml:"socket-mode,omitempty"`
 }
 
 type hookYaml struct {
 	PlugNames []string `yaml:"plugs,omitempty"`
 }
 
+type layoutYaml struct {
+	Bind     string `yaml:"bind,omitempty"`
+	BindFile string `yaml:"bind-file,omitempty"`
+	Type     string `yaml:"type,omitempty"`
+	Symlink  string `yaml:"symlink,omitempty"`
+}
+
 // InfoFromSnapYaml creates a new info based on the given snap.yaml data
 func InfoFromSnapYaml(yamlData []byte) (*Info, error) {
 	var y snapYaml
@@ -113,6 +139,9 @@ func InfoFromSnapYaml(yamlData []byte) (*Info, error) {
 	}
 	setHooksFromSnapYaml(y, snap)
 
+	// Collect the layout
+	setLayoutFromSnapYaml(y, snap)
+
 	// Bind unbound plugs to all apps and hooks
 	bindUnboundPlugs(globalPlugNames, snap)
 
@@ -138,10 +167,6 @@ func infoSkeletonFromSnapYaml(y snapYaml) *Info {
 	if y.Type != "" {
 		typ = y.Type
 	}
-	epoch := "0"
-	if y.Epoch != "" {
-		epoch = y.Epoch
-	}
 	confinement := StrictConfinement
 	if y.Confinement != "" {
 		confinement = y.Confinement
@@ -154,11 +179,12 @@ func infoSkeletonFromSnapYaml(y snapYaml) *Info {
 		Type:                typ,
 		Architectures:       architectures,
 		Assumes:             y.Assumes,
+		Base:                y.Base,
 		OriginalDescription: y.Description,
 		OriginalSummary:     y.Summary,
 		LicenseAgreement:    y.LicenseAgreement,
 		LicenseVersion:      y.LicenseVersion,
-		Epoch:               epoch,
+		Epoch:               y.Epoch,
 		Confinement:         confinement,
 		Apps:                make(map[string]*AppInfo),
 		LegacyAliases:       make(map[string]*AppInfo),
@@ -228,6 +254,9 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info) error {
 			Command:         yApp.Command,
 			Daemon:          yApp.Daemon,
 			StopTimeout:     yApp.StopTimeout,
+			WatchdogTimeout: yApp.WatchdogTimeout,
+			StopMode:        yApp.StopMode,
+			RefreshMode:     yApp.RefreshMode,
 			StopCommand:     yApp.StopCommand,
 			ReloadCommand:   yApp.ReloadCommand,
 			PostStopCommand: yApp.PostStopCommand,
@@ -235,6 +264,8 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info) error {
 			BusName:         yApp.BusName,
 			Environment:     yApp.Environment,
 			Completer:       yApp.Completer,
+			Before:          yApp.Before,
+			After:           yApp.After,
 		}
 		if len(y.Plugs) > 0 || len(yApp.PlugNames) > 0 {
 			app.Plugs = make(map[string]*PlugInfo)
@@ -242,6 +273,23 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info) error {
 		if len(y.Slots) > 0 || len(yApp.SlotNames) > 0 {
 			app.Slots = make(map[string]*SlotInfo)
 		}
+		if yApp.Timer != "" {
+			app.Timer = &TimerInfo{
+				App:   app,
+				Timer: yApp.Timer,
+			}
+		}
+		if len(yApp.Sockets) > 0 {
+			app.Sockets = make(map[string]*SocketInfo, len(yApp.Sockets))
+			for name, data := range yApp.Sockets {
+				app.Sockets[name] = &SocketInfo{
+					App:          app,
+					Name:         name,
+					ListenStream: data.ListenStream,
+					SocketMode:   data.SocketMode,
+				}
+			}
+		}
 		snap.Apps[appName] = app
 		for _, alias := range app.LegacyAliases {
 			if snap.LegacyAliases[alias] != nil {
@@ -319,6 +367,23 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info) {
 	}
 }
 
+func setLayoutFromSnapYaml(y snapYaml, snap *Info) {
+	if len(y.Layout) == 0 {
+		return
+	}
+	snap.Layout = make(map[string]*Layout, len(y.Layout))
+	for path, l := range y.Layout {
+		snap.Layout[path] = &Layout{
+			Snap:     snap,
+			Path:     path,
+			Bind:     l.Bind,
+			BindFile: l.BindFile,
+			Type:     l.Type,
+			Symlink:  l.Symlink,
+		}
+	}
+}
+
 func bindUnboundPlugs(plugNames []string, snap *Info) error {
 	for _, plugName := range plugNames {
 		plug, ok := snap.Plugs[plugName]
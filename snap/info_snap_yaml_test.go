@@ -818,6 +818,29 @@ hooks:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalLayout(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+layout:
+    /usr/share/foo:
+        bind: $SNAP/usr/share/foo
+    /etc/foo.conf:
+        bind-file: $SNAP_DATA/foo.conf
+    /var/cache/foo:
+        type: tmpfs
+    /usr/lib/foo:
+        symlink: $SNAP/lib/foo
+`))
+	c.Assert(err, IsNil)
+	c.Check(info.Layout, DeepEquals, map[string]*snap.Layout{
+		"/usr/share/foo": {Snap: info, Path: "/usr/share/foo", Bind: "$SNAP/usr/share/foo"},
+		"/etc/foo.conf":  {Snap: info, Path: "/etc/foo.conf", BindFile: "$SNAP_DATA/foo.conf"},
+		"/var/cache/foo": {Snap: info, Path: "/var/cache/foo", Type: "tmpfs"},
+		"/usr/lib/foo":   {Snap: info, Path: "/usr/lib/foo", Symlink: "$SNAP/lib/foo"},
+	})
+}
+
 func (s *YamlSuite) TestUnmarshalUnsupportedHook(c *C) {
 	s.restore()
 	hookType := snap.NewHookType(regexp.MustCompile("not-test-hook"))
@@ -1088,7 +1111,7 @@ slots:
 	c.Check(info.Name(), Equals, "foo")
 	c.Check(info.Version, Equals, "1.2")
 	c.Check(info.Type, Equals, snap.TypeApp)
-	c.Check(info.Epoch, Equals, "1*")
+	c.Check(info.Epoch, DeepEquals, snap.E("1*"))
 	c.Check(info.Confinement, Equals, snap.DevModeConfinement)
 	c.Check(info.Summary(), Equals, "foo app")
 	c.Check(info.Description(), Equals, "Foo provides useful services\n")
@@ -1220,13 +1243,35 @@ version: 1.0
 	c.Assert(info.Type, Equals, snap.TypeApp)
 }
 
+func (s *YamlSuite) TestSnapYamlBase(c *C) {
+	y := []byte(`name: binary
+version: 1.0
+base: some-base
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Assert(info.Base, Equals, "some-base")
+}
+
 func (s *YamlSuite) TestSnapYamlEpochDefault(c *C) {
 	y := []byte(`name: binary
 version: 1.0
 `)
 	info, err := snap.InfoFromSnapYaml(y)
 	c.Assert(err, IsNil)
-	c.Assert(info.Epoch, Equals, "0")
+	c.Assert(info.Epoch, DeepEquals, snap.Epoch{})
+}
+
+func (s *YamlSuite) TestSnapYamlEpochStructured(c *C) {
+	y := []byte(`name: binary
+version: 1.0
+epoch:
+  read: [1, 2]
+  write: [2]
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Assert(info.Epoch, DeepEquals, snap.E("2*"))
 }
 
 func (s *YamlSuite) TestSnapYamlConfinementDefault(c *C) {
@@ -1333,6 +1378,9 @@ apps:
    post-stop-command: post-stop-cmd
    restart-condition: on-abnormal
    bus-name: busName
+   watchdog-timeout: 30s
+   stop-mode: sighup-all
+   refresh-mode: endure
 `)
 	info, err := snap.InfoFromSnapYaml(y)
 	c.Assert(err, IsNil)
@@ -1347,10 +1395,86 @@ apps:
 			StopCommand:     "stop-cmd",
 			PostStopCommand: "post-stop-cmd",
 			BusName:         "busName",
+			WatchdogTimeout: timeout.Timeout(30 * time.Second),
+			StopMode:        "sighup-all",
+			RefreshMode:     "endure",
 		},
 	})
 }
 
+func (s *YamlSuite) TestSnapYamlAppTimer(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ svc:
+   command: svc1
+   daemon: oneshot
+   timer: mon@10:00-12:00
+ app:
+   command: app1
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	app := info.Apps["svc"]
+	c.Assert(app.Timer, NotNil)
+	c.Check(app.Timer.App, Equals, app)
+	c.Check(app.Timer.Timer, Equals, "mon@10:00-12:00")
+	c.Check(info.Apps["app"].Timer, IsNil)
+}
+
+func (s *YamlSuite) TestSnapYamlAppSockets(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ svc:
+   command: svc1
+   daemon: simple
+   sockets:
+     sock1:
+       listen-stream: $SNAP_DATA/sock1.socket
+       socket-mode: 0666
+     sock2:
+       listen-stream: 8080
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	app := info.Apps["svc"]
+	c.Check(app.Sockets, DeepEquals, map[string]*snap.SocketInfo{
+		"sock1": {
+			App:          app,
+			Name:         "sock1",
+			ListenStream: "$SNAP_DATA/sock1.socket",
+			SocketMode:   0666,
+		},
+		"sock2": {
+			App:          app,
+			Name:         "sock2",
+			ListenStream: "8080",
+		},
+	})
+	c.Check(app.Sockets["sock1"].IsPath(), Equals, true)
+	c.Check(app.Sockets["sock2"].IsPath(), Equals, false)
+	c.Check(app.Sockets["sock1"].SocketName(), Equals, "snap.wat.svc.sock1.socket")
+}
+
+func (s *YamlSuite) TestSnapYamlAppOrder(c *C) {
+	y := []byte(`name: wat
+version: 42
+apps:
+ db:
+   daemon: simple
+   before: [api]
+ api:
+   daemon: simple
+   after: [db, cache]
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Apps["db"].Before, DeepEquals, []string{"api"})
+	c.Check(info.Apps["db"].After, IsNil)
+	c.Check(info.Apps["api"].After, DeepEquals, []string{"db", "cache"})
+}
+
 func (s *YamlSuite) TestSnapYamlGlobalEnvironment(c *C) {
 	y := []byte(`
 name: foo
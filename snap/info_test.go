@@ -246,7 +246,7 @@ confinement: devmode`
 	c.Check(info.Version, Equals, "1.0")
 	c.Check(info.Type, Equals, snap.TypeApp)
 	c.Check(info.Revision, Equals, snap.R(0))
-	c.Check(info.Epoch, Equals, "1*")
+	c.Check(info.Epoch, DeepEquals, snap.E("1*"))
 	c.Check(info.Confinement, Equals, snap.DevModeConfinement)
 	c.Check(info.NeedsDevMode(), Equals, true)
 	c.Check(info.NeedsClassic(), Equals, false)
@@ -288,7 +288,7 @@ type: app`
 	c.Check(info.Version, Equals, "1.0")
 	c.Check(info.Type, Equals, snap.TypeApp)
 	c.Check(info.Revision, Equals, snap.R(0))
-	c.Check(info.Epoch, Equals, "0") // Defaults to 0
+	c.Check(info.Epoch.String(), Equals, "0") // Defaults to 0
 	c.Check(info.Confinement, Equals, snap.StrictConfinement)
 	c.Check(info.NeedsDevMode(), Equals, false)
 }
@@ -710,3 +710,92 @@ apps:
 	c.Check(info.Apps["app1"].IsService(), Equals, false)
 	c.Check(info.Apps["app1"].IsService(), Equals, false)
 }
+
+func (s *infoSuite) TestAppInfoBySnapApp(c *C) {
+	snapA := &snap.Info{SuggestedName: "a"}
+	snapB := &snap.Info{SuggestedName: "b"}
+	a2 := &snap.AppInfo{Snap: snapA, Name: "2"}
+	a1 := &snap.AppInfo{Snap: snapA, Name: "1"}
+	b1 := &snap.AppInfo{Snap: snapB, Name: "1"}
+
+	apps := []*snap.AppInfo{b1, a2, a1}
+	sort.Sort(snap.AppInfoBySnapApp(apps))
+	c.Check(apps, DeepEquals, []*snap.AppInfo{a1, a2, b1})
+}
+
+func (s *infoSuite) TestSortServices(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: foo
+apps:
+ db:
+  daemon: simple
+ api:
+  daemon: simple
+  after: [db]
+ cache:
+  daemon: simple
+  before: [api]
+ web:
+  daemon: simple
+  after: [api]
+ other:
+  daemon: simple
+`))
+	c.Assert(err, IsNil)
+	other := &snap.Info{SuggestedName: "bar"}
+	barDB := &snap.AppInfo{Snap: other, Name: "db", Daemon: "simple", Before: []string{"api"}}
+
+	apps := append(info.Services(), barDB)
+	sorted, err := snap.SortServices(apps)
+	c.Assert(err, IsNil)
+	// before/after only apply within a snap, ties are broken by name
+	c.Check(sorted, DeepEquals, []*snap.AppInfo{
+		barDB,
+		info.Apps["cache"],
+		info.Apps["db"],
+		info.Apps["api"],
+		info.Apps["other"],
+		info.Apps["web"],
+	})
+}
+
+func (s *infoSuite) TestSortServicesCycle(c *C) {
+	info, err := snap.InfoFromSnapYaml([]byte(`name: foo
+apps:
+ one:
+  daemon: simple
+  after: [three]
+ two:
+  daemon: simple
+  after: [one]
+ three:
+  daemon: simple
+  after: [two]
+ four:
+  daemon: simple
+  after: [one]
+`))
+	c.Assert(err, IsNil)
+
+	_, err = snap.SortServices(info.Services())
+	c.Check(err, ErrorMatches, `cannot order applications foo.four, foo.one, foo.three, foo.two: before/after cycle detected`)
+}
+
+func (s *infoSuite) TestStopModeTypeKillMode(c *C) {
+	for _, t := range []struct {
+		stopMode   string
+		killAll    bool
+		killSignal string
+	}{
+		{"", true, ""},
+		{"sigterm", false, "SIGTERM"},
+		{"sigterm-all", true, "SIGTERM"},
+		{"sighup", false, "SIGHUP"},
+		{"sighup-all", true, "SIGHUP"},
+		{"sigusr1", false, "SIGUSR1"},
+		{"sigusr2-all", true, "SIGUSR2"},
+	} {
+		sm := snap.StopModeType(t.stopMode)
+		c.Check(sm.KillAll(), Equals, t.killAll, Commentf(t.stopMode))
+		c.Check(sm.KillSignal(), Equals, t.killSignal, Commentf(t.stopMode))
+	}
+}
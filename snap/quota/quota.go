@@ -0,0 +1,122 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package quota defines the resource quota groups that the services of
+// snaps can be placed in.
+package quota
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// MinMemoryLimit is the smallest memory limit a group can have.
+const MinMemoryLimit = 4 * 1024 * 1024
+
+var validGroupName = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+
+// ValidateGroupName checks whether the given name is a valid quota group
+// name.
+func ValidateGroupName(name string) error {
+	if !validGroupName.MatchString(name) {
+		return fmt.Errorf("invalid quota group name: %q", name)
+	}
+	return nil
+}
+
+// Group is a quota group: the services of its snaps share the resource
+// limits of the group.
+type Group struct {
+	Name string `json:"name"`
+	// MemoryLimit is the maximum memory in bytes the group can use, or 0
+	// if not limited.
+	MemoryLimit uint64 `json:"memory-limit,omitempty"`
+	// CPULimit is the maximum CPU time the group can use, as a percentage
+	// of a single CPU, or 0 if not limited.
+	CPULimit int `json:"cpu-limit,omitempty"`
+	// Snaps are the names of the snaps in the group.
+	Snaps []string `json:"snaps,omitempty"`
+}
+
+// Validate checks the group for correctness.
+func (grp *Group) Validate() error {
+	if err := ValidateGroupName(grp.Name); err != nil {
+		return err
+	}
+	if grp.MemoryLimit == 0 && grp.CPULimit == 0 {
+		return fmt.Errorf("quota group %q must have a memory or CPU limit", grp.Name)
+	}
+	if grp.MemoryLimit != 0 && grp.MemoryLimit < MinMemoryLimit {
+		return fmt.Errorf("memory limit of quota group %q is too small: must be at least %s", grp.Name, strutil.SizeToStr(MinMemoryLimit))
+	}
+	if grp.CPULimit < 0 {
+		return fmt.Errorf("CPU limit of quota group %q cannot be negative", grp.Name)
+	}
+	seen := make(map[string]bool, len(grp.Snaps))
+	for _, name := range grp.Snaps {
+		if err := snap.ValidateName(name); err != nil {
+			return err
+		}
+		if seen[name] {
+			return fmt.Errorf("snap %q is listed twice in quota group %q", name, grp.Name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// HasSnap returns whether the given snap is in the group.
+func (grp *Group) HasSnap(snapName string) bool {
+	return strutil.ListContains(grp.Snaps, snapName)
+}
+
+// AddSnaps returns a copy of the group with the given snaps added to it.
+func (grp *Group) AddSnaps(snapNames []string) *Group {
+	newGrp := *grp
+	newGrp.Snaps = append([]string(nil), grp.Snaps...)
+	for _, name := range snapNames {
+		if !newGrp.HasSnap(name) {
+			newGrp.Snaps = append(newGrp.Snaps, name)
+		}
+	}
+	sort.Strings(newGrp.Snaps)
+	return &newGrp
+}
+
+// RemoveSnap returns a copy of the group without the given snap.
+func (grp *Group) RemoveSnap(snapName string) *Group {
+	newGrp := *grp
+	newGrp.Snaps = make([]string, 0, len(grp.Snaps))
+	for _, name := range grp.Snaps {
+		if name != snapName {
+			newGrp.Snaps = append(newGrp.Snaps, name)
+		}
+	}
+	return &newGrp
+}
+
+// SliceName returns the name of the systemd slice unit of the group.
+func (grp *Group) SliceName() string {
+	return fmt.Sprintf("snap.%s.slice", systemd.EscapeUnitNamePath(grp.Name))
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package quota_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type quotaSuite struct{}
+
+var _ = Suite(&quotaSuite{})
+
+func (s *quotaSuite) TestValidateGroupName(c *C) {
+	for _, name := range []string{"a", "group", "my-group", "group1", "1group"} {
+		c.Check(quota.ValidateGroupName(name), IsNil, Commentf(name))
+	}
+	for _, name := range []string{"", "-group", "group-", "my--group", "Group", "my.group", "my/group", "123"} {
+		c.Check(quota.ValidateGroupName(name), ErrorMatches, `invalid quota group name: ".*"`, Commentf(name))
+	}
+}
+
+func (s *quotaSuite) TestValidate(c *C) {
+	for _, t := range []struct {
+		grp *quota.Group
+		err string
+	}{
+		{&quota.Group{Name: "grp", MemoryLimit: quota.MinMemoryLimit}, ""},
+		{&quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"foo", "bar"}}, ""},
+		{&quota.Group{Name: "grp!", CPULimit: 50}, `invalid quota group name: "grp!"`},
+		{&quota.Group{Name: "grp"}, `quota group "grp" must have a memory or CPU limit`},
+		{&quota.Group{Name: "grp", MemoryLimit: 1024}, `memory limit of quota group "grp" is too small: must be at least 4MB`},
+		{&quota.Group{Name: "grp", CPULimit: -1}, `CPU limit of quota group "grp" cannot be negative`},
+		{&quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"Foo"}}, `invalid snap name: "Foo"`},
+		{&quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"foo", "foo"}}, `snap "foo" is listed twice in quota group "grp"`},
+	} {
+		err := t.grp.Validate()
+		if t.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (s *quotaSuite) TestAddSnaps(c *C) {
+	grp := &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"foo"}}
+	newGrp := grp.AddSnaps([]string{"baz", "foo", "bar"})
+	c.Check(newGrp, DeepEquals, &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"bar", "baz", "foo"}})
+	c.Check(grp.Snaps, DeepEquals, []string{"foo"})
+	c.Check(newGrp.HasSnap("bar"), Equals, true)
+	c.Check(grp.HasSnap("bar"), Equals, false)
+}
+
+func (s *quotaSuite) TestRemoveSnap(c *C) {
+	grp := &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"bar", "foo"}}
+	newGrp := grp.RemoveSnap("bar")
+	c.Check(newGrp, DeepEquals, &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"foo"}})
+	c.Check(grp.Snaps, DeepEquals, []string{"bar", "foo"})
+	c.Check(newGrp.RemoveSnap("other"), DeepEquals, newGrp)
+}
+
+func (s *quotaSuite) TestSliceName(c *C) {
+	c.Check((&quota.Group{Name: "grp"}).SliceName(), Equals, "snap.grp.slice")
+	c.Check((&quota.Group{Name: "my-grp"}).SliceName(), Equals, `snap.my\x2dgrp.slice`)
+}
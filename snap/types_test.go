@@ -77,6 +77,10 @@ func (s *typeSuite) TestJsonUnmarshalTypes(c *C) {
 	err = json.Unmarshal([]byte("\"kernel\""), &st)
 	c.Assert(err, IsNil)
 	c.Check(st, Equals, TypeKernel)
+
+	err = json.Unmarshal([]byte("\"base\""), &st)
+	c.Assert(err, IsNil)
+	c.Check(st, Equals, TypeBase)
 }
 
 func (s *typeSuite) TestJsonUnmarshalInvalidTypes(c *C) {
@@ -128,6 +132,10 @@ func (s *typeSuite) TestYamlUnmarshalTypes(c *C) {
 	err = yaml.Unmarshal([]byte("kernel"), &st)
 	c.Assert(err, IsNil)
 	c.Check(st, Equals, TypeKernel)
+
+	err = yaml.Unmarshal([]byte("base"), &st)
+	c.Assert(err, IsNil)
+	c.Check(st, Equals, TypeBase)
 }
 
 func (s *typeSuite) TestYamlUnmarshalInvalidTypes(c *C) {
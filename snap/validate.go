@@ -21,12 +21,18 @@ package snap
 
 import (
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 // Regular expression describing correct identifiers.
 var validSnapName = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
-var validEpoch = regexp.MustCompile("^(?:0|[1-9][0-9]*[*]?)$")
 var validHookName = regexp.MustCompile("^[a-z](?:-?[a-z0-9])*$")
 
 // ValidateName checks if a string can be used as a snap name.
@@ -38,11 +44,20 @@ func ValidateName(name string) error {
 	return nil
 }
 
-// ValidateEpoch checks if a string can be used as a snap epoch.
-func ValidateEpoch(epoch string) error {
-	valid := validEpoch.MatchString(epoch)
-	if !valid {
-		return fmt.Errorf("invalid snap epoch: %q", epoch)
+// validateBase checks the base declared by the snap, if any.
+func validateBase(info *Info) error {
+	if info.Base == "" {
+		return nil
+	}
+	switch info.Type {
+	case TypeOS, TypeBase, TypeKernel, TypeGadget:
+		return fmt.Errorf("cannot have base %q with snap type %q", info.Base, info.Type)
+	}
+	if info.Base == info.Name() {
+		return fmt.Errorf("cannot use snap %q as its own base", info.Base)
+	}
+	if err := ValidateName(info.Base); err != nil {
+		return fmt.Errorf("invalid base name: %v", err)
 	}
 	return nil
 }
@@ -78,12 +93,11 @@ func Validate(info *Info) error {
 		return err
 	}
 
-	epoch := info.Epoch
-	if epoch == "" {
-		return fmt.Errorf("snap epoch cannot be empty")
+	if err := validateBase(info); err != nil {
+		return err
 	}
-	err = ValidateEpoch(epoch)
-	if err != nil {
+
+	if err := info.Epoch.Validate(); err != nil {
 		return err
 	}
 
@@ -93,6 +107,16 @@ func Validate(info *Info) error {
 		if err != nil {
 			return err
 		}
+		if err := validateAppOrderNames(app, app.Before); err != nil {
+			return err
+		}
+		if err := validateAppOrderNames(app, app.After); err != nil {
+			return err
+		}
+	}
+	// and the ordering between them
+	if _, err := SortServices(info.Services()); err != nil {
+		return err
 	}
 
 	// validate aliases
@@ -114,6 +138,18 @@ func Validate(info *Info) error {
 	if err := plugsSlotsUniqueNames(info); err != nil {
 		return err
 	}
+
+	// validate the layout, in a predictable order
+	paths := make([]string, 0, len(info.Layout))
+	for path := range info.Layout {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := ValidateLayout(info.Layout[path]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -168,5 +204,214 @@ func ValidateApp(app *AppInfo) error {
 			return err
 		}
 	}
+
+	if err := app.StopMode.Validate(); err != nil {
+		return err
+	}
+	switch app.RefreshMode {
+	case "", "endure", "restart":
+		// valid
+	default:
+		return fmt.Errorf(`"refresh-mode" field contains invalid value %q`, app.RefreshMode)
+	}
+	if !app.IsService() {
+		for _, field := range []struct {
+			name string
+			set  bool
+		}{
+			{"stop-mode", app.StopMode != ""},
+			{"refresh-mode", app.RefreshMode != ""},
+			{"watchdog-timeout", app.WatchdogTimeout != 0},
+		} {
+			if field.set {
+				return fmt.Errorf("cannot use %q with application %q: not a service", field.name, app.Name)
+			}
+		}
+	}
+
+	if app.Timer != nil {
+		if err := validateAppTimer(app); err != nil {
+			return err
+		}
+	}
+
+	if len(app.Sockets) > 0 && !app.IsService() {
+		return fmt.Errorf("cannot use sockets with application %q: not a service", app.Name)
+	}
+	names := make([]string, 0, len(app.Sockets))
+	for name := range app.Sockets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := validateAppSocket(app.Sockets[name]); err != nil {
+			return fmt.Errorf("invalid definition of socket %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+var validSocketName = regexp.MustCompile("^[a-z](?:-?[a-z0-9])*$")
+
+// socketLocalAddrs are the addresses that a socket can be restricted
+// to; a socket given only a port listens on all addresses.
+var socketLocalAddrs = []string{"127.0.0.1", "[::1]"}
+
+// validateAppSocket validates a socket that activates a service.
+func validateAppSocket(socket *SocketInfo) error {
+	if !validSocketName.MatchString(socket.Name) {
+		return fmt.Errorf("invalid socket name")
+	}
+	if socket.ListenStream == "" {
+		return fmt.Errorf(`"listen-stream" is not defined`)
+	}
+	if socket.IsPath() {
+		return validateSocketPath(socket.ListenStream)
+	}
+	if socket.SocketMode != 0 {
+		return fmt.Errorf(`"socket-mode" can only be used with a path`)
+	}
+	return validateSocketAddr(socket.ListenStream)
+}
+
+// validateSocketPath checks that the path of a socket is within the
+// writable areas of the snap.
+func validateSocketPath(path string) error {
+	if filepath.Clean(path) != path {
+		return fmt.Errorf(`"listen-stream" path %q is not clean`, path)
+	}
+	if !strings.HasPrefix(path, "$SNAP_DATA/") && !strings.HasPrefix(path, "$SNAP_COMMON/") {
+		return fmt.Errorf(`"listen-stream" path %q must be prefixed with $SNAP_DATA or $SNAP_COMMON`, path)
+	}
+	return nil
+}
+
+// validateSocketAddr checks that the address of a socket is a port,
+// optionally prefixed with a local address.
+func validateSocketAddr(addr string) error {
+	port := addr
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		if !strutil.ListContains(socketLocalAddrs, addr[:i]) {
+			return fmt.Errorf(`"listen-stream" address %q must be a port or a local address and port`, addr)
+		}
+		port = addr[i+1:]
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf(`"listen-stream" address %q has invalid port %q`, addr, port)
+	}
+	return nil
+}
+
+// validateAppOrderNames checks that the services named in the before or
+// after declaration of the app are services of the same snap.
+func validateAppOrderNames(app *AppInfo, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if !app.IsService() {
+		return fmt.Errorf("cannot define before/after for application %q: not a service", app.Name)
+	}
+	for _, name := range names {
+		other, ok := app.Snap.Apps[name]
+		if !ok {
+			return fmt.Errorf("application %q refers to missing application %q in before/after", app.Name, name)
+		}
+		if !other.IsService() {
+			return fmt.Errorf("application %q refers to non-service application %q in before/after", app.Name, name)
+		}
+	}
+	return nil
+}
+
+// validateAppTimer validates the timer of the app, if any.
+func validateAppTimer(app *AppInfo) error {
+	if !app.IsService() {
+		return fmt.Errorf("cannot use timer with application %q: not a service", app.Name)
+	}
+	if _, err := timeutil.ParseSchedule(app.Timer.Timer); err != nil {
+		return fmt.Errorf("timer of application %q has invalid format: %v", app.Name, err)
+	}
+	return nil
+}
+
+// layoutForbiddenPaths are the locations that a layout can neither
+// replace nor be placed under.
+var layoutForbiddenPaths = []string{"/proc", "/sys", "/dev"}
+
+// isSnapPath returns whether the given path, in which the snap
+// variables have been expanded, is within the directories of the snap.
+func isSnapPath(info *Info, path string) bool {
+	for _, dir := range []string{info.MountDir(), info.DataDir(), info.CommonDataDir()} {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateLayout verifies the content of a single element of the layout
+// of a snap.
+func ValidateLayout(layout *Layout) error {
+	info := layout.Snap
+	path := layout.Path
+	if path == "" {
+		return fmt.Errorf("layout cannot use an empty path")
+	}
+	expandedPath := info.ExpandSnapVariables(path)
+	if !filepath.IsAbs(expandedPath) || filepath.Clean(path) != path || strings.Contains(expandedPath, "$") {
+		return fmt.Errorf("layout %q uses invalid path: must be absolute and clean, using only $SNAP, $SNAP_DATA and $SNAP_COMMON", path)
+	}
+	if expandedPath == "/" {
+		return fmt.Errorf("layout %q cannot replace the root directory", path)
+	}
+	for _, forbidden := range layoutForbiddenPaths {
+		if expandedPath == forbidden || strings.HasPrefix(expandedPath, forbidden+"/") {
+			return fmt.Errorf("layout %q in an off-limits area: %s", path, forbidden)
+		}
+	}
+	if expandedPath == info.MountDir() {
+		return fmt.Errorf("layout %q cannot replace $SNAP", path)
+	}
+
+	var kinds []string
+	for kind, value := range map[string]string{
+		"bind":      layout.Bind,
+		"bind-file": layout.BindFile,
+		"type":      layout.Type,
+		"symlink":   layout.Symlink,
+	} {
+		if value != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	switch len(kinds) {
+	case 0:
+		return fmt.Errorf("layout %q must define a bind mount, a filesystem mount or a symlink", path)
+	case 1:
+		// valid
+	default:
+		sort.Strings(kinds)
+		return fmt.Errorf("layout %q must define only one of bind, bind-file, type or symlink, not %s", path, strings.Join(kinds, ", "))
+	}
+
+	var source string
+	switch {
+	case layout.Bind != "":
+		source = layout.Bind
+	case layout.BindFile != "":
+		source = layout.BindFile
+	case layout.Symlink != "":
+		source = layout.Symlink
+	case layout.Type != "tmpfs":
+		return fmt.Errorf("layout %q uses invalid filesystem %q", path, layout.Type)
+	}
+	if source != "" {
+		expandedSource := info.ExpandSnapVariables(source)
+		if filepath.Clean(source) != source || !isSnapPath(info, expandedSource) {
+			return fmt.Errorf("layout %q uses invalid %s %q: must be a clean path within $SNAP, $SNAP_DATA or $SNAP_COMMON", path, kinds[0], source)
+		}
+	}
+
 	return nil
 }
@@ -21,11 +21,14 @@ package snap_test
 
 import (
 	"fmt"
+	"os"
 	"regexp"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	. "github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timeout"
 )
 
 type ValidateSuite struct{}
@@ -65,23 +68,6 @@ func (s *ValidateSuite) TestValidateName(c *C) {
 	}
 }
 
-func (s *ValidateSuite) TestValidateEpoch(c *C) {
-	validEpochs := []string{
-		"0", "1*", "1", "400*", "1234",
-	}
-	for _, epoch := range validEpochs {
-		err := ValidateEpoch(epoch)
-		c.Assert(err, IsNil)
-	}
-	invalidEpochs := []string{
-		"0*", "_", "1-", "1+", "-1", "+1", "-1*", "a", "1a", "1**",
-	}
-	for _, epoch := range invalidEpochs {
-		err := ValidateEpoch(epoch)
-		c.Assert(err, ErrorMatches, `invalid snap epoch: ".*"`)
-	}
-}
-
 func (s *ValidateSuite) TestValidateHook(c *C) {
 	validHooks := []*HookInfo{
 		{Name: "a"},
@@ -170,6 +156,115 @@ func (s *ValidateSuite) TestAppDaemonValue(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestAppStopMode(c *C) {
+	for _, t := range []struct {
+		stopMode string
+		ok       bool
+	}{
+		// good
+		{"", true},
+		{"sigterm", true},
+		{"sigterm-all", true},
+		{"sighup", true},
+		{"sighup-all", true},
+		{"sigusr1", true},
+		{"sigusr1-all", true},
+		{"sigusr2", true},
+		{"sigusr2-all", true},
+		// bad
+		{"sigkill", false},
+		{"-all", false},
+	} {
+		err := ValidateApp(&AppInfo{Name: "foo", Daemon: "simple", StopMode: StopModeType(t.stopMode)})
+		if t.ok {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, fmt.Sprintf(`"stop-mode" field contains invalid value %q`, t.stopMode))
+		}
+	}
+}
+
+func (s *ValidateSuite) TestAppRefreshMode(c *C) {
+	for _, t := range []struct {
+		refreshMode string
+		ok          bool
+	}{
+		// good
+		{"", true},
+		{"endure", true},
+		{"restart", true},
+		// bad
+		{"invalid-thing", false},
+	} {
+		err := ValidateApp(&AppInfo{Name: "foo", Daemon: "simple", RefreshMode: t.refreshMode})
+		if t.ok {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, fmt.Sprintf(`"refresh-mode" field contains invalid value %q`, t.refreshMode))
+		}
+	}
+}
+
+func (s *ValidateSuite) TestAppServiceOnlyFields(c *C) {
+	c.Check(ValidateApp(&AppInfo{Name: "foo", StopMode: "sigterm"}), ErrorMatches, `cannot use "stop-mode" with application "foo": not a service`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", RefreshMode: "endure"}), ErrorMatches, `cannot use "refresh-mode" with application "foo": not a service`)
+	c.Check(ValidateApp(&AppInfo{Name: "foo", WatchdogTimeout: timeout.Timeout(time.Second)}), ErrorMatches, `cannot use "watchdog-timeout" with application "foo": not a service`)
+}
+
+func (s *ValidateSuite) TestAppTimer(c *C) {
+	app := &AppInfo{Name: "foo", Daemon: "oneshot"}
+	app.Timer = &TimerInfo{App: app, Timer: "mon-fri@9:00-17:00~4"}
+	c.Check(ValidateApp(app), IsNil)
+
+	app.Timer.Timer = "mon@25:00"
+	c.Check(ValidateApp(app), ErrorMatches, `timer of application "foo" has invalid format: cannot parse "25:00": not a valid interval`)
+
+	app.Daemon = ""
+	app.Timer.Timer = "9:00"
+	c.Check(ValidateApp(app), ErrorMatches, `cannot use timer with application "foo": not a service`)
+}
+
+func (s *ValidateSuite) TestAppSockets(c *C) {
+	app := &AppInfo{Name: "foo", Daemon: "simple"}
+	socket := &SocketInfo{App: app, Name: "sock"}
+	app.Sockets = map[string]*SocketInfo{"sock": socket}
+
+	for _, t := range []struct {
+		listenStream string
+		mode         os.FileMode
+		err          string
+	}{
+		{"$SNAP_DATA/sock.socket", 0666, ""},
+		{"$SNAP_COMMON/run/sock.socket", 0, ""},
+		{"8080", 0, ""},
+		{"127.0.0.1:8080", 0, ""},
+		{"[::1]:8080", 0, ""},
+		{"", 0, `"listen-stream" is not defined`},
+		{"/run/sock.socket", 0, `"listen-stream" path "/run/sock.socket" must be prefixed with \$SNAP_DATA or \$SNAP_COMMON`},
+		{"$SNAP_DATA/../sock.socket", 0, `"listen-stream" path "\$SNAP_DATA/../sock.socket" is not clean`},
+		{"8080", 0666, `"socket-mode" can only be used with a path`},
+		{"0", 0, `"listen-stream" address "0" has invalid port "0"`},
+		{"65536", 0, `"listen-stream" address "65536" has invalid port "65536"`},
+		{"10.0.0.1:8080", 0, `"listen-stream" address "10.0.0.1:8080" must be a port or a local address and port`},
+	} {
+		socket.ListenStream = t.listenStream
+		socket.SocketMode = t.mode
+		if t.err == "" {
+			c.Check(ValidateApp(app), IsNil, Commentf(t.listenStream))
+		} else {
+			c.Check(ValidateApp(app), ErrorMatches, `invalid definition of socket "sock": `+t.err, Commentf(t.listenStream))
+		}
+	}
+
+	socket.ListenStream = "8080"
+	socket.Name = "Sock"
+	app.Sockets = map[string]*SocketInfo{"Sock": socket}
+	c.Check(ValidateApp(app), ErrorMatches, `invalid definition of socket "Sock": invalid socket name`)
+
+	app.Daemon = ""
+	c.Check(ValidateApp(app), ErrorMatches, `cannot use sockets with application "foo": not a service`)
+}
+
 func (s *ValidateSuite) TestAppWhitelistError(c *C) {
 	err := ValidateApp(&AppInfo{Name: "foo", Command: "x\n"})
 	c.Assert(err, NotNil)
@@ -230,10 +325,58 @@ func (s *ValidateSuite) TestIllegalSnapEpoch(c *C) {
 version: 1.0
 epoch: 0*
 `))
-	c.Assert(err, IsNil)
+	c.Assert(err, ErrorMatches, `info failed to parse: invalid epoch "0\*": 0\* is an invalid epoch`)
+	c.Check(info, IsNil)
 
+	info, err = InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+`))
+	c.Assert(err, IsNil)
+	info.Epoch = Epoch{Read: []uint32{1}, Write: []uint32{2}}
 	err = Validate(info)
-	c.Check(err, ErrorMatches, `invalid snap epoch: "0\*"`)
+	c.Check(err, ErrorMatches, `invalid epoch {"read":\[1\],"write":\[2\]}: cannot write epoch 2 that is not read`)
+}
+
+func (s *ValidateSuite) TestValidateBase(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{"base: some-base\n", ""},
+		{"base: some-base\ntype: base\n", `cannot have base "some-base" with snap type "base"`},
+		{"base: some-base\ntype: os\n", `cannot have base "some-base" with snap type "os"`},
+		{"base: foo\n", `cannot use snap "foo" as its own base`},
+		{"base: Base\n", `invalid base name: invalid snap name: "Base"`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: foo\nversion: 1.0\n" + t.yaml))
+		c.Assert(err, IsNil)
+		if t.err == "" {
+			c.Check(Validate(info), IsNil, Commentf(t.yaml))
+		} else {
+			c.Check(Validate(info), ErrorMatches, t.err, Commentf(t.yaml))
+		}
+	}
+}
+
+func (s *ValidateSuite) TestValidateAppOrder(c *C) {
+	for _, t := range []struct {
+		apps string
+		err  string
+	}{
+		{" foo:\n  daemon: simple\n  after: [bar]\n bar:\n  daemon: simple\n  before: [baz]\n baz:\n  daemon: simple\n", ""},
+		{" foo:\n  after: [bar]\n bar:\n  daemon: simple\n", `cannot define before/after for application "foo": not a service`},
+		{" foo:\n  daemon: simple\n  before: [bar]\n", `application "foo" refers to missing application "bar" in before/after`},
+		{" foo:\n  daemon: simple\n  after: [bar]\n bar:\n  command: bar\n", `application "foo" refers to non-service application "bar" in before/after`},
+		{" one:\n  daemon: simple\n  after: [two]\n two:\n  daemon: simple\n  after: [one]\n", `cannot order applications foo.one, foo.two: before/after cycle detected`},
+	} {
+		info, err := InfoFromSnapYaml([]byte("name: foo\nversion: 1.0\napps:\n" + t.apps))
+		c.Assert(err, IsNil)
+		if t.err == "" {
+			c.Check(Validate(info), IsNil, Commentf(t.apps))
+		} else {
+			c.Check(Validate(info), ErrorMatches, t.err, Commentf(t.apps))
+		}
+	}
 }
 
 func (s *ValidateSuite) TestMissingSnapEpochIsOkay(c *C) {
@@ -311,3 +454,50 @@ func (s *ValidateSuite) TestValidateAlias(c *C) {
 		c.Assert(err, ErrorMatches, `invalid alias name: ".*"`)
 	}
 }
+
+func (s *ValidateSuite) TestValidateLayout(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+layout:
+  /usr/share/foo:
+    bind: $SNAP/usr/share/foo
+  /etc/foo.conf:
+    bind-file: $SNAP_DATA/foo.conf
+  /var/cache/foo:
+    type: tmpfs
+  /usr/lib/foo:
+    symlink: $SNAP_COMMON/lib/foo
+  $SNAP/lib/bar:
+    bind: $SNAP_DATA/bar
+`))
+	c.Assert(err, IsNil)
+	c.Check(Validate(info), IsNil)
+}
+
+func (s *ValidateSuite) TestValidateLayoutErrors(c *C) {
+	info := &Info{SuggestedName: "foo", SideInfo: SideInfo{Revision: R(1)}}
+	for _, t := range []struct {
+		layout Layout
+		err    string
+	}{
+		{Layout{Path: "", Type: "tmpfs"}, `layout cannot use an empty path`},
+		{Layout{Path: "foo", Type: "tmpfs"}, `layout "foo" uses invalid path: .*`},
+		{Layout{Path: "/foo/../bar", Type: "tmpfs"}, `layout "/foo/../bar" uses invalid path: .*`},
+		{Layout{Path: "$HOME/foo", Type: "tmpfs"}, `layout "\$HOME/foo" uses invalid path: .*`},
+		{Layout{Path: "/", Type: "tmpfs"}, `layout "/" cannot replace the root directory`},
+		{Layout{Path: "/proc", Type: "tmpfs"}, `layout "/proc" in an off-limits area: /proc`},
+		{Layout{Path: "/sys/kernel", Type: "tmpfs"}, `layout "/sys/kernel" in an off-limits area: /sys`},
+		{Layout{Path: "/dev/foo", Bind: "$SNAP/dev"}, `layout "/dev/foo" in an off-limits area: /dev`},
+		{Layout{Path: "$SNAP", Type: "tmpfs"}, `layout "\$SNAP" cannot replace \$SNAP`},
+		{Layout{Path: "/foo"}, `layout "/foo" must define a bind mount, a filesystem mount or a symlink`},
+		{Layout{Path: "/foo", Bind: "$SNAP/foo", Type: "tmpfs"}, `layout "/foo" must define only one of bind, bind-file, type or symlink, not bind, type`},
+		{Layout{Path: "/foo", Type: "ext4"}, `layout "/foo" uses invalid filesystem "ext4"`},
+		{Layout{Path: "/foo", Bind: "/etc"}, `layout "/foo" uses invalid bind "/etc": must be a clean path within \$SNAP, \$SNAP_DATA or \$SNAP_COMMON`},
+		{Layout{Path: "/foo", BindFile: "$SNAP/../etc/passwd"}, `layout "/foo" uses invalid bind-file .*`},
+		{Layout{Path: "/foo", Symlink: "/etc/passwd"}, `layout "/foo" uses invalid symlink .*`},
+	} {
+		layout := t.layout
+		layout.Snap = info
+		c.Check(ValidateLayout(&layout), ErrorMatches, t.err, Commentf("path %q", t.layout.Path))
+	}
+}
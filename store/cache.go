@@ -0,0 +1,234 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachePolicy controls which downloads are kept in the download cache.
+type CachePolicy struct {
+	// MaxSize is the total size of the cached downloads above which
+	// the least recently used ones are evicted; zero disables caching.
+	MaxSize int64
+	// MaxAge is how long a download is kept after it was last used;
+	// zero means no limit.
+	MaxAge time.Duration
+}
+
+// DefaultCachePolicy is the policy of the download cache unless
+// configured otherwise, which caches nothing until given a MaxSize.
+var DefaultCachePolicy = CachePolicy{
+	MaxAge: 30 * 24 * time.Hour,
+}
+
+// downloadCache is where downloaded snaps are kept, keyed by their
+// sha3-384, so they need not be downloaded again.
+type downloadCache interface {
+	// Get puts the download with the given key at targetPath.
+	Get(cacheKey, targetPath string) error
+	// Put adds the file at sourcePath to the cache with the given key.
+	Put(cacheKey, sourcePath string) error
+}
+
+// nullCache is a downloadCache that caches nothing.
+type nullCache struct{}
+
+func (nullCache) Get(cacheKey, targetPath string) error {
+	return fmt.Errorf("cannot find %s in the download cache: caching is disabled", cacheKey)
+}
+
+func (nullCache) Put(cacheKey, sourcePath string) error {
+	return nil
+}
+
+// CacheManager is a downloadCache that hard-links downloads in and out
+// of a directory, evicting them according to a CachePolicy. As the
+// cached downloads share their inode with the installed snaps, the
+// time each one was last used is kept as the modification time of a
+// separate stamp file next to it.
+type CacheManager struct {
+	cacheDir string
+
+	mu     sync.Mutex
+	policy CachePolicy
+}
+
+// NewCacheManager returns a CacheManager for the given directory.
+func NewCacheManager(cacheDir string, policy CachePolicy) *CacheManager {
+	return &CacheManager{
+		cacheDir: cacheDir,
+		policy:   policy,
+	}
+}
+
+// SetPolicy changes the policy of the cache, evicting downloads as
+// needed.
+func (cm *CacheManager) SetPolicy(policy CachePolicy) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.policy = policy
+	return cm.cleanup()
+}
+
+func (cm *CacheManager) path(cacheKey string) (string, error) {
+	if cacheKey == "" || filepath.Base(cacheKey) != cacheKey || cacheKey[0] == '.' {
+		return "", fmt.Errorf("invalid download cache key %q", cacheKey)
+	}
+	return filepath.Join(cm.cacheDir, cacheKey), nil
+}
+
+// usedStampPath returns the path of the stamp file recording when the
+// download at the given path was last used. Keys cannot start with a
+// dot, so stamps are never taken for downloads.
+func usedStampPath(p string) string {
+	return filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".used")
+}
+
+// markUsed records that the download at the given path was just used.
+func markUsed(p string) error {
+	return ioutil.WriteFile(usedStampPath(p), nil, 0600)
+}
+
+// Get hard-links the download with the given key to targetPath, marking
+// it as used.
+func (cm *CacheManager) Get(cacheKey, targetPath string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	p, err := cm.path(cacheKey)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); err != nil {
+		return err
+	}
+	if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(p, targetPath); err != nil {
+		return err
+	}
+	return markUsed(p)
+}
+
+// Put hard-links the file at sourcePath into the cache with the given
+// key, evicting older downloads as needed.
+func (cm *CacheManager) Put(cacheKey, sourcePath string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	p, err := cm.path(cacheKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cm.cacheDir, 0700); err != nil {
+		return err
+	}
+	if err := os.Link(sourcePath, p); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err := markUsed(p); err != nil {
+		return err
+	}
+	return cm.cleanup()
+}
+
+// cacheEntry is a cached download along with when it was last used.
+type cacheEntry struct {
+	name     string
+	size     int64
+	lastUsed time.Time
+}
+
+type byLastUsed []cacheEntry
+
+func (es byLastUsed) Len() int           { return len(es) }
+func (es byLastUsed) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+func (es byLastUsed) Less(i, j int) bool { return es[i].lastUsed.Before(es[j].lastUsed) }
+
+// cleanup evicts the downloads not used within the maximum age, and
+// then the least recently used ones until the cache fits in the
+// maximum size.
+func (cm *CacheManager) cleanup() error {
+	fis, err := ioutil.ReadDir(cm.cacheDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stamps := make(map[string]time.Time, len(fis))
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") {
+			stamps[fi.Name()] = fi.ModTime()
+		}
+	}
+
+	var entries []cacheEntry
+	var size int64
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		stamp := usedStampPath(fi.Name())
+		lastUsed, ok := stamps[stamp]
+		if !ok {
+			// never marked as used, go by when it was written
+			lastUsed = fi.ModTime()
+		}
+		delete(stamps, stamp)
+		entries = append(entries, cacheEntry{name: fi.Name(), size: fi.Size(), lastUsed: lastUsed})
+		size += fi.Size()
+	}
+	sort.Sort(byLastUsed(entries))
+
+	// stamps left are of downloads that are gone
+	for stamp := range stamps {
+		if err := os.Remove(filepath.Join(cm.cacheDir, stamp)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, e := range entries {
+		expired := cm.policy.MaxAge > 0 && now.Sub(e.lastUsed) > cm.policy.MaxAge
+		if !expired && size <= cm.policy.MaxSize {
+			continue
+		}
+		p := filepath.Join(cm.cacheDir, e.name)
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		if err := os.Remove(usedStampPath(p)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= e.size
+	}
+	return nil
+}
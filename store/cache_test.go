@@ -0,0 +1,183 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+type cacheSuite struct {
+	cm  *CacheManager
+	tmp string
+}
+
+var _ = Suite(&cacheSuite{})
+
+func (s *cacheSuite) SetUpTest(c *C) {
+	s.tmp = c.MkDir()
+	s.cm = NewCacheManager(filepath.Join(s.tmp, "cache"), CachePolicy{MaxSize: 100})
+}
+
+// makeTestFile creates a file of the given size, used at the given time
+func (s *cacheSuite) makeTestFile(c *C, name string, size int, used time.Time) string {
+	p := filepath.Join(s.tmp, name)
+	c.Assert(ioutil.WriteFile(p, make([]byte, size), 0644), IsNil)
+	c.Assert(os.Chtimes(p, used, used), IsNil)
+	return p
+}
+
+// cached returns the keys of the cached downloads
+func (s *cacheSuite) cached(c *C) []string {
+	fis, err := ioutil.ReadDir(s.cm.cacheDir)
+	c.Assert(err, IsNil)
+	var names []string
+	for _, fi := range fis {
+		if !strings.HasPrefix(fi.Name(), ".") {
+			names = append(names, fi.Name())
+		}
+	}
+	return names
+}
+
+// setUsed sets when the cached download with the given key was last used
+func (s *cacheSuite) setUsed(c *C, key string, used time.Time) {
+	stamp := usedStampPath(filepath.Join(s.cm.cacheDir, key))
+	c.Assert(os.Chtimes(stamp, used, used), IsNil)
+}
+
+func (s *cacheSuite) TestPutGet(c *C) {
+	p := s.makeTestFile(c, "foo.snap", 10, time.Now())
+	c.Assert(s.cm.Put("some-sha3", p), IsNil)
+	c.Check(s.cached(c), DeepEquals, []string{"some-sha3"})
+
+	// putting it again is fine
+	c.Assert(s.cm.Put("some-sha3", p), IsNil)
+
+	// it survives the removal of the original
+	c.Assert(os.Remove(p), IsNil)
+	target := filepath.Join(s.tmp, "target.snap")
+	c.Assert(s.cm.Get("some-sha3", target), IsNil)
+	c.Check(osutil.FileExists(target), Equals, true)
+
+	// an existing target is replaced
+	c.Assert(os.Remove(target), IsNil)
+	c.Assert(ioutil.WriteFile(target, []byte("junk"), 0644), IsNil)
+	c.Assert(s.cm.Get("some-sha3", target), IsNil)
+	fi, err := os.Stat(target)
+	c.Assert(err, IsNil)
+	c.Check(fi.Size(), Equals, int64(10))
+}
+
+func (s *cacheSuite) TestGetMissing(c *C) {
+	err := s.cm.Get("some-sha3", filepath.Join(s.tmp, "target.snap"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *cacheSuite) TestInvalidKey(c *C) {
+	p := s.makeTestFile(c, "foo.snap", 10, time.Now())
+	for _, key := range []string{"", "..", ".hidden", "../foo", "foo/bar"} {
+		c.Check(s.cm.Put(key, p), ErrorMatches, `invalid download cache key ".*"`)
+		c.Check(s.cm.Get(key, p), ErrorMatches, `invalid download cache key ".*"`)
+	}
+}
+
+func (s *cacheSuite) TestEvictsLeastRecentlyUsed(c *C) {
+	now := time.Now()
+	c.Assert(s.cm.Put("a", s.makeTestFile(c, "a.snap", 40, now)), IsNil)
+	c.Assert(s.cm.Put("b", s.makeTestFile(c, "b.snap", 40, now)), IsNil)
+	// put sets the time of use, so make a the most recently used one
+	s.setUsed(c, "b", now.Add(-time.Hour))
+	c.Assert(s.cm.Get("a", filepath.Join(s.tmp, "target.snap")), IsNil)
+
+	c.Assert(s.cm.Put("c", s.makeTestFile(c, "c.snap", 40, now)), IsNil)
+	c.Check(s.cached(c), DeepEquals, []string{"a", "c"})
+}
+
+func (s *cacheSuite) TestEvictsExpired(c *C) {
+	c.Assert(s.cm.SetPolicy(CachePolicy{MaxSize: 1000, MaxAge: time.Hour}), IsNil)
+
+	c.Assert(s.cm.Put("a", s.makeTestFile(c, "a.snap", 10, time.Now())), IsNil)
+	c.Assert(s.cm.Put("b", s.makeTestFile(c, "b.snap", 10, time.Now())), IsNil)
+	s.setUsed(c, "a", time.Now().Add(-2*time.Hour))
+
+	c.Assert(s.cm.Put("c", s.makeTestFile(c, "c.snap", 10, time.Now())), IsNil)
+	c.Check(s.cached(c), DeepEquals, []string{"b", "c"})
+}
+
+func (s *cacheSuite) TestKeepsModTimeOfLinkedFiles(c *C) {
+	old := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	p := s.makeTestFile(c, "foo.snap", 10, old)
+	c.Assert(s.cm.Put("some-sha3", p), IsNil)
+	target := filepath.Join(s.tmp, "target.snap")
+	c.Assert(s.cm.Get("some-sha3", target), IsNil)
+
+	for _, q := range []string{p, target} {
+		fi, err := os.Stat(q)
+		c.Assert(err, IsNil)
+		c.Check(fi.ModTime().Equal(old), Equals, true)
+	}
+}
+
+func (s *cacheSuite) TestEvictsWithUsedStamp(c *C) {
+	c.Assert(s.cm.Put("a", s.makeTestFile(c, "a.snap", 60, time.Now())), IsNil)
+	stamp := usedStampPath(filepath.Join(s.cm.cacheDir, "a"))
+	c.Check(osutil.FileExists(stamp), Equals, true)
+
+	c.Assert(s.cm.Put("b", s.makeTestFile(c, "b.snap", 60, time.Now())), IsNil)
+	c.Check(s.cached(c), DeepEquals, []string{"b"})
+	c.Check(osutil.FileExists(stamp), Equals, false)
+}
+
+func (s *cacheSuite) TestRemovesStrayUsedStamps(c *C) {
+	c.Assert(os.MkdirAll(s.cm.cacheDir, 0700), IsNil)
+	stray := usedStampPath(filepath.Join(s.cm.cacheDir, "gone"))
+	c.Assert(ioutil.WriteFile(stray, nil, 0600), IsNil)
+
+	c.Assert(s.cm.Put("a", s.makeTestFile(c, "a.snap", 10, time.Now())), IsNil)
+	c.Check(osutil.FileExists(stray), Equals, false)
+}
+
+func (s *cacheSuite) TestSetPolicyEvicts(c *C) {
+	c.Assert(s.cm.Put("a", s.makeTestFile(c, "a.snap", 40, time.Now())), IsNil)
+	c.Assert(s.cm.Put("b", s.makeTestFile(c, "b.snap", 40, time.Now())), IsNil)
+	c.Check(s.cached(c), HasLen, 2)
+
+	c.Assert(s.cm.SetPolicy(CachePolicy{}), IsNil)
+	c.Check(s.cached(c), HasLen, 0)
+}
+
+func (s *cacheSuite) TestSetPolicyNoCacheDir(c *C) {
+	c.Check(s.cm.SetPolicy(DefaultCachePolicy), IsNil)
+}
+
+func (s *cacheSuite) TestNullCache(c *C) {
+	p := s.makeTestFile(c, "foo.snap", 10, time.Now())
+	var cacher downloadCache = nullCache{}
+	c.Assert(cacher.Put("some-sha3", p), IsNil)
+	c.Check(cacher.Get("some-sha3", filepath.Join(s.tmp, "target.snap")), ErrorMatches, `cannot find some-sha3 in the download cache: caching is disabled`)
+}
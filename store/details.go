@@ -27,6 +27,7 @@ import (
 type snapDetails struct {
 	AnonDownloadURL  string             `json:"anon_download_url,omitempty"`
 	Architectures    []string           `json:"architecture"`
+	Base             string             `json:"base,omitempty"`
 	Channel          string             `json:"channel,omitempty"`
 	DownloadSha3_384 string             `json:"download_sha3_384,omitempty"`
 	Summary          string             `json:"summary,omitempty"`
@@ -34,7 +35,7 @@ type snapDetails struct {
 	Deltas           []snapDeltaDetail  `json:"deltas,omitempty"`
 	DownloadSize     int64              `json:"binary_filesize,omitempty"`
 	DownloadURL      string             `json:"download_url,omitempty"`
-	Epoch            string             `json:"epoch"`
+	Epoch            snap.Epoch         `json:"epoch"`
 	IconURL          string             `json:"icon_url"`
 	LastUpdated      string             `json:"last_updated,omitempty"`
 	Name             string             `json:"package_name"`
@@ -83,11 +84,11 @@ type snapDeltaDetail struct {
 // channelSnapInfoDetails is the subset of snapDetails we need to get
 // information about the snaps in the various channels
 type channelSnapInfoDetails struct {
-	Revision     int    `json:"revision"` // store revisions are ints starting at 1
-	Confinement  string `json:"confinement"`
-	Version      string `json:"version"`
-	Channel      string `json:"channel"`
-	Epoch        string `json:"epoch"`
-	DownloadSize int64  `json:"binary_filesize"`
-	Info         string `json:"info"`
+	Revision     int        `json:"revision"` // store revisions are ints starting at 1
+	Confinement  string     `json:"confinement"`
+	Version      string     `json:"version"`
+	Channel      string     `json:"channel"`
+	Epoch        snap.Epoch `json:"epoch"`
+	DownloadSize int64      `json:"binary_filesize"`
+	Info         string     `json:"info"`
 }
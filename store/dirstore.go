@@ -0,0 +1,451 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+	"golang.org/x/net/context"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// overridden in the tests
+var dirStoreReadInfo = func(snapPath string, si *snap.SideInfo) (*snap.Info, error) {
+	snapf, err := snap.Open(snapPath)
+	if err != nil {
+		return nil, err
+	}
+	return snap.ReadInfoFromSnapFile(snapf, si)
+}
+
+// DirStore is a store serving snaps and assertions from a local
+// directory, e.g. a USB stick, for systems that cannot reach the
+// online store.
+//
+// The directory holds the .snap files next to .assert files with one
+// or more assertions each. A snap is only served if the directory has
+// its snap-revision and snap-declaration assertions, which give its
+// snap-id, name and revision.
+type DirStore struct {
+	dir string
+
+	mu      sync.Mutex
+	digests map[string]*fileDigest
+}
+
+// fileDigest is the sha3-384 of a file, remembered until the file
+// changes.
+type fileDigest struct {
+	modTime time.Time
+	size    uint64
+	digest  string
+}
+
+// NewDirStore returns a DirStore serving the given directory.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{
+		dir:     dir,
+		digests: make(map[string]*fileDigest),
+	}
+}
+
+// Dir returns the directory the store serves.
+func (s *DirStore) Dir() string {
+	return s.dir
+}
+
+func (s *DirStore) assertions() (asserts.Backstore, error) {
+	bs := asserts.NewMemoryBackstore()
+
+	fns, err := filepath.Glob(filepath.Join(s.dir, "*.assert"))
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range fns {
+		if err := addAssertions(bs, fn); err != nil {
+			return nil, fmt.Errorf("cannot read assertions from %q: %v", fn, err)
+		}
+	}
+	return bs, nil
+}
+
+func addAssertions(bs asserts.Backstore, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// the same assertion can be found in several bundles
+		if err := bs.Put(a.Type(), a); err != nil {
+			if _, ok := err.(*asserts.RevisionError); !ok {
+				return err
+			}
+		}
+	}
+}
+
+func (s *DirStore) digest(fn string) (digest string, size uint64, err error) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return "", 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d := s.digests[fn]; d != nil && d.modTime.Equal(fi.ModTime()) && int64(d.size) == fi.Size() {
+		return d.digest, d.size, nil
+	}
+	digest, size, err = asserts.SnapFileSHA3_384(fn)
+	if err != nil {
+		return "", 0, err
+	}
+	s.digests[fn] = &fileDigest{modTime: fi.ModTime(), size: size, digest: digest}
+	return digest, size, nil
+}
+
+func getAssertion(bs asserts.Backstore, assertType *asserts.AssertionType, primaryKey ...string) (asserts.Assertion, error) {
+	return bs.Get(assertType, primaryKey, assertType.MaxSupportedFormat())
+}
+
+// snapInfo returns the info of the snap at fn, or nil if the
+// directory lacks the assertions of the snap.
+func (s *DirStore) snapInfo(bs asserts.Backstore, fn string) (*snap.Info, error) {
+	digest, size, err := s.digest(fn)
+	if err != nil {
+		return nil, err
+	}
+	a, err := getAssertion(bs, asserts.SnapRevisionType, digest)
+	if err == asserts.ErrNotFound {
+		logger.Debugf("Ignoring %q in the store directory: no snap-revision assertion found.", fn)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapRev := a.(*asserts.SnapRevision)
+
+	a, err = getAssertion(bs, asserts.SnapDeclarationType, release.Series, snapRev.SnapID())
+	if err == asserts.ErrNotFound {
+		logger.Debugf("Ignoring %q in the store directory: no snap-declaration assertion found.", fn)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapDecl := a.(*asserts.SnapDeclaration)
+
+	info, err := dirStoreReadInfo(fn, &snap.SideInfo{
+		RealName: snapDecl.SnapName(),
+		SnapID:   snapRev.SnapID(),
+		Revision: snap.R(snapRev.SnapRevision()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	info.PublisherID = snapRev.DeveloperID()
+	if a, err := getAssertion(bs, asserts.AccountType, snapRev.DeveloperID()); err == nil {
+		info.Publisher = a.(*asserts.Account).Username()
+	}
+
+	sha3_384, err := base64.RawURLEncoding.DecodeString(digest)
+	if err != nil {
+		return nil, err
+	}
+	info.DownloadURL = fn
+	info.Sha3_384 = fmt.Sprintf("%x", sha3_384)
+	info.Size = int64(size)
+
+	return info, nil
+}
+
+type byRevision []*snap.Info
+
+func (infos byRevision) Len() int           { return len(infos) }
+func (infos byRevision) Swap(i, j int)      { infos[i], infos[j] = infos[j], infos[i] }
+func (infos byRevision) Less(i, j int) bool { return infos[i].Revision.N < infos[j].Revision.N }
+
+// snaps returns the infos of all the snaps in the directory, by
+// ascending revision.
+func (s *DirStore) snaps() ([]*snap.Info, error) {
+	bs, err := s.assertions()
+	if err != nil {
+		return nil, err
+	}
+
+	fns, err := filepath.Glob(filepath.Join(s.dir, "*.snap"))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*snap.Info, 0, len(fns))
+	for _, fn := range fns {
+		info, err := s.snapInfo(bs, fn)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read snap %q from the store directory: %v", fn, err)
+		}
+		if info != nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Sort(byRevision(infos))
+
+	return infos, nil
+}
+
+// SnapInfo returns the snap.Info for the store-hosted snap matching the given spec, or an error.
+func (s *DirStore) SnapInfo(spec SnapSpec, user *auth.UserState) (*snap.Info, error) {
+	infos, err := s.snaps()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *snap.Info
+	for _, info := range infos {
+		if info.Name() != spec.Name {
+			continue
+		}
+		if spec.Revision.Unset() || spec.Revision == info.Revision {
+			found = info
+		}
+	}
+	if found == nil {
+		return nil, ErrSnapNotFound
+	}
+	found.Channel = spec.Channel
+	return found, nil
+}
+
+// Find finds the latest revisions of the snaps in the directory whose
+// name matches the given Search.
+func (s *DirStore) Find(search *Search, user *auth.UserState) ([]*snap.Info, error) {
+	if search.Private || search.Section != "" {
+		// there are neither private snaps nor sections offline
+		return nil, nil
+	}
+
+	infos, err := s.snaps()
+	if err != nil {
+		return nil, err
+	}
+
+	query := strings.ToLower(strings.TrimSpace(search.Query))
+	latest := make(map[string]*snap.Info)
+	var names []string
+	for _, info := range infos {
+		name := info.Name()
+		if search.Prefix && !strings.HasPrefix(name, query) || !strings.Contains(name, query) {
+			continue
+		}
+		if latest[name] == nil {
+			names = append(names, name)
+		}
+		latest[name] = info
+	}
+
+	sort.Strings(names)
+	found := make([]*snap.Info, len(names))
+	for i, name := range names {
+		found[i] = latest[name]
+	}
+	return found, nil
+}
+
+// refreshFor returns the latest revision in the directory of the snap
+// that can be refreshed to from the given candidate, if any.
+func refreshFor(infos []*snap.Info, installed *RefreshCandidate) *snap.Info {
+	var latest *snap.Info
+	for _, info := range infos {
+		if info.SnapID != installed.SnapID || info.Revision.N <= installed.Revision.N {
+			continue
+		}
+		if findRev(info.Revision, installed.Block) || !info.Epoch.CanRead(installed.Epoch) {
+			continue
+		}
+		latest = info
+	}
+	if latest != nil {
+		latest.Channel = installed.Channel
+	}
+	return latest
+}
+
+// LookupRefresh returns the revision a snap can be refreshed to given
+// its refresh candidate.
+func (s *DirStore) LookupRefresh(installed *RefreshCandidate, user *auth.UserState) (*snap.Info, error) {
+	if installed.SnapID == "" || !installed.Revision.Store() {
+		return nil, ErrLocalSnap
+	}
+
+	infos, err := s.snaps()
+	if err != nil {
+		return nil, err
+	}
+
+	if info := refreshFor(infos, installed); info != nil {
+		return info, nil
+	}
+	for _, info := range infos {
+		if info.SnapID == installed.SnapID {
+			return nil, ErrNoUpdateAvailable
+		}
+	}
+	return nil, ErrSnapNotFound
+}
+
+// ListRefresh returns the available updates for a list of refresh candidates.
+func (s *DirStore) ListRefresh(installed []*RefreshCandidate, user *auth.UserState) ([]*snap.Info, error) {
+	infos, err := s.snaps()
+	if err != nil {
+		return nil, err
+	}
+
+	var toRefresh []*snap.Info
+	for _, cand := range installed {
+		if cand.SnapID == "" || !cand.Revision.Store() {
+			continue
+		}
+		if info := refreshFor(infos, cand); info != nil {
+			toRefresh = append(toRefresh, info)
+		}
+	}
+	return toRefresh, nil
+}
+
+// Sections returns no sections, as a directory has none.
+func (s *DirStore) Sections(user *auth.UserState) ([]string, error) {
+	return nil, nil
+}
+
+// Download copies the snap addressed by download info from the
+// directory to targetPath, checking its sha3-384 on the way.
+func (s *DirStore) Download(ctx context.Context, name string, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState) error {
+	source := downloadInfo.DownloadURL
+	if filepath.Dir(source) != filepath.Clean(s.dir) {
+		return fmt.Errorf("cannot download %q: %q is not in the store directory", name, source)
+	}
+	if pbar == nil {
+		pbar = &progress.NullProgress{}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+
+	r, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// on error the partial file is left for the caller to clean up, as
+	// with the online store
+	defer w.Close()
+
+	h := sha3.New384()
+	pbar.Start(name, float64(downloadInfo.Size))
+	_, err = io.Copy(io.MultiWriter(w, h, pbar), &contextReader{ctx: ctx, r: r})
+	pbar.Finished()
+	if err != nil {
+		return err
+	}
+
+	actualSha3 := fmt.Sprintf("%x", h.Sum(nil))
+	if downloadInfo.Sha3_384 != "" && downloadInfo.Sha3_384 != actualSha3 {
+		return HashError{name, actualSha3, downloadInfo.Sha3_384}
+	}
+	return w.Sync()
+}
+
+// contextReader stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if cr.ctx != nil {
+		if err := cr.ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	return cr.r.Read(p)
+}
+
+// Assertion retrieves the assertion for the given type and primary key
+// from the directory.
+func (s *DirStore) Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error) {
+	bs, err := s.assertions()
+	if err != nil {
+		return nil, err
+	}
+	a, err := getAssertion(bs, assertType, primaryKey...)
+	if err == asserts.ErrNotFound {
+		return nil, &AssertionNotFoundError{&asserts.Ref{Type: assertType, PrimaryKey: primaryKey}}
+	}
+	return a, err
+}
+
+// SuggestedCurrency returns no currency, as nothing can be bought
+// from a directory.
+func (s *DirStore) SuggestedCurrency() string {
+	return ""
+}
+
+var errDirStoreBuy = errors.New("cannot buy snaps from the store directory")
+
+// Buy fails, as nothing can be bought from a directory.
+func (s *DirStore) Buy(options *BuyOptions, user *auth.UserState) (*BuyResult, error) {
+	return nil, errDirStoreBuy
+}
+
+// ReadyToBuy fails, as nothing can be bought from a directory.
+func (s *DirStore) ReadyToBuy(user *auth.UserState) error {
+	return errDirStoreBuy
+}
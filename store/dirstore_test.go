@@ -0,0 +1,300 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/snap"
+)
+
+type dirStoreSuite struct {
+	dir          string
+	storeSigning *assertstest.StoreStack
+	devAcct      *asserts.Account
+	epochs       map[string]snap.Epoch
+	restore      func()
+
+	sto *DirStore
+}
+
+var _ = Suite(&dirStoreSuite{})
+
+func (s *dirStoreSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.epochs = make(map[string]snap.Epoch)
+
+	rootPrivKey, _ := assertstest.GenerateKey(752)
+	storePrivKey, _ := assertstest.GenerateKey(752)
+	s.storeSigning = assertstest.NewStoreStack("can0nical", rootPrivKey, storePrivKey)
+	s.devAcct = assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+
+	oldReadInfo := dirStoreReadInfo
+	dirStoreReadInfo = func(snapPath string, si *snap.SideInfo) (*snap.Info, error) {
+		info := &snap.Info{SideInfo: *si, Version: "1.0"}
+		info.Epoch = s.epochs[filepath.Base(snapPath)]
+		return info, nil
+	}
+	s.restore = func() { dirStoreReadInfo = oldReadInfo }
+
+	s.sto = NewDirStore(s.dir)
+}
+
+func (s *dirStoreSuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+func (s *dirStoreSuite) writeAssertions(c *C, fn string, as ...asserts.Assertion) {
+	var buf bytes.Buffer
+	enc := asserts.NewEncoder(&buf)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, fn), buf.Bytes(), 0644), IsNil)
+}
+
+func (s *dirStoreSuite) snapDecl(c *C, name string) asserts.Assertion {
+	snapDecl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      name + "-id",
+		"snap-name":    name,
+		"publisher-id": s.devAcct.AccountID(),
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return snapDecl
+}
+
+// addSnap writes a snap with the given content to the directory,
+// returning its snap-revision assertion
+func (s *dirStoreSuite) addSnap(c *C, name string, rev int, content string) asserts.Assertion {
+	fn := fmt.Sprintf("%s_%d.snap", name, rev)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, fn), []byte(content), 0644), IsNil)
+
+	h := sha3.Sum384([]byte(content))
+	digest, err := asserts.EncodeDigest(crypto.SHA3_384, h[:])
+	c.Assert(err, IsNil)
+	snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-id":       name + "-id",
+		"snap-sha3-384": digest,
+		"snap-size":     fmt.Sprintf("%d", len(content)),
+		"snap-revision": fmt.Sprintf("%d", rev),
+		"developer-id":  s.devAcct.AccountID(),
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return snapRev
+}
+
+// populate adds foo revisions 10 and 11, bar revision 5 and the
+// unasserted baz to the directory
+func (s *dirStoreSuite) populate(c *C) {
+	foo10 := s.addSnap(c, "foo", 10, "foo-10")
+	foo11 := s.addSnap(c, "foo", 11, "foo-11")
+	bar5 := s.addSnap(c, "bar", 5, "bar-5")
+	s.writeAssertions(c, "foo.assert", s.devAcct, s.snapDecl(c, "foo"), foo10, foo11)
+	// the account is repeated across bundles
+	s.writeAssertions(c, "bar.assert", s.devAcct, s.snapDecl(c, "bar"), bar5)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "baz_1.snap"), []byte("baz-1"), 0644), IsNil)
+}
+
+func (s *dirStoreSuite) TestSnapInfo(c *C) {
+	s.populate(c)
+
+	info, err := s.sto.SnapInfo(SnapSpec{Name: "foo", Channel: "stable"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Name(), Equals, "foo")
+	c.Check(info.SnapID, Equals, "foo-id")
+	c.Check(info.Revision, Equals, snap.R(11))
+	c.Check(info.Channel, Equals, "stable")
+	c.Check(info.PublisherID, Equals, s.devAcct.AccountID())
+	c.Check(info.Publisher, Equals, "developer1")
+	c.Check(info.DownloadURL, Equals, filepath.Join(s.dir, "foo_11.snap"))
+	c.Check(info.Size, Equals, int64(len("foo-11")))
+	c.Check(info.Sha3_384, Equals, fmt.Sprintf("%x", sha3.Sum384([]byte("foo-11"))))
+
+	info, err = s.sto.SnapInfo(SnapSpec{Name: "foo", Revision: snap.R(10)}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(10))
+
+	_, err = s.sto.SnapInfo(SnapSpec{Name: "foo", Revision: snap.R(12)}, nil)
+	c.Check(err, Equals, ErrSnapNotFound)
+}
+
+func (s *dirStoreSuite) TestSnapInfoUnasserted(c *C) {
+	s.populate(c)
+
+	_, err := s.sto.SnapInfo(SnapSpec{Name: "baz"}, nil)
+	c.Check(err, Equals, ErrSnapNotFound)
+}
+
+func (s *dirStoreSuite) TestSnapInfoBadAssertions(c *C) {
+	s.populate(c)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "bad.assert"), []byte("junk"), 0644), IsNil)
+
+	_, err := s.sto.SnapInfo(SnapSpec{Name: "foo"}, nil)
+	c.Check(err, ErrorMatches, `cannot read assertions from ".*/bad.assert": .*`)
+}
+
+func (s *dirStoreSuite) TestFind(c *C) {
+	s.populate(c)
+
+	names := func(infos []*snap.Info) []string {
+		var names []string
+		for _, info := range infos {
+			names = append(names, fmt.Sprintf("%s_%s", info.Name(), info.Revision))
+		}
+		return names
+	}
+
+	infos, err := s.sto.Find(&Search{}, nil)
+	c.Assert(err, IsNil)
+	c.Check(names(infos), DeepEquals, []string{"bar_5", "foo_11"})
+
+	infos, err = s.sto.Find(&Search{Query: "O"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(names(infos), DeepEquals, []string{"foo_11"})
+
+	infos, err = s.sto.Find(&Search{Query: "ar", Prefix: true}, nil)
+	c.Assert(err, IsNil)
+	c.Check(infos, HasLen, 0)
+
+	infos, err = s.sto.Find(&Search{Query: "ba", Prefix: true}, nil)
+	c.Assert(err, IsNil)
+	c.Check(names(infos), DeepEquals, []string{"bar_5"})
+
+	infos, err = s.sto.Find(&Search{Private: true}, nil)
+	c.Assert(err, IsNil)
+	c.Check(infos, HasLen, 0)
+}
+
+func (s *dirStoreSuite) TestLookupRefresh(c *C) {
+	s.populate(c)
+
+	info, err := s.sto.LookupRefresh(&RefreshCandidate{
+		SnapID:   "foo-id",
+		Revision: snap.R(10),
+		Channel:  "beta",
+	}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(11))
+	c.Check(info.Channel, Equals, "beta")
+
+	_, err = s.sto.LookupRefresh(&RefreshCandidate{SnapID: "foo-id", Revision: snap.R(11)}, nil)
+	c.Check(err, Equals, ErrNoUpdateAvailable)
+
+	_, err = s.sto.LookupRefresh(&RefreshCandidate{
+		SnapID:   "foo-id",
+		Revision: snap.R(10),
+		Block:    []snap.Revision{snap.R(11)},
+	}, nil)
+	c.Check(err, Equals, ErrNoUpdateAvailable)
+
+	_, err = s.sto.LookupRefresh(&RefreshCandidate{SnapID: "other-id", Revision: snap.R(1)}, nil)
+	c.Check(err, Equals, ErrSnapNotFound)
+
+	_, err = s.sto.LookupRefresh(&RefreshCandidate{Revision: snap.R(-1)}, nil)
+	c.Check(err, Equals, ErrLocalSnap)
+}
+
+func (s *dirStoreSuite) TestListRefresh(c *C) {
+	s.populate(c)
+	bar6 := s.addSnap(c, "bar", 6, "bar-6")
+	s.writeAssertions(c, "bar6.assert", bar6)
+	// foo 11 cannot read the data of epoch 0
+	s.epochs["foo_11.snap"] = snap.E("1")
+
+	infos, err := s.sto.ListRefresh([]*RefreshCandidate{
+		{SnapID: "foo-id", Revision: snap.R(10)},
+		{SnapID: "bar-id", Revision: snap.R(5)},
+		{Revision: snap.R(-1)},
+	}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 1)
+	c.Check(infos[0].Name(), Equals, "bar")
+	c.Check(infos[0].Revision, Equals, snap.R(6))
+}
+
+func (s *dirStoreSuite) TestDownload(c *C) {
+	s.populate(c)
+	info, err := s.sto.SnapInfo(SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	targetPath := filepath.Join(c.MkDir(), "foo.snap")
+	err = s.sto.Download(nil, "foo", targetPath, &info.DownloadInfo, nil, nil)
+	c.Assert(err, IsNil)
+
+	content, err := ioutil.ReadFile(targetPath)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "foo-11")
+}
+
+func (s *dirStoreSuite) TestDownloadHashMismatch(c *C) {
+	s.populate(c)
+	info, err := s.sto.SnapInfo(SnapSpec{Name: "foo"}, nil)
+	c.Assert(err, IsNil)
+
+	// the snap changed since its info was read
+	c.Assert(ioutil.WriteFile(info.DownloadURL, []byte("tampered"), 0644), IsNil)
+
+	targetPath := filepath.Join(c.MkDir(), "foo.snap")
+	err = s.sto.Download(nil, "foo", targetPath, &info.DownloadInfo, nil, nil)
+	c.Check(err, FitsTypeOf, HashError{})
+}
+
+func (s *dirStoreSuite) TestDownloadOutsideDir(c *C) {
+	other := filepath.Join(c.MkDir(), "foo.snap")
+	c.Assert(ioutil.WriteFile(other, []byte("foo"), 0644), IsNil)
+
+	targetPath := filepath.Join(c.MkDir(), "foo.snap")
+	err := s.sto.Download(nil, "foo", targetPath, &snap.DownloadInfo{DownloadURL: other}, nil, nil)
+	c.Check(err, ErrorMatches, `cannot download "foo": ".*/foo.snap" is not in the store directory`)
+	_, err = os.Stat(targetPath)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *dirStoreSuite) TestAssertion(c *C) {
+	s.populate(c)
+
+	a, err := s.sto.Assertion(asserts.SnapDeclarationType, []string{"16", "foo-id"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapDeclaration).SnapName(), Equals, "foo")
+
+	_, err = s.sto.Assertion(asserts.SnapDeclarationType, []string{"16", "other-id"}, nil)
+	c.Check(err, FitsTypeOf, &AssertionNotFoundError{})
+}
+
+func (s *dirStoreSuite) TestBuy(c *C) {
+	_, err := s.sto.Buy(&BuyOptions{}, nil)
+	c.Check(err, ErrorMatches, "cannot buy snaps from the store directory")
+	c.Check(s.sto.ReadyToBuy(nil), ErrorMatches, "cannot buy snaps from the store directory")
+	c.Check(s.sto.SuggestedCurrency(), Equals, "")
+}
@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// overridden in the tests
+var (
+	rateLimitNow   = time.Now
+	rateLimitSleep = func(ctx context.Context, d time.Duration) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+)
+
+// rateLimiter limits the overall rate at which the readers it wraps
+// can be read from, however many of them are in use at once.
+type rateLimiter struct {
+	mu sync.Mutex
+	// rate is in bytes per second, zero means no limit
+	rate int64
+	// next is when the bytes read so far are paid for
+	next time.Time
+}
+
+func (rl *rateLimiter) setRate(rate int64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.rate = rate
+}
+
+// wait accounts for n bytes being read, waiting until the bytes read
+// before them are paid for.
+func (rl *rateLimiter) wait(ctx context.Context, n int) error {
+	rl.mu.Lock()
+	if rl.rate <= 0 {
+		rl.mu.Unlock()
+		return nil
+	}
+	now := rateLimitNow()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	delay := rl.next.Sub(now)
+	rl.next = rl.next.Add(time.Duration(int64(n) * int64(time.Second) / rl.rate))
+	rl.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return rateLimitSleep(ctx, delay)
+}
+
+type rateLimitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	rl  *rateLimiter
+}
+
+func (rr *rateLimitedReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if n > 0 {
+		if werr := rr.rl.wait(rr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"golang.org/x/net/context"
+	. "gopkg.in/check.v1"
+)
+
+type rateLimitSuite struct {
+	now     time.Time
+	sleeps  []time.Duration
+	restore func()
+}
+
+var _ = Suite(&rateLimitSuite{})
+
+func (s *rateLimitSuite) SetUpTest(c *C) {
+	s.now = time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.sleeps = nil
+
+	oldNow := rateLimitNow
+	oldSleep := rateLimitSleep
+	rateLimitNow = func() time.Time { return s.now }
+	rateLimitSleep = func(ctx context.Context, d time.Duration) error {
+		s.sleeps = append(s.sleeps, d)
+		s.now = s.now.Add(d)
+		return nil
+	}
+	s.restore = func() {
+		rateLimitNow = oldNow
+		rateLimitSleep = oldSleep
+	}
+}
+
+func (s *rateLimitSuite) TearDownTest(c *C) {
+	s.restore()
+}
+
+func (s *rateLimitSuite) TestNoLimit(c *C) {
+	var rl rateLimiter
+	for i := 0; i < 3; i++ {
+		c.Assert(rl.wait(nil, 1000), IsNil)
+	}
+	c.Check(s.sleeps, HasLen, 0)
+}
+
+func (s *rateLimitSuite) TestWait(c *C) {
+	var rl rateLimiter
+	rl.setRate(1000)
+
+	// the first read goes through, the ones after it wait for the
+	// bytes read before them to be paid for
+	c.Assert(rl.wait(nil, 500), IsNil)
+	c.Assert(rl.wait(nil, 1000), IsNil)
+	c.Assert(rl.wait(nil, 100), IsNil)
+	c.Check(s.sleeps, DeepEquals, []time.Duration{500 * time.Millisecond, time.Second})
+
+	// no waiting once the reads have been paid for
+	s.sleeps = nil
+	s.now = s.now.Add(time.Hour)
+	c.Assert(rl.wait(nil, 100), IsNil)
+	c.Check(s.sleeps, HasLen, 0)
+}
+
+func (s *rateLimitSuite) TestSetRateZeroDisables(c *C) {
+	var rl rateLimiter
+	rl.setRate(10)
+	rl.setRate(0)
+	c.Assert(rl.wait(nil, 1000), IsNil)
+	c.Assert(rl.wait(nil, 1000), IsNil)
+	c.Check(s.sleeps, HasLen, 0)
+}
+
+func (s *rateLimitSuite) TestReader(c *C) {
+	var rl rateLimiter
+	rl.setRate(1000)
+
+	data := bytes.Repeat([]byte{'x'}, 3000)
+	r := &rateLimitedReader{r: bytes.NewReader(data), rl: &rl}
+	b, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(b, DeepEquals, data)
+
+	var total time.Duration
+	for _, d := range s.sleeps {
+		total += d
+	}
+	// all but the bytes of the last read are waited for
+	c.Check(total > time.Second, Equals, true)
+	c.Check(total < 3*time.Second, Equals, true)
+}
+
+func (s *rateLimitSuite) TestReaderCancelled(c *C) {
+	rateLimitSleep = func(ctx context.Context, d time.Duration) error {
+		return errors.New("cancelled")
+	}
+
+	var rl rateLimiter
+	rl.setRate(1)
+
+	r := &rateLimitedReader{ctx: context.Background(), r: bytes.NewReader(make([]byte, 10)), rl: &rl}
+	buf := make([]byte, 5)
+	n, err := r.Read(buf)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 5)
+	_, err = r.Read(buf)
+	c.Check(err, ErrorMatches, "cancelled")
+}
@@ -82,8 +82,9 @@ func infoFromRemote(d *snapDetails) *snap.Info {
 	info := &snap.Info{}
 	info.Architectures = d.Architectures
 	info.Type = d.Type
+	info.Base = d.Base
 	info.Version = d.Version
-	info.Epoch = "0"
+	info.Epoch = d.Epoch
 	info.RealName = d.Name
 	info.SnapID = d.SnapID
 	info.Revision = snap.R(d.Revision)
@@ -207,6 +208,9 @@ type Store struct {
 
 	mu                sync.Mutex
 	suggestedCurrency string
+
+	cacher      downloadCache
+	rateLimiter rateLimiter
 }
 
 func respToError(resp *http.Response, msg string) error {
@@ -442,6 +446,7 @@ func New(cfg *Config, authContext auth.AuthContext) *Store {
 		detailFields:    fields,
 		authContext:     authContext,
 		deltaFormat:     deltaFormat,
+		cacher:          nullCache{},
 
 		client: httputil.NewHTTPClient(&httputil.ClientOpts{
 			Timeout:    10 * time.Second,
@@ -1095,7 +1100,7 @@ func (s *Store) Sections(user *auth.UserState) ([]string, error) {
 type RefreshCandidate struct {
 	SnapID   string
 	Revision snap.Revision
-	Epoch    string
+	Epoch    snap.Epoch
 	Block    []snap.Revision
 
 	// the desired channel
@@ -1104,11 +1109,11 @@ type RefreshCandidate struct {
 
 // the exact bits that we need to send to the store
 type currentSnapJSON struct {
-	SnapID      string `json:"snap_id"`
-	Channel     string `json:"channel"`
-	Revision    int    `json:"revision,omitempty"`
-	Epoch       string `json:"epoch"`
-	Confinement string `json:"confinement"`
+	SnapID      string     `json:"snap_id"`
+	Channel     string     `json:"channel"`
+	Revision    int        `json:"revision,omitempty"`
+	Epoch       snap.Epoch `json:"epoch"`
+	Confinement string     `json:"confinement"`
 }
 
 type metadataWrapper struct {
@@ -1275,6 +1280,56 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 		return err
 	}
+
+	s.mu.Lock()
+	cacher := s.cacher
+	s.mu.Unlock()
+
+	if err := cacher.Get(downloadInfo.Sha3_384, targetPath); err == nil {
+		if pbar == nil {
+			pbar = &progress.NullProgress{}
+		}
+		pbar.Notify(fmt.Sprintf(i18n.G("Using %s from the download cache"), name))
+		return nil
+	}
+
+	if err := s.downloadBlob(ctx, name, targetPath, downloadInfo, pbar, user); err != nil {
+		return err
+	}
+
+	if err := cacher.Put(downloadInfo.Sha3_384, targetPath); err != nil {
+		logger.Noticef("Cannot add %s to the download cache: %v", name, err)
+	}
+	return nil
+}
+
+// SetCachePolicy enables caching downloads, keeping them according to
+// the given policy, or disables it, dropping what was cached, if the
+// policy has no MaxSize.
+func (s *Store) SetCachePolicy(policy CachePolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cm, ok := s.cacher.(*CacheManager)
+	if !ok {
+		cm = NewCacheManager(dirs.SnapDownloadCacheDir, policy)
+	}
+	if policy.MaxSize == 0 {
+		s.cacher = nullCache{}
+	} else {
+		s.cacher = cm
+	}
+	return cm.SetPolicy(policy)
+}
+
+// SetDownloadRateLimit limits the overall rate of downloads to the
+// given number of bytes per second; zero means no limit.
+func (s *Store) SetDownloadRateLimit(bytesPerSecond int64) {
+	s.rateLimiter.setRate(bytesPerSecond)
+}
+
+// downloadBlob downloads the snap to targetPath, from deltas if possible.
+func (s *Store) downloadBlob(ctx context.Context, name string, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState) error {
 	if useDeltas() {
 		logger.Debugf("Available deltas returned by store: %v", downloadInfo.Deltas)
 	}
@@ -1413,7 +1468,8 @@ var download = func(ctx context.Context, name, sha3_384, downloadURL string, use
 		}
 		pbar.Start(name, float64(resp.ContentLength))
 		mw := io.MultiWriter(w, h, pbar)
-		_, finalErr = io.Copy(mw, resp.Body)
+		body := &rateLimitedReader{ctx: ctx, r: resp.Body, rl: &s.rateLimiter}
+		_, finalErr = io.Copy(mw, body)
 		pbar.Finished()
 		if finalErr != nil {
 			if httputil.ShouldRetryError(attempt, finalErr) {
@@ -301,6 +301,119 @@ func (t *remoteRepoTestSuite) TestDownloadOK(c *C) {
 	c.Assert(string(content), Equals, "I was downloaded")
 }
 
+type cacheObserver struct {
+	inCache map[string]bool
+
+	gets []string
+	puts []string
+}
+
+func (co *cacheObserver) Get(cacheKey, targetPath string) error {
+	co.gets = append(co.gets, fmt.Sprintf("%s:%s", cacheKey, targetPath))
+	if !co.inCache[cacheKey] {
+		return fmt.Errorf("cannot find %s in cache", cacheKey)
+	}
+	return ioutil.WriteFile(targetPath, []byte("I was cached"), 0644)
+}
+
+func (co *cacheObserver) Put(cacheKey, sourcePath string) error {
+	co.puts = append(co.puts, fmt.Sprintf("%s:%s", cacheKey, sourcePath))
+	return nil
+}
+
+type notifyPBar struct {
+	progress.NullProgress
+	notices []string
+}
+
+func (pb *notifyPBar) Notify(msg string) {
+	pb.notices = append(pb.notices, msg)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadCacheHit(c *C) {
+	obs := &cacheObserver{inCache: map[string]bool{"the-snaps-sha3_384": true}}
+	t.store.cacher = obs
+
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+		c.Fatalf("download should not be called when results come from the cache")
+		return nil
+	}
+
+	snap := &snap.Info{}
+	snap.Sha3_384 = "the-snaps-sha3_384"
+
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	pbar := &notifyPBar{}
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, pbar, nil)
+	c.Assert(err, IsNil)
+
+	c.Check(obs.gets, DeepEquals, []string{fmt.Sprintf("%s:%s", snap.Sha3_384, path)})
+	c.Check(obs.puts, IsNil)
+	c.Check(pbar.notices, DeepEquals, []string{"Using foo from the download cache"})
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "I was cached")
+}
+
+func (t *remoteRepoTestSuite) TestDownloadCacheMiss(c *C) {
+	obs := &cacheObserver{inCache: map[string]bool{}}
+	t.store.cacher = obs
+
+	downloadWasCalled := false
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+		downloadWasCalled = true
+		return nil
+	}
+
+	snap := &snap.Info{}
+	snap.Sha3_384 = "the-snaps-sha3_384"
+
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(downloadWasCalled, Equals, true)
+
+	c.Check(obs.gets, DeepEquals, []string{fmt.Sprintf("the-snaps-sha3_384:%s", path)})
+	c.Check(obs.puts, DeepEquals, []string{fmt.Sprintf("the-snaps-sha3_384:%s", path)})
+}
+
+func (t *remoteRepoTestSuite) TestSetCachePolicy(c *C) {
+	c.Check(t.store.cacher, Equals, nullCache{})
+
+	download = func(ctx context.Context, name, sha3, url string, user *auth.UserState, s *Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter) error {
+		w.Write([]byte("I was downloaded"))
+		return nil
+	}
+
+	// the default policy does not enable caching
+	c.Assert(t.store.SetCachePolicy(DefaultCachePolicy), IsNil)
+	c.Check(t.store.cacher, Equals, nullCache{})
+
+	c.Assert(t.store.SetCachePolicy(CachePolicy{MaxSize: 1024 * 1024}), IsNil)
+	cm, ok := t.store.cacher.(*CacheManager)
+	c.Assert(ok, Equals, true)
+	c.Check(cm.cacheDir, Equals, dirs.SnapDownloadCacheDir)
+
+	snap := &snap.Info{}
+	snap.Sha3_384 = "the-snaps-sha3_384"
+	path := filepath.Join(c.MkDir(), "downloaded-file")
+	err := t.store.Download(context.TODO(), "foo", path, &snap.DownloadInfo, nil, nil)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadFile(filepath.Join(dirs.SnapDownloadCacheDir, "the-snaps-sha3_384"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "I was downloaded")
+
+	// the policy can be changed, keeping the same cache
+	c.Assert(t.store.SetCachePolicy(CachePolicy{MaxSize: 2 * 1024 * 1024}), IsNil)
+	c.Check(t.store.cacher, Equals, cm)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDownloadCacheDir, "the-snaps-sha3_384")), Equals, true)
+
+	// disabling caching drops what was cached
+	c.Assert(t.store.SetCachePolicy(CachePolicy{}), IsNil)
+	c.Check(t.store.cacher, Equals, nullCache{})
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDownloadCacheDir, "the-snaps-sha3_384")), Equals, false)
+}
+
 func (t *remoteRepoTestSuite) TestDownloadRangeRequest(c *C) {
 	partialContentStr := "partial content "
 
@@ -1781,8 +1894,8 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetails(c *C) {
 	c.Check(result.MustBuy, Equals, true)
 	c.Check(result.Contact, Equals, "mailto:snappy-devel@lists.ubuntu.com")
 
-	// Make sure the epoch (currently not sent by the store) defaults to "0"
-	c.Check(result.Epoch, Equals, "0")
+	// Make sure the epoch (currently not sent by the store) defaults to 0
+	c.Check(result.Epoch.String(), Equals, "0")
 
 	c.Check(repo.SuggestedCurrency(), Equals, "GBP")
 
@@ -1948,7 +2061,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetailsAndChannels(c *C)
 			Confinement: snap.StrictConfinement,
 			Channel:     "stable",
 			Size:        12345,
-			Epoch:       "0",
+			Epoch:       snap.E("0"),
 		},
 		"latest/candidate": {
 			Revision:    snap.R(2),
@@ -1956,7 +2069,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetailsAndChannels(c *C)
 			Confinement: snap.StrictConfinement,
 			Channel:     "candidate",
 			Size:        12345,
-			Epoch:       "0",
+			Epoch:       snap.E("0"),
 		},
 		"latest/beta": {
 			Revision:    snap.R(8),
@@ -1964,7 +2077,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetailsAndChannels(c *C)
 			Confinement: snap.DevModeConfinement,
 			Channel:     "beta",
 			Size:        12345,
-			Epoch:       "0",
+			Epoch:       snap.E("0"),
 		},
 		"latest/edge": {
 			Revision:    snap.R(9),
@@ -1972,7 +2085,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetailsAndChannels(c *C)
 			Confinement: snap.DevModeConfinement,
 			Channel:     "edge",
 			Size:        12345,
-			Epoch:       "0",
+			Epoch:       snap.E("0"),
 		},
 	})
 
@@ -2617,13 +2730,13 @@ func (t *remoteRepoTestSuite) TestCurrentSnap(c *C) {
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(1),
-		Epoch:    "1",
+		Epoch:    snap.E("1"),
 	}
 	cs := currentSnap(cand)
 	c.Assert(cs, NotNil)
 	c.Check(cs.SnapID, Equals, cand.SnapID)
 	c.Check(cs.Channel, Equals, cand.Channel)
-	c.Check(cs.Epoch, Equals, cand.Epoch)
+	c.Check(cs.Epoch, DeepEquals, cand.Epoch)
 	c.Check(cs.Revision, Equals, cand.Revision.N)
 	c.Check(t.logbuf.String(), Equals, "")
 }
@@ -2632,13 +2745,13 @@ func (t *remoteRepoTestSuite) TestCurrentSnapNoChannel(c *C) {
 	cand := &RefreshCandidate{
 		SnapID:   helloWorldSnapID,
 		Revision: snap.R(1),
-		Epoch:    "1",
+		Epoch:    snap.E("1"),
 	}
 	cs := currentSnap(cand)
 	c.Assert(cs, NotNil)
 	c.Check(cs.SnapID, Equals, cand.SnapID)
 	c.Check(cs.Channel, Equals, "stable")
-	c.Check(cs.Epoch, Equals, cand.Epoch)
+	c.Check(cs.Epoch, DeepEquals, cand.Epoch)
 	c.Check(cs.Revision, Equals, cand.Revision.N)
 	c.Check(t.logbuf.String(), Equals, "")
 }
@@ -2747,7 +2860,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidates(c *C
 			"snap_id":     helloWorldSnapID,
 			"channel":     "stable",
 			"revision":    float64(1),
-			"epoch":       "0",
+			"epoch":       map[string]interface{}{"read": []interface{}{float64(0)}, "write": []interface{}{float64(0)}},
 			"confinement": "",
 		})
 		c.Assert(resp.Fields, DeepEquals, detailFields)
@@ -2772,7 +2885,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidates(c *C
 			SnapID:   helloWorldSnapID,
 			Channel:  "stable",
 			Revision: 1,
-			Epoch:    "0",
+			Epoch:    snap.E("0"),
 		},
 	}, nil)
 
@@ -2822,7 +2935,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidatesRetri
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: 1,
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(n, Equals, 4)
@@ -2844,7 +2957,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefresh(c *C) {
 			SnapID:   helloWorldSnapID,
 			Channel:  "stable",
 			Revision: 1,
-			Epoch:    "0",
+			Epoch:    snap.E("0"),
 		}})
 		return []*snapDetails{{
 			Name:        "hello-world",
@@ -2862,7 +2975,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryLookupRefresh(c *C) {
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(1),
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(result.Name(), Equals, "hello-world")
@@ -2960,7 +3073,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefresh(c *C) {
 			"snap_id":     helloWorldSnapID,
 			"channel":     "stable",
 			"revision":    float64(1),
-			"epoch":       "0",
+			"epoch":       map[string]interface{}{"read": []interface{}{float64(0)}, "write": []interface{}{float64(0)}},
 			"confinement": "",
 		})
 		c.Assert(resp.Fields, DeepEquals, detailFields)
@@ -2985,7 +3098,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefresh(c *C) {
 			SnapID:   helloWorldSnapID,
 			Channel:  "stable",
 			Revision: snap.R(1),
-			Epoch:    "0",
+			Epoch:    snap.E("0"),
 		},
 	}, nil)
 	c.Assert(err, IsNil)
@@ -3018,7 +3131,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshDefaultChannel
 			"snap_id":     helloWorldSnapID,
 			"channel":     "stable",
 			"revision":    float64(1),
-			"epoch":       "0",
+			"epoch":       map[string]interface{}{"read": []interface{}{float64(0)}, "write": []interface{}{float64(0)}},
 			"confinement": "",
 		})
 		c.Assert(resp.Fields, DeepEquals, detailFields)
@@ -3042,7 +3155,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshDefaultChannel
 		{
 			SnapID:   helloWorldSnapID,
 			Revision: snap.R(1),
-			Epoch:    "0",
+			Epoch:    snap.E("0"),
 		},
 	}, nil)
 	c.Assert(err, IsNil)
@@ -3091,7 +3204,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshRetryOnEOF(c *
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(1),
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(n, Equals, 4)
@@ -3133,7 +3246,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreUnexpectedEOFhandling(c *C) {
 			SnapID:   helloWorldSnapID,
 			Channel:  "stable",
 			Revision: 1,
-			Epoch:    "0",
+			Epoch:    snap.E("0"),
 		}}, nil)
 		return err
 	}
@@ -3179,7 +3292,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidatesEOF(c
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: 1,
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, NotNil)
 	c.Assert(err, ErrorMatches, `^Post http://127.0.0.1:.*?/updates/: EOF$`)
@@ -3212,7 +3325,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryRefreshForCandidatesUnaut
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: 24,
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(n, Equals, 1)
 	c.Assert(err, ErrorMatches, `cannot query the store for updates: got unexpected HTTP status code 401 via POST to "http://.*?/updates/"`)
@@ -3232,7 +3345,7 @@ func (t *remoteRepoTestSuite) TestRefreshForCandidatesFailOnDNS(c *C) {
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: 24,
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	// the error differs depending on whether a proxy is in use (e.g. on travis), so don't inspect error message
 	c.Assert(err, NotNil)
@@ -3260,7 +3373,7 @@ func (t *remoteRepoTestSuite) TestRefreshForCandidates500(c *C) {
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: 24,
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, ErrorMatches, `cannot query the store for updates: got unexpected HTTP status code 500 via POST to "http://.*?/updates/"`)
 	c.Assert(n, Equals, 5)
@@ -3289,7 +3402,7 @@ func (t *remoteRepoTestSuite) TestRefreshForCandidates500DurationExceeded(c *C)
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: 24,
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, ErrorMatches, `cannot query the store for updates: got unexpected HTTP status code 500 via POST to "http://.*?/updates/"`)
 	c.Assert(n, Equals, 1)
@@ -3325,7 +3438,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshSkipCurrent(c
 			"snap_id":     helloWorldSnapID,
 			"channel":     "stable",
 			"revision":    float64(26),
-			"epoch":       "0",
+			"epoch":       map[string]interface{}{"read": []interface{}{float64(0)}, "write": []interface{}{float64(0)}},
 			"confinement": "",
 		})
 
@@ -3347,7 +3460,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshSkipCurrent(c
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(26),
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(results, HasLen, 0)
@@ -3370,7 +3483,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshSkipBlocked(c
 			"snap_id":     helloWorldSnapID,
 			"channel":     "stable",
 			"revision":    float64(25),
-			"epoch":       "0",
+			"epoch":       map[string]interface{}{"read": []interface{}{float64(0)}, "write": []interface{}{float64(0)}},
 			"confinement": "",
 		})
 
@@ -3392,7 +3505,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshSkipBlocked(c
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(25),
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 		Block:    []snap.Revision{snap.R(26)},
 	}}, nil)
 	c.Assert(err, IsNil)
@@ -3497,7 +3610,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDefaultsDeltasOnClassicOn
 			SnapID:   helloWorldSnapID,
 			Channel:  "stable",
 			Revision: 1,
-			Epoch:    "0",
+			Epoch:    snap.E("0"),
 		}}, nil)
 	}
 }
@@ -3524,7 +3637,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshWithDeltas(c *
 			"snap_id":     helloWorldSnapID,
 			"channel":     "stable",
 			"revision":    float64(24),
-			"epoch":       "0",
+			"epoch":       map[string]interface{}{"read": []interface{}{float64(0)}, "write": []interface{}{float64(0)}},
 			"confinement": "",
 		})
 		c.Assert(resp.Fields, DeepEquals, getStructFields(snapDetails{}))
@@ -3547,7 +3660,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshWithDeltas(c *
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(24),
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(results, HasLen, 1)
@@ -3595,7 +3708,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshWithoutDeltas(
 			"snap_id":     helloWorldSnapID,
 			"channel":     "stable",
 			"revision":    float64(24),
-			"epoch":       "0",
+			"epoch":       map[string]interface{}{"read": []interface{}{float64(0)}, "write": []interface{}{float64(0)}},
 			"confinement": "",
 		})
 		c.Assert(resp.Fields, DeepEquals, detailFields)
@@ -3618,7 +3731,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshWithoutDeltas(
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(24),
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, IsNil)
 	c.Assert(results, HasLen, 1)
@@ -3656,7 +3769,7 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryUpdateNotSendLocalRevs(c
 		SnapID:   helloWorldSnapID,
 		Channel:  "stable",
 		Revision: snap.R(-2),
-		Epoch:    "0",
+		Epoch:    snap.E("0"),
 	}}, nil)
 	c.Assert(err, IsNil)
 }
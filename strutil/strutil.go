@@ -22,6 +22,7 @@ package strutil
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -60,6 +61,43 @@ func SizeToStr(size int64) string {
 	panic("SizeToStr got a size bigger than math.MaxInt64")
 }
 
+// ParseByteSize parses a size in bytes given as a number with an optional
+// unit suffix, the inverse of SizeToStr, e.g. "512MB" or "2GB".
+func ParseByteSize(inp string) (int64, error) {
+	suffixes := []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	parseErr := func(reason interface{}) error {
+		return fmt.Errorf("cannot parse %q: %v", inp, reason)
+	}
+
+	idx := strings.IndexFunc(inp, func(r rune) bool { return r < '0' || r > '9' })
+	if idx == 0 || inp == "" {
+		return 0, parseErr("need a number with a unit as input")
+	}
+	num, unit := inp, ""
+	if idx > 0 {
+		num, unit = inp[:idx], inp[idx:]
+	}
+	val, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0, parseErr(err)
+	}
+	if unit == "" {
+		return val, nil
+	}
+
+	mul := int64(1)
+	for _, suf := range suffixes {
+		if unit == suf {
+			if val > math.MaxInt64/mul {
+				return 0, parseErr("overflow")
+			}
+			return val * mul, nil
+		}
+		mul *= 1000
+	}
+	return 0, parseErr("try 'kB' or 'MB'")
+}
+
 // Quoted formats a slice of strings to a quoted list of
 // comma-separated strings, e.g. `"snap1", "snap2"`
 func Quoted(names []string) string {
@@ -105,3 +143,14 @@ func WordWrap(s string, max int) []string {
 
 	return out
 }
+
+// ListContains determines whether the given string is contained in the
+// given list of strings.
+func ListContains(list []string, str string) bool {
+	for _, k := range list {
+		if k == str {
+			return true
+		}
+	}
+	return false
+}
@@ -82,6 +82,36 @@ func (ts *strutilSuite) TestSizeToStr(c *check.C) {
 	}
 }
 
+func (ts *strutilSuite) TestParseByteSize(c *check.C) {
+	for _, t := range []struct {
+		str  string
+		size int64
+		err  string
+	}{
+		{"0", 0, ""},
+		{"400", 400, ""},
+		{"400B", 400, ""},
+		{"1kB", 1000, ""},
+		{"512MB", 512 * 1000 * 1000, ""},
+		{"2GB", 2 * 1000 * 1000 * 1000, ""},
+		{"9EB", 9 * 1000 * 1000 * 1000 * 1000 * 1000 * 1000, ""},
+		{"10EB", 0, `cannot parse "10EB": overflow`},
+		{"", 0, `cannot parse "": need a number with a unit as input`},
+		{"MB", 0, `cannot parse "MB": need a number with a unit as input`},
+		{"1.5GB", 0, `cannot parse "1.5GB": try 'kB' or 'MB'`},
+		{"12XB", 0, `cannot parse "12XB": try 'kB' or 'MB'`},
+		{"99999999999999999999", 0, `cannot parse "99999999999999999999": .* value out of range`},
+	} {
+		size, err := strutil.ParseByteSize(t.str)
+		if t.err == "" {
+			c.Check(err, check.IsNil, check.Commentf(t.str))
+			c.Check(size, check.Equals, t.size, check.Commentf(t.str))
+		} else {
+			c.Check(err, check.ErrorMatches, t.err, check.Commentf(t.str))
+		}
+	}
+}
+
 func (ts *strutilSuite) TestWordWrap(c *check.C) {
 	for _, t := range []struct {
 		in  string
@@ -101,3 +131,23 @@ func (ts *strutilSuite) TestWordWrap(c *check.C) {
 		c.Check(strutil.WordWrap(t.in, t.n), check.DeepEquals, t.out)
 	}
 }
+
+func (ts *strutilSuite) TestListContains(c *check.C) {
+	for _, xs := range [][]string{
+		{},
+		nil,
+		{"foo"},
+		{"foo", "baz", "barbar"},
+	} {
+		c.Check(strutil.ListContains(xs, "bar"), check.Equals, false)
+	}
+
+	for _, xs := range [][]string{
+		{"bar"},
+		{"foo", "bar", "baz"},
+		{"bar", "foo", "baz"},
+		{"foo", "baz", "bar"},
+	} {
+		c.Check(strutil.ListContains(xs, "bar"), check.Equals, true)
+	}
+}
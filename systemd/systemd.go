@@ -25,11 +25,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/snapcore/snapd/dirs"
@@ -82,6 +84,53 @@ func jctl(svcs []string) ([]byte, error) {
 // JournalctlCmd is called from Logs to run journalctl; exported for testing.
 var JournalctlCmd = jctl
 
+// journalReader is the output of a running journalctl, which it stops
+// when closed.
+type journalReader struct {
+	io.ReadCloser
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+func (r *journalReader) Close() error {
+	// journalctl might be following the logs, so it won't stop by itself
+	r.once.Do(func() {
+		r.cmd.Process.Kill()
+		r.cmd.Wait()
+	})
+	return nil
+}
+
+// jctlStream starts journalctl to stream the last n JSON logs of the given
+// services, following them if requested. A negative n means all of them.
+func jctlStream(svcs []string, n int, follow bool) (io.ReadCloser, error) {
+	lines := "all"
+	if n >= 0 {
+		lines = strconv.Itoa(n)
+	}
+	cmd := []string{"journalctl", "-o", "json", "--no-pager", "-q", "--lines=" + lines}
+	if follow {
+		cmd = append(cmd, "-f")
+	}
+	for i := range svcs {
+		cmd = append(cmd, "-u", svcs[i])
+	}
+
+	c := exec.Command(cmd[0], cmd[1:]...)
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+
+	return &journalReader{ReadCloser: stdout, cmd: c}, nil
+}
+
+// JournalctlStream is called from LogReader to run journalctl; exported for testing.
+var JournalctlStream = jctlStream
+
 // Systemd exposes a minimal interface to manage systemd via the systemctl command.
 type Systemd interface {
 	DaemonReload() error
@@ -93,7 +142,9 @@ type Systemd interface {
 	Restart(service string, timeout time.Duration) error
 	Status(service string) (string, error)
 	ServiceStatus(service string) (*ServiceStatus, error)
+	UnitUsage(unit string) (*UnitUsage, error)
 	Logs(services []string) ([]Log, error)
+	LogReader(services []string, n int, follow bool) (io.ReadCloser, error)
 	WriteMountUnitFile(name, what, where, fstype string) (string, error)
 }
 
@@ -156,6 +207,12 @@ const (
 
 	// the default target for systemd units that we generate
 	SocketsTarget = "sockets.target"
+
+	// the target for systemd timer units that we generate
+	TimersTarget = "timers.target"
+
+	// the target for systemd slice units that we generate
+	SlicesTarget = "slices.target"
 )
 
 type reporter interface {
@@ -228,6 +285,13 @@ func (*systemd) Logs(serviceNames []string) ([]Log, error) {
 	return logs, nil
 }
 
+// LogReader returns a reader over the JSON logs of the given services,
+// starting with the last n of them (or all, if n is negative) and
+// following new ones if requested. The reader must be closed when done.
+func (*systemd) LogReader(serviceNames []string, n int, follow bool) (io.ReadCloser, error) {
+	return JournalctlStream(serviceNames, n, follow)
+}
+
 var statusregex = regexp.MustCompile(`(?m)^(?:(.*?)=(.*))?$`)
 
 func (s *systemd) Status(serviceName string) (string, error) {
@@ -276,6 +340,58 @@ func (s *systemd) ServiceStatus(serviceName string) (*ServiceStatus, error) {
 	return status, nil
 }
 
+// A UnitUsage holds the resource usage of a unit as accounted by systemd.
+type UnitUsage struct {
+	// Memory is the current memory usage in bytes.
+	Memory uint64 `json:"memory"`
+	// CPUTime is the CPU time consumed so far.
+	CPUTime time.Duration `json:"cpu-time"`
+}
+
+// parseUsageValue parses a resource usage value as reported by "show",
+// which is "[not set]" or the maximum uint64 when accounting is off.
+func parseUsageValue(v string) (uint64, error) {
+	if v == "" || v == "[not set]" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n == math.MaxUint64 {
+		return 0, nil
+	}
+	return n, nil
+}
+
+// UnitUsage returns the current resource usage of the given unit.
+func (s *systemd) UnitUsage(unit string) (*UnitUsage, error) {
+	bs, err := SystemctlCmd("show", "--property=MemoryCurrent,CPUUsageNSec", unit)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &UnitUsage{}
+
+	for _, bs := range statusregex.FindAllSubmatch(bs, -1) {
+		if len(bs[0]) > 0 {
+			k := string(bs[1])
+			v, err := parseUsageValue(string(bs[2]))
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse %s of %s: %v", k, unit, err)
+			}
+			switch k {
+			case "MemoryCurrent":
+				usage.Memory = v
+			case "CPUUsageNSec":
+				usage.CPUTime = time.Duration(v)
+			}
+		}
+	}
+
+	return usage, nil
+}
+
 // Stop the given service, and wait until it has stopped.
 func (s *systemd) Stop(serviceName string, timeout time.Duration) error {
 	if _, err := SystemctlCmd("stop", serviceName); err != nil {
@@ -393,6 +509,32 @@ func (l Log) Message() string {
 	return "-"
 }
 
+// Time of the Log, if it has a valid timestamp.
+func (l Log) Time() (time.Time, error) {
+	sus, ok := l["__REALTIME_TIMESTAMP"].(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("no timestamp")
+	}
+	// according to systemd.journal-fields(7) it's microseconds as a decimal string
+	us, err := strconv.ParseInt(sus, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp not a decimal number: %#v", sus)
+	}
+	return time.Unix(us/1000000, 1000*(us%1000000)).UTC(), nil
+}
+
+// PID is the pid of the client of the Log, if any; otherwise, "-".
+func (l Log) PID() string {
+	if pid, ok := l["_PID"].(string); ok {
+		return pid
+	}
+	if pid, ok := l["SYSLOG_PID"].(string); ok {
+		return pid
+	}
+
+	return "-"
+}
+
 // SID is the syslog identifier of the Log, if any; otherwise, "-".
 func (l Log) SID() string {
 	if sid, ok := l["SYSLOG_IDENTIFIER"].(string); ok {
@@ -21,9 +21,11 @@ package systemd_test
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -176,6 +178,27 @@ func (s *SystemdTestSuite) TestStatusObj(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestUnitUsage(c *C) {
+	s.outs = [][]byte{
+		[]byte("MemoryCurrent=1048576\nCPUUsageNSec=2500000000\n"),
+		[]byte("MemoryCurrent=[not set]\nCPUUsageNSec=18446744073709551615\n"),
+		[]byte("MemoryCurrent=lots\n"),
+	}
+	s.errors = []error{nil, nil, nil}
+
+	usage, err := New("", s.rep).UnitUsage("snap.foo.slice")
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, &UnitUsage{Memory: 1048576, CPUTime: 2500 * time.Millisecond})
+	c.Check(s.argses[0], DeepEquals, []string{"show", "--property=MemoryCurrent,CPUUsageNSec", "snap.foo.slice"})
+
+	usage, err = New("", s.rep).UnitUsage("snap.foo.slice")
+	c.Assert(err, IsNil)
+	c.Check(usage, DeepEquals, &UnitUsage{})
+
+	_, err = New("", s.rep).UnitUsage("snap.foo.slice")
+	c.Check(err, ErrorMatches, `cannot parse MemoryCurrent of snap.foo.slice: .*invalid syntax`)
+}
+
 func (s *SystemdTestSuite) TestStopTimeout(c *C) {
 	restore := MockStopDelays(time.Millisecond, 25*time.Second)
 	defer restore()
@@ -256,6 +279,61 @@ func (s *SystemdTestSuite) TestLogs(c *C) {
 	c.Check(s.j, Equals, 1)
 }
 
+func (s *SystemdTestSuite) TestLogReader(c *C) {
+	var calls []string
+	restore := JournalctlStream
+	defer func() { JournalctlStream = restore }()
+	JournalctlStream = func(svcs []string, n int, follow bool) (io.ReadCloser, error) {
+		calls = append(calls, fmt.Sprintf("%v %d %v", svcs, n, follow))
+		return ioutil.NopCloser(strings.NewReader(`{"a": 1}`)), nil
+	}
+
+	r, err := New("", s.rep).LogReader([]string{"foo", "bar"}, 10, true)
+	c.Assert(err, IsNil)
+	defer r.Close()
+	bs, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(string(bs), Equals, `{"a": 1}`)
+	c.Check(calls, DeepEquals, []string{"[foo bar] 10 true"})
+}
+
+func (s *SystemdTestSuite) TestJctlStream(c *C) {
+	cmd := testutil.MockCommand(c, "journalctl", `echo '{"MESSAGE": "hi"}'`)
+	defer cmd.Restore()
+
+	r, err := JctlStream([]string{"foo", "bar"}, 10, false)
+	c.Assert(err, IsNil)
+	bs, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(r.Close(), IsNil)
+	c.Check(string(bs), Equals, "{\"MESSAGE\": \"hi\"}\n")
+
+	r, err = JctlStream([]string{"foo"}, -1, true)
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(r.Close(), IsNil)
+
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"journalctl", "-o", "json", "--no-pager", "-q", "--lines=10", "-u", "foo", "-u", "bar"},
+		{"journalctl", "-o", "json", "--no-pager", "-q", "--lines=all", "-f", "-u", "foo"},
+	})
+}
+
+func (s *SystemdTestSuite) TestLogTimeAndPID(c *C) {
+	_, err := Log{}.Time()
+	c.Check(err, ErrorMatches, "no timestamp")
+	_, err = Log{"__REALTIME_TIMESTAMP": "what"}.Time()
+	c.Check(err, ErrorMatches, `timestamp not a decimal number: "what"`)
+	t, err := Log{"__REALTIME_TIMESTAMP": "42"}.Time()
+	c.Check(err, IsNil)
+	c.Check(t, Equals, time.Unix(0, 42000).UTC())
+
+	c.Check(Log{}.PID(), Equals, "-")
+	c.Check(Log{"SYSLOG_PID": "99"}.PID(), Equals, "99")
+	c.Check(Log{"_PID": "42", "SYSLOG_PID": "99"}.PID(), Equals, "42")
+}
+
 func (s *SystemdTestSuite) TestLogString(c *C) {
 	c.Check(Log{}.String(), Equals, "-(no timestamp!)- - -")
 	c.Check(Log{
@@ -161,36 +161,74 @@ var weekdayMap = map[string]int{
 	"sat": 6,
 }
 
-// parseWeekday gets an input like "mon@9:00-11:00" or "9:00-11:00"
-// and extracts the weekday of that schedule string (which can be
-// empty). It returns the remainder of the string, the weekday
-// and an error.
-func parseWeekday(s string) (weekday, rest string, err error) {
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parseWeekdays gets an input like "mon@9:00-11:00", "mon-fri@9:00-11:00"
+// or "9:00-11:00" and extracts the weekdays of that schedule string (which
+// can be empty). A span of weekdays can wrap around the end of the week, as
+// in "fri-mon". It returns the weekdays, the remainder of the string and an
+// error.
+func parseWeekdays(s string) (weekdays []string, rest string, err error) {
 	if !strings.Contains(s, "@") {
-		return "", s, nil
+		return nil, s, nil
 	}
 	s = strings.ToLower(s)
 	l := strings.SplitN(s, "@", 2)
-	weekday = l[0]
-	_, ok := weekdayMap[weekday]
-	if !ok {
-		return "", "", fmt.Errorf(`cannot parse %q, want "mon", "tue", etc`, l[0])
-	}
 	rest = l[1]
+	if _, ok := weekdayMap[l[0]]; ok {
+		return []string{l[0]}, rest, nil
+	}
+
+	span := strings.SplitN(l[0], "-", 2)
+	if len(span) != 2 {
+		return nil, "", fmt.Errorf(`cannot parse %q, want "mon", "tue", etc`, l[0])
+	}
+	first, ok1 := weekdayMap[span[0]]
+	last, ok2 := weekdayMap[span[1]]
+	if !ok1 || !ok2 {
+		return nil, "", fmt.Errorf(`cannot parse %q, want "mon", "tue", etc`, l[0])
+	}
+	for i := first; ; i = (i + 1) % 7 {
+		weekdays = append(weekdays, weekdayNames[i])
+		if i == last {
+			break
+		}
+	}
+
+	return weekdays, rest, nil
+}
 
-	return weekday, rest, nil
+// parseRepetition gets an input like "9:00-11:00~4" or "9:00-11:00"
+// and extracts the number of times the schedule repeats within the
+// interval, which is 1 when not given. It returns the remainder of the
+// string, the number of repetitions and an error.
+func parseRepetition(s string) (rest string, n int, err error) {
+	if !strings.Contains(s, "~") {
+		return s, 1, nil
+	}
+	l := strings.SplitN(s, "~", 2)
+	n, err = strconv.Atoi(l[1])
+	if err != nil || n < 1 {
+		return "", 0, fmt.Errorf("cannot parse %q: not a valid repetition", l[1])
+	}
+	return l[0], n, nil
 }
 
-// parseTimeInterval gets an input like "9:00-11:00"
+// parseTimeInterval gets an input like "9:00-11:00" or "9:00"
 // and extracts the start and end of that schedule string and
-// returns them and any errors.
+// returns them and any errors. A single time is an interval that
+// starts and ends at that time.
 func parseTimeInterval(s string) (start, end TimeOfDay, err error) {
 	if strings.Contains(s, "@") {
 		return start, end, fmt.Errorf("cannot parse %q: contains invalid @", s)
 	}
 	l := strings.SplitN(s, "-", 2)
 	if len(l) != 2 {
-		return start, end, fmt.Errorf("cannot parse %q: not a valid interval", s)
+		start, err = ParseTime(s)
+		if err != nil {
+			return start, end, fmt.Errorf("cannot parse %q: not a valid interval", s)
+		}
+		return start, start, nil
 	}
 
 	start, err = ParseTime(l[0])
@@ -201,17 +239,30 @@ func parseTimeInterval(s string) (start, end TimeOfDay, err error) {
 	if err != nil {
 		return start, end, fmt.Errorf("cannot parse %q: not a valid time", l[1])
 	}
-	if !(start.Hour <= end.Hour && start.Minute <= end.Minute) {
+	if start.minutes() > end.minutes() {
 		return start, end, fmt.Errorf("cannot parse %q: time in an interval cannot go backwards", s)
 	}
 
 	return start, end, nil
 }
 
-// parseSingleSchedule parses a schedule string like "mon@9:00-11:00" or
-// "9:00-11:00" and returns a Schedule struct and an error.
-func parseSingleSchedule(s string) (*Schedule, error) {
-	weekday, rest, err := parseWeekday(s)
+func (t TimeOfDay) minutes() int {
+	return t.Hour*60 + t.Minute
+}
+
+func timeOfDayFromMinutes(minutes int) TimeOfDay {
+	return TimeOfDay{Hour: minutes / 60, Minute: minutes % 60}
+}
+
+// parseSingleSchedule parses a schedule string like "mon@9:00-11:00",
+// "mon-fri@9:00-11:00~2" or "9:00-11:00" and returns the Schedule structs
+// it expands to, one for each weekday and repetition, and an error.
+func parseSingleSchedule(s string) ([]*Schedule, error) {
+	weekdays, rest, err := parseWeekdays(s)
+	if err != nil {
+		return nil, err
+	}
+	rest, n, err := parseRepetition(rest)
 	if err != nil {
 		return nil, err
 	}
@@ -219,12 +270,26 @@ func parseSingleSchedule(s string) (*Schedule, error) {
 	if err != nil {
 		return nil, err
 	}
+	span := end.minutes() - start.minutes()
+	if n > 1 && span/n == 0 {
+		return nil, fmt.Errorf("cannot parse %q: interval too short to repeat %d times", s, n)
+	}
+
+	if len(weekdays) == 0 {
+		weekdays = []string{""}
+	}
+	var schedule []*Schedule
+	for _, weekday := range weekdays {
+		for i := 0; i < n; i++ {
+			schedule = append(schedule, &Schedule{
+				Weekday: weekday,
+				Start:   timeOfDayFromMinutes(start.minutes() + i*span/n),
+				End:     timeOfDayFromMinutes(start.minutes() + (i+1)*span/n),
+			})
+		}
+	}
 
-	return &Schedule{
-		Weekday: weekday,
-		Start:   start,
-		End:     end,
-	}, nil
+	return schedule, nil
 }
 
 // ParseSchedule takes a schedule string in the form of:
@@ -234,8 +299,13 @@ func parseSingleSchedule(s string) (*Schedule, error) {
 // thu@9:00-15:00 (only Thursday between 9am and 3pm)
 // fri@9:00-11:00/mon@13:00-15:00 (only Friday between 9am and 3pm and Monday between 1pm and 3pm)
 // fri@9:00-11:00/13:00-15:00  (only Friday between 9am and 3pm and every day between 1pm and 3pm)
+// mon-fri@9:00-15:00 (Monday to Friday between 9am and 3pm)
+// 9:00-15:00~3 (every day between 9am and 11am, 11am and 1pm, and 1pm and 3pm)
+// 9:00 (every day at 9am)
 //
-// and returns a list of Schedule types or an error
+// and returns a list of Schedule types or an error. Spans of weekdays
+// and repetitions are expanded into one Schedule for each weekday and
+// repetition.
 func ParseSchedule(scheduleSpec string) ([]*Schedule, error) {
 	var schedule []*Schedule
 
@@ -244,8 +314,33 @@ func ParseSchedule(scheduleSpec string) ([]*Schedule, error) {
 		if err != nil {
 			return nil, err
 		}
-		schedule = append(schedule, sched)
+		schedule = append(schedule, sched...)
 	}
 
 	return schedule, nil
 }
+
+// NextStart returns the earliest time after the given one at which a
+// window of the schedule starts.
+func NextStart(schedule []*Schedule, after time.Time) time.Time {
+	var next time.Time
+	for _, sched := range schedule {
+		wd := time.Weekday(weekdayMap[sched.Weekday])
+		// a week and a day covers every window of a schedule
+		for i := 0; i <= 7; i++ {
+			t := after.Add(time.Duration(i) * 24 * time.Hour)
+			start := time.Date(t.Year(), t.Month(), t.Day(), sched.Start.Hour, sched.Start.Minute, 0, 0, after.Location())
+			if sched.Weekday != "" && start.Weekday() != wd {
+				continue
+			}
+			if !start.After(after) {
+				continue
+			}
+			if next.IsZero() || start.Before(next) {
+				next = start
+			}
+			break
+		}
+	}
+	return next
+}
@@ -87,12 +87,35 @@ func (ts *timeutilSuite) TestParseSchedule(c *C) {
 		{"23:00-01:00", nil, `cannot parse "23:00-01:00": time in an interval cannot go backwards`},
 		// FIXME: error message sucks
 		{"9:00-mon@11:00", nil, `cannot parse "9:00-mon", want "mon", "tue", etc`},
+		{"mon-xxx@11:00", nil, `cannot parse "mon-xxx", want "mon", "tue", etc`},
+		{"9:00-11:00~0", nil, `cannot parse "0": not a valid repetition`},
+		{"9:00-11:00~x", nil, `cannot parse "x": not a valid repetition`},
+		{"9:00~2", nil, `cannot parse "9:00~2": interval too short to repeat 2 times`},
 
 		// valid
 		{"9:00-11:00", []*timeutil.Schedule{{Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 11}}}, ""},
 		{"mon@9:00-11:00", []*timeutil.Schedule{{Weekday: "mon", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 11}}}, ""},
 		{"9:00-11:00/20:00-22:00", []*timeutil.Schedule{{Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 11}}, {Start: timeutil.TimeOfDay{Hour: 20}, End: timeutil.TimeOfDay{Hour: 22}}}, ""},
 		{"mon@9:00-11:00/Wed@22:00-23:00", []*timeutil.Schedule{{Weekday: "mon", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 11}}, {Weekday: "wed", Start: timeutil.TimeOfDay{Hour: 22}, End: timeutil.TimeOfDay{Hour: 23}}}, ""},
+		{"9:30-10:00", []*timeutil.Schedule{{Start: timeutil.TimeOfDay{Hour: 9, Minute: 30}, End: timeutil.TimeOfDay{Hour: 10}}}, ""},
+		{"9:00", []*timeutil.Schedule{{Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 9}}}, ""},
+		{"fri-mon@9:00", []*timeutil.Schedule{
+			{Weekday: "fri", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 9}},
+			{Weekday: "sat", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 9}},
+			{Weekday: "sun", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 9}},
+			{Weekday: "mon", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 9}},
+		}, ""},
+		{"9:00-10:30~3", []*timeutil.Schedule{
+			{Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 9, Minute: 30}},
+			{Start: timeutil.TimeOfDay{Hour: 9, Minute: 30}, End: timeutil.TimeOfDay{Hour: 10}},
+			{Start: timeutil.TimeOfDay{Hour: 10}, End: timeutil.TimeOfDay{Hour: 10, Minute: 30}},
+		}, ""},
+		{"mon-tue@9:00-11:00~2", []*timeutil.Schedule{
+			{Weekday: "mon", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 10}},
+			{Weekday: "mon", Start: timeutil.TimeOfDay{Hour: 10}, End: timeutil.TimeOfDay{Hour: 11}},
+			{Weekday: "tue", Start: timeutil.TimeOfDay{Hour: 9}, End: timeutil.TimeOfDay{Hour: 10}},
+			{Weekday: "tue", Start: timeutil.TimeOfDay{Hour: 10}, End: timeutil.TimeOfDay{Hour: 11}},
+		}, ""},
 	} {
 		schedule, err := timeutil.ParseSchedule(t.in)
 		if t.errStr != "" {
@@ -260,3 +283,32 @@ func (ts *timeutilSuite) TestScheduleNext(c *C) {
 	}
 
 }
+
+func (ts *timeutilSuite) TestNextStart(c *C) {
+	const shortForm = "2006-01-02 15:04"
+
+	for _, t := range []struct {
+		schedule string
+		after    string
+		next     string
+	}{
+		// later the same day
+		{"9:00/21:00", "2017-02-06 20:00", "2017-02-06 21:00"},
+		// the next day
+		{"9:00-11:00", "2017-02-06 10:00", "2017-02-07 09:00"},
+		// a window starting right now is not next
+		{"9:00", "2017-02-06 09:00", "2017-02-07 09:00"},
+		// 2017-02-06 is a Monday
+		{"fri@9:00", "2017-02-06 10:00", "2017-02-10 09:00"},
+		{"mon@9:00", "2017-02-06 10:00", "2017-02-13 09:00"},
+		{"mon-fri@9:00-17:00~4", "2017-02-06 10:00", "2017-02-06 11:00"},
+	} {
+		schedule, err := timeutil.ParseSchedule(t.schedule)
+		c.Assert(err, IsNil)
+		after, err := time.ParseInLocation(shortForm, t.after, time.Local)
+		c.Assert(err, IsNil)
+		next, err := time.ParseInLocation(shortForm, t.next, time.Local)
+		c.Assert(err, IsNil)
+		c.Check(timeutil.NextStart(schedule, after), DeepEquals, next, Commentf("%q after %s", t.schedule, t.after))
+	}
+}
@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/systemd"
+)
+
+// quotaDropInFile returns the path of the systemd drop-in placing the
+// service of the app in a quota group slice.
+func quotaDropInFile(app *snap.AppInfo) string {
+	return filepath.Join(app.ServiceFile()+".d", "snap-quota.conf")
+}
+
+func genSliceFile(grp *quota.Group) []byte {
+	sliceTemplate := `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Slice for snap quota group {{.Group.Name}}
+Before={{.SlicesTarget}}
+X-Snappy=yes
+
+[Slice]
+MemoryAccounting=true
+{{if .Group.MemoryLimit}}MemoryMax={{.Group.MemoryLimit}}
+{{end}}CPUAccounting=true
+{{if .Group.CPULimit}}CPUQuota={{.Group.CPULimit}}%
+{{end}}`
+	var templateOut bytes.Buffer
+	t := template.Must(template.New("slice-wrapper").Parse(sliceTemplate))
+	wrapperData := struct {
+		Group        *quota.Group
+		SlicesTarget string
+	}{
+		Group:        grp,
+		SlicesTarget: systemd.SlicesTarget,
+	}
+	if err := t.Execute(&templateOut, wrapperData); err != nil {
+		// this can never happen, except we forget a variable
+		logger.Panicf("Unable to execute template: %v", err)
+	}
+
+	return templateOut.Bytes()
+}
+
+func genQuotaDropInFile(grp *quota.Group) []byte {
+	return []byte(fmt.Sprintf(`# Auto-generated, DO NOT EDIT
+[Service]
+Slice=%s
+`, grp.SliceName()))
+}
+
+// restartActiveServices restarts the given services that are running,
+// so that they are started again in their current slice.
+func restartActiveServices(sysd systemd.Systemd, apps []*snap.AppInfo) error {
+	for _, app := range apps {
+		status, err := sysd.ServiceStatus(app.ServiceName())
+		if err != nil {
+			return err
+		}
+		if status.ActiveState != "active" {
+			continue
+		}
+		if err := sysd.Restart(app.ServiceName(), serviceStopTimeout(app)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureQuotaGroup writes the slice unit of the quota group and places
+// the services of the given snaps in it. The running services that are
+// moved into the slice are restarted.
+func EnsureQuotaGroup(grp *quota.Group, snaps []*snap.Info, inter interacter) error {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+
+	if err := os.MkdirAll(dirs.SnapServicesDir, 0755); err != nil {
+		return err
+	}
+	sliceFile := filepath.Join(dirs.SnapServicesDir, grp.SliceName())
+	reload := false
+	err := osutil.EnsureFileState(sliceFile, &osutil.FileState{Content: genSliceFile(grp), Mode: 0644})
+	switch err {
+	case nil:
+		reload = true
+	case osutil.ErrSameState:
+	default:
+		return err
+	}
+
+	var moved []*snap.AppInfo
+	dropIn := genQuotaDropInFile(grp)
+	for _, s := range snaps {
+		for _, app := range s.Services() {
+			dropInFile := quotaDropInFile(app)
+			if err := os.MkdirAll(filepath.Dir(dropInFile), 0755); err != nil {
+				return err
+			}
+			err := osutil.EnsureFileState(dropInFile, &osutil.FileState{Content: dropIn, Mode: 0644})
+			switch err {
+			case nil:
+				moved = append(moved, app)
+			case osutil.ErrSameState:
+			default:
+				return err
+			}
+		}
+	}
+
+	if !reload && len(moved) == 0 {
+		return nil
+	}
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+
+	return restartActiveServices(sysd, moved)
+}
+
+// removeQuotaDropIns removes the drop-ins placing the services of the
+// snap in a quota group, returning the services that had one.
+func removeQuotaDropIns(s *snap.Info) ([]*snap.AppInfo, error) {
+	var removed []*snap.AppInfo
+	for _, app := range s.Services() {
+		dropInFile := quotaDropInFile(app)
+		if err := os.Remove(dropInFile); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, err
+		}
+		removed = append(removed, app)
+		// the drop-in directory is only removed if it is now empty
+		os.Remove(filepath.Dir(dropInFile))
+	}
+	return removed, nil
+}
+
+// RemoveSnapsFromQuotaGroup takes the services of the given snaps out
+// of the slice of their quota group. The running services that are moved
+// out of it are restarted.
+func RemoveSnapsFromQuotaGroup(snaps []*snap.Info, inter interacter) error {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+
+	var moved []*snap.AppInfo
+	for _, s := range snaps {
+		removed, err := removeQuotaDropIns(s)
+		moved = append(moved, removed...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(moved) == 0 {
+		return nil
+	}
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+
+	return restartActiveServices(sysd, moved)
+}
+
+// RemoveQuotaGroup removes the slice unit of the quota group. The
+// services of its snaps must have been taken out of it beforehand.
+func RemoveQuotaGroup(grp *quota.Group, inter interacter) error {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+
+	sliceFile := filepath.Join(dirs.SnapServicesDir, grp.SliceName())
+	if err := os.Remove(sliceFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return sysd.DaemonReload()
+}
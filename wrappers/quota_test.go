@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type quotaTestSuite struct {
+	tempdir    string
+	prevctlCmd func(...string) ([]byte, error)
+	sysdLog    [][]string
+	active     bool
+}
+
+var _ = Suite(&quotaTestSuite{})
+
+func (s *quotaTestSuite) SetUpTest(c *C) {
+	s.tempdir = c.MkDir()
+	dirs.SetRootDir(s.tempdir)
+
+	s.sysdLog = nil
+	s.active = false
+	s.prevctlCmd = systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		s.sysdLog = append(s.sysdLog, cmd)
+		if s.active && cmd[0] == "show" && cmd[1] != "--property=ActiveState" {
+			return []byte("ActiveState=active\n"), nil
+		}
+		return []byte("ActiveState=inactive\n"), nil
+	}
+}
+
+func (s *quotaTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+	systemd.SystemctlCmd = s.prevctlCmd
+}
+
+func (s *quotaTestSuite) TestEnsureQuotaGroup(c *C) {
+	info := snaptest.MockSnap(c, packageHello, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	grp := &quota.Group{Name: "my-grp", MemoryLimit: 64 * 1024 * 1024, CPULimit: 50, Snaps: []string{"hello-snap"}}
+
+	err := wrappers.EnsureQuotaGroup(grp, []*snap.Info{info}, nil)
+	c.Assert(err, IsNil)
+
+	sliceFile := filepath.Join(dirs.SnapServicesDir, `snap.my\x2dgrp.slice`)
+	content, err := ioutil.ReadFile(sliceFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Slice for snap quota group my-grp
+Before=slices.target
+X-Snappy=yes
+
+[Slice]
+MemoryAccounting=true
+MemoryMax=67108864
+CPUAccounting=true
+CPUQuota=50%
+`)
+	dropInFile := filepath.Join(dirs.SnapServicesDir, "snap.hello-snap.svc1.service.d", "snap-quota.conf")
+	content, err = ioutil.ReadFile(dropInFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, `# Auto-generated, DO NOT EDIT
+[Service]
+Slice=snap.my\x2dgrp.slice
+`)
+	// the service is not running so it is not restarted
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"show", "--property=Id,LoadState,ActiveState,SubState,UnitFileState", "snap.hello-snap.svc1.service"},
+	})
+
+	// nothing changed, nothing to do
+	s.sysdLog = nil
+	err = wrappers.EnsureQuotaGroup(grp, []*snap.Info{info}, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+
+	// changing the limits only rewrites the slice
+	grp.CPULimit = 0
+	err = wrappers.EnsureQuotaGroup(grp, []*snap.Info{info}, nil)
+	c.Assert(err, IsNil)
+	content, err = ioutil.ReadFile(sliceFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Not(Matches), "(?ms).*^CPUQuota=.*")
+	c.Check(s.sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+}
+
+func (s *quotaTestSuite) TestEnsureQuotaGroupRestartsRunningServices(c *C) {
+	info := snaptest.MockSnap(c, packageHello, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	grp := &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"hello-snap"}}
+	s.active = true
+
+	err := wrappers.EnsureQuotaGroup(grp, []*snap.Info{info}, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"show", "--property=Id,LoadState,ActiveState,SubState,UnitFileState", "snap.hello-snap.svc1.service"},
+		{"stop", "snap.hello-snap.svc1.service"},
+		{"show", "--property=ActiveState", "snap.hello-snap.svc1.service"},
+		{"start", "snap.hello-snap.svc1.service"},
+	})
+}
+
+func (s *quotaTestSuite) TestRemoveSnapsFromQuotaGroupAndRemoveQuotaGroup(c *C) {
+	info := snaptest.MockSnap(c, packageHello, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	grp := &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"hello-snap"}}
+	err := wrappers.EnsureQuotaGroup(grp, []*snap.Info{info}, nil)
+	c.Assert(err, IsNil)
+
+	s.sysdLog = nil
+	err = wrappers.RemoveSnapsFromQuotaGroup([]*snap.Info{info}, nil)
+	c.Assert(err, IsNil)
+	dropInDir := filepath.Join(dirs.SnapServicesDir, "snap.hello-snap.svc1.service.d")
+	c.Check(osutil.FileExists(dropInDir), Equals, false)
+	c.Check(s.sysdLog, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"show", "--property=Id,LoadState,ActiveState,SubState,UnitFileState", "snap.hello-snap.svc1.service"},
+	})
+
+	s.sysdLog = nil
+	err = wrappers.RemoveQuotaGroup(grp, nil)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "snap.grp.slice")), Equals, false)
+	c.Check(s.sysdLog, DeepEquals, [][]string{{"daemon-reload"}})
+
+	// removing again is a no-op
+	s.sysdLog = nil
+	c.Assert(wrappers.RemoveSnapsFromQuotaGroup([]*snap.Info{info}, nil), IsNil)
+	c.Assert(wrappers.RemoveQuotaGroup(grp, nil), IsNil)
+	c.Check(s.sysdLog, HasLen, 0)
+}
+
+func (s *quotaTestSuite) TestRemoveSnapServicesRemovesQuotaDropIn(c *C) {
+	info := snaptest.MockSnap(c, packageHello, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+	grp := &quota.Group{Name: "grp", CPULimit: 50, Snaps: []string{"hello-snap"}}
+	c.Assert(wrappers.EnsureQuotaGroup(grp, []*snap.Info{info}, nil), IsNil)
+
+	c.Assert(wrappers.RemoveSnapServices(info, nil), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "snap.hello-snap.svc1.service.d")), Equals, false)
+}
@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 	"time"
 
@@ -33,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/timeout"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 type interacter interface {
@@ -58,9 +61,49 @@ func generateSnapServiceFile(app *snap.AppInfo) ([]byte, error) {
 	return genServiceFile(app), nil
 }
 
+func generateSnapTimerFile(app *snap.AppInfo) ([]byte, error) {
+	schedule, err := timeutil.ParseSchedule(app.Timer.Timer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse timer of application %q: %v", app.Name, err)
+	}
+
+	return genTimerFile(app, schedule), nil
+}
+
+// activatorNames returns the names of the units that activate the
+// service of the app, in the order they are started: its sockets
+// and its timer.
+func activatorNames(app *snap.AppInfo) []string {
+	names := make([]string, 0, len(app.Sockets)+1)
+	for _, socket := range app.Sockets {
+		names = append(names, socket.SocketName())
+	}
+	sort.Strings(names)
+	if app.Timer != nil {
+		names = append(names, app.ServiceTimerName())
+	}
+	return names
+}
+
+// startUnitNames returns the names of the units that are enabled and
+// started for the app: the units that activate its service if it has
+// any, the service itself otherwise.
+func startUnitNames(app *snap.AppInfo) []string {
+	if names := activatorNames(app); len(names) > 0 {
+		return names
+	}
+	return []string{app.ServiceName()}
+}
+
 func stopService(sysd systemd.Systemd, app *snap.AppInfo, inter interacter) error {
 	serviceName := app.ServiceName()
 	tout := serviceStopTimeout(app)
+	// stop the activators first so they do not start the service again
+	for _, name := range activatorNames(app) {
+		if err := sysd.Stop(name, tout); err != nil && !systemd.IsTimeout(err) {
+			return err
+		}
+	}
 	if err := sysd.Stop(serviceName, tout); err != nil {
 		if !systemd.IsTimeout(err) {
 			return err
@@ -75,17 +118,24 @@ func stopService(sysd systemd.Systemd, app *snap.AppInfo, inter interacter) erro
 	return nil
 }
 
-// StartServices starts service units for the applications from the snap which are services.
+// StartServices starts service units for the applications from the snap which are services,
+// in the order given by their before and after declarations.
 func StartServices(apps []*snap.AppInfo, inter interacter) (err error) {
 	sysd := systemd.New(dirs.GlobalRootDir, inter)
 
-	for _, app := range apps {
+	sorted, err := snap.SortServices(apps)
+	if err != nil {
+		return err
+	}
+	for _, app := range sorted {
 		// they're *supposed* to be all services, but checking doesn't hurt
 		if !app.IsService() {
 			continue
 		}
-		if err := sysd.Start(app.ServiceName()); err != nil {
-			return err
+		for _, name := range startUnitNames(app) {
+			if err := sysd.Start(name); err != nil {
+				return err
+			}
 		}
 		defer func(app *snap.AppInfo) {
 			if err == nil {
@@ -120,8 +170,25 @@ func AddSnapServices(s *snap.Info, inter interacter) error {
 		if err := osutil.AtomicWriteFile(svcFilePath, content, 0644, 0); err != nil {
 			return err
 		}
-		if err := sysd.Enable(app.ServiceName()); err != nil {
-			return err
+		if app.Timer != nil {
+			content, err := generateSnapTimerFile(app)
+			if err != nil {
+				return err
+			}
+			if err := osutil.AtomicWriteFile(app.ServiceTimerFile(), content, 0644, 0); err != nil {
+				return err
+			}
+		}
+		for _, socket := range app.Sockets {
+			content := genSocketFile(socket)
+			if err := osutil.AtomicWriteFile(socket.File(), content, 0644, 0); err != nil {
+				return err
+			}
+		}
+		for _, name := range startUnitNames(app) {
+			if err := sysd.Enable(name); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -134,11 +201,17 @@ func AddSnapServices(s *snap.Info, inter interacter) error {
 	return nil
 }
 
-// StopServices stops service units for the applications from the snap which are services.
+// StopServices stops service units for the applications from the snap which are services,
+// in the reverse of the order they are started in.
 func StopServices(apps []*snap.AppInfo, inter interacter) error {
 	sysd := systemd.New(dirs.GlobalRootDir, inter)
 
-	for _, app := range apps {
+	sorted, err := snap.SortServices(apps)
+	if err != nil {
+		return err
+	}
+	for i := len(sorted) - 1; i >= 0; i-- {
+		app := sorted[i]
 		// Handle the case where service file doesn't exist and don't try to stop it as it will fail.
 		// This can happen with snap try when snap.yaml is modified on the fly and a daemon line is added.
 		if !app.IsService() || !osutil.FileExists(app.ServiceFile()) {
@@ -153,6 +226,60 @@ func StopServices(apps []*snap.AppInfo, inter interacter) error {
 
 }
 
+// RestartServices restarts service units for the applications from the snap which are services.
+func RestartServices(apps []*snap.AppInfo, inter interacter) error {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+
+	for _, app := range apps {
+		if !app.IsService() {
+			continue
+		}
+		if err := sysd.Restart(app.ServiceName(), serviceStopTimeout(app)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnableServices enables service units for the applications from the snap which are services,
+// so that they are started at boot.
+func EnableServices(apps []*snap.AppInfo, inter interacter) error {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+
+	for _, app := range apps {
+		if !app.IsService() {
+			continue
+		}
+		for _, name := range startUnitNames(app) {
+			if err := sysd.Enable(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DisableServices disables service units for the applications from the snap which are services,
+// so that they are no longer started at boot.
+func DisableServices(apps []*snap.AppInfo, inter interacter) error {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+
+	for _, app := range apps {
+		if !app.IsService() {
+			continue
+		}
+		for _, name := range startUnitNames(app) {
+			if err := sysd.Disable(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // RemoveSnapServices disables and removes service units for the applications from the snap which are services.
 func RemoveSnapServices(s *snap.Info, inter interacter) error {
 	sysd := systemd.New(dirs.GlobalRootDir, inter)
@@ -165,8 +292,10 @@ func RemoveSnapServices(s *snap.Info, inter interacter) error {
 		nservices++
 
 		serviceName := filepath.Base(app.ServiceFile())
-		if err := sysd.Disable(serviceName); err != nil {
-			return err
+		for _, name := range startUnitNames(app) {
+			if err := sysd.Disable(name); err != nil {
+				return err
+			}
 		}
 
 		if err := os.Remove(app.ServiceFile()); err != nil && !os.IsNotExist(err) {
@@ -176,6 +305,22 @@ func RemoveSnapServices(s *snap.Info, inter interacter) error {
 		if err := os.Remove(app.ServiceSocketFile()); err != nil && !os.IsNotExist(err) {
 			logger.Noticef("Failed to remove socket file for %q: %v", serviceName, err)
 		}
+
+		for _, socket := range app.Sockets {
+			if err := os.Remove(socket.File()); err != nil && !os.IsNotExist(err) {
+				logger.Noticef("Failed to remove socket file %q for %q: %v", socket.Name, serviceName, err)
+			}
+		}
+
+		if err := os.Remove(app.ServiceTimerFile()); err != nil && !os.IsNotExist(err) {
+			logger.Noticef("Failed to remove timer file for %q: %v", serviceName, err)
+		}
+
+		if err := os.Remove(quotaDropInFile(app)); err == nil {
+			os.Remove(filepath.Dir(quotaDropInFile(app)))
+		} else if !os.IsNotExist(err) {
+			logger.Noticef("Failed to remove quota drop-in file for %q: %v", serviceName, err)
+		}
 	}
 
 	// only reload if we actually had services
@@ -194,8 +339,9 @@ func genServiceFile(appInfo *snap.AppInfo) []byte {
 Description=Service for snap application {{.App.Snap.Name}}.{{.App.Name}}
 Requires={{.MountUnit}}
 Wants={{.PrerequisiteTarget}}
-After={{.MountUnit}} {{.PrerequisiteTarget}}
-X-Snappy=yes
+After={{.MountUnit}} {{.PrerequisiteTarget}}{{if .After}} {{.After}}{{end}}
+{{if .Before}}Before={{.Before}}
+{{end}}X-Snappy=yes
 
 [Service]
 ExecStart={{.App.LauncherCommand}}
@@ -205,13 +351,16 @@ WorkingDirectory={{.App.Snap.DataDir}}
 {{if .App.ReloadCommand}}ExecReload={{.App.LauncherReloadCommand}}{{end}}
 {{if .App.PostStopCommand}}ExecStopPost={{.App.LauncherPostStopCommand}}{{end}}
 {{if .StopTimeout}}TimeoutStopSec={{.StopTimeout.Seconds}}{{end}}
-Type={{.App.Daemon}}
+{{if .App.WatchdogTimeout}}WatchdogSec={{.App.WatchdogTimeout.Seconds}}
+{{end}}{{if .KillMode}}KillMode={{.KillMode}}
+{{end}}{{if .App.StopMode.KillSignal}}KillSignal={{.App.StopMode.KillSignal}}
+{{end}}Type={{.App.Daemon}}
 {{if .Remain}}RemainAfterExit={{.Remain}}{{end}}
 {{if .App.BusName}}BusName={{.App.BusName}}{{end}}
-
+{{if not (or .App.Timer .App.Sockets)}}
 [Install]
 WantedBy={{.ServicesTarget}}
-`
+{{end}}`
 	var templateOut bytes.Buffer
 	t := template.Must(template.New("service-wrapper").Parse(serviceTemplate))
 
@@ -236,6 +385,12 @@ WantedBy={{.ServicesTarget}}
 		}
 	}
 
+	var killMode string
+	if !appInfo.StopMode.KillAll() {
+		// only the main process is sent the stop signal
+		killMode = "process"
+	}
+
 	wrapperData := struct {
 		App *snap.AppInfo
 
@@ -245,6 +400,9 @@ WantedBy={{.ServicesTarget}}
 		PrerequisiteTarget string
 		MountUnit          string
 		Remain             string
+		Before             string
+		After              string
+		KillMode           string
 
 		Home    string
 		EnvVars string
@@ -257,6 +415,9 @@ WantedBy={{.ServicesTarget}}
 		PrerequisiteTarget: systemd.PrerequisiteTarget,
 		MountUnit:          filepath.Base(systemd.MountUnitPath(appInfo.Snap.MountDir())),
 		Remain:             remain,
+		Before:             strings.Join(serviceNames(appInfo.Snap, appInfo.Before), " "),
+		After:              strings.Join(serviceNames(appInfo.Snap, appInfo.After), " "),
+		KillMode:           killMode,
 
 		// systemd runs as PID 1 so %h will not work.
 		Home: "/root",
@@ -269,3 +430,138 @@ WantedBy={{.ServicesTarget}}
 
 	return templateOut.Bytes()
 }
+
+// serviceNames returns the names of the service units of the given
+// apps of the snap.
+func serviceNames(s *snap.Info, appNames []string) []string {
+	names := make([]string, 0, len(appNames))
+	for _, name := range appNames {
+		if app, ok := s.Apps[name]; ok {
+			names = append(names, app.ServiceName())
+		}
+	}
+	return names
+}
+
+// timerCalendars returns the systemd calendar events, as used in
+// OnCalendar=, at which the windows of the given schedule start.
+func timerCalendars(schedule []*timeutil.Schedule) []string {
+	calendars := make([]string, len(schedule))
+	for i, sched := range schedule {
+		calendar := fmt.Sprintf("*-*-* %s", sched.Start)
+		if sched.Weekday != "" {
+			calendar = strings.Title(sched.Weekday) + " " + calendar
+		}
+		calendars[i] = calendar
+	}
+	return calendars
+}
+
+// timerRandomizedDelay returns how long after the start of its
+// windows the timer can fire, as used in RandomizedDelaySec=. A
+// single value applies to all the windows, so it is the length of the
+// shortest one, and zero if any of them is a single time.
+func timerRandomizedDelay(schedule []*timeutil.Schedule) time.Duration {
+	var delay time.Duration
+	for i, sched := range schedule {
+		start := time.Duration(sched.Start.Hour)*time.Hour + time.Duration(sched.Start.Minute)*time.Minute
+		end := time.Duration(sched.End.Hour)*time.Hour + time.Duration(sched.End.Minute)*time.Minute
+		length := end - start
+		if i == 0 || length < delay {
+			delay = length
+		}
+	}
+	return delay
+}
+
+func genTimerFile(appInfo *snap.AppInfo, schedule []*timeutil.Schedule) []byte {
+	timerTemplate := `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Timer {{.App.Name}} for snap application {{.App.Snap.Name}}.{{.App.Name}}
+Requires={{.MountUnit}}
+After={{.MountUnit}}
+X-Snappy=yes
+
+[Timer]
+Unit={{.ServiceName}}
+{{range .Calendars}}OnCalendar={{.}}
+{{end}}{{if .RandomizedDelaySec}}RandomizedDelaySec={{.RandomizedDelaySec}}
+{{end}}
+[Install]
+WantedBy={{.TimersTarget}}
+`
+	var templateOut bytes.Buffer
+	t := template.Must(template.New("timer-wrapper").Parse(timerTemplate))
+
+	timerData := struct {
+		App *snap.AppInfo
+
+		ServiceName        string
+		Calendars          []string
+		RandomizedDelaySec int64
+		MountUnit          string
+		TimersTarget       string
+	}{
+		App: appInfo,
+
+		ServiceName:        appInfo.ServiceName(),
+		Calendars:          timerCalendars(schedule),
+		RandomizedDelaySec: int64(timerRandomizedDelay(schedule) / time.Second),
+		MountUnit:          filepath.Base(systemd.MountUnitPath(appInfo.Snap.MountDir())),
+		TimersTarget:       systemd.TimersTarget,
+	}
+
+	if err := t.Execute(&templateOut, timerData); err != nil {
+		// this can never happen, except we forget a variable
+		logger.Panicf("Unable to execute template: %v", err)
+	}
+
+	return templateOut.Bytes()
+}
+
+func genSocketFile(socket *snap.SocketInfo) []byte {
+	socketTemplate := `[Unit]
+# Auto-generated, DO NOT EDIT
+Description=Socket {{.Socket.Name}} for snap application {{.App.Snap.Name}}.{{.App.Name}}
+Requires={{.MountUnit}}
+After={{.MountUnit}}
+X-Snappy=yes
+
+[Socket]
+Service={{.ServiceName}}
+FileDescriptorName={{.Socket.Name}}
+ListenStream={{.ListenStream}}
+{{if .Socket.SocketMode}}SocketMode={{.Socket.SocketMode | printf "%04o"}}
+{{end}}
+[Install]
+WantedBy={{.SocketsTarget}}
+`
+	var templateOut bytes.Buffer
+	t := template.Must(template.New("socket-wrapper").Parse(socketTemplate))
+
+	appInfo := socket.App
+	socketData := struct {
+		App    *snap.AppInfo
+		Socket *snap.SocketInfo
+
+		ServiceName   string
+		ListenStream  string
+		MountUnit     string
+		SocketsTarget string
+	}{
+		App:    appInfo,
+		Socket: socket,
+
+		ServiceName:   appInfo.ServiceName(),
+		ListenStream:  appInfo.Snap.ExpandSnapVariables(socket.ListenStream),
+		MountUnit:     filepath.Base(systemd.MountUnitPath(appInfo.Snap.MountDir())),
+		SocketsTarget: systemd.SocketsTarget,
+	}
+
+	if err := t.Execute(&templateOut, socketData); err != nil {
+		// this can never happen, except we forget a variable
+		logger.Panicf("Unable to execute template: %v", err)
+	}
+
+	return templateOut.Bytes()
+}
@@ -21,6 +21,7 @@ package wrappers_test
 
 import (
 	"fmt"
+	"regexp"
 
 	. "gopkg.in/check.v1"
 
@@ -234,3 +235,78 @@ apps:
 
 	c.Assert(string(generatedWrapper), Equals, expectedOneshotService)
 }
+
+func (s *servicesWrapperGenSuite) TestGenServiceFileWithBeforeAfter(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        daemon: simple
+        before: [web]
+        after: [db, cache]
+    db:
+        command: bin/db
+        daemon: simple
+    cache:
+        command: bin/cache
+        daemon: simple
+    web:
+        command: bin/web
+        daemon: simple
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(info.Apps["app"])
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Matches, fmt.Sprintf(`(?ms).*
+After=%s-snap-44.mount network-online.target snap.snap.db.service snap.snap.cache.service
+Before=snap.snap.web.service
+X-Snappy=yes
+.*`, regexp.QuoteMeta(mountUnitPrefix)))
+
+	generatedWrapper, err = wrappers.GenerateSnapServiceFile(info.Apps["db"])
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Not(Matches), "(?ms).*^Before=.*")
+}
+
+func (s *servicesWrapperGenSuite) TestGenServiceFileWithWatchdogAndStopMode(c *C) {
+	yamlText := `
+name: snap
+version: 1.0
+apps:
+    app:
+        command: bin/start
+        daemon: notify
+        watchdog-timeout: 12s
+        stop-mode: sighup
+    other:
+        command: bin/other
+        daemon: simple
+        stop-mode: sigusr1-all
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yamlText))
+	c.Assert(err, IsNil)
+	info.Revision = snap.R(44)
+
+	generatedWrapper, err := wrappers.GenerateSnapServiceFile(info.Apps["app"])
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Matches, `(?ms).*
+TimeoutStopSec=30
+WatchdogSec=12
+KillMode=process
+KillSignal=SIGHUP
+Type=notify
+.*`)
+
+	generatedWrapper, err = wrappers.GenerateSnapServiceFile(info.Apps["other"])
+	c.Assert(err, IsNil)
+	c.Check(string(generatedWrapper), Matches, `(?ms).*
+TimeoutStopSec=30
+KillSignal=SIGUSR1
+Type=simple
+.*`)
+}
@@ -22,6 +22,7 @@ package wrappers_test
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
 	"time"
@@ -104,6 +105,264 @@ func (s *servicesTestSuite) TestAddSnapServicesAndRemove(c *C) {
 	c.Check(sysdLog[1], DeepEquals, []string{"daemon-reload"})
 }
 
+func (s *servicesTestSuite) TestAddSnapServicesWithTimerAndRemove(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, `name: hello-snap
+version: 1.10
+apps:
+ svc2:
+  command: bin/hello
+  daemon: oneshot
+  timer: mon-fri@9:00
+`, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.service")
+	timerFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.timer")
+
+	err := wrappers.AddSnapServices(info, nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", filepath.Base(timerFile)},
+		{"daemon-reload"},
+	})
+
+	// the service is activated by the timer only
+	content, err := ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Not(Matches), "(?ms).*^\\[Install\\]")
+
+	content, err = ioutil.ReadFile(timerFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, `(?ms).*
+\[Timer\]
+Unit=snap.hello-snap.svc2.service
+OnCalendar=Mon \*-\*-\* 09:00
+OnCalendar=Tue \*-\*-\* 09:00
+OnCalendar=Wed \*-\*-\* 09:00
+OnCalendar=Thu \*-\*-\* 09:00
+OnCalendar=Fri \*-\*-\* 09:00
+
+\[Install\]
+WantedBy=timers.target
+`)
+
+	sysdLog = nil
+	err = wrappers.StartServices(info.Services(), nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"start", filepath.Base(timerFile)},
+	})
+
+	sysdLog = nil
+	err = wrappers.StopServices(info.Services(), &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"stop", filepath.Base(timerFile)},
+		{"show", "--property=ActiveState", filepath.Base(timerFile)},
+		{"stop", filepath.Base(svcFile)},
+		{"show", "--property=ActiveState", filepath.Base(svcFile)},
+	})
+
+	sysdLog = nil
+	err = wrappers.RemoveSnapServices(info, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(svcFile), Equals, false)
+	c.Check(osutil.FileExists(timerFile), Equals, false)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "disable", filepath.Base(timerFile)},
+		{"daemon-reload"},
+	})
+}
+
+func (s *servicesTestSuite) TestAddSnapServicesWithTimerWindows(c *C) {
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	for _, t := range []struct {
+		timer    string
+		expected string
+	}{
+		// the timer fires anywhere in the window
+		{"9:00-11:00", `OnCalendar=\*-\*-\* 09:00
+RandomizedDelaySec=7200
+`},
+		// within the shortest window when there are several
+		{"mon@9:00-11:00/13:00-13:30", `OnCalendar=Mon \*-\*-\* 09:00
+OnCalendar=\*-\*-\* 13:00
+RandomizedDelaySec=1800
+`},
+		{"9:00-11:00~2", `OnCalendar=\*-\*-\* 09:00
+OnCalendar=\*-\*-\* 10:00
+RandomizedDelaySec=3600
+`},
+		// and exactly at the given time otherwise
+		{"9:00-11:00/12:00", `OnCalendar=\*-\*-\* 09:00
+OnCalendar=\*-\*-\* 12:00
+`},
+	} {
+		info := snaptest.MockSnap(c, fmt.Sprintf(`name: hello-snap
+version: 1.10
+apps:
+ svc2:
+  command: bin/hello
+  daemon: oneshot
+  timer: %s
+`, t.timer), contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+		timerFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.timer")
+
+		c.Assert(wrappers.AddSnapServices(info, nil), IsNil)
+		content, err := ioutil.ReadFile(timerFile)
+		c.Assert(err, IsNil)
+		c.Check(string(content), Matches, `(?ms).*
+Unit=snap.hello-snap.svc2.service
+`+t.expected+`
+\[Install\].*`, Commentf(t.timer))
+		c.Assert(wrappers.RemoveSnapServices(info, &progress.NullProgress{}), IsNil)
+	}
+}
+
+func (s *servicesTestSuite) TestAddSnapServicesWithSocketsAndRemove(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, `name: hello-snap
+version: 1.10
+apps:
+ svc2:
+  command: bin/hello
+  daemon: simple
+  sockets:
+   sock1:
+    listen-stream: $SNAP_DATA/sock1.socket
+    socket-mode: 0666
+   sock2:
+    listen-stream: 127.0.0.1:8080
+`, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFile := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.service")
+	sock1File := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.sock1.socket")
+	sock2File := filepath.Join(s.tempdir, "/etc/systemd/system/snap.hello-snap.svc2.sock2.socket")
+
+	err := wrappers.AddSnapServices(info, nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", filepath.Base(sock1File)},
+		{"--root", dirs.GlobalRootDir, "enable", filepath.Base(sock2File)},
+		{"daemon-reload"},
+	})
+
+	// the service is activated by its sockets only
+	content, err := ioutil.ReadFile(svcFile)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Not(Matches), "(?ms).*^\\[Install\\]")
+
+	content, err = ioutil.ReadFile(sock1File)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, fmt.Sprintf(`(?ms).*
+\[Socket\]
+Service=snap.hello-snap.svc2.service
+FileDescriptorName=sock1
+ListenStream=%s/var/snap/hello-snap/12/sock1.socket
+SocketMode=0666
+
+\[Install\]
+WantedBy=sockets.target
+`, regexp.QuoteMeta(s.tempdir)))
+
+	content, err = ioutil.ReadFile(sock2File)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Matches, `(?ms).*
+\[Socket\]
+Service=snap.hello-snap.svc2.service
+FileDescriptorName=sock2
+ListenStream=127.0.0.1:8080
+
+\[Install\]
+WantedBy=sockets.target
+`)
+
+	sysdLog = nil
+	err = wrappers.StartServices(info.Services(), nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"start", filepath.Base(sock1File)},
+		{"start", filepath.Base(sock2File)},
+	})
+
+	sysdLog = nil
+	err = wrappers.RemoveSnapServices(info, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(svcFile), Equals, false)
+	c.Check(osutil.FileExists(sock1File), Equals, false)
+	c.Check(osutil.FileExists(sock2File), Equals, false)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "disable", filepath.Base(sock1File)},
+		{"--root", dirs.GlobalRootDir, "disable", filepath.Base(sock2File)},
+		{"daemon-reload"},
+	})
+}
+
+func (s *servicesTestSuite) TestStartAndStopServicesInOrder(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		if cmd[0] != "show" {
+			sysdLog = append(sysdLog, cmd)
+		}
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, `name: hello-snap
+version: 1.10
+apps:
+ api:
+  command: bin/hello
+  daemon: simple
+  after: [db]
+ db:
+  command: bin/hello
+  daemon: simple
+ web:
+  command: bin/hello
+  daemon: simple
+  after: [api]
+ cache:
+  command: bin/hello
+  daemon: simple
+  before: [db]
+`, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	// StopServices only stops services that have a service file
+	c.Assert(os.MkdirAll(dirs.SnapServicesDir, 0755), IsNil)
+	for _, app := range info.Services() {
+		c.Assert(osutil.AtomicWriteFile(app.ServiceFile(), nil, 0644, 0), IsNil)
+	}
+
+	err := wrappers.StartServices(info.Services(), nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"start", "snap.hello-snap.cache.service"},
+		{"start", "snap.hello-snap.db.service"},
+		{"start", "snap.hello-snap.api.service"},
+		{"start", "snap.hello-snap.web.service"},
+	})
+
+	sysdLog = nil
+	err = wrappers.StopServices(info.Services(), &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"stop", "snap.hello-snap.web.service"},
+		{"stop", "snap.hello-snap.api.service"},
+		{"stop", "snap.hello-snap.db.service"},
+		{"stop", "snap.hello-snap.cache.service"},
+	})
+}
+
 func (s *servicesTestSuite) TestRemoveSnapPackageFallbackToKill(c *C) {
 	restore := wrappers.MockKillWait(200 * time.Millisecond)
 	defer restore()
@@ -161,6 +420,47 @@ func (s *servicesTestSuite) TestStartServices(c *C) {
 	c.Assert(sysdLog, DeepEquals, [][]string{{"start", filepath.Base(svcFile)}})
 }
 
+func (s *servicesTestSuite) TestRestartServices(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	info := snaptest.MockSnap(c, packageHello, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFName := "snap.hello-snap.svc1.service"
+
+	err := wrappers.RestartServices(info.Services(), nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(sysdLog, DeepEquals, [][]string{
+		{"stop", svcFName},
+		{"show", "--property=ActiveState", svcFName},
+		{"start", svcFName},
+	})
+}
+
+func (s *servicesTestSuite) TestEnableAndDisableServices(c *C) {
+	var sysdLog [][]string
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return nil, nil
+	}
+
+	info := snaptest.MockSnap(c, packageHello, contentsHello, &snap.SideInfo{Revision: snap.R(12)})
+	svcFName := "snap.hello-snap.svc1.service"
+
+	err := wrappers.EnableServices(info.Services(), nil)
+	c.Assert(err, IsNil)
+	err = wrappers.DisableServices(info.Services(), nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(sysdLog, DeepEquals, [][]string{
+		{"--root", s.tempdir, "enable", svcFName},
+		{"--root", s.tempdir, "disable", svcFName},
+	})
+}
+
 func (s *servicesTestSuite) TestStartSnapMultiServicesFailStartCleanup(c *C) {
 	var sysdLog [][]string
 	svc1Name := "snap.hello-snap.svc1.service"